@@ -1,9 +1,17 @@
 package action
 
+import (
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+)
+
 type Action interface {
 	IsAsynchronous() bool
 	IsPersistent() bool
 
+	// Resources lists the parts of the VM asynchronous action modifies.
+	// Tasks of actions that share any resource are run one at a time
+	Resources() []boshtask.Resource
+
 	// Action should implement Run
 	// Arguments should be the list of arguments the payload will include
 	// and necessary for running the action
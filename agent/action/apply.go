@@ -5,6 +5,7 @@ import (
 
 	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
@@ -13,6 +14,8 @@ type ApplyAction struct {
 	applier         boshappl.Applier
 	specService     boshas.V1Service
 	settingsService boshsettings.Service
+
+	canceller *boshtask.Canceller
 }
 
 func NewApply(
@@ -23,6 +26,7 @@ func NewApply(
 	action.applier = applier
 	action.specService = specService
 	action.settingsService = settingsService
+	action.canceller = boshtask.NewCanceller()
 	return
 }
 
@@ -30,11 +34,25 @@ func (a ApplyAction) IsAsynchronous() bool {
 	return true
 }
 
+// IsPersistent is true so that apply interrupted by agent restart
+// is finished instead of leaving jobs half applied
 func (a ApplyAction) IsPersistent() bool {
-	return false
+	return true
 }
 
-func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
+func (a ApplyAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceJobs}
+}
+
+// Steps of apply recorded with checkpoints
+const (
+	applyStepApplied = "applied"
+)
+
+func (a ApplyAction) Run(reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints, desiredSpec boshas.V1ApplySpec) (string, error) {
+	cancelCh, stopCancelling := a.canceller.Start()
+	defer stopCancelling()
+
 	settings := a.settingsService.GetSettings()
 
 	resolvedDesiredSpec, err := a.specService.PopulateDHCPNetworks(desiredSpec, settings)
@@ -42,16 +60,23 @@ func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
 		return "", bosherr.WrapError(err, "Resolving dynamic networks")
 	}
 
-	if desiredSpec.ConfigurationHash != "" {
+	// Current spec is only replaced after jobs are applied
+	// so resumed apply still sees the spec it is applying over
+	if desiredSpec.ConfigurationHash != "" && !checkpoints.IsCompleted(applyStepApplied) {
 		currentSpec, err := a.specService.Get()
 		if err != nil {
 			return "", bosherr.WrapError(err, "Getting current spec")
 		}
 
-		err = a.applier.Apply(currentSpec, resolvedDesiredSpec)
+		err = a.applier.Apply(currentSpec, resolvedDesiredSpec, reporter, checkpoints, cancelCh)
 		if err != nil {
 			return "", bosherr.WrapError(err, "Applying")
 		}
+
+		err = checkpoints.Complete(applyStepApplied)
+		if err != nil {
+			return "", bosherr.WrapError(err, "Recording applied jobs")
+		}
 	}
 
 	err = a.specService.Set(resolvedDesiredSpec)
@@ -62,10 +87,13 @@ func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
 	return "applied", nil
 }
 
+// Resume is not used since runner resumes apply by running it again with its checkpoints
 func (a ApplyAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
 
+// Cancel stops downloading and installing jobs and packages
 func (a ApplyAction) Cancel() error {
-	return errors.New("not supported")
+	a.canceller.Cancel()
+	return nil
 }
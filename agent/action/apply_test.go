@@ -10,6 +10,8 @@ import (
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	fakeappl "github.com/cloudfoundry/bosh-agent/agent/applier/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 )
@@ -20,6 +22,8 @@ func init() {
 			applier         *fakeappl.FakeApplier
 			specService     *fakeas.FakeV1Service
 			settingsService *fakesettings.FakeSettingsService
+			reporter        *faketask.FakeProgressReporter
+			checkpoints     *faketask.FakeCheckpoints
 			action          ApplyAction
 		)
 
@@ -27,6 +31,8 @@ func init() {
 			applier = fakeappl.NewFakeApplier()
 			specService = fakeas.NewFakeV1Service()
 			settingsService = &fakesettings.FakeSettingsService{}
+			reporter = faketask.NewFakeProgressReporter()
+			checkpoints = faketask.NewFakeCheckpoints()
 			action = NewApply(applier, specService, settingsService)
 		})
 
@@ -34,8 +40,12 @@ func init() {
 			Expect(action.IsAsynchronous()).To(BeTrue())
 		})
 
-		It("is not persistent", func() {
-			Expect(action.IsPersistent()).To(BeFalse())
+		It("is persistent so that it is finished after agent restart", func() {
+			Expect(action.IsPersistent()).To(BeTrue())
+		})
+
+		It("modifies jobs", func() {
+			Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceJobs}))
 		})
 
 		Describe("Run", func() {
@@ -58,7 +68,7 @@ func init() {
 					})
 
 					It("populates dynamic networks in desired spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 						Expect(err).ToNot(HaveOccurred())
 						Expect(specService.PopulateDHCPNetworksSpec).To(Equal(desiredApplySpec))
 						Expect(specService.PopulateDHCPNetworksSettings).To(Equal(settings))
@@ -70,17 +80,88 @@ func init() {
 						})
 
 						It("runs applier with populated desired spec", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 							Expect(err).ToNot(HaveOccurred())
 							Expect(applier.Applied).To(BeTrue())
 							Expect(applier.ApplyCurrentApplySpec).To(Equal(currentApplySpec))
 							Expect(applier.ApplyDesiredApplySpec).To(Equal(populatedDesiredApplySpec))
 						})
 
+						It("runs applier with progress reporter", func() {
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(applier.ApplyReporter).To(Equal(reporter))
+						})
+
+						Context("when apply is resumed after jobs were applied", func() {
+							BeforeEach(func() {
+								checkpoints.CompletedSteps = []string{"applied"}
+							})
+
+							It("does not apply jobs again and only saves populated desired spec as current spec", func() {
+								value, err := action.Run(reporter, checkpoints, desiredApplySpec)
+								Expect(err).ToNot(HaveOccurred())
+								Expect(value).To(Equal("applied"))
+
+								Expect(applier.Applied).To(BeFalse())
+								Expect(specService.Spec).To(Equal(populatedDesiredApplySpec))
+							})
+						})
+
+						It("cancels applier when action is cancelled", func() {
+							applier.ApplyCallBack = func() {
+								err := action.Cancel()
+								Expect(err).ToNot(HaveOccurred())
+							}
+
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(applier.ApplyCancelCh).To(BeClosed())
+						})
+
+						It("does not cancel applier when action was cancelled after previous run finished", func() {
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
+							Expect(err).ToNot(HaveOccurred())
+
+							err = action.Cancel()
+							Expect(err).ToNot(HaveOccurred())
+
+							_, err = action.Run(reporter, checkpoints, desiredApplySpec)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(applier.ApplyCancelCh).ToNot(BeClosed())
+						})
+
+						It("passes checkpoints to applier so that resumed apply skips completed steps", func() {
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(applier.ApplyCheckpoints).To(Equal(checkpoints))
+						})
+
+						It("does not cancel applier when action is not cancelled", func() {
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(applier.ApplyCancelCh).ToNot(BeClosed())
+						})
+
 						Context("when applier succeeds applying desired spec", func() {
+							It("records that jobs were applied", func() {
+								_, err := action.Run(reporter, checkpoints, desiredApplySpec)
+								Expect(err).ToNot(HaveOccurred())
+								Expect(checkpoints.CompletedSteps).To(Equal([]string{"applied"}))
+							})
+
+							It("returns error without saving spec when recording applied jobs fails", func() {
+								checkpoints.CompleteErr = errors.New("fake-complete-err")
+
+								_, err := action.Run(reporter, checkpoints, desiredApplySpec)
+								Expect(err).To(HaveOccurred())
+								Expect(err.Error()).To(ContainSubstring("fake-complete-err"))
+								Expect(specService.Spec).To(Equal(currentApplySpec))
+							})
+
 							Context("when saving desires spec as current spec succeeds", func() {
 								It("returns 'applied' after setting populated desired spec as current spec", func() {
-									value, err := action.Run(desiredApplySpec)
+									value, err := action.Run(reporter, checkpoints, desiredApplySpec)
 									Expect(err).ToNot(HaveOccurred())
 									Expect(value).To(Equal("applied"))
 
@@ -92,7 +173,7 @@ func init() {
 								It("returns error because agent was not able to remember that is converged to desired spec", func() {
 									specService.SetErr = errors.New("fake-set-error")
 
-									_, err := action.Run(desiredApplySpec)
+									_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 									Expect(err).To(HaveOccurred())
 									Expect(err.Error()).To(ContainSubstring("fake-set-error"))
 								})
@@ -105,13 +186,13 @@ func init() {
 							})
 
 							It("returns error", func() {
-								_, err := action.Run(desiredApplySpec)
+								_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 								Expect(err).To(HaveOccurred())
 								Expect(err.Error()).To(ContainSubstring("fake-apply-error"))
 							})
 
 							It("does not save desired spec as current spec", func() {
-								_, err := action.Run(desiredApplySpec)
+								_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 								Expect(err).To(HaveOccurred())
 								Expect(specService.Spec).To(Equal(currentApplySpec))
 							})
@@ -124,19 +205,19 @@ func init() {
 						})
 
 						It("returns error", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(err.Error()).To(ContainSubstring("fake-populate-dynamic-networks-err"))
 						})
 
 						It("does not apply desired spec as current spec", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(applier.Applied).To(BeFalse())
 						})
 
 						It("does not save desired spec as current spec", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(specService.Spec).To(Equal(currentApplySpec))
 						})
@@ -150,19 +231,19 @@ func init() {
 					})
 
 					It("returns error and does not apply desired spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(err.Error()).To(ContainSubstring("fake-get-error"))
 					})
 
 					It("does not run applier with desired spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(applier.Applied).To(BeFalse())
 					})
 
 					It("does not save desired spec as current spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(specService.Spec).To(Equal(currentApplySpec))
 					})
@@ -183,7 +264,7 @@ func init() {
 				}
 
 				It("populates dynamic networks in desired spec", func() {
-					_, err := action.Run(desiredApplySpec)
+					_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(specService.PopulateDHCPNetworksSpec).To(Equal(desiredApplySpec))
 					Expect(specService.PopulateDHCPNetworksSettings).To(Equal(settings))
@@ -196,7 +277,7 @@ func init() {
 
 					Context("when saving desires spec as current spec succeeds", func() {
 						It("returns 'applied' after setting desired spec as current spec", func() {
-							value, err := action.Run(desiredApplySpec)
+							value, err := action.Run(reporter, checkpoints, desiredApplySpec)
 							Expect(err).ToNot(HaveOccurred())
 							Expect(value).To(Equal("applied"))
 
@@ -204,7 +285,7 @@ func init() {
 						})
 
 						It("does not try to apply desired spec since it does not have jobs and packages", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 							Expect(err).ToNot(HaveOccurred())
 							Expect(applier.Applied).To(BeFalse())
 						})
@@ -216,13 +297,13 @@ func init() {
 						})
 
 						It("returns error because agent was not able to remember that is converged to desired spec", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(err.Error()).To(ContainSubstring("fake-set-error"))
 						})
 
 						It("does not try to apply desired spec since it does not have jobs and packages", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(applier.Applied).To(BeFalse())
 						})
@@ -235,19 +316,19 @@ func init() {
 					})
 
 					It("returns error", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(err.Error()).To(ContainSubstring("fake-populate-dynamic-networks-err"))
 					})
 
 					It("does not apply desired spec as current spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(applier.Applied).To(BeFalse())
 					})
 
 					It("does not save desired spec as current spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(reporter, checkpoints, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(specService.Spec).ToNot(Equal(desiredApplySpec))
 					})
@@ -24,13 +24,17 @@ func (a CancelTaskAction) IsPersistent() bool {
 	return false
 }
 
+func (a CancelTaskAction) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a CancelTaskAction) Run(taskID string) (string, error) {
-	task, found := a.taskService.FindTaskWithID(taskID)
+	_, found := a.taskService.FindTaskWithID(taskID)
 	if !found {
 		return "", bosherr.Errorf("Task with id %s could not be found", taskID)
 	}
 
-	return "canceled", task.Cancel()
+	return "canceled", a.taskService.CancelTask(taskID)
 }
 
 func (a CancelTaskAction) Resume() (interface{}, error) {
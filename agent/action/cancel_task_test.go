@@ -45,6 +45,7 @@ var _ = Describe("CancelTaskAction", func() {
 		Expect(value).To(Equal("canceled")) // 1 l
 
 		Expect(cancelCalled).To(BeTrue())
+		Expect(taskService.CancelledTaskIDs).To(Equal([]string{"fake-task-id"}))
 	})
 
 	It("returns error when canceling task fails", func() {
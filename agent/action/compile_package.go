@@ -5,15 +5,19 @@ import (
 
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type CompilePackageAction struct {
 	compiler boshcomp.Compiler
+
+	canceller *boshtask.Canceller
 }
 
 func NewCompilePackage(compiler boshcomp.Compiler) (compilePackage CompilePackageAction) {
 	compilePackage.compiler = compiler
+	compilePackage.canceller = boshtask.NewCanceller()
 	return
 }
 
@@ -25,7 +29,11 @@ func (a CompilePackageAction) IsPersistent() bool {
 	return false
 }
 
-func (a CompilePackageAction) Run(blobID, sha1, name, version string, deps boshcomp.Dependencies) (val map[string]interface{}, err error) {
+func (a CompilePackageAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceCompilation}
+}
+
+func (a CompilePackageAction) Run(reporter boshtask.ProgressReporter, blobID, sha1, name, version string, deps boshcomp.Dependencies) (val map[string]interface{}, err error) {
 	pkg := boshcomp.Package{
 		BlobstoreID: blobID,
 		Name:        name,
@@ -46,7 +54,10 @@ func (a CompilePackageAction) Run(blobID, sha1, name, version string, deps boshc
 		})
 	}
 
-	uploadedBlobID, uploadedSha1, err := a.compiler.Compile(pkg, modelsDeps)
+	cancelCh, stopCancelling := a.canceller.Start()
+	defer stopCancelling()
+
+	uploadedBlobID, uploadedSha1, err := a.compiler.Compile(pkg, modelsDeps, reporter, cancelCh)
 	if err != nil {
 		err = bosherr.WrapErrorf(err, "Compiling package %s", pkg.Name)
 		return
@@ -67,6 +78,8 @@ func (a CompilePackageAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
 
+// Cancel stops downloading packages and kills packaging script
 func (a CompilePackageAction) Cancel() error {
-	return errors.New("not supported")
+	a.canceller.Cancel()
+	return nil
 }
@@ -10,9 +10,12 @@ import (
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
 	fakecomp "github.com/cloudfoundry/bosh-agent/agent/compiler/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 )
 
-func getCompileActionArguments() (blobID, sha1, name, version string, deps boshcomp.Dependencies) {
+func getCompileActionArguments() (reporter boshtask.ProgressReporter, blobID, sha1, name, version string, deps boshcomp.Dependencies) {
+	reporter = faketask.NewFakeProgressReporter()
 	blobID = "fake-blobstore-id"
 	sha1 = "fake-sha1"
 	name = "fake-package-name"
@@ -53,6 +56,10 @@ var _ = Describe("CompilePackageAction", func() {
 		Expect(action.IsPersistent()).To(BeFalse())
 	})
 
+	It("modifies compilation directory", func() {
+		Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceCompilation}))
+	})
+
 	Describe("Run", func() {
 		It("compile package compiles the package abd returns blob id", func() {
 			compiler.CompileBlobID = "my-blob-id"
@@ -101,6 +108,37 @@ var _ = Describe("CompilePackageAction", func() {
 			Expect(compiler.CompileDeps).To(ConsistOf(expectedDeps))
 		})
 
+		It("passes progress reporter to compiler", func() {
+			reporter := faketask.NewFakeProgressReporter()
+
+			_, err := action.Run(reporter, "fake-blobstore-id", "fake-sha1", "fake-package-name", "fake-package-version", boshcomp.Dependencies{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(compiler.CompileReporter).To(Equal(reporter))
+		})
+
+		It("cancels compiler when action is cancelled", func() {
+			compiler.CompileCallBack = func() {
+				err := action.Cancel()
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			_, err := action.Run(getCompileActionArguments())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(compiler.CompileCancelCh).To(BeClosed())
+		})
+
+		It("does not cancel compiler when action was cancelled after previous run finished", func() {
+			_, err := action.Run(getCompileActionArguments())
+			Expect(err).ToNot(HaveOccurred())
+
+			err = action.Cancel()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = action.Run(getCompileActionArguments())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(compiler.CompileCancelCh).ToNot(BeClosed())
+		})
+
 		It("returns error when compile fails", func() {
 			compiler.CompileErr = errors.New("fake-compile-error")
 
@@ -10,6 +10,7 @@ import (
 	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -18,6 +19,7 @@ import (
 
 type concreteFactory struct {
 	availableActions map[string]Action
+	policy           Policy
 }
 
 func NewFactory(
@@ -31,7 +33,9 @@ func NewFactory(
 	jobSupervisor boshjobsuper.JobSupervisor,
 	specService boshas.V1Service,
 	drainScriptProvider boshdrain.ScriptProvider,
+	vitalsHistory boshvitals.History,
 	logger boshlog.Logger,
+	options Options,
 ) (factory Factory) {
 	compressor := platform.GetCompressor()
 	copier := platform.GetCopier()
@@ -40,42 +44,62 @@ func NewFactory(
 	certManager := platform.GetCertManager()
 	ntpService := boshntp.NewConcreteService(platform.GetFs(), dirProvider)
 
+	policy := NewPolicy(options)
+
+	availableActions := map[string]Action{
+		// Task management
+		"ping":        NewPing(),
+		"get_task":    NewGetTask(taskService),
+		"cancel_task": NewCancelTask(taskService),
+		"list_tasks":  NewListTasks(taskService),
+
+		// VM admin
+		"ssh":             NewSSH(settingsService, platform, dirProvider),
+		"fetch_logs":      NewFetchLogs(compressor, copier, blobstore, dirProvider),
+		"update_settings": NewUpdateSettings(certManager, logger),
+
+		// Job management
+		"prepare":    NewPrepare(applier),
+		"apply":      NewApply(applier, specService, settingsService),
+		"start":      NewStart(jobSupervisor),
+		"stop":       NewStop(jobSupervisor),
+		"drain":      NewDrain(notifier, specService, drainScriptProvider, jobSupervisor, logger),
+		"get_state":  NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService),
+		"run_errand": NewRunErrand(specService, dirProvider.JobsDir(), platform.GetRunner(), logger),
+
+		// Monitoring
+		"get_vitals_history": NewGetVitalsHistory(vitalsHistory),
+
+		// Compilation
+		"compile_package":    NewCompilePackage(compiler),
+		"release_apply_spec": NewReleaseApplySpec(platform),
+
+		// Disk management
+		"list_disk":    NewListDisk(settingsService, platform, logger),
+		"migrate_disk": NewMigrateDisk(platform, dirProvider),
+		"mount_disk":   NewMountDisk(settingsService, platform, platform, dirProvider),
+		"unmount_disk": NewUnmountDisk(settingsService, platform),
+
+		// Networking
+		"prepare_network_change":     NewPrepareNetworkChange(platform.GetFs(), settingsService),
+		"prepare_configure_networks": NewPrepareConfigureNetworks(platform, settingsService),
+		"configure_networks":         NewConfigureNetworks(),
+	}
+
+	// Capability listing includes itself and actions added below
+	methods := []string{"get_capabilities", "describe_actions"}
+	for method := range availableActions {
+		methods = append(methods, method)
+	}
+
+	availableActions["get_capabilities"] = NewGetCapabilities(policy.Partition(methods))
+
+	// Description covers all actions including itself since the map is shared
+	availableActions["describe_actions"] = NewDescribeActions(availableActions)
+
 	factory = concreteFactory{
-		availableActions: map[string]Action{
-			// Task management
-			"ping":        NewPing(),
-			"get_task":    NewGetTask(taskService),
-			"cancel_task": NewCancelTask(taskService),
-
-			// VM admin
-			"ssh":             NewSSH(settingsService, platform, dirProvider),
-			"fetch_logs":      NewFetchLogs(compressor, copier, blobstore, dirProvider),
-			"update_settings": NewUpdateSettings(certManager, logger),
-
-			// Job management
-			"prepare":    NewPrepare(applier),
-			"apply":      NewApply(applier, specService, settingsService),
-			"start":      NewStart(jobSupervisor),
-			"stop":       NewStop(jobSupervisor),
-			"drain":      NewDrain(notifier, specService, drainScriptProvider, jobSupervisor, logger),
-			"get_state":  NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService),
-			"run_errand": NewRunErrand(specService, dirProvider.JobsDir(), platform.GetRunner(), logger),
-
-			// Compilation
-			"compile_package":    NewCompilePackage(compiler),
-			"release_apply_spec": NewReleaseApplySpec(platform),
-
-			// Disk management
-			"list_disk":    NewListDisk(settingsService, platform, logger),
-			"migrate_disk": NewMigrateDisk(platform, dirProvider),
-			"mount_disk":   NewMountDisk(settingsService, platform, platform, dirProvider),
-			"unmount_disk": NewUnmountDisk(settingsService, platform),
-
-			// Networking
-			"prepare_network_change":     NewPrepareNetworkChange(platform.GetFs(), settingsService),
-			"prepare_configure_networks": NewPrepareConfigureNetworks(platform, settingsService),
-			"configure_networks":         NewConfigureNetworks(),
-		},
+		availableActions: availableActions,
+		policy:           policy,
 	}
 	return
 }
@@ -86,5 +110,9 @@ func (f concreteFactory) Create(method string) (Action, error) {
 		return nil, bosherr.Errorf("Could not create action with method %s", method)
 	}
 
+	if !f.policy.IsAllowed(method) {
+		return nil, DisabledError{Method: method}
+	}
+
 	return action, nil
 }
@@ -14,6 +14,7 @@ import (
 	fakenotif "github.com/cloudfoundry/bosh-agent/notification/fakes"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
+	fakevitals "github.com/cloudfoundry/bosh-agent/platform/vitals/fakes"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	fakeblobstore "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -31,6 +32,7 @@ var _ = Describe("concreteFactory", func() {
 		jobSupervisor       *fakejobsuper.FakeJobSupervisor
 		specService         *fakeas.FakeV1Service
 		drainScriptProvider boshdrain.ScriptProvider
+		vitalsHistory       *fakevitals.FakeHistory
 		factory             Factory
 		logger              boshlog.Logger
 	)
@@ -46,6 +48,7 @@ var _ = Describe("concreteFactory", func() {
 		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
 		specService = fakeas.NewFakeV1Service()
 		drainScriptProvider = boshdrain.NewConcreteScriptProvider(nil, nil, platform.GetDirProvider())
+		vitalsHistory = &fakevitals.FakeHistory{}
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 
 		factory = NewFactory(
@@ -59,7 +62,9 @@ var _ = Describe("concreteFactory", func() {
 			jobSupervisor,
 			specService,
 			drainScriptProvider,
+			vitalsHistory,
 			logger,
+			Options{},
 		)
 	})
 
@@ -69,22 +74,119 @@ var _ = Describe("concreteFactory", func() {
 		Expect(action).To(BeNil())
 	})
 
+	Context("when actions are restricted by policy", func() {
+		BeforeEach(func() {
+			factory = NewFactory(
+				settingsService,
+				platform,
+				blobstore,
+				taskService,
+				notifier,
+				applier,
+				compiler,
+				jobSupervisor,
+				specService,
+				drainScriptProvider,
+				vitalsHistory,
+				logger,
+				Options{
+					AllowedActions: []string{"get_state", "ssh"},
+					DeniedActions:  []string{"ssh"},
+				},
+			)
+		})
+
+		It("returns disabled error for actions that are not allowed", func() {
+			action, err := factory.Create("apply")
+			Expect(err).To(Equal(DisabledError{Method: "apply"}))
+			Expect(action).To(BeNil())
+		})
+
+		It("returns disabled error for denied actions even if they are allowed", func() {
+			_, err := factory.Create("ssh")
+			Expect(err).To(Equal(DisabledError{Method: "ssh"}))
+		})
+
+		It("returns allowed actions", func() {
+			_, err := factory.Create("get_state")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error for unknown actions rather than disabled error", func() {
+			_, err := factory.Create("fake-unknown-action")
+			Expect(err).To(HaveOccurred())
+			Expect(err).ToNot(BeAssignableToTypeOf(DisabledError{}))
+		})
+
+		It("lists allowed and disabled actions in capabilities", func() {
+			action, err := factory.Create("get_capabilities")
+			Expect(err).ToNot(HaveOccurred())
+
+			value, err := action.(GetCapabilitiesAction).Run()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value.AllowedActions).To(Equal([]string{"get_capabilities", "get_state", "get_task", "ping"}))
+			Expect(value.DisabledActions).To(ContainElement("apply"))
+			Expect(value.DisabledActions).To(ContainElement("ssh"))
+		})
+	})
+
+	It("get_capabilities", func() {
+		action, err := factory.Create("get_capabilities")
+		Expect(err).ToNot(HaveOccurred())
+
+		value, err := action.(GetCapabilitiesAction).Run()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(value.AllowedActions).To(ContainElement("apply"))
+		Expect(value.AllowedActions).To(ContainElement("get_capabilities"))
+		Expect(value.DisabledActions).To(BeEmpty())
+	})
+
+	It("describe_actions", func() {
+		action, err := factory.Create("describe_actions")
+		Expect(err).ToNot(HaveOccurred())
+
+		descriptions, err := action.(DescribeActionsAction).Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		var names []string
+		for _, description := range descriptions {
+			names = append(names, description.Name)
+		}
+
+		Expect(names).To(ContainElement("apply"))
+		Expect(names).To(ContainElement("get_capabilities"))
+		Expect(names).To(ContainElement("describe_actions"))
+
+		value, err := factory.Create("get_capabilities")
+		Expect(err).ToNot(HaveOccurred())
+
+		capabilities, err := value.(GetCapabilitiesAction).Run()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(capabilities.AllowedActions).To(Equal(names))
+	})
+
 	It("apply", func() {
 		action, err := factory.Create("apply")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewApply(applier, specService, settingsService)))
+
+		// Cannot do equality check since channel is used in initializer
+		Expect(action).To(BeAssignableToTypeOf(ApplyAction{}))
 	})
 
 	It("drain", func() {
 		action, err := factory.Create("drain")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewDrain(notifier, specService, drainScriptProvider, jobSupervisor, logger)))
+
+		// Cannot do equality check since channel is used in initializer
+		Expect(action).To(BeAssignableToTypeOf(DrainAction{}))
 	})
 
 	It("fetch_logs", func() {
 		action, err := factory.Create("fetch_logs")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewFetchLogs(platform.GetCompressor(), platform.GetCopier(), blobstore, platform.GetDirProvider())))
+
+		// Cannot do equality check since channel is used in initializer
+		Expect(action).To(BeAssignableToTypeOf(FetchLogsAction{}))
 	})
 
 	It("get_task", func() {
@@ -99,6 +201,12 @@ var _ = Describe("concreteFactory", func() {
 		Expect(action).To(Equal(NewCancelTask(taskService)))
 	})
 
+	It("list_tasks", func() {
+		action, err := factory.Create("list_tasks")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewListTasks(taskService)))
+	})
+
 	It("get_state", func() {
 		ntpService := boshntp.NewConcreteService(platform.GetFs(), platform.GetDirProvider())
 		action, err := factory.Create("get_state")
@@ -106,6 +214,12 @@ var _ = Describe("concreteFactory", func() {
 		Expect(action).To(Equal(NewGetState(settingsService, specService, jobSupervisor, platform.GetVitalsService(), ntpService)))
 	})
 
+	It("get_vitals_history", func() {
+		action, err := factory.Create("get_vitals_history")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewGetVitalsHistory(vitalsHistory)))
+	})
+
 	It("list_disk", func() {
 		action, err := factory.Create("list_disk")
 		Expect(err).ToNot(HaveOccurred())
@@ -175,7 +289,9 @@ var _ = Describe("concreteFactory", func() {
 	It("compile_package", func() {
 		action, err := factory.Create("compile_package")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewCompilePackage(compiler)))
+
+		// Cannot do equality check since channel is used in initializer
+		Expect(action).To(BeAssignableToTypeOf(CompilePackageAction{}))
 	})
 
 	It("run_errand", func() {
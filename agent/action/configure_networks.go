@@ -4,6 +4,8 @@ import (
 	"errors"
 	"os"
 	"time"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type ConfigureNetworksAction struct {
@@ -23,6 +25,10 @@ func (a ConfigureNetworksAction) IsPersistent() bool {
 	return true
 }
 
+func (a ConfigureNetworksAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceNetwork}
+}
+
 func (a ConfigureNetworksAction) Run() (interface{}, error) {
 	// Two possible ways to implement this action:
 	// (1) Restart agent which will in turn fetch infrastructure settings
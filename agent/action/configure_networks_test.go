@@ -5,6 +5,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 func init() {
@@ -25,6 +26,10 @@ func init() {
 			Expect(action.IsPersistent()).To(BeTrue())
 		})
 
+		It("modifies network", func() {
+			Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceNetwork}))
+		})
+
 		Describe("Run", func() {
 			// restarts agent process
 		})
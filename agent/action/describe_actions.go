@@ -0,0 +1,110 @@
+package action
+
+import (
+	"errors"
+	"sort"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type DescribeActionsAction struct {
+	actions map[string]Action
+}
+
+func NewDescribeActions(actions map[string]Action) DescribeActionsAction {
+	return DescribeActionsAction{actions: actions}
+}
+
+type ActionDescription struct {
+	Name         string                `json:"name"`
+	Asynchronous bool                  `json:"asynchronous"`
+	Persistent   bool                  `json:"persistent"`
+	Arguments    []ArgumentDescription `json:"arguments"`
+	Returns      *Schema               `json:"returns"`
+}
+
+type ArgumentDescription struct {
+	Schema *Schema `json:"schema"`
+
+	// Variadic argument can be given any number of times (including zero)
+	Variadic bool `json:"variadic"`
+}
+
+// DescribeAction derives description of arguments and return value
+// from action's Run method in the same way Runner interprets them
+func DescribeAction(name string, action Action) (ActionDescription, error) {
+	description := ActionDescription{
+		Name:         name,
+		Asynchronous: action.IsAsynchronous(),
+		Persistent:   action.IsPersistent(),
+		Arguments:    []ArgumentDescription{},
+	}
+
+	runMethodValue, err := runMethod(action)
+	if err != nil {
+		return description, bosherr.WrapErrorf(err, "Describing action %s", name)
+	}
+
+	runMethodType := runMethodValue.Type()
+
+	for i := injectedArgsCount(runMethodType); i < runMethodType.NumIn(); i++ {
+		argType := runMethodType.In(i)
+		variadic := runMethodType.IsVariadic() && i == runMethodType.NumIn()-1
+
+		if variadic {
+			argType = argType.Elem()
+		}
+
+		description.Arguments = append(description.Arguments, ArgumentDescription{
+			Schema:   NewSchema(argType),
+			Variadic: variadic,
+		})
+	}
+
+	description.Returns = NewSchema(runMethodType.Out(0))
+
+	return description, nil
+}
+
+func (a DescribeActionsAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a DescribeActionsAction) IsPersistent() bool {
+	return false
+}
+
+func (a DescribeActionsAction) Resources() []boshtask.Resource {
+	return nil
+}
+
+func (a DescribeActionsAction) Run() ([]ActionDescription, error) {
+	names := []string{}
+	for name := range a.actions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	descriptions := []ActionDescription{}
+
+	for _, name := range names {
+		description, err := DescribeAction(name, a.actions[name])
+		if err != nil {
+			return nil, err
+		}
+
+		descriptions = append(descriptions, description)
+	}
+
+	return descriptions, nil
+}
+
+func (a DescribeActionsAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a DescribeActionsAction) Cancel() error {
+	return errors.New("not supported")
+}
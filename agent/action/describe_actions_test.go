@@ -0,0 +1,135 @@
+package action_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+)
+
+var _ = Describe("DescribeActionsAction", func() {
+	var (
+		action DescribeActionsAction
+	)
+
+	BeforeEach(func() {
+		action = NewDescribeActions(map[string]Action{
+			"fake-good":     &actionWithGoodRunMethod{},
+			"fake-optional": &actionWithOptionalRunArgument{},
+			"fake-progress": &actionWithProgressReporter{},
+		})
+	})
+
+	It("is synchronous", func() {
+		Expect(action.IsAsynchronous()).To(BeFalse())
+	})
+
+	It("is not persistent", func() {
+		Expect(action.IsPersistent()).To(BeFalse())
+	})
+
+	It("describes actions sorted by name", func() {
+		descriptions, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(descriptions).To(HaveLen(3))
+		Expect(descriptions[0].Name).To(Equal("fake-good"))
+		Expect(descriptions[1].Name).To(Equal("fake-optional"))
+		Expect(descriptions[2].Name).To(Equal("fake-progress"))
+	})
+
+	It("describes arguments and return value derived from Run method", func() {
+		descriptions, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		descriptionJSON, err := json.Marshal(descriptions[0])
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(descriptionJSON).To(MatchJSON(`{
+			"name": "fake-good",
+			"asynchronous": false,
+			"persistent": false,
+			"arguments": [
+				{"schema": {"type": "string"}, "variadic": false},
+				{"schema": {"type": "integer"}, "variadic": false},
+				{
+					"schema": {
+						"type": "object",
+						"go_type": "action_test.argsType",
+						"properties": {
+							"user": {"type": "string"},
+							"pwd": {"type": "string"},
+							"id": {"type": "integer"}
+						}
+					},
+					"variadic": false
+				},
+				{"schema": {"type": "array", "items": {"type": "string"}}, "variadic": false}
+			],
+			"returns": {
+				"type": "object",
+				"go_type": "action_test.valueType",
+				"properties": {
+					"ID": {"type": "integer"},
+					"Success": {"type": "boolean"}
+				}
+			}
+		}`))
+	})
+
+	It("marks variadic arguments", func() {
+		descriptions, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		arguments := descriptions[1].Arguments
+		Expect(arguments).To(HaveLen(2))
+		Expect(arguments[0].Variadic).To(BeFalse())
+		Expect(arguments[1].Variadic).To(BeTrue())
+		Expect(arguments[1].Schema.GoType).To(Equal("action_test.argsType"))
+	})
+
+	It("does not describe progress reporter as an argument", func() {
+		descriptions, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(descriptions[2].Asynchronous).To(BeTrue())
+		Expect(descriptions[2].Arguments).To(HaveLen(1))
+		Expect(descriptions[2].Arguments[0].Schema.Type).To(Equal("string"))
+	})
+
+	It("does not describe checkpoints as an argument", func() {
+		action = NewDescribeActions(map[string]Action{
+			"fake-checkpoints": &actionWithCheckpoints{},
+		})
+
+		descriptions, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(descriptions[0].Persistent).To(BeTrue())
+		Expect(descriptions[0].Arguments).To(HaveLen(1))
+		Expect(descriptions[0].Arguments[0].Schema.Type).To(Equal("string"))
+	})
+
+	It("returns error when action does not have a valid Run method", func() {
+		action = NewDescribeActions(map[string]Action{
+			"fake-without-run": &actionWithoutRunMethod{},
+		})
+
+		_, err := action.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Describing action fake-without-run: Run method not found"))
+	})
+
+	It("returns JSON array even when there are no actions", func() {
+		action = NewDescribeActions(map[string]Action{})
+
+		descriptions, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		descriptionsJSON, err := json.Marshal(descriptions)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(descriptionsJSON)).To(Equal("[]"))
+	})
+})
@@ -5,6 +5,7 @@ import (
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	boshdrain "github.com/cloudfoundry/bosh-agent/agent/drain"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -21,6 +22,8 @@ type DrainAction struct {
 	specService         boshas.V1Service
 	jobSupervisor       boshjobsuper.JobSupervisor
 	logger              boshlog.Logger
+
+	canceller *boshtask.Canceller
 }
 
 func NewDrain(
@@ -35,6 +38,7 @@ func NewDrain(
 	drain.drainScriptProvider = drainScriptProvider
 	drain.jobSupervisor = jobSupervisor
 	drain.logger = logger
+	drain.canceller = boshtask.NewCanceller()
 	return
 }
 
@@ -46,6 +50,10 @@ func (a DrainAction) IsPersistent() bool {
 	return false
 }
 
+func (a DrainAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceJobs}
+}
+
 type DrainType string
 
 const (
@@ -55,6 +63,9 @@ const (
 )
 
 func (a DrainAction) Run(drainType DrainType, newSpecs ...boshas.V1ApplySpec) (int, error) {
+	cancelCh, stopCancelling := a.canceller.Start()
+	defer stopCancelling()
+
 	a.logger.Debug(drainActionLogTag, "Running drain action with drain type %s", drainType)
 	currentSpec, err := a.specService.Get()
 	if err != nil {
@@ -110,7 +121,7 @@ func (a DrainAction) Run(drainType DrainType, newSpecs ...boshas.V1ApplySpec) (i
 		return 0, nil
 	}
 
-	value, err := drainScript.Run(params)
+	value, err := drainScript.Run(params, cancelCh)
 	if err != nil {
 		return 0, bosherr.WrapError(err, "Running Drain Script")
 	}
@@ -122,6 +133,8 @@ func (a DrainAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
 
+// Cancel terminates running drain script
 func (a DrainAction) Cancel() error {
-	return errors.New("not supported")
+	a.canceller.Cancel()
+	return nil
 }
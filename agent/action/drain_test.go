@@ -11,6 +11,7 @@ import (
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	boshdrain "github.com/cloudfoundry/bosh-agent/agent/drain"
 	fakedrain "github.com/cloudfoundry/bosh-agent/agent/drain/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	fakenotif "github.com/cloudfoundry/bosh-agent/notification/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -48,6 +49,10 @@ func init() {
 			Expect(action.IsPersistent()).To(BeFalse())
 		})
 
+		It("modifies jobs", func() {
+			Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceJobs}))
+		})
+
 		Context("when drain update is requested", func() {
 			act := func() (int, error) { return action.Run(DrainTypeUpdate, boshas.V1ApplySpec{}) }
 
@@ -100,6 +105,18 @@ func init() {
 								Expect(params).To(Equal(boshdrain.NewUpdateParams(currentSpec, newSpec)))
 							})
 
+							It("terminates drain script when cancelled", func() {
+								drainScriptProvider.NewScriptScript.RunWaitsForCancel = true
+								drainScriptProvider.NewScriptScript.RunCallBack = func() {
+									err := action.Cancel()
+									Expect(err).ToNot(HaveOccurred())
+								}
+
+								_, err := act()
+								Expect(err).To(HaveOccurred())
+								Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+							})
+
 							Context("when drain script runs and errs", func() {
 								It("returns error", func() {
 									drainScriptProvider.NewScriptScript.RunError = errors.New("fake-drain-run-error")
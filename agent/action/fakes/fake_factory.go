@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeFactory struct {
@@ -44,8 +45,9 @@ func (f *FakeFactory) RegisterActionErr(method string, err error) {
 }
 
 type TestAction struct {
-	Asynchronous bool
-	Persistent   bool
+	Asynchronous  bool
+	Persistent    bool
+	TaskResources []boshtask.Resource
 
 	ResumeValue interface{}
 	ResumeErr   error
@@ -63,6 +65,10 @@ func (a *TestAction) IsPersistent() bool {
 	return a.Persistent
 }
 
+func (a *TestAction) Resources() []boshtask.Resource {
+	return a.TaskResources
+}
+
 func (a *TestAction) Run(payload []byte) (interface{}, error) {
 	return nil, nil
 }
@@ -2,28 +2,39 @@ package fakes
 
 import (
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeRunner struct {
-	RunAction  boshaction.Action
-	RunPayload []byte
-	RunValue   interface{}
-	RunErr     error
+	RunAction      boshaction.Action
+	RunPayload     []byte
+	RunReporter    boshtask.ProgressReporter
+	RunCheckpoints boshtask.Checkpoints
+	RunValue       interface{}
+	RunErr         error
+	RunCallCount   int
 
-	ResumeAction  boshaction.Action
-	ResumePayload []byte
-	ResumeValue   interface{}
-	ResumeErr     error
+	ResumeAction      boshaction.Action
+	ResumePayload     []byte
+	ResumeReporter    boshtask.ProgressReporter
+	ResumeCheckpoints boshtask.Checkpoints
+	ResumeValue       interface{}
+	ResumeErr         error
 }
 
-func (runner *FakeRunner) Run(action boshaction.Action, payload []byte) (interface{}, error) {
+func (runner *FakeRunner) Run(action boshaction.Action, payload []byte, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints) (interface{}, error) {
+	runner.RunCallCount++
 	runner.RunAction = action
 	runner.RunPayload = payload
+	runner.RunReporter = reporter
+	runner.RunCheckpoints = checkpoints
 	return runner.RunValue, runner.RunErr
 }
 
-func (runner *FakeRunner) Resume(action boshaction.Action, payload []byte) (interface{}, error) {
+func (runner *FakeRunner) Resume(action boshaction.Action, payload []byte, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints) (interface{}, error) {
 	runner.ResumeAction = action
 	runner.ResumePayload = payload
+	runner.ResumeReporter = reporter
+	runner.ResumeCheckpoints = checkpoints
 	return runner.ResumeValue, runner.ResumeErr
 }
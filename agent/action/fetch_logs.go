@@ -4,6 +4,7 @@ import (
 	"errors"
 	"path/filepath"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -15,6 +16,8 @@ type FetchLogsAction struct {
 	copier      boshcmd.Copier
 	blobstore   boshblob.Blobstore
 	settingsDir boshdirs.Provider
+
+	canceller *boshtask.Canceller
 }
 
 func NewFetchLogs(
@@ -27,6 +30,7 @@ func NewFetchLogs(
 	action.copier = copier
 	action.blobstore = blobstore
 	action.settingsDir = settingsDir
+	action.canceller = boshtask.NewCanceller()
 	return
 }
 
@@ -38,7 +42,14 @@ func (a FetchLogsAction) IsPersistent() bool {
 	return false
 }
 
-func (a FetchLogsAction) Run(logType string, filters []string) (value map[string]string, err error) {
+func (a FetchLogsAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceLogs}
+}
+
+func (a FetchLogsAction) Run(reporter boshtask.ProgressReporter, logType string, filters []string) (value map[string]string, err error) {
+	cancelCh, stopCancelling := a.canceller.Start()
+	defer stopCancelling()
+
 	var logsDir string
 
 	switch logType {
@@ -57,6 +68,8 @@ func (a FetchLogsAction) Run(logType string, filters []string) (value map[string
 		return
 	}
 
+	reporter.ReportProgress("Copying logs", 0)
+
 	tmpDir, err := a.copier.FilteredCopyToTemp(logsDir, filters)
 	if err != nil {
 		err = bosherr.WrapError(err, "Copying filtered files to temp directory")
@@ -65,6 +78,13 @@ func (a FetchLogsAction) Run(logType string, filters []string) (value map[string
 
 	defer a.copier.CleanUp(tmpDir)
 
+	if boshtask.IsCancelled(cancelCh) {
+		err = boshtask.ErrCancelled
+		return
+	}
+
+	reporter.ReportProgress("Compressing logs", 40)
+
 	tarball, err := a.compressor.CompressFilesInDir(tmpDir)
 	if err != nil {
 		err = bosherr.WrapError(err, "Making logs tarball")
@@ -73,6 +93,13 @@ func (a FetchLogsAction) Run(logType string, filters []string) (value map[string
 
 	defer a.compressor.CleanUp(tarball)
 
+	if boshtask.IsCancelled(cancelCh) {
+		err = boshtask.ErrCancelled
+		return
+	}
+
+	reporter.ReportProgress("Uploading logs", 70)
+
 	blobID, _, err := a.blobstore.Create(tarball)
 	if err != nil {
 		err = bosherr.WrapError(err, "Create file on blobstore")
@@ -87,6 +114,8 @@ func (a FetchLogsAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
 
+// Cancel stops fetching logs before they are uploaded
 func (a FetchLogsAction) Cancel() error {
-	return errors.New("not supported")
+	a.canceller.Cancel()
+	return nil
 }
@@ -7,6 +7,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
 	fakeblobstore "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
@@ -19,6 +21,7 @@ var _ = Describe("FetchLogsAction", func() {
 		copier      *fakecmd.FakeCopier
 		blobstore   *fakeblobstore.FakeBlobstore
 		dirProvider boshdirs.Provider
+		reporter    *faketask.FakeProgressReporter
 		action      FetchLogsAction
 	)
 
@@ -27,6 +30,7 @@ var _ = Describe("FetchLogsAction", func() {
 		blobstore = &fakeblobstore.FakeBlobstore{}
 		dirProvider = boshdirs.NewProvider("/fake/dir")
 		copier = fakecmd.NewFakeCopier()
+		reporter = faketask.NewFakeProgressReporter()
 		action = NewFetchLogs(compressor, copier, blobstore, dirProvider)
 	})
 
@@ -38,13 +42,17 @@ var _ = Describe("FetchLogsAction", func() {
 		Expect(action.IsPersistent()).To(BeFalse())
 	})
 
+	It("uses logs resource", func() {
+		Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceLogs}))
+	})
+
 	Describe("Run", func() {
 		testLogs := func(logType string, filters []string, expectedFilters []string) {
 			copier.FilteredCopyToTempTempDir = "/fake-temp-dir"
 			compressor.CompressFilesInDirTarballPath = "logs_test.tar"
 			blobstore.CreateBlobID = "my-blob-id"
 
-			logs, err := action.Run(logType, filters)
+			logs, err := action.Run(reporter, logType, filters)
 			Expect(err).ToNot(HaveOccurred())
 
 			var expectedPath string
@@ -64,10 +72,12 @@ var _ = Describe("FetchLogsAction", func() {
 			Expect(compressor.CompressFilesInDirTarballPath).To(Equal(blobstore.CreateFileNames[0]))
 
 			boshassert.MatchesJSONString(GinkgoT(), logs, `{"blobstore_id":"my-blob-id"}`)
+
+			Expect(reporter.Stages()).To(Equal([]string{"Copying logs", "Compressing logs", "Uploading logs"}))
 		}
 
 		It("logs errs if given invalid log type", func() {
-			_, err := action.Run("other-logs", []string{})
+			_, err := action.Run(reporter, "other-logs", []string{})
 			Expect(err).To(HaveOccurred())
 		})
 
@@ -95,6 +105,25 @@ var _ = Describe("FetchLogsAction", func() {
 			testLogs("job", filters, expectedFilters)
 		})
 
+		It("stops before uploading logs and cleans up copied logs when cancelled", func() {
+			copier.FilteredCopyToTempTempDir = "/fake-temp-dir"
+
+			reporter.ReportProgressCallBack = func(stage string) {
+				if stage == "Copying logs" {
+					err := action.Cancel()
+					Expect(err).ToNot(HaveOccurred())
+				}
+			}
+
+			_, err := action.Run(reporter, "job", []string{})
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(Equal(boshtask.ErrCancelled))
+
+			Expect(compressor.CompressFilesInDirDir).To(BeEmpty())
+			Expect(blobstore.CreateFileNames).To(BeEmpty())
+			Expect(copier.CleanUpTempDir).To(Equal("/fake-temp-dir"))
+		})
+
 		It("cleans up compressed package after uploading it to blobstore", func() {
 			var beforeCleanUpTarballPath, afterCleanUpTarballPath string
 
@@ -104,7 +133,7 @@ var _ = Describe("FetchLogsAction", func() {
 				beforeCleanUpTarballPath = compressor.CleanUpTarballPath
 			}
 
-			_, err := action.Run("job", []string{})
+			_, err := action.Run(reporter, "job", []string{})
 			Expect(err).ToNot(HaveOccurred())
 
 			// Logs are not cleaned up before blobstore upload
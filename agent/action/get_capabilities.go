@@ -0,0 +1,52 @@
+package action
+
+import (
+	"errors"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+)
+
+type GetCapabilitiesAction struct {
+	allowedActions  []string
+	disabledActions []string
+}
+
+func NewGetCapabilities(allowedActions, disabledActions []string) GetCapabilitiesAction {
+	return GetCapabilitiesAction{
+		allowedActions:  allowedActions,
+		disabledActions: disabledActions,
+	}
+}
+
+type CapabilitiesValue struct {
+	AllowedActions  []string `json:"allowed_actions"`
+	DisabledActions []string `json:"disabled_actions"`
+}
+
+func (a GetCapabilitiesAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a GetCapabilitiesAction) IsPersistent() bool {
+	return false
+}
+
+func (a GetCapabilitiesAction) Resources() []boshtask.Resource {
+	return nil
+}
+
+func (a GetCapabilitiesAction) Run() (CapabilitiesValue, error) {
+	value := CapabilitiesValue{
+		AllowedActions:  a.allowedActions,
+		DisabledActions: a.disabledActions,
+	}
+	return value, nil
+}
+
+func (a GetCapabilitiesAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a GetCapabilitiesAction) Cancel() error {
+	return errors.New("not supported")
+}
@@ -0,0 +1,35 @@
+package action_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshassert "github.com/cloudfoundry/bosh-utils/assert"
+)
+
+var _ = Describe("GetCapabilitiesAction", func() {
+	var (
+		action GetCapabilitiesAction
+	)
+
+	BeforeEach(func() {
+		action = NewGetCapabilities([]string{"get_state", "ping"}, []string{"ssh"})
+	})
+
+	It("is synchronous", func() {
+		Expect(action.IsAsynchronous()).To(BeFalse())
+	})
+
+	It("is not persistent", func() {
+		Expect(action.IsPersistent()).To(BeFalse())
+	})
+
+	It("returns allowed and disabled actions", func() {
+		value, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		boshassert.MatchesJSONString(GinkgoT(), value,
+			`{"allowed_actions":["get_state","ping"],"disabled_actions":["ssh"]}`)
+	})
+})
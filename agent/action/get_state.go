@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
@@ -42,15 +43,20 @@ func (a GetStateAction) IsPersistent() bool {
 	return false
 }
 
+func (a GetStateAction) Resources() []boshtask.Resource {
+	return nil
+}
+
 type GetStateV1ApplySpec struct {
 	boshas.V1ApplySpec
 
-	AgentID      string             `json:"agent_id"`
-	BoshProtocol string             `json:"bosh_protocol"`
-	JobState     string             `json:"job_state"`
-	Vitals       *boshvitals.Vitals `json:"vitals,omitempty"`
-	VM           boshsettings.VM    `json:"vm"`
-	Ntp          boshntp.Info       `json:"ntp"`
+	AgentID      string                 `json:"agent_id"`
+	BoshProtocol string                 `json:"bosh_protocol"`
+	JobState     string                 `json:"job_state"`
+	Vitals       *boshvitals.Vitals     `json:"vitals,omitempty"`
+	Processes    []boshjobsuper.Process `json:"processes,omitempty"`
+	VM           boshsettings.VM        `json:"vm"`
+	Ntp          boshntp.Info           `json:"ntp"`
 }
 
 func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
@@ -61,6 +67,7 @@ func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 
 	var vitals boshvitals.Vitals
 	var vitalsReference *boshvitals.Vitals
+	var processes []boshjobsuper.Process
 
 	if len(filters) > 0 && filters[0] == "full" {
 		vitals, err = a.vitalsService.Get()
@@ -68,6 +75,10 @@ func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 			return GetStateV1ApplySpec{}, bosherr.WrapError(err, "Building full vitals")
 		}
 		vitalsReference = &vitals
+
+		// Processes are left out when job supervisor cannot be reached;
+		// job state is reported as unknown in that case
+		processes, _ = a.jobSupervisor.Processes()
 	}
 
 	settings := a.settingsService.GetSettings()
@@ -78,6 +89,7 @@ func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 		"1",
 		a.jobSupervisor.Status(),
 		vitalsReference,
+		processes,
 		settings.VM,
 		a.ntpService.GetInfo(),
 	}
@@ -9,6 +9,7 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
 	fakentp "github.com/cloudfoundry/bosh-agent/platform/ntp/fakes"
@@ -87,6 +88,7 @@ var _ = Describe("GetState", func() {
 					Expect(state.JobState).To(Equal(expectedSpec.JobState))
 					Expect(state.Deployment).To(Equal(expectedSpec.Deployment))
 					boshassert.LacksJSONKey(GinkgoT(), state, "vitals")
+					boshassert.LacksJSONKey(GinkgoT(), state, "processes")
 
 					Expect(state).To(Equal(expectedSpec))
 				})
@@ -107,6 +109,11 @@ var _ = Describe("GetState", func() {
 					vitalsService.GetVitals = expectedVitals
 					expectedVM := map[string]interface{}{"name": "vm-abc-def"}
 
+					expectedProcesses := []boshjobsuper.Process{
+						{Name: "fake-process", State: "running", PID: 123},
+					}
+					jobSupervisor.ProcessesProcesses = expectedProcesses
+
 					state, err := action.Run("full")
 					Expect(err).ToNot(HaveOccurred())
 
@@ -114,9 +121,18 @@ var _ = Describe("GetState", func() {
 					boshassert.MatchesJSONString(GinkgoT(), state.JobState, `"running"`)
 					boshassert.MatchesJSONString(GinkgoT(), state.Deployment, `"fake-deployment"`)
 					Expect(*state.Vitals).To(Equal(expectedVitals))
+					Expect(state.Processes).To(Equal(expectedProcesses))
 					boshassert.MatchesJSONMap(GinkgoT(), state.VM, expectedVM)
 				})
 
+				It("leaves out processes when job supervisor cannot tell them", func() {
+					jobSupervisor.ProcessesErr = errors.New("fake-processes-err")
+
+					state, err := action.Run("full")
+					Expect(err).ToNot(HaveOccurred())
+					boshassert.LacksJSONKey(GinkgoT(), state, "processes")
+				})
+
 				Describe("non-populated field formatting", func() {
 					It("returns network as empty hash if not set", func() {
 						specService.Spec = boshas.V1ApplySpec{NetworkSpecs: nil}
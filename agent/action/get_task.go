@@ -24,17 +24,19 @@ func (a GetTaskAction) IsPersistent() bool {
 	return false
 }
 
+func (a GetTaskAction) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a GetTaskAction) Run(taskID string) (interface{}, error) {
 	task, found := a.taskService.FindTaskWithID(taskID)
 	if !found {
 		return nil, bosherr.Errorf("Task with id %s could not be found", taskID)
 	}
 
-	if task.State == boshtask.StateRunning {
-		return boshtask.StateValue{
-			AgentTaskID: task.ID,
-			State:       task.State,
-		}, nil
+	switch task.State {
+	case boshtask.StateRunning, boshtask.StateQueued, boshtask.StateCancelled:
+		return task.StateValue(), nil
 	}
 
 	if task.Error != nil {
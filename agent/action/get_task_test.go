@@ -45,6 +45,35 @@ var _ = Describe("GetTask", func() {
 			`{"agent_task_id":"fake-task-id","state":"running"}`)
 	})
 
+	It("returns progress of a running task", func() {
+		taskService.StartedTasks["fake-task-id"] = boshtask.Task{
+			ID:    "fake-task-id",
+			State: boshtask.StateRunning,
+			Events: []boshtask.Event{
+				{Time: 1, Stage: "fake-stage-1", Percent: 10},
+				{Time: 2, Stage: "fake-stage-2", Percent: 60},
+			},
+		}
+
+		taskValue, err := action.Run("fake-task-id")
+		Expect(err).ToNot(HaveOccurred())
+
+		boshassert.MatchesJSONString(GinkgoT(), taskValue, `{"agent_task_id":"fake-task-id","state":"running","stage":"fake-stage-2","percent":60,"events":[{"time":1,"stage":"fake-stage-1","percent":10},{"time":2,"stage":"fake-stage-2","percent":60}]}`)
+	})
+
+	It("returns a queued task", func() {
+		taskService.StartedTasks["fake-task-id"] = boshtask.Task{
+			ID:    "fake-task-id",
+			State: boshtask.StateQueued,
+		}
+
+		taskValue, err := action.Run("fake-task-id")
+		Expect(err).ToNot(HaveOccurred())
+
+		boshassert.MatchesJSONString(GinkgoT(), taskValue,
+			`{"agent_task_id":"fake-task-id","state":"queued"}`)
+	})
+
 	It("returns a failed task", func() {
 		taskService.StartedTasks["fake-task-id"] = boshtask.Task{
 			ID:    "fake-task-id",
@@ -58,6 +87,18 @@ var _ = Describe("GetTask", func() {
 		Expect(taskValue).To(BeNil())
 	})
 
+	It("returns error of a timed out task", func() {
+		taskService.StartedTasks["fake-task-id"] = boshtask.Task{
+			ID:    "fake-task-id",
+			State: boshtask.StateTimedOut,
+			Error: errors.New("Action fake-action timed out after 1m0s"),
+		}
+
+		_, err := action.Run("fake-task-id")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Task fake-task-id result: Action fake-action timed out after 1m0s"))
+	})
+
 	It("returns a successful task", func() {
 		taskService.StartedTasks["fake-task-id"] = boshtask.Task{
 			ID:    "fake-task-id",
@@ -0,0 +1,82 @@
+package action
+
+import (
+	"errors"
+	"time"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type GetVitalsHistoryAction struct {
+	vitalsHistory boshvitals.History
+}
+
+func NewGetVitalsHistory(vitalsHistory boshvitals.History) (action GetVitalsHistoryAction) {
+	action.vitalsHistory = vitalsHistory
+	return
+}
+
+func (a GetVitalsHistoryAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a GetVitalsHistoryAction) IsPersistent() bool {
+	return false
+}
+
+func (a GetVitalsHistoryAction) Resources() []boshtask.Resource {
+	return nil
+}
+
+type GetVitalsHistoryParams struct {
+	// Unix timestamps of the window; whole history is returned when not set
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+
+	// Number of seconds each returned point summarizes with min, max and avg;
+	// every sample is returned as is when not set
+	Resolution int `json:"resolution"`
+}
+
+func (a GetVitalsHistoryAction) Run(params GetVitalsHistoryParams) ([]boshvitals.HistoryPoint, error) {
+	if params.From < 0 || params.To < 0 {
+		return nil, bosherr.Error("Expected from and to to be unix timestamps")
+	}
+
+	if params.From > 0 && params.To > 0 && params.From > params.To {
+		return nil, bosherr.Errorf("Expected from (%d) to not be after to (%d)", params.From, params.To)
+	}
+
+	if params.Resolution < 0 {
+		return nil, bosherr.Errorf("Expected resolution (%d) to not be negative", params.Resolution)
+	}
+
+	var from, to time.Time
+
+	if params.From > 0 {
+		from = time.Unix(params.From, 0)
+	}
+
+	if params.To > 0 {
+		to = time.Unix(params.To, 0)
+	}
+
+	points := a.vitalsHistory.Get(from, to, time.Duration(params.Resolution)*time.Second)
+
+	// Always return JSON array even if no samples were taken yet
+	if points == nil {
+		points = []boshvitals.HistoryPoint{}
+	}
+
+	return points, nil
+}
+
+func (a GetVitalsHistoryAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a GetVitalsHistoryAction) Cancel() error {
+	return errors.New("not supported")
+}
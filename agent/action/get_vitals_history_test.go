@@ -0,0 +1,89 @@
+package action_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	fakevitals "github.com/cloudfoundry/bosh-agent/platform/vitals/fakes"
+)
+
+var _ = Describe("GetVitalsHistoryAction", func() {
+	var (
+		vitalsHistory *fakevitals.FakeHistory
+		action        GetVitalsHistoryAction
+	)
+
+	BeforeEach(func() {
+		vitalsHistory = &fakevitals.FakeHistory{}
+		action = NewGetVitalsHistory(vitalsHistory)
+	})
+
+	It("is synchronous", func() {
+		Expect(action.IsAsynchronous()).To(BeFalse())
+	})
+
+	It("is not persistent", func() {
+		Expect(action.IsPersistent()).To(BeFalse())
+	})
+
+	It("does not use any resources", func() {
+		Expect(action.Resources()).To(BeEmpty())
+	})
+
+	It("returns points of requested window and resolution", func() {
+		vitalsHistory.GetPoints = []boshvitals.HistoryPoint{
+			{
+				Time:    1000,
+				Samples: 6,
+				Vitals: map[string]boshvitals.HistoryValue{
+					"mem.percent": boshvitals.HistoryValue{Min: 10, Max: 30, Avg: 20},
+				},
+			},
+		}
+
+		points, err := action.Run(GetVitalsHistoryParams{From: 1000, To: 2000, Resolution: 60})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(points).To(Equal(vitalsHistory.GetPoints))
+
+		Expect(vitalsHistory.GetFrom).To(Equal(time.Unix(1000, 0)))
+		Expect(vitalsHistory.GetTo).To(Equal(time.Unix(2000, 0)))
+		Expect(vitalsHistory.GetResolution).To(Equal(60 * time.Second))
+	})
+
+	It("asks for whole history at sample resolution when window and resolution are not given", func() {
+		_, err := action.Run(GetVitalsHistoryParams{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(vitalsHistory.GetFrom.IsZero()).To(BeTrue())
+		Expect(vitalsHistory.GetTo.IsZero()).To(BeTrue())
+		Expect(vitalsHistory.GetResolution).To(Equal(time.Duration(0)))
+	})
+
+	It("returns empty list when there is no history yet", func() {
+		points, err := action.Run(GetVitalsHistoryParams{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(points).To(Equal([]boshvitals.HistoryPoint{}))
+	})
+
+	It("returns error when from is after to", func() {
+		_, err := action.Run(GetVitalsHistoryParams{From: 2000, To: 1000})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Expected from (2000) to not be after to (1000)"))
+	})
+
+	It("returns error when from or to is negative", func() {
+		_, err := action.Run(GetVitalsHistoryParams{From: -1})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Expected from and to to be unix timestamps"))
+	})
+
+	It("returns error when resolution is negative", func() {
+		_, err := action.Run(GetVitalsHistoryParams{Resolution: -60})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Expected resolution (-60) to not be negative"))
+	})
+})
@@ -3,6 +3,7 @@ package action
 import (
 	"errors"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -34,6 +35,10 @@ func (a ListDiskAction) IsPersistent() bool {
 	return false
 }
 
+func (a ListDiskAction) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a ListDiskAction) Run() (interface{}, error) {
 	settings := a.settingsService.GetSettings()
 	diskIDs := []string{}
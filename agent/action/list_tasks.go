@@ -0,0 +1,47 @@
+package action
+
+import (
+	"errors"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+)
+
+type ListTasksAction struct {
+	taskService boshtask.Service
+}
+
+func NewListTasks(taskService boshtask.Service) (listTasks ListTasksAction) {
+	listTasks.taskService = taskService
+	return
+}
+
+func (a ListTasksAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a ListTasksAction) IsPersistent() bool {
+	return false
+}
+
+func (a ListTasksAction) Resources() []boshtask.Resource {
+	return nil
+}
+
+func (a ListTasksAction) Run() ([]boshtask.Summary, error) {
+	summaries := a.taskService.ListTasks()
+
+	// Always return JSON array even if agent has not run any tasks
+	if summaries == nil {
+		summaries = []boshtask.Summary{}
+	}
+
+	return summaries, nil
+}
+
+func (a ListTasksAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a ListTasksAction) Cancel() error {
+	return errors.New("not supported")
+}
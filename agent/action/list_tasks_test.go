@@ -0,0 +1,72 @@
+package action_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
+	boshassert "github.com/cloudfoundry/bosh-utils/assert"
+)
+
+var _ = Describe("ListTasksAction", func() {
+	var (
+		taskService *faketask.FakeService
+		action      ListTasksAction
+	)
+
+	BeforeEach(func() {
+		taskService = faketask.NewFakeService()
+		action = NewListTasks(taskService)
+	})
+
+	It("is synchronous", func() {
+		Expect(action.IsAsynchronous()).To(BeFalse())
+	})
+
+	It("is not persistent", func() {
+		Expect(action.IsPersistent()).To(BeFalse())
+	})
+
+	It("does not use any resources", func() {
+		Expect(action.Resources()).To(BeEmpty())
+	})
+
+	It("returns task summaries from task service", func() {
+		queuedAt := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+		finishedAt := queuedAt.Add(time.Minute)
+
+		taskService.ListedTasks = []boshtask.Summary{
+			{
+				AgentTaskID: "fake-task-id-1",
+				Method:      "fake-method-1",
+				State:       boshtask.StateFailed,
+				QueuedAt:    queuedAt,
+				StartedAt:   &queuedAt,
+				FinishedAt:  &finishedAt,
+				Error:       "fake-error",
+			},
+			{
+				AgentTaskID: "fake-task-id-2",
+				Method:      "fake-method-2",
+				State:       boshtask.StateQueued,
+				QueuedAt:    finishedAt,
+			},
+		}
+
+		summaries, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(summaries).To(Equal(taskService.ListedTasks))
+
+		boshassert.MatchesJSONString(GinkgoT(), summaries, `[{"agent_task_id":"fake-task-id-1","method":"fake-method-1","state":"failed","queued_at":"2015-01-01T00:00:00Z","started_at":"2015-01-01T00:00:00Z","finished_at":"2015-01-01T00:01:00Z","error":"fake-error"},{"agent_task_id":"fake-task-id-2","method":"fake-method-2","state":"queued","queued_at":"2015-01-01T00:01:00Z"}]`)
+	})
+
+	It("returns empty list when there are no tasks", func() {
+		summaries, err := action.Run()
+		Expect(err).ToNot(HaveOccurred())
+		boshassert.MatchesJSONString(GinkgoT(), summaries, `[]`)
+	})
+})
@@ -3,6 +3,7 @@ package action
 import (
 	"errors"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -26,21 +27,58 @@ func (a MigrateDiskAction) IsAsynchronous() bool {
 	return true
 }
 
+// IsPersistent is true so that migration interrupted by agent restart is finished
 func (a MigrateDiskAction) IsPersistent() bool {
-	return false
+	return true
+}
+
+func (a MigrateDiskAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceDisks}
 }
 
-func (a MigrateDiskAction) Run() (value interface{}, err error) {
-	err = a.platform.MigratePersistentDisk(a.dirProvider.StoreDir(), a.dirProvider.StoreMigrationDir())
-	if err != nil {
-		err = bosherr.WrapError(err, "Migrating persistent disk")
-		return
+// Steps of migrate_disk recorded with checkpoints
+const (
+	migrateDiskStepCopied    = "copied"
+	migrateDiskStepRemounted = "remounted"
+)
+
+func (a MigrateDiskAction) Run(checkpoints boshtask.Checkpoints) (value interface{}, err error) {
+	fromMountPoint := a.dirProvider.StoreDir()
+	toMountPoint := a.dirProvider.StoreMigrationDir()
+
+	if !checkpoints.IsCompleted(migrateDiskStepCopied) {
+		err = a.platform.CopyPersistentDisk(fromMountPoint, toMountPoint)
+		if err != nil {
+			err = bosherr.WrapError(err, "Copying persistent disk")
+			return
+		}
+
+		err = checkpoints.Complete(migrateDiskStepCopied)
+		if err != nil {
+			err = bosherr.WrapError(err, "Recording copied persistent disk")
+			return
+		}
+	}
+
+	if !checkpoints.IsCompleted(migrateDiskStepRemounted) {
+		err = a.platform.RemountPersistentDisk(fromMountPoint, toMountPoint)
+		if err != nil {
+			err = bosherr.WrapError(err, "Remounting persistent disk")
+			return
+		}
+
+		err = checkpoints.Complete(migrateDiskStepRemounted)
+		if err != nil {
+			err = bosherr.WrapError(err, "Recording remounted persistent disk")
+			return
+		}
 	}
 
 	value = map[string]string{}
 	return
 }
 
+// Resume is not used since runner resumes migrate_disk by running it again with its checkpoints
 func (a MigrateDiskAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
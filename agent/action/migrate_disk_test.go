@@ -1,10 +1,14 @@
 package action_test
 
 import (
+	"errors"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
@@ -23,21 +27,81 @@ func init() {
 			Expect(action.IsAsynchronous()).To(BeTrue())
 		})
 
-		It("is not persistent", func() {
+		It("is persistent so that it is finished after agent restart", func() {
+			_, action := buildMigrateDiskAction()
+			Expect(action.IsPersistent()).To(BeTrue())
+		})
+
+		It("modifies disks", func() {
 			_, action := buildMigrateDiskAction()
-			Expect(action.IsPersistent()).To(BeFalse())
+			Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceDisks}))
 		})
 
 		It("migrate disk action run", func() {
+			platform, action := buildMigrateDiskAction()
+
+			checkpoints := faketask.NewFakeCheckpoints()
+
+			value, err := action.Run(checkpoints)
+			Expect(err).ToNot(HaveOccurred())
+			boshassert.MatchesJSONString(GinkgoT(), value, "{}")
+
+			Expect(platform.CopyPersistentDiskFromMountPoint).To(Equal("/foo/store"))
+			Expect(platform.CopyPersistentDiskToMountPoint).To(Equal("/foo/store_migration_target"))
+			Expect(platform.RemountPersistentDiskFromMountPoint).To(Equal("/foo/store"))
+			Expect(platform.RemountPersistentDiskToMountPoint).To(Equal("/foo/store_migration_target"))
+			Expect(checkpoints.CompletedSteps).To(Equal([]string{"copied", "remounted"}))
+		})
+
+		It("migrate disk action returns error without remounting disk when copying fails", func() {
+			platform, action := buildMigrateDiskAction()
+			platform.CopyPersistentDiskErr = errors.New("fake-copy-err")
+
+			checkpoints := faketask.NewFakeCheckpoints()
+
+			_, err := action.Run(checkpoints)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-copy-err"))
+
+			Expect(platform.RemountPersistentDiskFromMountPoint).To(BeEmpty())
+			Expect(checkpoints.CompletedSteps).To(BeEmpty())
+		})
+
+		It("migrate disk action returns error when recording copied disk fails", func() {
+			platform, action := buildMigrateDiskAction()
+
+			checkpoints := faketask.NewFakeCheckpoints()
+			checkpoints.CompleteErr = errors.New("fake-complete-err")
+
+			_, err := action.Run(checkpoints)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-complete-err"))
+
+			Expect(platform.RemountPersistentDiskFromMountPoint).To(BeEmpty())
+		})
+
+		It("migrate disk action only remounts disk when resumed after copying", func() {
+			platform, action := buildMigrateDiskAction()
+
+			checkpoints := faketask.NewFakeCheckpoints("copied")
+
+			_, err := action.Run(checkpoints)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(platform.CopyPersistentDiskFromMountPoint).To(BeEmpty())
+			Expect(platform.RemountPersistentDiskFromMountPoint).To(Equal("/foo/store"))
+			Expect(checkpoints.CompletedSteps).To(Equal([]string{"copied", "remounted"}))
+		})
 
+		It("migrate disk action does not migrate again when resumed after migration", func() {
 			platform, action := buildMigrateDiskAction()
 
-			value, err := action.Run()
+			value, err := action.Run(faketask.NewFakeCheckpoints("copied", "remounted"))
 			Expect(err).ToNot(HaveOccurred())
 			boshassert.MatchesJSONString(GinkgoT(), value, "{}")
 
-			Expect(platform.MigratePersistentDiskFromMountPoint).To(Equal("/foo/store"))
-			Expect(platform.MigratePersistentDiskToMountPoint).To(Equal("/foo/store_migration_target"))
+			Expect(platform.CopyPersistentDiskFromMountPoint).To(BeEmpty())
+			Expect(platform.RemountPersistentDiskFromMountPoint).To(BeEmpty())
 		})
 	})
 }
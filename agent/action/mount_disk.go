@@ -3,6 +3,7 @@ package action
 import (
 	"errors"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -40,11 +41,30 @@ func (a MountDiskAction) IsAsynchronous() bool {
 	return true
 }
 
+// IsPersistent is true so that disk mounted right before agent restart
+// is not mounted again under the migration directory
 func (a MountDiskAction) IsPersistent() bool {
-	return false
+	return true
+}
+
+func (a MountDiskAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceDisks}
 }
 
-func (a MountDiskAction) Run(diskCid string) (interface{}, error) {
+// Steps of mount_disk recorded with checkpoints
+const (
+	// Mount point is recorded before mounting since once disk is mounted
+	// store dir is a mount point and resumed mount_disk would pick migration dir
+	mountDiskStepStoreDirSelected     = "store_dir_selected"
+	mountDiskStepMigrationDirSelected = "migration_dir_selected"
+	mountDiskStepMounted              = "mounted"
+)
+
+func (a MountDiskAction) Run(checkpoints boshtask.Checkpoints, diskCid string) (interface{}, error) {
+	if checkpoints.IsCompleted(mountDiskStepMounted) {
+		return map[string]string{}, nil
+	}
+
 	err := a.settingsService.LoadSettings()
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Refreshing the settings")
@@ -57,24 +77,55 @@ func (a MountDiskAction) Run(diskCid string) (interface{}, error) {
 		return nil, bosherr.Errorf("Persistent disk with volume id '%s' could not be found", diskCid)
 	}
 
+	mountPoint, err := a.selectMountPoint(checkpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.diskMounter.MountPersistentDisk(diskSettings, mountPoint)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Mounting persistent disk")
+	}
+
+	err = checkpoints.Complete(mountDiskStepMounted)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Recording mounted persistent disk")
+	}
+
+	return map[string]string{}, nil
+}
+
+// selectMountPoint picks store dir unless it is already used by another disk
+// in which case new disk is mounted at migration dir until migrate_disk
+func (a MountDiskAction) selectMountPoint(checkpoints boshtask.Checkpoints) (string, error) {
+	switch {
+	case checkpoints.IsCompleted(mountDiskStepStoreDirSelected):
+		return a.dirProvider.StoreDir(), nil
+	case checkpoints.IsCompleted(mountDiskStepMigrationDirSelected):
+		return a.dirProvider.StoreMigrationDir(), nil
+	}
+
 	mountPoint := a.dirProvider.StoreDir()
+	step := mountDiskStepStoreDirSelected
 
 	isMountPoint, err := a.mountPoints.IsMountPoint(mountPoint)
 	if err != nil {
-		return nil, bosherr.WrapError(err, "Checking mount point")
+		return "", bosherr.WrapError(err, "Checking mount point")
 	}
 	if isMountPoint {
 		mountPoint = a.dirProvider.StoreMigrationDir()
+		step = mountDiskStepMigrationDirSelected
 	}
 
-	err = a.diskMounter.MountPersistentDisk(diskSettings, mountPoint)
+	err = checkpoints.Complete(step)
 	if err != nil {
-		return nil, bosherr.WrapError(err, "Mounting persistent disk")
+		return "", bosherr.WrapError(err, "Recording selected mount point")
 	}
 
-	return map[string]string{}, nil
+	return mountPoint, nil
 }
 
+// Resume is not used since runner resumes mount_disk by running it again with its checkpoints
 func (a MountDiskAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
@@ -7,6 +7,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -17,12 +19,14 @@ var _ = Describe("MountDiskAction", func() {
 	var (
 		settingsService *fakesettings.FakeSettingsService
 		platform        *fakeplatform.FakePlatform
+		checkpoints     *faketask.FakeCheckpoints
 		action          MountDiskAction
 	)
 
 	BeforeEach(func() {
 		settingsService = &fakesettings.FakeSettingsService{}
 		platform = fakeplatform.NewFakePlatform()
+		checkpoints = faketask.NewFakeCheckpoints()
 		dirProvider := boshdirs.NewProvider("/fake-base-dir")
 		action = NewMountDisk(settingsService, platform, platform, dirProvider)
 	})
@@ -31,8 +35,12 @@ var _ = Describe("MountDiskAction", func() {
 		Expect(action.IsAsynchronous()).To(BeTrue())
 	})
 
-	It("is not persistent", func() {
-		Expect(action.IsPersistent()).To(BeFalse())
+	It("is persistent so that it is finished after agent restart", func() {
+		Expect(action.IsPersistent()).To(BeTrue())
+	})
+
+	It("modifies disks", func() {
+		Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceDisks}))
 	})
 
 	Describe("Run", func() {
@@ -48,7 +56,7 @@ var _ = Describe("MountDiskAction", func() {
 				})
 
 				It("checks if store directory is already mounted", func() {
-					_, err := action.Run("fake-disk-cid")
+					_, err := action.Run(checkpoints, "fake-disk-cid")
 					Expect(err).NotTo(HaveOccurred())
 					Expect(platform.IsMountPointPath).To(Equal("/fake-base-dir/store"))
 				})
@@ -60,7 +68,7 @@ var _ = Describe("MountDiskAction", func() {
 
 					Context("when mounting succeeds", func() {
 						It("returns without an error after mounting store directory", func() {
-							result, err := action.Run("fake-disk-cid")
+							result, err := action.Run(checkpoints, "fake-disk-cid")
 							Expect(err).NotTo(HaveOccurred())
 							Expect(result).To(Equal(map[string]string{}))
 
@@ -71,13 +79,28 @@ var _ = Describe("MountDiskAction", func() {
 							}))
 							Expect(platform.MountPersistentDiskMountPoint).To(Equal("/fake-base-dir/store"))
 						})
+
+						It("records selected mount point and that disk was mounted", func() {
+							_, err := action.Run(checkpoints, "fake-disk-cid")
+							Expect(err).NotTo(HaveOccurred())
+							Expect(checkpoints.CompletedSteps).To(Equal([]string{"store_dir_selected", "mounted"}))
+						})
+
+						It("returns error without mounting disk when recording selected mount point fails", func() {
+							checkpoints.CompleteErr = errors.New("fake-complete-err")
+
+							_, err := action.Run(checkpoints, "fake-disk-cid")
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("fake-complete-err"))
+							Expect(platform.MountPersistentDiskCalled).To(BeFalse())
+						})
 					})
 
 					Context("when mounting fails", func() {
 						It("returns error after trying to mount store directory", func() {
 							platform.MountPersistentDiskErr = errors.New("fake-mount-persistent-disk-err")
 
-							_, err := action.Run("fake-disk-cid")
+							_, err := action.Run(checkpoints, "fake-disk-cid")
 							Expect(err).To(HaveOccurred())
 							Expect(err.Error()).To(ContainSubstring("fake-mount-persistent-disk-err"))
 						})
@@ -91,7 +114,7 @@ var _ = Describe("MountDiskAction", func() {
 
 					Context("when mounting succeeds", func() {
 						It("returns without an error after mounting store migration directory", func() {
-							result, err := action.Run("fake-disk-cid")
+							result, err := action.Run(checkpoints, "fake-disk-cid")
 							Expect(err).NotTo(HaveOccurred())
 							Expect(result).To(Equal(map[string]string{}))
 
@@ -102,13 +125,19 @@ var _ = Describe("MountDiskAction", func() {
 							}))
 							Expect(platform.MountPersistentDiskMountPoint).To(Equal("/fake-base-dir/store_migration_target"))
 						})
+
+						It("records selected mount point and that disk was mounted", func() {
+							_, err := action.Run(checkpoints, "fake-disk-cid")
+							Expect(err).NotTo(HaveOccurred())
+							Expect(checkpoints.CompletedSteps).To(Equal([]string{"migration_dir_selected", "mounted"}))
+						})
 					})
 
 					Context("when mounting fails", func() {
 						It("returns error after trying to mount store migration directory", func() {
 							platform.MountPersistentDiskErr = errors.New("fake-mount-persistent-disk-err")
 
-							_, err := action.Run("fake-disk-cid")
+							_, err := action.Run(checkpoints, "fake-disk-cid")
 							Expect(err).To(HaveOccurred())
 							Expect(err.Error()).To(ContainSubstring("fake-mount-persistent-disk-err"))
 						})
@@ -121,13 +150,13 @@ var _ = Describe("MountDiskAction", func() {
 					})
 
 					It("returns error", func() {
-						_, err := action.Run("fake-disk-cid")
+						_, err := action.Run(checkpoints, "fake-disk-cid")
 						Expect(err).To(HaveOccurred())
 						Expect(err.Error()).To(ContainSubstring("fake-is-mount-point-err"))
 					})
 
 					It("does not try to mount disk", func() {
-						_, err := action.Run("fake-disk-cid")
+						_, err := action.Run(checkpoints, "fake-disk-cid")
 						Expect(err).To(HaveOccurred())
 						Expect(platform.MountPersistentDiskCalled).To(BeFalse())
 					})
@@ -142,18 +171,61 @@ var _ = Describe("MountDiskAction", func() {
 				})
 
 				It("returns error", func() {
-					_, err := action.Run("fake-unknown-disk-cid")
+					_, err := action.Run(checkpoints, "fake-unknown-disk-cid")
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(Equal("Persistent disk with volume id 'fake-unknown-disk-cid' could not be found"))
 				})
 			})
 		})
 
+		Context("when mount disk is resumed after mount point was selected", func() {
+			BeforeEach(func() {
+				settingsService.Settings.Disks.Persistent = map[string]interface{}{
+					"fake-disk-cid": "fake-device-path",
+				}
+			})
+
+			It("mounts disk at store directory even though it may already be mounted there", func() {
+				checkpoints.CompletedSteps = []string{"store_dir_selected"}
+				platform.IsMountPointResult = true
+
+				_, err := action.Run(checkpoints, "fake-disk-cid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(platform.MountPersistentDiskMountPoint).To(Equal("/fake-base-dir/store"))
+				Expect(checkpoints.CompletedSteps).To(Equal([]string{"store_dir_selected", "mounted"}))
+			})
+
+			It("mounts disk at store migration directory", func() {
+				checkpoints.CompletedSteps = []string{"migration_dir_selected"}
+				platform.IsMountPointResult = false
+
+				_, err := action.Run(checkpoints, "fake-disk-cid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(platform.MountPersistentDiskMountPoint).To(Equal("/fake-base-dir/store_migration_target"))
+			})
+		})
+
+		Context("when mount disk is resumed after disk was mounted", func() {
+			BeforeEach(func() {
+				checkpoints.CompletedSteps = []string{"mounted"}
+			})
+
+			It("does not mount disk again under store migration directory", func() {
+				platform.IsMountPointResult = true
+
+				result, err := action.Run(checkpoints, "fake-disk-cid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(map[string]string{}))
+
+				Expect(platform.MountPersistentDiskCalled).To(BeFalse())
+			})
+		})
+
 		Context("when settings cannot be loaded", func() {
 			It("returns error", func() {
 				settingsService.LoadSettingsError = errors.New("fake-load-settings-err")
 
-				_, err := action.Run("fake-disk-cid")
+				_, err := action.Run(checkpoints, "fake-disk-cid")
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-load-settings-err"))
 			})
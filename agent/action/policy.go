@@ -0,0 +1,98 @@
+package action
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Options struct {
+	// When set only listed actions can be run
+	// (e.g. ["get_state", "list_disk"] for read-only VMs)
+	AllowedActions []string
+
+	// Listed actions can never be run (e.g. ["ssh", "run_errand"]);
+	// takes precedence over AllowedActions
+	DeniedActions []string
+}
+
+// Actions that are needed to talk to the agent at all are never disabled
+var alwaysAllowedActions = []string{"ping", "get_task", "get_capabilities"}
+
+// DisabledErrorCode is included in exception responses for disabled actions
+const DisabledErrorCode = "action_disabled"
+
+type DisabledError struct {
+	Method string
+}
+
+func (e DisabledError) Error() string {
+	return fmt.Sprintf("Action %s is disabled by policy", e.Method)
+}
+
+func (e DisabledError) Code() string {
+	return DisabledErrorCode
+}
+
+type Policy struct {
+	// Nil means that all actions that are not denied are allowed
+	allowedActions map[string]struct{}
+	deniedActions  map[string]struct{}
+}
+
+func NewPolicy(options Options) Policy {
+	policy := Policy{
+		deniedActions: stringSet(options.DeniedActions),
+	}
+
+	if options.AllowedActions != nil {
+		policy.allowedActions = stringSet(options.AllowedActions)
+	}
+
+	return policy
+}
+
+func (p Policy) IsAllowed(method string) bool {
+	for _, alwaysAllowedAction := range alwaysAllowedActions {
+		if method == alwaysAllowedAction {
+			return true
+		}
+	}
+
+	if _, denied := p.deniedActions[method]; denied {
+		return false
+	}
+
+	if p.allowedActions != nil {
+		_, allowed := p.allowedActions[method]
+		return allowed
+	}
+
+	return true
+}
+
+// Partition splits given actions into allowed and disabled ones, both sorted by name
+func (p Policy) Partition(methods []string) (allowed, disabled []string) {
+	allowed = []string{}
+	disabled = []string{}
+
+	for _, method := range methods {
+		if p.IsAllowed(method) {
+			allowed = append(allowed, method)
+		} else {
+			disabled = append(disabled, method)
+		}
+	}
+
+	sort.Strings(allowed)
+	sort.Strings(disabled)
+
+	return allowed, disabled
+}
+
+func stringSet(strs []string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, str := range strs {
+		set[str] = struct{}{}
+	}
+	return set
+}
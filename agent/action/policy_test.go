@@ -0,0 +1,65 @@
+package action_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+)
+
+var _ = Describe("Policy", func() {
+	Describe("IsAllowed", func() {
+		It("allows all actions by default", func() {
+			policy := NewPolicy(Options{})
+			Expect(policy.IsAllowed("ssh")).To(BeTrue())
+		})
+
+		It("allows only listed actions when allowed actions are set", func() {
+			policy := NewPolicy(Options{AllowedActions: []string{"get_state"}})
+			Expect(policy.IsAllowed("get_state")).To(BeTrue())
+			Expect(policy.IsAllowed("ssh")).To(BeFalse())
+		})
+
+		It("allows no other actions when allowed actions are empty", func() {
+			policy := NewPolicy(Options{AllowedActions: []string{}})
+			Expect(policy.IsAllowed("get_state")).To(BeFalse())
+		})
+
+		It("does not allow denied actions even if they are allowed", func() {
+			policy := NewPolicy(Options{
+				AllowedActions: []string{"ssh"},
+				DeniedActions:  []string{"ssh", "run_errand"},
+			})
+			Expect(policy.IsAllowed("ssh")).To(BeFalse())
+			Expect(policy.IsAllowed("run_errand")).To(BeFalse())
+		})
+
+		It("always allows actions needed to talk to agent", func() {
+			policy := NewPolicy(Options{
+				AllowedActions: []string{},
+				DeniedActions:  []string{"ping", "get_task", "get_capabilities"},
+			})
+			Expect(policy.IsAllowed("ping")).To(BeTrue())
+			Expect(policy.IsAllowed("get_task")).To(BeTrue())
+			Expect(policy.IsAllowed("get_capabilities")).To(BeTrue())
+		})
+	})
+
+	Describe("Partition", func() {
+		It("returns sorted allowed and disabled actions", func() {
+			policy := NewPolicy(Options{DeniedActions: []string{"ssh", "run_errand"}})
+
+			allowed, disabled := policy.Partition([]string{"ssh", "get_state", "run_errand", "apply"})
+			Expect(allowed).To(Equal([]string{"apply", "get_state"}))
+			Expect(disabled).To(Equal([]string{"run_errand", "ssh"}))
+		})
+	})
+})
+
+var _ = Describe("DisabledError", func() {
+	It("names the action and has a code", func() {
+		err := DisabledError{Method: "ssh"}
+		Expect(err.Error()).To(Equal("Action ssh is disabled by policy"))
+		Expect(err.Code()).To(Equal("action_disabled"))
+	})
+})
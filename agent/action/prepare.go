@@ -5,6 +5,7 @@ import (
 
 	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
@@ -25,6 +26,10 @@ func (a PrepareAction) IsPersistent() bool {
 	return false
 }
 
+func (a PrepareAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceJobs}
+}
+
 func (a PrepareAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
 	err := a.applier.Prepare(desiredSpec)
 	if err != nil {
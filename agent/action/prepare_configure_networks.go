@@ -3,6 +3,7 @@ package action
 import (
 	"errors"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -31,6 +32,10 @@ func (a PrepareConfigureNetworksAction) IsPersistent() bool {
 	return false
 }
 
+func (a PrepareConfigureNetworksAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceNetwork}
+}
+
 func (a PrepareConfigureNetworksAction) Run() (string, error) {
 	err := a.settingsService.InvalidateSettings()
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"os"
 	"time"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -34,6 +35,10 @@ func (a PrepareNetworkChangeAction) IsPersistent() bool {
 	return false
 }
 
+func (a PrepareNetworkChangeAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceNetwork}
+}
+
 func (a PrepareNetworkChangeAction) Run() (interface{}, error) {
 	err := a.settingsService.InvalidateSettings()
 	if err != nil {
@@ -9,6 +9,7 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeappl "github.com/cloudfoundry/bosh-agent/agent/applier/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 var _ = Describe("PrepareAction", func() {
@@ -30,6 +31,10 @@ var _ = Describe("PrepareAction", func() {
 		Expect(action.IsPersistent()).To(BeFalse())
 	})
 
+	It("modifies jobs", func() {
+		Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceJobs}))
+	})
+
 	Describe("Run", func() {
 		desiredApplySpec := boshas.V1ApplySpec{ConfigurationHash: "fake-desired-config-hash"}
 
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
@@ -25,6 +26,10 @@ func (a ReleaseApplySpecAction) IsPersistent() bool {
 	return false
 }
 
+func (a ReleaseApplySpecAction) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a ReleaseApplySpecAction) Run() (value interface{}, err error) {
 	fs := a.platform.GetFs()
 	specBytes, err := fs.ReadFile("/var/vcap/micro/apply_spec.json")
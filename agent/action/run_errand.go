@@ -1,17 +1,27 @@
 package action
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"path/filepath"
 	"time"
+	"unicode/utf8"
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-const runErrandActionLogTag = "runErrandAction"
+const (
+	runErrandActionLogTag = "runErrandAction"
+
+	// Only the end of errand output is returned once errand exits
+	// since whole output was already reported line by line while it ran
+	errandOutputTruncateLength = 10 * 1024
+)
 
 type RunErrandAction struct {
 	specService boshas.V1Service
@@ -48,13 +58,19 @@ func (a RunErrandAction) IsPersistent() bool {
 	return false
 }
 
+func (a RunErrandAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceJobs}
+}
+
 type ErrandResult struct {
 	Stdout     string `json:"stdout"`
 	Stderr     string `json:"stderr"`
 	ExitStatus int    `json:"exit_code"`
 }
 
-func (a RunErrandAction) Run() (ErrandResult, error) {
+// Run reports every line errand script prints while it is running
+// and returns end of output once script exits
+func (a RunErrandAction) Run(reporter boshtask.ProgressReporter) (ErrandResult, error) {
 	currentSpec, err := a.specService.Get()
 	if err != nil {
 		return ErrandResult{}, bosherr.WrapError(err, "Getting current spec")
@@ -64,11 +80,19 @@ func (a RunErrandAction) Run() (ErrandResult, error) {
 		return ErrandResult{}, bosherr.Error("At least one job template is required to run an errand")
 	}
 
+	stdout := &truncatingBuffer{limit: errandOutputTruncateLength}
+	stderr := &truncatingBuffer{limit: errandOutputTruncateLength}
+
+	stdoutWriter := boshtask.NewOutputWriter(reporter, boshtask.OutputStdout)
+	stderrWriter := boshtask.NewOutputWriter(reporter, boshtask.OutputStderr)
+
 	command := boshsys.Command{
 		Name: filepath.Join(a.jobsDir, currentSpec.JobSpec.Template, "bin", "run"),
 		Env: map[string]string{
 			"PATH": "/usr/sbin:/usr/bin:/sbin:/bin",
 		},
+		Stdout: io.MultiWriter(stdout, stdoutWriter),
+		Stderr: io.MultiWriter(stderr, stderrWriter),
 	}
 
 	process, err := a.cmdRunner.RunComplexCommandAsync(command)
@@ -92,13 +116,16 @@ func (a RunErrandAction) Run() (ErrandResult, error) {
 		}
 	}
 
+	stdoutWriter.Close()
+	stderrWriter.Close()
+
 	if result.Error != nil && result.ExitStatus == -1 {
 		return ErrandResult{}, bosherr.WrapError(result.Error, "Running errand script")
 	}
 
 	return ErrandResult{
-		Stdout:     result.Stdout,
-		Stderr:     result.Stderr,
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
 		ExitStatus: result.ExitStatus,
 	}, nil
 }
@@ -126,3 +153,43 @@ func (a RunErrandAction) Cancel() error {
 	}
 	return nil
 }
+
+// truncatingBuffer keeps only last limit bytes written to it
+type truncatingBuffer struct {
+	limit     int
+	data      []byte
+	truncated bool
+}
+
+func (b *truncatingBuffer) Write(data []byte) (int, error) {
+	b.data = append(b.data, data...)
+
+	if overflow := len(b.data) - b.limit; overflow > 0 {
+		b.data = b.data[:copy(b.data, b.data[overflow:])]
+		b.truncated = true
+	}
+
+	return len(data), nil
+}
+
+// String cuts off partial first line of truncated output
+// the same way as FileLoggingCmdRunner does
+func (b *truncatingBuffer) String() string {
+	data := b.data
+
+	if !b.truncated {
+		return string(data)
+	}
+
+	// Do not drop more than 25% of kept output
+	if i := bytes.IndexByte(data, '\n'); i >= 0 && i <= b.limit/4 {
+		return string(data[i+1:])
+	}
+
+	// Do not start inside UTF encoded rune
+	for len(data) > 0 && !utf8.RuneStart(data[0]) {
+		data = data[1:]
+	}
+
+	return string(data)
+}
@@ -2,6 +2,7 @@ package action_test
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -10,21 +11,47 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
+// printingCmdRunner prints given output to writers of started commands
+// like real runner does while process is running
+type printingCmdRunner struct {
+	*fakesys.FakeCmdRunner
+
+	Stdout string
+	Stderr string
+}
+
+func (r *printingCmdRunner) RunComplexCommandAsync(cmd boshsys.Command) (boshsys.Process, error) {
+	process, err := r.FakeCmdRunner.RunComplexCommandAsync(cmd)
+
+	cmd.Stdout.Write([]byte(r.Stdout))
+	cmd.Stderr.Write([]byte(r.Stderr))
+
+	return process, err
+}
+
 var _ = Describe("RunErrand", func() {
 	var (
 		specService *fakeas.FakeV1Service
-		cmdRunner   *fakesys.FakeCmdRunner
+		cmdRunner   *printingCmdRunner
+		reporter    *faketask.FakeProgressReporter
 		action      RunErrandAction
 	)
 
 	BeforeEach(func() {
 		specService = fakeas.NewFakeV1Service()
-		cmdRunner = fakesys.NewFakeCmdRunner()
+		cmdRunner = &printingCmdRunner{
+			FakeCmdRunner: fakesys.NewFakeCmdRunner(),
+			Stdout:        "fake-stdout",
+			Stderr:        "fake-stderr",
+		}
+		reporter = faketask.NewFakeProgressReporter()
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 		action = NewRunErrand(specService, "/fake-jobs-dir", cmdRunner, logger)
 	})
@@ -37,6 +64,10 @@ var _ = Describe("RunErrand", func() {
 		Expect(action.IsPersistent()).To(BeFalse())
 	})
 
+	It("modifies jobs", func() {
+		Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceJobs}))
+	})
+
 	Describe("Run", func() {
 		Context("when apply spec is successfully retrieved", func() {
 			Context("when current agent has a job spec template", func() {
@@ -50,15 +81,13 @@ var _ = Describe("RunErrand", func() {
 					BeforeEach(func() {
 						cmdRunner.AddProcess("/fake-jobs-dir/fake-job-name/bin/run", &fakesys.FakeProcess{
 							WaitResult: boshsys.Result{
-								Stdout:     "fake-stdout",
-								Stderr:     "fake-stderr",
 								ExitStatus: 0,
 							},
 						})
 					})
 
 					It("returns errand result without error after running an errand", func() {
-						result, err := action.Run()
+						result, err := action.Run(reporter)
 						Expect(err).ToNot(HaveOccurred())
 						Expect(result).To(Equal(
 							ErrandResult{
@@ -70,25 +99,48 @@ var _ = Describe("RunErrand", func() {
 					})
 
 					It("runs errand script with properly configured environment", func() {
-						_, err := action.Run()
+						_, err := action.Run(reporter)
 						Expect(err).ToNot(HaveOccurred())
-						Expect(cmdRunner.RunComplexCommands).To(Equal([]boshsys.Command{
-							boshsys.Command{
-								Name: "/fake-jobs-dir/fake-job-name/bin/run",
-								Env: map[string]string{
-									"PATH": "/usr/sbin:/usr/bin:/sbin:/bin",
-								},
-							},
+						Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+						Expect(cmdRunner.RunComplexCommands[0].Name).To(Equal("/fake-jobs-dir/fake-job-name/bin/run"))
+						Expect(cmdRunner.RunComplexCommands[0].Env).To(Equal(map[string]string{
+							"PATH": "/usr/sbin:/usr/bin:/sbin:/bin",
 						}))
 					})
+
+					It("reports output lines while errand script is running", func() {
+						cmdRunner.Stdout = "fake-stdout-1\nfake-stdout-2\n"
+						cmdRunner.Stderr = "fake-stderr-1\nfake-stderr-2"
+
+						result, err := action.Run(reporter)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(reporter.OutputLines(boshtask.OutputStdout)).To(Equal([]string{"fake-stdout-1", "fake-stdout-2"}))
+						Expect(reporter.OutputLines(boshtask.OutputStderr)).To(Equal([]string{"fake-stderr-1", "fake-stderr-2"}))
+
+						Expect(result.Stdout).To(Equal("fake-stdout-1\nfake-stdout-2\n"))
+						Expect(result.Stderr).To(Equal("fake-stderr-1\nfake-stderr-2"))
+					})
+
+					It("returns only the end of long output starting at line break", func() {
+						cmdRunner.Stdout = strings.Repeat("fake-dropped\n", 1000) + strings.Repeat("x", 10*1024-20) + "\nfake-last\n"
+						cmdRunner.Stderr = strings.Repeat("y", 20*1024)
+
+						result, err := action.Run(reporter)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(result.Stdout).To(Equal(strings.Repeat("x", 10*1024-20) + "\nfake-last\n"))
+						Expect(result.Stderr).To(Equal(strings.Repeat("y", 10*1024)))
+
+						// Whole output was reported while errand was running
+						Expect(reporter.OutputLines(boshtask.OutputStdout)).To(HaveLen(1002))
+					})
 				})
 
 				Context("when errand script fails with non-0 exit code (execution of script is ok)", func() {
 					BeforeEach(func() {
 						cmdRunner.AddProcess("/fake-jobs-dir/fake-job-name/bin/run", &fakesys.FakeProcess{
 							WaitResult: boshsys.Result{
-								Stdout:     "fake-stdout",
-								Stderr:     "fake-stderr",
 								ExitStatus: 123,
 								Error:      errors.New("fake-bosh-error"), // not used
 							},
@@ -96,7 +148,7 @@ var _ = Describe("RunErrand", func() {
 					})
 
 					It("returns errand result without an error", func() {
-						result, err := action.Run()
+						result, err := action.Run(reporter)
 						Expect(err).ToNot(HaveOccurred())
 						Expect(result).To(Equal(
 							ErrandResult{
@@ -119,7 +171,7 @@ var _ = Describe("RunErrand", func() {
 					})
 
 					It("returns error because script failed to execute", func() {
-						result, err := action.Run()
+						result, err := action.Run(reporter)
 						Expect(err).To(HaveOccurred())
 						Expect(err.Error()).To(ContainSubstring("fake-bosh-error"))
 						Expect(result).To(Equal(ErrandResult{}))
@@ -133,13 +185,13 @@ var _ = Describe("RunErrand", func() {
 				})
 
 				It("returns error stating that job template is required", func() {
-					_, err := action.Run()
+					_, err := action.Run(reporter)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(Equal("At least one job template is required to run an errand"))
 				})
 
 				It("does not run errand script", func() {
-					_, err := action.Run()
+					_, err := action.Run(reporter)
 					Expect(err).To(HaveOccurred())
 					Expect(len(cmdRunner.RunComplexCommands)).To(Equal(0))
 				})
@@ -152,13 +204,13 @@ var _ = Describe("RunErrand", func() {
 			})
 
 			It("returns error stating that job template is required", func() {
-				_, err := action.Run()
+				_, err := action.Run(reporter)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-get-error"))
 			})
 
 			It("does not run errand script", func() {
-				_, err := action.Run()
+				_, err := action.Run(reporter)
 				Expect(err).To(HaveOccurred())
 				Expect(len(cmdRunner.RunComplexCommands)).To(Equal(0))
 			})
@@ -177,8 +229,6 @@ var _ = Describe("RunErrand", func() {
 				process := &fakesys.FakeProcess{
 					TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
 						p.WaitCh <- boshsys.Result{
-							Stdout:     "fake-stdout",
-							Stderr:     "fake-stderr",
 							ExitStatus: 0,
 						}
 					},
@@ -189,7 +239,7 @@ var _ = Describe("RunErrand", func() {
 				err := action.Cancel()
 				Expect(err).ToNot(HaveOccurred())
 
-				_, err = action.Run()
+				_, err = action.Run(reporter)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(process.TerminateNicelyKillGracePeriod).To(Equal(10 * time.Second))
@@ -200,8 +250,6 @@ var _ = Describe("RunErrand", func() {
 					cmdRunner.AddProcess("/fake-jobs-dir/fake-job-name/bin/run", &fakesys.FakeProcess{
 						TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
 							p.WaitCh <- boshsys.Result{
-								Stdout:     "fake-stdout",
-								Stderr:     "fake-stderr",
 								ExitStatus: 0,
 							}
 						},
@@ -212,7 +260,7 @@ var _ = Describe("RunErrand", func() {
 					err := action.Cancel()
 					Expect(err).ToNot(HaveOccurred())
 
-					result, err := action.Run()
+					result, err := action.Run(reporter)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(result).To(Equal(
 						ErrandResult{
@@ -229,8 +277,6 @@ var _ = Describe("RunErrand", func() {
 					cmdRunner.AddProcess("/fake-jobs-dir/fake-job-name/bin/run", &fakesys.FakeProcess{
 						TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
 							p.WaitCh <- boshsys.Result{
-								Stdout:     "fake-stdout",
-								Stderr:     "fake-stderr",
 								ExitStatus: 123,
 								Error:      errors.New("fake-bosh-error"), // not used
 							}
@@ -242,7 +288,7 @@ var _ = Describe("RunErrand", func() {
 					err := action.Cancel()
 					Expect(err).ToNot(HaveOccurred())
 
-					result, err := action.Run()
+					result, err := action.Run(reporter)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(result).To(Equal(
 						ErrandResult{
@@ -270,7 +316,7 @@ var _ = Describe("RunErrand", func() {
 					err := action.Cancel()
 					Expect(err).ToNot(HaveOccurred())
 
-					result, err := action.Run()
+					result, err := action.Run(reporter)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-bosh-error"))
 					Expect(result).To(Equal(ErrandResult{}))
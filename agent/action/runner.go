@@ -3,53 +3,86 @@ package action
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"reflect"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
+// Runner calls action's Run method with arguments taken from the payload.
+// If the first argument of Run is a boshtask.ProgressReporter
+// given reporter is passed in its place; if the next one is boshtask.Checkpoints
+// given checkpoints are passed in its place.
 type Runner interface {
-	Run(action Action, payload []byte) (value interface{}, err error)
-	Resume(action Action, payload []byte) (value interface{}, err error)
+	Run(action Action, payload []byte, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints) (value interface{}, err error)
+
+	// Resume continues persistent action after agent restart.
+	// Actions that take checkpoints are run again with the same payload
+	// and skip completed steps; other actions are resumed with their Resume method.
+	Resume(action Action, payload []byte, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints) (value interface{}, err error)
 }
 
+var (
+	progressReporterType = reflect.TypeOf((*boshtask.ProgressReporter)(nil)).Elem()
+	checkpointsType      = reflect.TypeOf((*boshtask.Checkpoints)(nil)).Elem()
+)
+
 func NewRunner() Runner {
 	return concreteRunner{}
 }
 
 type concreteRunner struct{}
 
-func (r concreteRunner) Run(action Action, payloadBytes []byte) (value interface{}, err error) {
+func (r concreteRunner) Run(action Action, payloadBytes []byte, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints) (value interface{}, err error) {
 	payloadArgs, err := r.extractJSONArguments(payloadBytes)
 	if err != nil {
 		err = bosherr.WrapError(err, "Extracting json arguments")
 		return
 	}
 
-	actionValue := reflect.ValueOf(action)
-	runMethodValue := actionValue.MethodByName("Run")
-	if runMethodValue.Kind() != reflect.Func {
-		err = bosherr.Error("Run method not found")
+	runMethodValue, err := runMethod(action)
+	if err != nil {
 		return
 	}
 
 	runMethodType := runMethodValue.Type()
-	if r.invalidReturnTypes(runMethodType) {
-		err = bosherr.Error("Run method should return a value and an error")
-		return
+
+	var methodArgs []reflect.Value
+	var firstPayloadArg int
+
+	if takesProgressReporter(runMethodType) {
+		if reporter == nil {
+			reporter = noopProgressReporter{}
+		}
+		methodArgs = append(methodArgs, reflect.ValueOf(reporter))
+		firstPayloadArg = 1
+	}
+
+	if takesCheckpoints(runMethodType) {
+		if checkpoints == nil {
+			checkpoints = noopCheckpoints{}
+		}
+		methodArgs = append(methodArgs, reflect.ValueOf(checkpoints))
+		firstPayloadArg++
 	}
 
-	methodArgs, err := r.extractMethodArgs(runMethodType, payloadArgs)
+	payloadMethodArgs, err := r.extractMethodArgs(runMethodType, firstPayloadArg, payloadArgs)
 	if err != nil {
 		err = bosherr.WrapError(err, "Extracting method arguments from payload")
 		return
 	}
 
-	values := runMethodValue.Call(methodArgs)
+	values := runMethodValue.Call(append(methodArgs, payloadMethodArgs...))
 	return r.extractReturns(values)
 }
 
-func (r concreteRunner) Resume(action Action, payloadBytes []byte) (value interface{}, err error) {
+func (r concreteRunner) Resume(action Action, payloadBytes []byte, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints) (value interface{}, err error) {
+	runMethodValue, err := runMethod(action)
+	if err == nil && takesCheckpoints(runMethodValue.Type()) {
+		return r.Run(action, payloadBytes, reporter, checkpoints)
+	}
+
 	return action.Resume()
 }
 
@@ -69,7 +102,48 @@ func (r concreteRunner) extractJSONArguments(payloadBytes []byte) (args []interf
 	return
 }
 
-func (r concreteRunner) invalidReturnTypes(methodType reflect.Type) (valid bool) {
+func runMethod(action Action) (reflect.Value, error) {
+	runMethodValue := reflect.ValueOf(action).MethodByName("Run")
+	if runMethodValue.Kind() != reflect.Func {
+		return reflect.Value{}, bosherr.Error("Run method not found")
+	}
+
+	if invalidReturnTypes(runMethodValue.Type()) {
+		return reflect.Value{}, bosherr.Error("Run method should return a value and an error")
+	}
+
+	return runMethodValue, nil
+}
+
+func takesProgressReporter(runMethodType reflect.Type) bool {
+	return runMethodType.NumIn() > 0 && runMethodType.In(0) == progressReporterType
+}
+
+func takesCheckpoints(runMethodType reflect.Type) bool {
+	index := 0
+	if takesProgressReporter(runMethodType) {
+		index = 1
+	}
+
+	return runMethodType.NumIn() > index && runMethodType.In(index) == checkpointsType
+}
+
+// injectedArgsCount is the number of Run arguments that are not taken from the payload
+func injectedArgsCount(runMethodType reflect.Type) int {
+	count := 0
+
+	if takesProgressReporter(runMethodType) {
+		count++
+	}
+
+	if takesCheckpoints(runMethodType) {
+		count++
+	}
+
+	return count
+}
+
+func invalidReturnTypes(methodType reflect.Type) (valid bool) {
 	if methodType.NumOut() != 2 {
 		return true
 	}
@@ -88,8 +162,8 @@ func (r concreteRunner) invalidReturnTypes(methodType reflect.Type) (valid bool)
 	return
 }
 
-func (r concreteRunner) extractMethodArgs(runMethodType reflect.Type, args []interface{}) (methodArgs []reflect.Value, err error) {
-	numberOfArgs := runMethodType.NumIn()
+func (r concreteRunner) extractMethodArgs(runMethodType reflect.Type, firstArg int, args []interface{}) (methodArgs []reflect.Value, err error) {
+	numberOfArgs := runMethodType.NumIn() - firstArg
 	numberOfReqArgs := numberOfArgs
 
 	if runMethodType.IsVariadic() {
@@ -97,10 +171,28 @@ func (r concreteRunner) extractMethodArgs(runMethodType reflect.Type, args []int
 	}
 
 	if len(args) < numberOfReqArgs {
-		err = bosherr.Errorf("Not enough arguments, expected %d, got %d", numberOfReqArgs, len(args))
+		if runMethodType.IsVariadic() {
+			err = bosherr.Errorf("Not enough arguments, expected at least %d, got %d", numberOfReqArgs, len(args))
+		} else {
+			err = bosherr.Errorf("Not enough arguments, expected %d, got %d", numberOfReqArgs, len(args))
+		}
 		return
 	}
 
+	// Arguments are validated up front so that error points to the offending value
+	// instead of coming out of json unmarshalling; extra arguments are ignored.
+	for i, argFromPayload := range args {
+		argType, typeFound := r.getMethodArgType(runMethodType, firstArg+i)
+		if !typeFound {
+			break
+		}
+
+		err = NewSchema(argType).Validate(fmt.Sprintf("argument %d", i+1), argFromPayload)
+		if err != nil {
+			return
+		}
+	}
+
 	for i, argFromPayload := range args {
 		var rawArgBytes []byte
 		rawArgBytes, err = json.Marshal(argFromPayload)
@@ -109,7 +201,7 @@ func (r concreteRunner) extractMethodArgs(runMethodType reflect.Type, args []int
 			return
 		}
 
-		argType, typeFound := r.getMethodArgType(runMethodType, i)
+		argType, typeFound := r.getMethodArgType(runMethodType, firstArg+i)
 		if !typeFound {
 			continue
 		}
@@ -154,3 +246,16 @@ func (r concreteRunner) extractReturns(values []reflect.Value) (value interface{
 	value = values[0].Interface()
 	return
 }
+
+type noopProgressReporter struct{}
+
+func (r noopProgressReporter) ReportProgress(stage string, percent int) {}
+
+func (r noopProgressReporter) ReportOutput(stream boshtask.OutputStream, line string) {}
+
+// noopCheckpoints are used when action is not run as a persistent task
+type noopCheckpoints struct{}
+
+func (c noopCheckpoints) IsCompleted(step string) bool { return false }
+
+func (c noopCheckpoints) Complete(step string) error { return nil }
@@ -9,6 +9,8 @@ import (
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 )
 
 type valueType struct {
@@ -52,6 +54,10 @@ func (a *actionWithTypes) IsPersistent() bool {
 	return false
 }
 
+func (a *actionWithTypes) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a *actionWithTypes) Run(arg argumentWithTypes) (valueType, error) {
 	a.Arg = arg
 	return a.Value, a.Err
@@ -83,6 +89,10 @@ func (a *actionWithGoodRunMethod) IsPersistent() bool {
 	return false
 }
 
+func (a *actionWithGoodRunMethod) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a *actionWithGoodRunMethod) Run(subAction string, someID int, extraArgs argsType, sliceArgs []string) (valueType, error) {
 	a.SubAction = subAction
 	a.SomeID = someID
@@ -115,6 +125,10 @@ func (a *actionWithOptionalRunArgument) IsPersistent() bool {
 	return false
 }
 
+func (a *actionWithOptionalRunArgument) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a *actionWithOptionalRunArgument) Run(subAction string, optionalArgs ...argsType) (valueType, error) {
 	a.SubAction = subAction
 	a.OptionalArgs = optionalArgs
@@ -139,6 +153,10 @@ func (a *actionWithoutRunMethod) IsPersistent() bool {
 	return false
 }
 
+func (a *actionWithoutRunMethod) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a *actionWithoutRunMethod) Resume() (interface{}, error) {
 	return nil, nil
 }
@@ -157,6 +175,10 @@ func (a *actionWithOneRunReturnValue) IsPersistent() bool {
 	return false
 }
 
+func (a *actionWithOneRunReturnValue) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a *actionWithOneRunReturnValue) Run() error {
 	return nil
 }
@@ -179,6 +201,10 @@ func (a *actionWithSecondReturnValueNotError) IsPersistent() bool {
 	return false
 }
 
+func (a *actionWithSecondReturnValueNotError) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a *actionWithSecondReturnValueNotError) Run() (interface{}, string) {
 	return nil, ""
 }
@@ -191,6 +217,73 @@ func (a *actionWithSecondReturnValueNotError) Cancel() error {
 	return nil
 }
 
+type actionWithProgressReporter struct {
+	Reporter  boshtask.ProgressReporter
+	SubAction string
+}
+
+func (a *actionWithProgressReporter) IsAsynchronous() bool {
+	return true
+}
+
+func (a *actionWithProgressReporter) IsPersistent() bool {
+	return false
+}
+
+func (a *actionWithProgressReporter) Resources() []boshtask.Resource {
+	return nil
+}
+
+func (a *actionWithProgressReporter) Run(reporter boshtask.ProgressReporter, subAction string) (string, error) {
+	a.Reporter = reporter
+	a.SubAction = subAction
+	reporter.ReportProgress("fake-stage", 50)
+	return "fake-value", nil
+}
+
+func (a *actionWithProgressReporter) Resume() (interface{}, error) {
+	return nil, nil
+}
+
+func (a *actionWithProgressReporter) Cancel() error {
+	return nil
+}
+
+type actionWithCheckpoints struct {
+	Reporter    boshtask.ProgressReporter
+	Checkpoints boshtask.Checkpoints
+	SubAction   string
+	Resumed     bool
+}
+
+func (a *actionWithCheckpoints) IsAsynchronous() bool {
+	return true
+}
+
+func (a *actionWithCheckpoints) IsPersistent() bool {
+	return true
+}
+
+func (a *actionWithCheckpoints) Resources() []boshtask.Resource {
+	return nil
+}
+
+func (a *actionWithCheckpoints) Run(reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints, subAction string) (string, error) {
+	a.Reporter = reporter
+	a.Checkpoints = checkpoints
+	a.SubAction = subAction
+	return "fake-value", nil
+}
+
+func (a *actionWithCheckpoints) Resume() (interface{}, error) {
+	a.Resumed = true
+	return nil, nil
+}
+
+func (a *actionWithCheckpoints) Cancel() error {
+	return nil
+}
+
 func init() {
 	Describe("concreteRunner", func() {
 		It("runner run parses the payload", func() {
@@ -210,7 +303,7 @@ func init() {
 				]
 			}`
 
-			value, err := runner.Run(action, []byte(payload))
+			value, err := runner.Run(action, []byte(payload), nil, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("fake-run-error"))
 
@@ -231,7 +324,7 @@ func init() {
 			action := &actionWithGoodRunMethod{Value: expectedValue}
 			payload := `{"arguments":["setup"]}`
 
-			_, err := runner.Run(action, []byte(payload))
+			_, err := runner.Run(action, []byte(payload), nil, nil)
 			Expect(err).To(HaveOccurred())
 		})
 
@@ -243,8 +336,43 @@ func init() {
 			action := &actionWithGoodRunMethod{Value: expectedValue}
 			payload := `{"arguments":[123, "setup", {"user":"rob","pwd":"rob123","id":12}]}`
 
-			_, err := runner.Run(action, []byte(payload))
+			_, err := runner.Run(action, []byte(payload), nil, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("runner run errs with expected number of arguments", func() {
+			runner := NewRunner()
+
+			_, err := runner.Run(&actionWithGoodRunMethod{}, []byte(`{"arguments":["setup"]}`), nil, nil)
 			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Not enough arguments, expected 4, got 1"))
+
+			_, err = runner.Run(&actionWithOptionalRunArgument{}, []byte(`{"arguments":[]}`), nil, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Not enough arguments, expected at least 1, got 0"))
+		})
+
+		It("runner run errs with position and path of argument that does not match expected type", func() {
+			runner := NewRunner()
+
+			action := &actionWithGoodRunMethod{}
+			payload := `{"arguments":["setup", 123, {"user":"rob","pwd":"rob123","id":"12"}, []]}`
+
+			_, err := runner.Run(action, []byte(payload), nil, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid argument 3.id: expected integer, got string"))
+			Expect(action.SubAction).To(BeEmpty())
+		})
+
+		It("runner run errs with position of variadic argument that does not match expected type", func() {
+			runner := NewRunner()
+
+			action := &actionWithOptionalRunArgument{}
+			payload := `{"arguments":["setup", {"user":"rob"}, "bob"]}`
+
+			_, err := runner.Run(action, []byte(payload), nil, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid argument 3: expected object, got string"))
 		})
 
 		It("extracts argument types correctly", func() {
@@ -264,7 +392,7 @@ func init() {
 					"bool_type":false
 				}]
 			}`
-			_, err := runner.Run(action, []byte(payload))
+			_, err := runner.Run(action, []byte(payload), nil, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(action.Arg.IntType).To(Equal(int(-1024000)))
@@ -286,7 +414,7 @@ func init() {
 			action := &actionWithOptionalRunArgument{Value: expectedValue, Err: expectedErr}
 			payload := `{"arguments":["setup", {"user":"rob","pwd":"rob123","id":12}, {"user":"bob","pwd":"bob123","id":13}]}`
 
-			value, err := runner.Run(action, []byte(payload))
+			value, err := runner.Run(action, []byte(payload), nil, nil)
 
 			Expect(value).To(Equal(expectedValue))
 			Expect(err).To(Equal(expectedErr))
@@ -303,7 +431,7 @@ func init() {
 			action := &actionWithOptionalRunArgument{}
 			payload := `{"arguments":["setup"]}`
 
-			runner.Run(action, []byte(payload))
+			runner.Run(action, []byte(payload), nil, nil)
 
 			Expect(action.SubAction).To(Equal("setup"))
 			Expect(action.OptionalArgs).To(Equal([]argsType{}))
@@ -311,20 +439,77 @@ func init() {
 
 		It("runner run errs when action does not implement run", func() {
 			runner := NewRunner()
-			_, err := runner.Run(&actionWithoutRunMethod{}, []byte(`{"arguments":[]}`))
+			_, err := runner.Run(&actionWithoutRunMethod{}, []byte(`{"arguments":[]}`), nil, nil)
 			Expect(err).To(HaveOccurred())
 		})
 
 		It("runner run errs when actions run does not return two values", func() {
 			runner := NewRunner()
-			_, err := runner.Run(&actionWithOneRunReturnValue{}, []byte(`{"arguments":[]}`))
+			_, err := runner.Run(&actionWithOneRunReturnValue{}, []byte(`{"arguments":[]}`), nil, nil)
 			Expect(err).To(HaveOccurred())
 		})
 
 		It("runner run errs when actions run second return type is not error", func() {
 			runner := NewRunner()
-			_, err := runner.Run(&actionWithSecondReturnValueNotError{}, []byte(`{"arguments":[]}`))
+			_, err := runner.Run(&actionWithSecondReturnValueNotError{}, []byte(`{"arguments":[]}`), nil, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("passes progress reporter to run when it is the first argument", func() {
+			runner := NewRunner()
+			reporter := faketask.NewFakeProgressReporter()
+
+			action := &actionWithProgressReporter{}
+			value, err := runner.Run(action, []byte(`{"arguments":["setup"]}`), reporter, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal("fake-value"))
+
+			Expect(action.Reporter).To(Equal(reporter))
+			Expect(action.SubAction).To(Equal("setup"))
+			Expect(reporter.Stages()).To(Equal([]string{"fake-stage"}))
+		})
+
+		It("passes a reporter that does nothing when progress reporter is not given", func() {
+			runner := NewRunner()
+
+			action := &actionWithProgressReporter{}
+			_, err := runner.Run(action, []byte(`{"arguments":["setup"]}`), nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action.Reporter).ToNot(BeNil())
+		})
+
+		It("does not count progress reporter as a payload argument", func() {
+			runner := NewRunner()
+			_, err := runner.Run(&actionWithProgressReporter{}, []byte(`{"arguments":[]}`), nil, nil)
 			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Not enough arguments, expected 1, got 0"))
+		})
+
+		It("passes checkpoints to run when they follow progress reporter", func() {
+			runner := NewRunner()
+			reporter := faketask.NewFakeProgressReporter()
+			checkpoints := faketask.NewFakeCheckpoints()
+
+			action := &actionWithCheckpoints{}
+			value, err := runner.Run(action, []byte(`{"arguments":["setup"]}`), reporter, checkpoints)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal("fake-value"))
+
+			Expect(action.Reporter).To(Equal(reporter))
+			Expect(action.Checkpoints).To(Equal(checkpoints))
+			Expect(action.SubAction).To(Equal("setup"))
+		})
+
+		It("passes checkpoints that record nothing when checkpoints are not given", func() {
+			runner := NewRunner()
+
+			action := &actionWithCheckpoints{}
+			_, err := runner.Run(action, []byte(`{"arguments":["setup"]}`), nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(action.Checkpoints.IsCompleted("fake-step")).To(BeFalse())
+			Expect(action.Checkpoints.Complete("fake-step")).ToNot(HaveOccurred())
+			Expect(action.Checkpoints.IsCompleted("fake-step")).To(BeFalse())
 		})
 
 		Describe("Resume", func() {
@@ -335,12 +520,28 @@ func init() {
 					ResumeValue: "fake-action-resume-value",
 				}
 
-				value, err := runner.Resume(testAction, []byte{})
+				value, err := runner.Resume(testAction, []byte{}, nil, nil)
 				Expect(value).To(Equal("fake-action-resume-value"))
 				Expect(err.Error()).To(Equal("fake-action-error"))
 
 				Expect(testAction.Resumed).To(BeTrue())
 			})
+
+			It("runs action again with the same payload and given checkpoints when action takes checkpoints", func() {
+				runner := NewRunner()
+				reporter := faketask.NewFakeProgressReporter()
+				checkpoints := faketask.NewFakeCheckpoints("fake-step")
+
+				action := &actionWithCheckpoints{}
+				value, err := runner.Resume(action, []byte(`{"arguments":["setup"]}`), reporter, checkpoints)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal("fake-value"))
+
+				Expect(action.Reporter).To(Equal(reporter))
+				Expect(action.Checkpoints).To(Equal(checkpoints))
+				Expect(action.SubAction).To(Equal("setup"))
+				Expect(action.Resumed).To(BeFalse())
+			})
 		})
 	})
 }
@@ -0,0 +1,281 @@
+package action
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// Schema describes JSON shape of a Go type as it is unmarshalled by encoding/json
+type Schema struct {
+	// One of string, integer, number, boolean, array, object or any
+	Type string `json:"type"`
+
+	// Name of the Go type (e.g. applyspec.V1ApplySpec) for named non-builtin types
+	GoType string `json:"go_type,omitempty"`
+
+	// Set for arrays
+	Items *Schema `json:"items,omitempty"`
+
+	// Set for objects unmarshalled into structs
+	Properties map[string]*Schema `json:"properties,omitempty"`
+
+	// Set for objects unmarshalled into maps
+	AdditionalProperties *Schema `json:"additional_properties,omitempty"`
+}
+
+const (
+	SchemaTypeString  = "string"
+	SchemaTypeInteger = "integer"
+	SchemaTypeNumber  = "number"
+	SchemaTypeBoolean = "boolean"
+	SchemaTypeArray   = "array"
+	SchemaTypeObject  = "object"
+	SchemaTypeAny     = "any"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func NewSchema(t reflect.Type) *Schema {
+	return newSchema(t, map[reflect.Type]bool{})
+}
+
+func newSchema(t reflect.Type, seenStructs map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	schema := &Schema{}
+
+	if t.Name() != "" && t.PkgPath() != "" {
+		schema.GoType = t.String()
+	}
+
+	// Custom unmarshalling may accept any JSON value
+	if t.Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) ||
+		t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		schema.Type = SchemaTypeAny
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		schema.Type = SchemaTypeString
+
+	case reflect.Bool:
+		schema.Type = SchemaTypeBoolean
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		schema.Type = SchemaTypeInteger
+
+	case reflect.Float32, reflect.Float64:
+		schema.Type = SchemaTypeNumber
+
+	case reflect.Slice, reflect.Array:
+		// Byte slices are base64 encoded strings
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			schema.Type = SchemaTypeString
+		} else {
+			schema.Type = SchemaTypeArray
+			schema.Items = newSchema(t.Elem(), seenStructs)
+		}
+
+	case reflect.Map:
+		schema.Type = SchemaTypeObject
+		schema.AdditionalProperties = newSchema(t.Elem(), seenStructs)
+
+	case reflect.Struct:
+		schema.Type = SchemaTypeObject
+
+		// Recursive types are only described up to the first repetition
+		if seenStructs[t] {
+			return schema
+		}
+
+		seenStructs[t] = true
+		schema.Properties = structProperties(t, seenStructs)
+		delete(seenStructs, t)
+
+	default:
+		schema.Type = SchemaTypeAny
+	}
+
+	return schema
+}
+
+func structProperties(t reflect.Type, seenStructs map[reflect.Type]bool) map[string]*Schema {
+	properties := map[string]*Schema{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		tagParts := strings.Split(tag, ",")
+		name := tagParts[0]
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		// Fields of embedded structs are unmarshalled as if they were declared in the outer struct
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			if seenStructs[fieldType] {
+				continue
+			}
+
+			seenStructs[fieldType] = true
+			for embeddedName, embeddedSchema := range structProperties(fieldType, seenStructs) {
+				if _, found := properties[embeddedName]; !found {
+					properties[embeddedName] = embeddedSchema
+				}
+			}
+			delete(seenStructs, fieldType)
+
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := newSchema(field.Type, seenStructs)
+
+		for _, option := range tagParts[1:] {
+			if option == "string" {
+				schema = &Schema{Type: SchemaTypeString}
+			}
+		}
+
+		properties[name] = schema
+	}
+
+	return properties
+}
+
+// Validate checks that value decoded from JSON (with numbers as json.Number)
+// can be unmarshalled into type described by the schema.
+// Name is used to point to the invalid value in the error (e.g. argument 1).
+func (s *Schema) Validate(name string, value interface{}) error {
+	// encoding/json leaves zero value in place of null
+	if value == nil || s.Type == SchemaTypeAny {
+		return nil
+	}
+
+	invalidErr := bosherr.Errorf("Invalid %s: expected %s, got %s", name, s.Type, jsonTypeName(value))
+
+	switch s.Type {
+	case SchemaTypeString:
+		if _, ok := value.(string); !ok {
+			return invalidErr
+		}
+
+	case SchemaTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return invalidErr
+		}
+
+	case SchemaTypeInteger:
+		number, ok := value.(json.Number)
+		if !ok {
+			return invalidErr
+		}
+
+		_, intErr := strconv.ParseInt(string(number), 10, 64)
+		_, uintErr := strconv.ParseUint(string(number), 10, 64)
+		if intErr != nil && uintErr != nil {
+			return bosherr.Errorf("Invalid %s: expected integer, got %s", name, number)
+		}
+
+	case SchemaTypeNumber:
+		if _, ok := value.(json.Number); !ok {
+			return invalidErr
+		}
+
+	case SchemaTypeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return invalidErr
+		}
+
+		for i, item := range items {
+			err := s.Items.Validate(name+"["+strconv.Itoa(i)+"]", item)
+			if err != nil {
+				return err
+			}
+		}
+
+	case SchemaTypeObject:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return invalidErr
+		}
+
+		for key, propertyValue := range object {
+			propertySchema := s.propertySchema(key)
+			if propertySchema == nil {
+				continue
+			}
+
+			err := propertySchema.Validate(name+"."+key, propertyValue)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// propertySchema matches keys the same way encoding/json does:
+// exact match is preferred over case-insensitive one; unknown keys are ignored.
+func (s *Schema) propertySchema(key string) *Schema {
+	if s.AdditionalProperties != nil {
+		return s.AdditionalProperties
+	}
+
+	if schema, found := s.Properties[key]; found {
+		return schema
+	}
+
+	for name, schema := range s.Properties {
+		if strings.EqualFold(name, key) {
+			return schema
+		}
+	}
+
+	return nil
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return SchemaTypeString
+	case bool:
+		return SchemaTypeBoolean
+	case json.Number:
+		return SchemaTypeNumber
+	case []interface{}:
+		return SchemaTypeArray
+	case map[string]interface{}:
+		return SchemaTypeObject
+	default:
+		return "null"
+	}
+}
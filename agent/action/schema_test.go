@@ -0,0 +1,133 @@
+package action_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+)
+
+type schemaEmbeddedType struct {
+	Embedded string `json:"embedded"`
+	Shadowed int    `json:"shadowed"`
+}
+
+type schemaRecursiveType struct {
+	Name     string                `json:"name"`
+	Children []schemaRecursiveType `json:"children"`
+}
+
+type schemaType struct {
+	schemaEmbeddedType
+
+	Shadowed   string `json:"shadowed"`
+	Untagged   float64
+	Pointer    *bool             `json:"pointer"`
+	Bytes      []byte            `json:"bytes"`
+	Labels     map[string]string `json:"labels"`
+	Anything   interface{}       `json:"anything"`
+	Time       time.Time         `json:"time"`
+	Quoted     int               `json:"quoted,string"`
+	Recursive  schemaRecursiveType
+	Ignored    string `json:"-"`
+	unexported string
+}
+
+var _ = Describe("Schema", func() {
+	Describe("NewSchema", func() {
+		It("describes struct fields as unmarshalled by encoding/json", func() {
+			schema := NewSchema(reflect.TypeOf(schemaType{}))
+
+			Expect(schema.Type).To(Equal("object"))
+			Expect(schema.GoType).To(Equal("action_test.schemaType"))
+
+			properties := schema.Properties
+			Expect(properties).To(HaveLen(10))
+			Expect(properties["embedded"]).To(Equal(&Schema{Type: "string"}))
+			Expect(properties["shadowed"]).To(Equal(&Schema{Type: "string"}))
+			Expect(properties["Untagged"]).To(Equal(&Schema{Type: "number"}))
+			Expect(properties["pointer"]).To(Equal(&Schema{Type: "boolean"}))
+			Expect(properties["bytes"]).To(Equal(&Schema{Type: "string"}))
+			Expect(properties["labels"]).To(Equal(&Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}}))
+			Expect(properties["anything"]).To(Equal(&Schema{Type: "any"}))
+			Expect(properties["time"]).To(Equal(&Schema{Type: "any", GoType: "time.Time"}))
+			Expect(properties["quoted"]).To(Equal(&Schema{Type: "string"}))
+		})
+
+		It("describes recursive types up to the first repetition", func() {
+			schema := NewSchema(reflect.TypeOf(schemaRecursiveType{}))
+
+			Expect(schema.Properties["children"]).To(Equal(&Schema{
+				Type:  "array",
+				Items: &Schema{Type: "object", GoType: "action_test.schemaRecursiveType"},
+			}))
+		})
+	})
+
+	Describe("Validate", func() {
+		var (
+			schema *Schema
+		)
+
+		BeforeEach(func() {
+			schema = NewSchema(reflect.TypeOf(schemaType{}))
+		})
+
+		decode := func(valueJSON string) interface{} {
+			var value interface{}
+
+			decoder := json.NewDecoder(strings.NewReader(valueJSON))
+			decoder.UseNumber()
+
+			err := decoder.Decode(&value)
+			Expect(err).ToNot(HaveOccurred())
+
+			return value
+		}
+
+		It("accepts values that can be unmarshalled", func() {
+			err := schema.Validate("argument 1", decode(`{
+				"embedded": "fake-embedded",
+				"shadowed": "fake-shadowed",
+				"untagged": 1.5,
+				"pointer": null,
+				"labels": {"fake-key": "fake-value"},
+				"anything": [1, "two"],
+				"time": "2015-01-01T00:00:00Z",
+				"quoted": "1",
+				"Recursive": {"name": "fake-name", "children": [{"name": "fake-child-name"}]},
+				"unknown": 1
+			}`))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error pointing to the invalid value", func() {
+			err := schema.Validate("argument 1", decode(`{"Recursive": {"children": [{}, 1]}}`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid argument 1.Recursive.children[1]: expected object, got number"))
+		})
+
+		It("returns error when value is of a different kind", func() {
+			err := schema.Validate("argument 1", decode(`["fake-value"]`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid argument 1: expected object, got array"))
+		})
+
+		It("returns error when map value is invalid", func() {
+			err := schema.Validate("argument 1", decode(`{"labels": {"fake-key": true}}`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid argument 1.labels.fake-key: expected string, got boolean"))
+		})
+
+		It("returns error when integer is expected but fractional number is given", func() {
+			err := NewSchema(reflect.TypeOf(0)).Validate("argument 2", decode(`1.5`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid argument 2: expected integer, got 1.5"))
+		})
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"path/filepath"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -35,6 +36,10 @@ func (a SSHAction) IsPersistent() bool {
 	return false
 }
 
+func (a SSHAction) Resources() []boshtask.Resource {
+	return nil
+}
+
 type SSHParams struct {
 	UserRegex string `json:"user_regex"`
 	User      string
@@ -3,6 +3,7 @@ package action
 import (
 	"errors"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
@@ -26,6 +27,10 @@ func (a StartAction) IsPersistent() bool {
 	return false
 }
 
+func (a StartAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceJobs}
+}
+
 func (a StartAction) Run() (value string, err error) {
 	err = a.jobSupervisor.Start()
 	if err != nil {
@@ -3,6 +3,7 @@ package action
 import (
 	"errors"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
@@ -26,6 +27,10 @@ func (a StopAction) IsPersistent() bool {
 	return false
 }
 
+func (a StopAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceJobs}
+}
+
 func (a StopAction) Run() (value string, err error) {
 	err = a.jobSupervisor.Stop()
 	if err != nil {
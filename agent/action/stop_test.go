@@ -5,6 +5,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 )
 
@@ -28,6 +29,10 @@ func init() {
 			Expect(action.IsPersistent()).To(BeFalse())
 		})
 
+		It("modifies jobs", func() {
+			Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceJobs}))
+		})
+
 		It("returns stopped", func() {
 			stopped, err := action.Run()
 			Expect(err).ToNot(HaveOccurred())
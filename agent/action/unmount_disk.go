@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -31,6 +32,10 @@ func (a UnmountDiskAction) IsPersistent() bool {
 	return false
 }
 
+func (a UnmountDiskAction) Resources() []boshtask.Resource {
+	return []boshtask.Resource{boshtask.ResourceDisks}
+}
+
 func (a UnmountDiskAction) Run(diskID string) (value interface{}, err error) {
 	settings := a.settingsService.GetSettings()
 
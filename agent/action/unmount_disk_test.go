@@ -1,6 +1,7 @@
 package action_test
 
 import (
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
@@ -52,6 +53,10 @@ var _ = Describe("UnmountDiskAction", func() {
 		Expect(action.IsPersistent()).To(BeFalse())
 	})
 
+	It("modifies disks", func() {
+		Expect(action.Resources()).To(Equal([]boshtask.Resource{boshtask.ResourceDisks}))
+	})
+
 	It("unmount disk when the disk is mounted", func() {
 		platform.UnmountPersistentDiskDidUnmount = true
 
@@ -3,6 +3,7 @@ package action
 import (
 	"errors"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	"github.com/cloudfoundry/bosh-agent/platform/cert"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	"github.com/cloudfoundry/bosh-utils/logger"
@@ -28,6 +29,10 @@ func (a UpdateSettingsAction) IsPersistent() bool {
 	return false
 }
 
+func (a UpdateSettingsAction) Resources() []boshtask.Resource {
+	return nil
+}
+
 func (a UpdateSettingsAction) Run(newSettings boshsettings.Settings) (string, error) {
 	a.logger.Info("update-settings-action", "Running Update Settings command")
 
@@ -1,9 +1,17 @@
 package agent
 
 import (
+	"encoding/json"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshaudit "github.com/cloudfoundry/bosh-agent/agent/audit"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
@@ -21,6 +29,15 @@ type concreteActionDispatcher struct {
 	taskManager   boshtask.Manager
 	actionFactory boshaction.Factory
 	actionRunner  boshaction.Runner
+	notifier      boshnotif.Notifier
+
+	timeService    clock.Clock
+	actionTimeouts map[string]time.Duration
+	requestCache   RequestCache
+	auditLog       boshaudit.Log
+
+	actionCounter  boshmetrics.Counter
+	actionDuration boshmetrics.Summary
 }
 
 func NewActionDispatcher(
@@ -29,13 +46,35 @@ func NewActionDispatcher(
 	taskManager boshtask.Manager,
 	actionFactory boshaction.Factory,
 	actionRunner boshaction.Runner,
+	notifier boshnotif.Notifier,
+	timeService clock.Clock,
+	actionTimeouts map[string]time.Duration,
+	requestCache RequestCache,
+	auditLog boshaudit.Log,
+	metricsRegistry *boshmetrics.Registry,
 ) (dispatcher ActionDispatcher) {
 	return concreteActionDispatcher{
-		logger:        logger,
-		taskService:   taskService,
-		taskManager:   taskManager,
-		actionFactory: actionFactory,
-		actionRunner:  actionRunner,
+		logger:         logger,
+		taskService:    taskService,
+		taskManager:    taskManager,
+		actionFactory:  actionFactory,
+		actionRunner:   actionRunner,
+		notifier:       notifier,
+		timeService:    timeService,
+		actionTimeouts: actionTimeouts,
+		requestCache:   requestCache,
+		auditLog:       auditLog,
+
+		actionCounter: metricsRegistry.Counter(
+			"bosh_agent_actions_total",
+			"Number of dispatched actions by method and outcome.",
+			"method", "outcome",
+		),
+		actionDuration: metricsRegistry.Summary(
+			"bosh_agent_action_duration_seconds",
+			"Time it took to run dispatched actions by method.",
+			"method",
+		),
 	}
 }
 
@@ -61,56 +100,126 @@ func (dispatcher concreteActionDispatcher) ResumePreviouslyDispatchedTasks() {
 		taskID := taskInfo.TaskID
 		payload := taskInfo.Payload
 
+		// Sender of the original request is not known after restart
+		req := boshhandler.NewRequest("", taskInfo.Method, payload)
+		endedTask := dispatcher.recordTaskEnd(req, dispatcher.timeService.Now(), dispatcher.removeInfo)
+
+		method := taskInfo.Method
+
+		// Deadline of resumed task starts over since agent was not running in between
+		timeout := dispatcher.timeout(method, payload)
+		endTask, taskEnded := dispatcher.trackTaskEnd(endedTask, timeout)
+
+		// Resumed task continues after the last step it has completed
+		checkpoints := boshtask.NewCheckpoints(taskID, dispatcher.taskManager, taskInfo.Checkpoints)
+
 		task := dispatcher.taskService.CreateTaskWithID(
 			taskID,
-			func() (interface{}, error) { return dispatcher.actionRunner.Resume(action, payload) },
+			func() (interface{}, error) {
+				dispatcher.enforceTimeout(taskID, method, timeout, taskEnded)
+				return dispatcher.actionRunner.Resume(action, payload, dispatcher.progressReporter(taskID), checkpoints)
+			},
 			func(_ boshtask.Task) error { return action.Cancel() },
-			dispatcher.removeInfo,
+			endTask,
 		)
+		task.Method = method
+		task.Resources = action.Resources()
 
 		dispatcher.taskService.StartTask(task)
 	}
 }
 
 func (dispatcher concreteActionDispatcher) Dispatch(req boshhandler.Request) boshhandler.Response {
+	if req.RequestID == "" {
+		return dispatcher.dispatch(req)
+	}
+
+	// Redelivered or retried requests (e.g. mount_disk) must not run actions twice;
+	// duplicate async request gets id of already created task
+	var dispatched bool
+
+	resp := dispatcher.requestCache.Respond(req.RequestID, req.Method, func() boshhandler.Response {
+		dispatched = true
+		return dispatcher.dispatch(req)
+	})
+
+	if !dispatched {
+		dispatcher.logger.Info(actionDispatcherLogTag, "Responding to duplicate request %s for action %s", req.RequestID, req.Method)
+	}
+
+	return resp
+}
+
+func (dispatcher concreteActionDispatcher) dispatch(req boshhandler.Request) boshhandler.Response {
+	startedAt := dispatcher.timeService.Now()
+
 	action, err := dispatcher.actionFactory.Create(req.Method)
+	if _, disabled := err.(boshaction.DisabledError); disabled {
+		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
+		dispatcher.actionEnded(req, "", startedAt, boshaudit.OutcomeRejected)
+		return boshhandler.NewExceptionResponse(err)
+	}
+
 	if err != nil {
 		dispatcher.logger.Error(actionDispatcherLogTag, "Unknown action %s", req.Method)
+		dispatcher.actionEnded(req, "", startedAt, boshaudit.OutcomeRejected)
 		return boshhandler.NewExceptionResponse(bosherr.Errorf("unknown message %s", req.Method))
 	}
 
 	if action.IsAsynchronous() {
-		return dispatcher.dispatchAsynchronousAction(action, req)
+		return dispatcher.dispatchAsynchronousAction(action, req, startedAt)
 	}
 
-	return dispatcher.dispatchSynchronousAction(action, req)
+	return dispatcher.dispatchSynchronousAction(action, req, startedAt)
 }
 
 func (dispatcher concreteActionDispatcher) dispatchAsynchronousAction(
 	action boshaction.Action,
 	req boshhandler.Request,
+	startedAt time.Time,
 ) boshhandler.Response {
 	dispatcher.logger.Info(actionDispatcherLogTag, "Running async action %s", req.Method)
 
 	var task boshtask.Task
+	var endTask boshtask.EndFunc
+	var taskEnded <-chan struct{}
 	var err error
 
+	timeout := dispatcher.timeout(req.Method, req.GetPayload())
+
 	runTask := func() (interface{}, error) {
-		return dispatcher.actionRunner.Run(action, req.GetPayload())
+		// Time spent queued behind tasks using the same resources does not count
+		dispatcher.enforceTimeout(task.ID, req.Method, timeout, taskEnded)
+
+		var checkpoints boshtask.Checkpoints
+
+		// Only persistent tasks have task info to record checkpoints in
+		if action.IsPersistent() {
+			checkpoints = boshtask.NewCheckpoints(task.ID, dispatcher.taskManager, nil)
+		}
+
+		return dispatcher.actionRunner.Run(action, req.GetPayload(), dispatcher.progressReporter(task.ID), checkpoints)
 	}
 
 	cancelTask := func(_ boshtask.Task) error { return action.Cancel() }
 
+	failedToStart := func(err error) boshhandler.Response {
+		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
+		dispatcher.actionEnded(req, "", startedAt, string(boshtask.StateFailed))
+		return boshhandler.NewExceptionResponse(err)
+	}
+
 	// Certain long-running tasks (e.g. configure_networks) must be resumed
 	// after agent restart so that API consumers do not need to know
 	// if agent is restarted midway through the task.
 	if action.IsPersistent() {
 		dispatcher.logger.Info(actionDispatcherLogTag, "Running persistent action %s", req.Method)
-		task, err = dispatcher.taskService.CreateTask(runTask, cancelTask, dispatcher.removeInfo)
+		endedTask := dispatcher.recordTaskEnd(req, startedAt, dispatcher.removeInfo)
+		endTask, taskEnded = dispatcher.trackTaskEnd(endedTask, timeout)
+
+		task, err = dispatcher.taskService.CreateTask(runTask, cancelTask, endTask)
 		if err != nil {
-			err = bosherr.WrapErrorf(err, "Create Task Failed %s", req.Method)
-			dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
-			return boshhandler.NewExceptionResponse(err)
+			return failedToStart(bosherr.WrapErrorf(err, "Create Task Failed %s", req.Method))
 		}
 
 		taskInfo := boshtask.Info{
@@ -121,43 +230,110 @@ func (dispatcher concreteActionDispatcher) dispatchAsynchronousAction(
 
 		err = dispatcher.taskManager.AddInfo(taskInfo)
 		if err != nil {
-			err = bosherr.WrapErrorf(err, "Action Failed %s", req.Method)
-			dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
-			return boshhandler.NewExceptionResponse(err)
+			return failedToStart(bosherr.WrapErrorf(err, "Action Failed %s", req.Method))
 		}
 	} else {
-		task, err = dispatcher.taskService.CreateTask(runTask, cancelTask, nil)
+		endedTask := dispatcher.recordTaskEnd(req, startedAt, nil)
+		endTask, taskEnded = dispatcher.trackTaskEnd(endedTask, timeout)
+
+		task, err = dispatcher.taskService.CreateTask(runTask, cancelTask, endTask)
 		if err != nil {
-			err = bosherr.WrapErrorf(err, "Create Task Failed %s", req.Method)
-			dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
-			return boshhandler.NewExceptionResponse(err)
+			return failedToStart(bosherr.WrapErrorf(err, "Create Task Failed %s", req.Method))
 		}
 	}
 
+	task.Method = req.Method
+
+	// Tasks touching the same resources are queued until earlier ones finish
+	task.Resources = action.Resources()
+
+	// Recorded before task starts so that it comes before record of the task's end
+	dispatcher.audit(req, task.ID, startedAt, dispatcher.timeService.Now(), boshaudit.OutcomeAccepted)
+
 	dispatcher.taskService.StartTask(task)
 
-	return boshhandler.NewValueResponse(boshtask.StateValue{
-		AgentTaskID: task.ID,
-		State:       task.State,
-	})
+	// Task is usually queued rather than running once started;
+	// quick task might already be forgotten by the time it is looked up
+	if startedTask, found := dispatcher.taskService.FindTaskWithID(task.ID); found {
+		task = startedTask
+	}
+
+	return boshhandler.NewValueResponse(task.StateValue())
 }
 
 func (dispatcher concreteActionDispatcher) dispatchSynchronousAction(
 	action boshaction.Action,
 	req boshhandler.Request,
+	startedAt time.Time,
 ) boshhandler.Response {
 	dispatcher.logger.Info(actionDispatcherLogTag, "Running sync action %s", req.Method)
 
-	value, err := dispatcher.actionRunner.Run(action, req.GetPayload())
+	value, err := dispatcher.actionRunner.Run(action, req.GetPayload(), nil, nil)
 	if err != nil {
 		err = bosherr.WrapErrorf(err, "Action Failed %s", req.Method)
 		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
+		dispatcher.actionEnded(req, "", startedAt, string(boshtask.StateFailed))
 		return boshhandler.NewExceptionResponse(err)
 	}
 
+	dispatcher.actionEnded(req, "", startedAt, string(boshtask.StateDone))
+
 	return boshhandler.NewValueResponse(value)
 }
 
+func (dispatcher concreteActionDispatcher) progressReporter(taskID string) boshtask.ProgressReporter {
+	return &taskProgressReporter{
+		taskID:      taskID,
+		taskService: dispatcher.taskService,
+		notifier:    dispatcher.notifier,
+		timeService: dispatcher.timeService,
+		logger:      dispatcher.logger,
+	}
+}
+
+// recordTaskEnd wraps task's end func so that outcome of the task is recorded once it ends
+func (dispatcher concreteActionDispatcher) recordTaskEnd(req boshhandler.Request, startedAt time.Time, endFunc boshtask.EndFunc) boshtask.EndFunc {
+	return func(task boshtask.Task) {
+		dispatcher.actionEnded(req, task.ID, startedAt, string(task.State))
+		if endFunc != nil {
+			endFunc(task)
+		}
+	}
+}
+
+// actionEnded counts dispatched action and audits it
+func (dispatcher concreteActionDispatcher) actionEnded(req boshhandler.Request, taskID string, startedAt time.Time, outcome string) {
+	finishedAt := dispatcher.timeService.Now()
+
+	dispatcher.actionCounter.Inc(req.Method, outcome)
+
+	// Rejected actions did not run
+	if outcome != boshaudit.OutcomeRejected {
+		dispatcher.actionDuration.Observe(finishedAt.Sub(startedAt).Seconds(), req.Method)
+	}
+
+	dispatcher.audit(req, taskID, startedAt, finishedAt, outcome)
+}
+
+func (dispatcher concreteActionDispatcher) audit(req boshhandler.Request, taskID string, startedAt, finishedAt time.Time, outcome string) {
+	record := boshaudit.Record{
+		Method:        req.Method,
+		ReplyTo:       req.ReplyTo,
+		RequestID:     req.RequestID,
+		TaskID:        taskID,
+		StartedAt:     startedAt,
+		FinishedAt:    finishedAt,
+		Outcome:       outcome,
+		PayloadSHA256: boshaudit.PayloadSHA256(req.GetPayload()),
+	}
+
+	err := dispatcher.auditLog.Append(record)
+	if err != nil {
+		// Failing to audit does not stop agent from responding to the director
+		dispatcher.logger.Error(actionDispatcherLogTag, "Auditing action %s: %s", req.Method, err.Error())
+	}
+}
+
 func (dispatcher concreteActionDispatcher) removeInfo(task boshtask.Task) {
 	err := dispatcher.taskManager.RemoveInfo(task.ID)
 	if err != nil {
@@ -166,3 +342,73 @@ func (dispatcher concreteActionDispatcher) removeInfo(task boshtask.Task) {
 		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
 	}
 }
+
+// timeout returns how long task for given request is allowed to take
+// which is either set in request's payload (e.g. {"timeout": 600, ...}) in seconds
+// or configured for the action; zero means that task is allowed to run forever
+func (dispatcher concreteActionDispatcher) timeout(method string, payload []byte) time.Duration {
+	var timeoutPayload struct {
+		Timeout int `json:"timeout"`
+	}
+
+	// Malformed payload is reported by the action runner
+	err := json.Unmarshal(payload, &timeoutPayload)
+	if err == nil && timeoutPayload.Timeout > 0 {
+		return time.Duration(timeoutPayload.Timeout) * time.Second
+	}
+
+	return dispatcher.actionTimeouts[method]
+}
+
+// trackTaskEnd wraps task's end func so that timeout can be stopped once task ends;
+// end func is left as is if task does not have a timeout
+func (dispatcher concreteActionDispatcher) trackTaskEnd(endFunc boshtask.EndFunc, timeout time.Duration) (boshtask.EndFunc, <-chan struct{}) {
+	if timeout <= 0 {
+		return endFunc, nil
+	}
+
+	endedCh := make(chan struct{})
+
+	return func(task boshtask.Task) {
+		close(endedCh)
+		if endFunc != nil {
+			endFunc(task)
+		}
+	}, endedCh
+}
+
+// enforceTimeout times out the task unless it ends within given timeout;
+// it is called once task starts running
+func (dispatcher concreteActionDispatcher) enforceTimeout(taskID, method string, timeout time.Duration, taskEnded <-chan struct{}) {
+	if timeout <= 0 {
+		return
+	}
+
+	startedAt := dispatcher.timeService.Now()
+	timer := dispatcher.timeService.NewTimer(timeout)
+
+	go func() {
+		defer dispatcher.logger.HandlePanic("Action Dispatcher Enforce Timeout")
+
+		select {
+		case <-taskEnded:
+			timer.Stop()
+
+		case <-timer.C():
+			select {
+			case <-taskEnded:
+				// Task ended right at the deadline
+				return
+			default:
+			}
+
+			elapsed := dispatcher.timeService.Now().Sub(startedAt)
+			timeoutErr := bosherr.Errorf("Action %s timed out after %s", method, elapsed)
+
+			err := dispatcher.taskService.TimeOutTask(taskID, timeoutErr)
+			if err != nil {
+				dispatcher.logger.Error(actionDispatcherLogTag, "Stopping timed out task %s: %s", taskID, err.Error())
+			}
+		}
+	}()
+}
@@ -4,15 +4,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	. "github.com/cloudfoundry/bosh-agent/agent"
+	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
 	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
+	boshaudit "github.com/cloudfoundry/bosh-agent/agent/audit"
+	fakeaudit "github.com/cloudfoundry/bosh-agent/agent/audit/fakes"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	fakenotif "github.com/cloudfoundry/bosh-agent/notification/fakes"
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
@@ -25,6 +32,11 @@ func init() {
 			taskManager   *faketask.FakeManager
 			actionFactory *fakeaction.FakeFactory
 			actionRunner  *fakeaction.FakeRunner
+			notifier      *fakenotif.FakeNotifier
+			timeService   *fakeclock.FakeClock
+			timeouts      map[string]time.Duration
+			auditLog      *fakeaudit.FakeLog
+			registry      *boshmetrics.Registry
 			dispatcher    ActionDispatcher
 		)
 
@@ -34,7 +46,13 @@ func init() {
 			taskManager = faketask.NewFakeManager()
 			actionFactory = fakeaction.NewFakeFactory()
 			actionRunner = &fakeaction.FakeRunner{}
-			dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner)
+			notifier = fakenotif.NewFakeNotifier()
+			timeService = fakeclock.NewFakeClock(time.Now())
+			timeouts = map[string]time.Duration{}
+			auditLog = &fakeaudit.FakeLog{}
+			registry = boshmetrics.NewRegistry()
+			requestCache := NewRequestCache(RequestCacheOptions{}, timeService)
+			dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner, notifier, timeService, timeouts, requestCache, auditLog, registry)
 		})
 
 		It("responds with exception when the method is unknown", func() {
@@ -45,6 +63,14 @@ func init() {
 			boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"unknown message fake-action"}}`)
 		})
 
+		It("responds with coded exception when the action is disabled by policy", func() {
+			actionFactory.RegisterActionErr("ssh", boshaction.DisabledError{Method: "ssh"})
+
+			req := boshhandler.NewRequest("fake-reply", "ssh", []byte{})
+			resp := dispatcher.Dispatch(req)
+			boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"Action ssh is disabled by policy","code":"action_disabled"}}`)
+		})
+
 		Context("when action is synchronous", func() {
 			var (
 				req boshhandler.Request
@@ -104,6 +130,114 @@ func init() {
 				})
 			}
 
+			It("gives task a progress reporter that records and publishes events", func() {
+				dispatcher.Dispatch(req)
+
+				_, err := taskService.StartedTasks["fake-generated-task-id"].Func()
+				Expect(err).ToNot(HaveOccurred())
+
+				timeService.Increment(time.Hour)
+				actionRunner.RunReporter.ReportProgress("fake-stage", 42)
+
+				events := taskService.AddedEvents["fake-generated-task-id"]
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].Stage).To(Equal("fake-stage"))
+				Expect(events[0].Percent).To(Equal(42))
+				Expect(events[0].Time).To(Equal(timeService.Now().Unix()))
+
+				Expect(notifier.NotifiedTaskProgressTaskIDs).To(Equal([]string{"fake-generated-task-id"}))
+				Expect(notifier.NotifiedTaskProgressEvents).To(Equal(events))
+			})
+
+			It("records progress events even if publishing them fails", func() {
+				notifier.NotifyTaskProgressErr = errors.New("fake-notify-error")
+				dispatcher.Dispatch(req)
+
+				_, err := taskService.StartedTasks["fake-generated-task-id"].Func()
+				Expect(err).ToNot(HaveOccurred())
+
+				actionRunner.RunReporter.ReportProgress("fake-stage", 42)
+				Expect(taskService.AddedEvents["fake-generated-task-id"]).To(HaveLen(1))
+			})
+
+			It("gives task a progress reporter that numbers, records and publishes output lines", func() {
+				dispatcher.Dispatch(req)
+
+				_, err := taskService.StartedTasks["fake-generated-task-id"].Func()
+				Expect(err).ToNot(HaveOccurred())
+
+				timeService.Increment(time.Hour)
+				actionRunner.RunReporter.ReportOutput(boshtask.OutputStdout, "fake-line-1")
+				actionRunner.RunReporter.ReportOutput(boshtask.OutputStderr, "fake-line-2")
+
+				output := taskService.AddedOutput["fake-generated-task-id"]
+				Expect(output).To(HaveLen(2))
+				Expect(output[0].Sequence).To(Equal(1))
+				Expect(output[0].Stream).To(Equal(boshtask.OutputStdout))
+				Expect(output[0].Line).To(Equal("fake-line-1"))
+				Expect(output[0].Time).To(Equal(timeService.Now().Unix()))
+				Expect(output[1].Sequence).To(Equal(2))
+				Expect(output[1].Stream).To(Equal(boshtask.OutputStderr))
+
+				// Lines are published together once flush interval passes
+				Expect(notifier.NotifiedTaskOutputLines()).To(BeEmpty())
+
+				Eventually(timeService.WatcherCount).Should(Equal(1))
+				timeService.Increment(time.Second)
+
+				Eventually(notifier.NotifiedTaskOutputLines).Should(Equal(output))
+				Expect(notifier.NotifiedTaskOutputTaskIDs()).To(Equal([]string{"fake-generated-task-id"}))
+			})
+
+			It("drops output lines from publishing but not from task once too many are waiting to be published", func() {
+				dispatcher.Dispatch(req)
+
+				_, err := taskService.StartedTasks["fake-generated-task-id"].Func()
+				Expect(err).ToNot(HaveOccurred())
+
+				for i := 0; i < 1001; i++ {
+					actionRunner.RunReporter.ReportOutput(boshtask.OutputStdout, fmt.Sprintf("fake-line-%d", i))
+				}
+
+				Expect(taskService.AddedOutput["fake-generated-task-id"]).To(HaveLen(1001))
+
+				Eventually(timeService.WatcherCount).Should(Equal(1))
+				timeService.Increment(time.Second)
+
+				Eventually(func() int { return len(notifier.NotifiedTaskOutputLines()) }).Should(Equal(1000))
+
+				// Lines are sent in batches of limited size
+				Expect(notifier.NotifiedTaskOutputTaskIDs()).To(HaveLen(10))
+				Expect(notifier.NotifiedTaskOutputLines()[999].Line).To(Equal("fake-line-999"))
+			})
+
+			It("starts task with resources modified by the action", func() {
+				action.TaskResources = []boshtask.Resource{boshtask.ResourceJobs}
+				dispatcher.Dispatch(req)
+
+				task := taskService.StartedTasks["fake-generated-task-id"]
+				Expect(task.Resources).To(Equal([]boshtask.Resource{boshtask.ResourceJobs}))
+			})
+
+			It("starts task with method of the request so that it can be listed", func() {
+				dispatcher.Dispatch(req)
+
+				task := taskService.StartedTasks["fake-generated-task-id"]
+				Expect(task.Method).To(Equal(req.Method))
+			})
+
+			It("responds with state task service recorded for started task", func() {
+				// Task waits for earlier tasks using the same resources
+				taskService.StartTaskCallBack = func(task boshtask.Task) {
+					task.State = boshtask.StateQueued
+					taskService.StartedTasks[task.ID] = task
+				}
+
+				resp := dispatcher.Dispatch(req)
+				boshassert.MatchesJSONString(GinkgoT(), resp,
+					`{"value":{"agent_task_id":"fake-generated-task-id","state":"queued"}}`)
+			})
+
 			Context("when action is not persistent", func() {
 				BeforeEach(func() {
 					action.Persistent = false
@@ -162,9 +296,22 @@ func init() {
 					Expect(taskInfos).To(BeEmpty())
 				})
 
-				It("does not do anything after task finishes", func() {
+				It("does not give task checkpoints since there is no task info to record them in", func() {
+					dispatcher.Dispatch(req)
+
+					_, err := taskService.StartedTasks["fake-generated-task-id"].Func()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(actionRunner.RunCheckpoints).To(BeNil())
+				})
+
+				It("audits accepted task and then its outcome once task finishes", func() {
 					dispatcher.Dispatch(req)
-					Expect(taskService.StartedTasks["fake-generated-task-id"].EndFunc).To(BeNil())
+					Expect(auditLog.Records()).To(HaveLen(1))
+					Expect(auditLog.Records()[0].Outcome).To(Equal(boshaudit.OutcomeAccepted))
+
+					taskService.StartedTasks["fake-generated-task-id"].EndFunc(boshtask.Task{ID: "fake-generated-task-id", State: boshtask.StateDone})
+					Expect(auditLog.Records()).To(HaveLen(2))
+					Expect(auditLog.Records()[1].Outcome).To(Equal("done"))
 				})
 			})
 
@@ -220,6 +367,20 @@ func init() {
 
 				ItAllowsToCancelTask()
 
+				It("gives task checkpoints that are recorded in task manager", func() {
+					dispatcher.Dispatch(req)
+
+					_, err := taskService.StartedTasks["fake-generated-task-id"].Func()
+					Expect(err).ToNot(HaveOccurred())
+
+					err = actionRunner.RunCheckpoints.Complete("fake-step")
+					Expect(err).ToNot(HaveOccurred())
+
+					taskInfos, err := taskManager.GetInfos()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(taskInfos[0].Checkpoints).To(Equal([]string{"fake-step"}))
+				})
+
 				It("adds task to task manager before task starts so that it could be resumed if agent is restarted", func() {
 					dispatcher.Dispatch(req)
 					taskInfos, _ := taskManager.GetInfos()
@@ -252,6 +413,197 @@ func init() {
 			})
 		})
 
+		Context("when request is identified", func() {
+			var (
+				action *fakeaction.TestAction
+			)
+
+			BeforeEach(func() {
+				action = &fakeaction.TestAction{}
+				actionFactory.RegisterAction("fake-action", action)
+			})
+
+			newRequest := func(requestID, method string) boshhandler.Request {
+				req := boshhandler.NewRequest("fake-reply", method, []byte("fake-payload"))
+				req.RequestID = requestID
+				return req
+			}
+
+			It("responds to duplicate synchronous request with cached response without running action again", func() {
+				actionRunner.RunValue = "fake-value-1"
+				resp := dispatcher.Dispatch(newRequest("fake-request-id", "fake-action"))
+				Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value-1")))
+
+				actionRunner.RunValue = "fake-value-2"
+				resp = dispatcher.Dispatch(newRequest("fake-request-id", "fake-action"))
+				Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value-1")))
+
+				Expect(actionRunner.RunCallCount).To(Equal(1))
+			})
+
+			It("responds to duplicate asynchronous request with already created task", func() {
+				action.Asynchronous = true
+
+				resp := dispatcher.Dispatch(newRequest("fake-request-id", "fake-action"))
+				boshassert.MatchesJSONString(GinkgoT(), resp,
+					`{"value":{"agent_task_id":"fake-generated-task-id","state":"running"}}`)
+
+				// Creating another task would fail
+				taskService.CreateTaskErr = errors.New("fake-create-task-error")
+
+				resp = dispatcher.Dispatch(newRequest("fake-request-id", "fake-action"))
+				boshassert.MatchesJSONString(GinkgoT(), resp,
+					`{"value":{"agent_task_id":"fake-generated-task-id","state":"running"}}`)
+			})
+
+			It("runs action again once duplicate request window passes", func() {
+				dispatcher.Dispatch(newRequest("fake-request-id", "fake-action"))
+
+				timeService.Increment(DefaultDuplicateRequestWindowSeconds*time.Second + time.Second)
+
+				dispatcher.Dispatch(newRequest("fake-request-id", "fake-action"))
+				Expect(actionRunner.RunCallCount).To(Equal(2))
+			})
+
+			It("runs action for requests with different ids", func() {
+				dispatcher.Dispatch(newRequest("fake-request-id-1", "fake-action"))
+				dispatcher.Dispatch(newRequest("fake-request-id-2", "fake-action"))
+				Expect(actionRunner.RunCallCount).To(Equal(2))
+			})
+
+			It("runs action for request with the same id but different method", func() {
+				actionFactory.RegisterAction("fake-other-action", &fakeaction.TestAction{})
+
+				dispatcher.Dispatch(newRequest("fake-request-id", "fake-action"))
+				dispatcher.Dispatch(newRequest("fake-request-id", "fake-other-action"))
+				Expect(actionRunner.RunCallCount).To(Equal(2))
+			})
+
+			It("runs action for every request that is not identified", func() {
+				dispatcher.Dispatch(newRequest("", "fake-action"))
+				dispatcher.Dispatch(newRequest("", "fake-action"))
+				Expect(actionRunner.RunCallCount).To(Equal(2))
+			})
+		})
+
+		Context("when action is asynchronous and has a deadline", func() {
+			var (
+				req    boshhandler.Request
+				action *fakeaction.TestAction
+			)
+
+			BeforeEach(func() {
+				req = boshhandler.NewRequest("fake-reply", "fake-action", []byte(`{"arguments":[]}`))
+				action = &fakeaction.TestAction{Asynchronous: true}
+				actionFactory.RegisterAction("fake-action", action)
+				timeouts["fake-action"] = time.Minute
+			})
+
+			runTask := func(taskID string) {
+				_, err := taskService.StartedTasks[taskID].Func()
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			It("times out task with error naming the action and elapsed time once configured timeout passes", func() {
+				dispatcher.Dispatch(req)
+				runTask("fake-generated-task-id")
+
+				timeService.Increment(59 * time.Second)
+				Consistently(taskService.TimedOutTaskIDs).Should(BeEmpty())
+
+				timeService.Increment(time.Second)
+				Eventually(taskService.TimedOutTaskIDs).Should(Equal([]string{"fake-generated-task-id"}))
+
+				timeoutErrs := taskService.TimedOutTaskErrs()
+				Expect(timeoutErrs[0].Error()).To(Equal("Action fake-action timed out after 1m0s"))
+			})
+
+			It("uses timeout from request instead of configured timeout", func() {
+				req = boshhandler.NewRequest("fake-reply", "fake-action", []byte(`{"arguments":[],"timeout":10}`))
+				dispatcher.Dispatch(req)
+				runTask("fake-generated-task-id")
+
+				timeService.Increment(10 * time.Second)
+				Eventually(taskService.TimedOutTaskIDs).Should(Equal([]string{"fake-generated-task-id"}))
+
+				timeoutErrs := taskService.TimedOutTaskErrs()
+				Expect(timeoutErrs[0].Error()).To(Equal("Action fake-action timed out after 10s"))
+			})
+
+			It("uses timeout from request for actions without configured timeout", func() {
+				delete(timeouts, "fake-action")
+				req = boshhandler.NewRequest("fake-reply", "fake-action", []byte(`{"arguments":[],"timeout":10}`))
+				dispatcher.Dispatch(req)
+				runTask("fake-generated-task-id")
+
+				timeService.Increment(10 * time.Second)
+				Eventually(taskService.TimedOutTaskIDs).Should(Equal([]string{"fake-generated-task-id"}))
+			})
+
+			It("does not count time task spends queued before it starts running", func() {
+				dispatcher.Dispatch(req)
+
+				timeService.Increment(time.Hour)
+				Consistently(taskService.TimedOutTaskIDs).Should(BeEmpty())
+
+				runTask("fake-generated-task-id")
+
+				timeService.Increment(59 * time.Second)
+				Consistently(taskService.TimedOutTaskIDs).Should(BeEmpty())
+
+				timeService.Increment(time.Second)
+				Eventually(taskService.TimedOutTaskIDs).Should(Equal([]string{"fake-generated-task-id"}))
+
+				timeoutErrs := taskService.TimedOutTaskErrs()
+				Expect(timeoutErrs[0].Error()).To(Equal("Action fake-action timed out after 1m0s"))
+			})
+
+			It("does not time out task that ended before the deadline", func() {
+				dispatcher.Dispatch(req)
+				runTask("fake-generated-task-id")
+
+				taskService.StartedTasks["fake-generated-task-id"].EndFunc(boshtask.Task{ID: "fake-generated-task-id"})
+
+				timeService.Increment(time.Minute)
+				Consistently(taskService.TimedOutTaskIDs).Should(BeEmpty())
+			})
+
+			It("still removes persistent task from task manager after task ends", func() {
+				action.Persistent = true
+				dispatcher.Dispatch(req)
+
+				taskService.StartedTasks["fake-generated-task-id"].EndFunc(boshtask.Task{ID: "fake-generated-task-id"})
+
+				taskInfos, _ := taskManager.GetInfos()
+				Expect(taskInfos).To(BeEmpty())
+			})
+
+			It("does not time out tasks of actions without timeout", func() {
+				delete(timeouts, "fake-action")
+				dispatcher.Dispatch(req)
+				runTask("fake-generated-task-id")
+
+				timeService.Increment(24 * time.Hour)
+				Consistently(taskService.TimedOutTaskIDs).Should(BeEmpty())
+			})
+
+			It("times out resumed task once configured timeout passes after resuming", func() {
+				action.Persistent = true
+				err := taskManager.AddInfo(boshtask.Info{
+					TaskID:  "fake-resumed-task-id",
+					Method:  "fake-action",
+					Payload: []byte(`{"arguments":[],"timeout":10}`),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				dispatcher.ResumePreviouslyDispatchedTasks()
+				runTask("fake-resumed-task-id")
+
+				timeService.Increment(10 * time.Second)
+				Eventually(taskService.TimedOutTaskIDs).Should(Equal([]string{"fake-resumed-task-id"}))
+			})
+		})
+
 		Describe("ResumePreviouslyDispatchedTasks", func() {
 			var firstAction, secondAction *fakeaction.TestAction
 
@@ -300,6 +652,37 @@ func init() {
 				}
 			})
 
+			It("resumes tasks with progress reporter and checkpoints they recorded before restart", func() {
+				err := taskManager.AddCheckpoint("fake-task-id-1", "fake-step")
+				Expect(err).ToNot(HaveOccurred())
+
+				actionFactory.RegisterAction("fake-action-1", firstAction)
+				actionFactory.RegisterAction("fake-action-2", secondAction)
+
+				dispatcher.ResumePreviouslyDispatchedTasks()
+
+				_, err = taskService.StartedTasks["fake-task-id-1"].Func()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(actionRunner.ResumeCheckpoints.IsCompleted("fake-step")).To(BeTrue())
+				Expect(actionRunner.ResumeCheckpoints.IsCompleted("fake-other-step")).To(BeFalse())
+
+				err = actionRunner.ResumeCheckpoints.Complete("fake-other-step")
+				Expect(err).ToNot(HaveOccurred())
+
+				taskInfos, err := taskManager.GetInfos()
+				Expect(err).ToNot(HaveOccurred())
+
+				for _, taskInfo := range taskInfos {
+					if taskInfo.TaskID == "fake-task-id-1" {
+						Expect(taskInfo.Checkpoints).To(Equal([]string{"fake-step", "fake-other-step"}))
+					}
+				}
+
+				actionRunner.ResumeReporter.ReportProgress("fake-stage", 42)
+				Expect(taskService.AddedEvents["fake-task-id-1"]).To(HaveLen(1))
+			})
+
 			It("removes tasks from task manager after each task finishes", func() {
 				actionFactory.RegisterAction("fake-action-1", firstAction)
 				actionFactory.RegisterAction("fake-action-2", secondAction)
@@ -370,6 +753,27 @@ func init() {
 				}
 			})
 
+			It("starts resumed tasks with resources modified by their actions", func() {
+				firstAction.TaskResources = []boshtask.Resource{boshtask.ResourceNetwork}
+				actionFactory.RegisterAction("fake-action-1", firstAction)
+				actionFactory.RegisterAction("fake-action-2", secondAction)
+
+				dispatcher.ResumePreviouslyDispatchedTasks()
+
+				Expect(taskService.StartedTasks["fake-task-id-1"].Resources).To(Equal([]boshtask.Resource{boshtask.ResourceNetwork}))
+				Expect(taskService.StartedTasks["fake-task-id-2"].Resources).To(BeEmpty())
+			})
+
+			It("starts resumed tasks with their methods", func() {
+				actionFactory.RegisterAction("fake-action-1", firstAction)
+				actionFactory.RegisterAction("fake-action-2", secondAction)
+
+				dispatcher.ResumePreviouslyDispatchedTasks()
+
+				Expect(taskService.StartedTasks["fake-task-id-1"].Method).To(Equal("fake-action-1"))
+				Expect(taskService.StartedTasks["fake-task-id-2"].Method).To(Equal("fake-action-2"))
+			})
+
 			It("allows to cancel after resume", func() {
 				actionFactory.RegisterAction("fake-action-1", firstAction)
 				actionFactory.RegisterAction("fake-action-2", secondAction)
@@ -404,5 +808,187 @@ func init() {
 				Expect(err.Error()).To(ContainSubstring("fake-cancel-err-2"))
 			})
 		})
+
+		Context("auditing", func() {
+			var (
+				req       boshhandler.Request
+				startedAt time.Time
+			)
+
+			BeforeEach(func() {
+				req = boshhandler.NewRequest("fake-reply", "fake-action", []byte(`{"arguments":["fake-password"]}`))
+				req.RequestID = "fake-request-id"
+				startedAt = timeService.Now()
+			})
+
+			It("records outcome of synchronous action without the payload", func() {
+				actionFactory.RegisterAction("fake-action", &fakeaction.TestAction{Asynchronous: false})
+
+				dispatcher.Dispatch(req)
+
+				Expect(auditLog.Records()).To(Equal([]boshaudit.Record{
+					{
+						Method:        "fake-action",
+						ReplyTo:       "fake-reply",
+						RequestID:     "fake-request-id",
+						StartedAt:     startedAt,
+						FinishedAt:    startedAt,
+						Outcome:       "done",
+						PayloadSHA256: boshaudit.PayloadSHA256(req.GetPayload()),
+					},
+				}))
+			})
+
+			It("records failure of synchronous action", func() {
+				actionFactory.RegisterAction("fake-action", &fakeaction.TestAction{Asynchronous: false})
+				actionRunner.RunErr = errors.New("fake-run-error")
+
+				dispatcher.Dispatch(req)
+
+				Expect(auditLog.Records()).To(HaveLen(1))
+				Expect(auditLog.Records()[0].Outcome).To(Equal("failed"))
+			})
+
+			It("records unknown and disabled actions as rejected", func() {
+				actionFactory.RegisterActionErr("fake-action", errors.New("fake-create-error"))
+				actionFactory.RegisterActionErr("ssh", boshaction.DisabledError{Method: "ssh"})
+
+				dispatcher.Dispatch(req)
+				dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "ssh", []byte{}))
+
+				Expect(auditLog.Records()).To(HaveLen(2))
+				Expect(auditLog.Records()[0].Method).To(Equal("fake-action"))
+				Expect(auditLog.Records()[0].Outcome).To(Equal(boshaudit.OutcomeRejected))
+				Expect(auditLog.Records()[1].Method).To(Equal("ssh"))
+				Expect(auditLog.Records()[1].Outcome).To(Equal(boshaudit.OutcomeRejected))
+			})
+
+			It("records asynchronous action once it is accepted so that it is audited even if agent restarts", func() {
+				actionFactory.RegisterAction("fake-action", &fakeaction.TestAction{Asynchronous: true})
+
+				dispatcher.Dispatch(req)
+
+				Expect(auditLog.Records()).To(Equal([]boshaudit.Record{
+					{
+						Method:        "fake-action",
+						ReplyTo:       "fake-reply",
+						RequestID:     "fake-request-id",
+						TaskID:        "fake-generated-task-id",
+						StartedAt:     startedAt,
+						FinishedAt:    startedAt,
+						Outcome:       boshaudit.OutcomeAccepted,
+						PayloadSHA256: boshaudit.PayloadSHA256(req.GetPayload()),
+					},
+				}))
+			})
+
+			It("records accepted asynchronous action before its task starts", func() {
+				actionFactory.RegisterAction("fake-action", &fakeaction.TestAction{Asynchronous: true})
+
+				var recordsWhenStarted []boshaudit.Record
+				taskService.StartTaskCallBack = func(_ boshtask.Task) {
+					recordsWhenStarted = auditLog.Records()
+				}
+
+				dispatcher.Dispatch(req)
+				Expect(recordsWhenStarted).To(HaveLen(1))
+				Expect(recordsWhenStarted[0].Outcome).To(Equal(boshaudit.OutcomeAccepted))
+			})
+
+			It("does not record accepted asynchronous action when its task cannot be created", func() {
+				actionFactory.RegisterAction("fake-action", &fakeaction.TestAction{Asynchronous: true})
+				taskService.CreateTaskErr = errors.New("fake-create-task-error")
+
+				dispatcher.Dispatch(req)
+
+				Expect(auditLog.Records()).To(HaveLen(1))
+				Expect(auditLog.Records()[0].Outcome).To(Equal("failed"))
+			})
+
+			It("records outcome of asynchronous action linked by task id once its task ends", func() {
+				actionFactory.RegisterAction("fake-action", &fakeaction.TestAction{Asynchronous: true})
+
+				dispatcher.Dispatch(req)
+				Expect(auditLog.Records()).To(HaveLen(1))
+
+				timeService.Increment(time.Minute)
+				taskService.StartedTasks["fake-generated-task-id"].EndFunc(boshtask.Task{ID: "fake-generated-task-id", State: boshtask.StateTimedOut})
+
+				Expect(auditLog.Records()[1:]).To(Equal([]boshaudit.Record{
+					{
+						Method:        "fake-action",
+						ReplyTo:       "fake-reply",
+						RequestID:     "fake-request-id",
+						TaskID:        "fake-generated-task-id",
+						StartedAt:     startedAt,
+						FinishedAt:    startedAt.Add(time.Minute),
+						Outcome:       "timed_out",
+						PayloadSHA256: boshaudit.PayloadSHA256(req.GetPayload()),
+					},
+				}))
+			})
+
+			It("still responds when audit log cannot be written", func() {
+				actionFactory.RegisterAction("fake-action", &fakeaction.TestAction{Asynchronous: false})
+				actionRunner.RunValue = "fake-value"
+				auditLog.AppendErr = errors.New("fake-append-err")
+
+				resp := dispatcher.Dispatch(req)
+				Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value")))
+			})
+
+			It("still counts actions when audit log cannot be written", func() {
+				actionFactory.RegisterAction("fake-action", &fakeaction.TestAction{Asynchronous: false})
+				auditLog.AppendErr = errors.New("fake-append-err")
+
+				dispatcher.Dispatch(req)
+
+				Expect(metricFamily(registry, "bosh_agent_actions_total").Samples).To(HaveLen(1))
+			})
+		})
+
+		Context("metrics", func() {
+			It("counts actions by method and outcome and observes how long they ran", func() {
+				actionFactory.RegisterAction("fake-action", &fakeaction.TestAction{Asynchronous: true})
+
+				dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "fake-action", []byte(`{"arguments":[]}`)))
+				Expect(metricFamily(registry, "bosh_agent_actions_total").Samples).To(BeEmpty())
+
+				timeService.Increment(1500 * time.Millisecond)
+				taskService.StartedTasks["fake-generated-task-id"].EndFunc(boshtask.Task{ID: "fake-generated-task-id", State: boshtask.StateDone})
+
+				Expect(metricFamily(registry, "bosh_agent_actions_total").Samples).To(Equal([]boshmetrics.Sample{
+					{Labels: []boshmetrics.Label{{Name: "method", Value: "fake-action"}, {Name: "outcome", Value: "done"}}, Value: 1},
+				}))
+
+				Expect(metricFamily(registry, "bosh_agent_action_duration_seconds").Samples).To(Equal([]boshmetrics.Sample{
+					{Name: "bosh_agent_action_duration_seconds_sum", Labels: []boshmetrics.Label{{Name: "method", Value: "fake-action"}}, Value: 1.5},
+					{Name: "bosh_agent_action_duration_seconds_count", Labels: []boshmetrics.Label{{Name: "method", Value: "fake-action"}}, Value: 1},
+				}))
+			})
+
+			It("counts rejected actions without observing how long they ran", func() {
+				actionFactory.RegisterActionErr("fake-action", errors.New("fake-create-error"))
+
+				dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "fake-action", []byte{}))
+
+				Expect(metricFamily(registry, "bosh_agent_actions_total").Samples).To(Equal([]boshmetrics.Sample{
+					{Labels: []boshmetrics.Label{{Name: "method", Value: "fake-action"}, {Name: "outcome", Value: boshaudit.OutcomeRejected}}, Value: 1},
+				}))
+				Expect(metricFamily(registry, "bosh_agent_action_duration_seconds").Samples).To(BeEmpty())
+			})
+		})
 	})
 }
+
+func metricFamily(registry *boshmetrics.Registry, name string) boshmetrics.Family {
+	for _, family := range registry.Families() {
+		if family.Name == name {
+			return family
+		}
+	}
+
+	Fail(fmt.Sprintf("Metric %s is not registered", name))
+
+	return boshmetrics.Family{}
+}
@@ -10,6 +10,7 @@ import (
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshsyslog "github.com/cloudfoundry/bosh-agent/syslog"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -24,6 +25,7 @@ const (
 type Agent struct {
 	logger            boshlog.Logger
 	mbusHandler       boshhandler.Handler
+	localHandler      boshhandler.Handler
 	platform          boshplatform.Platform
 	actionDispatcher  ActionDispatcher
 	heartbeatInterval time.Duration
@@ -33,11 +35,16 @@ type Agent struct {
 	settingsService   boshsettings.Service
 	uuidGenerator     boshuuid.Generator
 	timeService       clock.Clock
+
+	vitalsChecker        boshalert.VitalsChecker
+	vitalsHistory        boshvitals.History
+	vitalsSampleInterval time.Duration
 }
 
 func New(
 	logger boshlog.Logger,
 	mbusHandler boshhandler.Handler,
+	localHandler boshhandler.Handler,
 	platform boshplatform.Platform,
 	actionDispatcher ActionDispatcher,
 	jobSupervisor boshjobsuper.JobSupervisor,
@@ -47,10 +54,14 @@ func New(
 	settingsService boshsettings.Service,
 	uuidGenerator boshuuid.Generator,
 	timeService clock.Clock,
+	vitalsChecker boshalert.VitalsChecker,
+	vitalsHistory boshvitals.History,
+	vitalsSampleInterval time.Duration,
 ) Agent {
 	return Agent{
 		logger:            logger,
 		mbusHandler:       mbusHandler,
+		localHandler:      localHandler,
 		platform:          platform,
 		actionDispatcher:  actionDispatcher,
 		heartbeatInterval: heartbeatInterval,
@@ -60,6 +71,10 @@ func New(
 		settingsService:   settingsService,
 		uuidGenerator:     uuidGenerator,
 		timeService:       timeService,
+
+		vitalsChecker:        vitalsChecker,
+		vitalsHistory:        vitalsHistory,
+		vitalsSampleInterval: vitalsSampleInterval,
 	}
 }
 
@@ -70,8 +85,14 @@ func (a Agent) Run() error {
 
 	go a.subscribeActionDispatcher(errCh)
 
+	go a.serveLocalRequests()
+
 	go a.generateHeartbeats(errCh)
 
+	if a.vitalsSampleInterval > 0 {
+		go a.sampleVitals(errCh)
+	}
+
 	go a.jobSupervisor.MonitorJobFailures(a.handleJobFailure(errCh))
 
 	go a.syslogServer.Start(a.handleSyslogMsg(errCh))
@@ -93,6 +114,17 @@ func (a Agent) subscribeActionDispatcher(errCh chan error) {
 	errCh <- err
 }
 
+// serveLocalRequests lets on-VM tools talk to the agent;
+// agent keeps running even if they cannot
+func (a Agent) serveLocalRequests() {
+	defer a.logger.HandlePanic("Agent Local Handler")
+
+	err := a.localHandler.Run(a.actionDispatcher.Dispatch)
+	if err != nil {
+		a.logger.Error(agentLogTag, "Local handler: %s", err.Error())
+	}
+}
+
 func (a Agent) generateHeartbeats(errCh chan error) {
 	a.logger.Debug(agentLogTag, "Generating heartbeat")
 	defer a.logger.HandlePanic("Agent Generate Heartbeats")
@@ -139,15 +171,58 @@ func (a Agent) getHeartbeat() (Heartbeat, error) {
 		return Heartbeat{}, bosherr.WrapError(err, "Getting job spec")
 	}
 
+	// Heartbeat is still sent without processes when job supervisor cannot tell them
+	processes, err := a.jobSupervisor.Processes()
+	if err != nil {
+		a.logger.Warn(agentLogTag, "Getting job processes: %s", err.Error())
+	}
+
 	hb := Heartbeat{
-		Job:      spec.JobSpec.Name,
-		Index:    spec.Index,
-		JobState: a.jobSupervisor.Status(),
-		Vitals:   vitals,
+		Job:       spec.JobSpec.Name,
+		Index:     spec.Index,
+		JobState:  a.jobSupervisor.Status(),
+		Vitals:    vitals,
+		Processes: processes,
 	}
 	return hb, nil
 }
 
+// sampleVitals keeps vitals more often than heartbeats are sent
+// so that short spikes can be looked at later, and sends alerts once
+// the same sample crosses or clears configured thresholds;
+// vitals that cannot be collected are already reported through heartbeats
+func (a Agent) sampleVitals(errCh chan error) {
+	defer a.logger.HandlePanic("Agent Sample Vitals")
+
+	ticker := a.timeService.NewTicker(a.vitalsSampleInterval)
+	defer ticker.Stop()
+
+	for range ticker.C() {
+		vitals, err := a.platform.GetVitalsService().Get()
+		if err != nil {
+			a.logger.Error(agentLogTag, "Getting vitals sample: %s", err.Error())
+			continue
+		}
+
+		err = a.vitalsHistory.Add(vitals)
+		if err != nil {
+			a.logger.Error(agentLogTag, "Recording vitals: %s", err.Error())
+		}
+
+		alerts, err := a.vitalsChecker.Check(vitals)
+		if err != nil {
+			a.logger.Error(agentLogTag, "Checking vitals: %s", err.Error())
+		}
+
+		for _, alert := range alerts {
+			err = a.mbusHandler.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+			if err != nil {
+				errCh <- bosherr.WrapError(err, "Sending vitals alert")
+			}
+		}
+	}
+}
+
 func (a Agent) handleJobFailure(errCh chan error) boshjobsuper.JobFailureHandler {
 	return func(monitAlert boshalert.MonitAlert) error {
 		alertAdapter := boshalert.NewMonitAdapter(monitAlert, a.settingsService, a.timeService)
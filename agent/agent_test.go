@@ -14,10 +14,12 @@ import (
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	fakeagent "github.com/cloudfoundry/bosh-agent/agent/fakes"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	fakembus "github.com/cloudfoundry/bosh-agent/mbus/fakes"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	fakevitals "github.com/cloudfoundry/bosh-agent/platform/vitals/fakes"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	boshsyslog "github.com/cloudfoundry/bosh-agent/syslog"
 	fakesyslog "github.com/cloudfoundry/bosh-agent/syslog/fakes"
@@ -31,6 +33,7 @@ func init() {
 		var (
 			logger           boshlog.Logger
 			handler          *fakembus.FakeHandler
+			localHandler     *fakembus.FakeHandler
 			platform         *fakeplatform.FakePlatform
 			actionDispatcher *fakeagent.FakeActionDispatcher
 			jobSupervisor    *fakejobsuper.FakeJobSupervisor
@@ -39,12 +42,18 @@ func init() {
 			settingsService  *fakesettings.FakeSettingsService
 			uuidGenerator    *fakeuuid.FakeGenerator
 			timeService      *fakeclock.FakeClock
+			vitalsChecker    boshalert.VitalsChecker
+			vitalsHistory    *fakevitals.FakeHistory
 			agent            Agent
+
+			// Closed once test is done so that blocking handler callbacks return
+			testDone chan struct{}
 		)
 
 		BeforeEach(func() {
 			logger = boshlog.NewLogger(boshlog.LevelNone)
 			handler = &fakembus.FakeHandler{}
+			localHandler = &fakembus.FakeHandler{}
 			platform = fakeplatform.NewFakePlatform()
 			actionDispatcher = &fakeagent.FakeActionDispatcher{}
 			jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
@@ -53,9 +62,13 @@ func init() {
 			settingsService = &fakesettings.FakeSettingsService{}
 			uuidGenerator = &fakeuuid.FakeGenerator{}
 			timeService = fakeclock.NewFakeClock(time.Now())
+			vitalsChecker = boshalert.NewVitalsChecker(boshalert.VitalsOptions{}, 1, settingsService, uuidGenerator, timeService)
+			vitalsHistory = &fakevitals.FakeHistory{}
+			testDone = make(chan struct{})
 			agent = New(
 				logger,
 				handler,
+				localHandler,
 				platform,
 				actionDispatcher,
 				jobSupervisor,
@@ -65,9 +78,16 @@ func init() {
 				settingsService,
 				uuidGenerator,
 				timeService,
+				vitalsChecker,
+				vitalsHistory,
+				0,
 			)
 		})
 
+		AfterEach(func() {
+			close(testDone)
+		})
+
 		Describe("Run", func() {
 			It("lets dispatcher handle requests arriving via handler", func() {
 				err := agent.Run()
@@ -83,6 +103,44 @@ func init() {
 				Expect(resp).To(Equal(expectedResp))
 			})
 
+			It("lets dispatcher handle requests arriving via local handler", func() {
+				handler.KeepOnRunning()
+
+				expectedResp := boshhandler.NewValueResponse("pong")
+				actionDispatcher.DispatchResp = expectedResp
+
+				localHandlerRunning := make(chan struct{})
+				done := testDone
+				localHandler.RunCallBack = func() {
+					close(localHandlerRunning)
+					<-done
+				}
+
+				go agent.Run()
+
+				Eventually(localHandlerRunning).Should(BeClosed())
+
+				req := boshhandler.NewRequest("", "ping", []byte("fake-payload"))
+				resp := localHandler.RunFunc(req)
+
+				Expect(actionDispatcher.DispatchReq).To(Equal(req))
+				Expect(resp).To(Equal(expectedResp))
+			})
+
+			It("keeps running when local handler fails", func() {
+				localHandler.RunErr = errors.New("fake-run-err")
+
+				localHandlerRan := make(chan struct{})
+				localHandler.RunCallBack = func() { close(localHandlerRan) }
+
+				handler.RunCallBack = func() {
+					Eventually(localHandlerRan).Should(BeClosed())
+				}
+
+				err := agent.Run()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
 			It("resumes persistent actions *before* dispatching new requests", func() {
 				resumedBeforeStartingToDispatch := false
 				handler.RunCallBack = func() {
@@ -108,6 +166,9 @@ func init() {
 					}
 
 					jobSupervisor.StatusStatus = "fake-state"
+					jobSupervisor.ProcessesProcesses = []boshjobsuper.Process{
+						{Name: "fake-process", State: "running"},
+					}
 
 					platform.FakeVitalsService.GetVitals = boshvitals.Vitals{
 						Load: []string{"a", "b", "c"},
@@ -121,6 +182,9 @@ func init() {
 					Index:    &expectedJobIndex,
 					JobState: "fake-state",
 					Vitals:   boshvitals.Vitals{Load: []string{"a", "b", "c"}},
+					Processes: []boshjobsuper.Process{
+						{Name: "fake-process", State: "running"},
+					},
 				}
 
 				It("sends initial heartbeat", func() {
@@ -129,6 +193,7 @@ func init() {
 					agent = New(
 						logger,
 						handler,
+						localHandler,
 						platform,
 						actionDispatcher,
 						jobSupervisor,
@@ -138,6 +203,9 @@ func init() {
 						settingsService,
 						uuidGenerator,
 						timeService,
+						vitalsChecker,
+						vitalsHistory,
+						0,
 					)
 
 					// Immediately exit after sending initial heartbeat
@@ -202,6 +270,26 @@ func init() {
 				})
 			})
 
+			Context("when the agent fails to get job processes for a heartbeat", func() {
+				BeforeEach(func() {
+					jobSupervisor.ProcessesErr = errors.New("fake-processes-error")
+					handler.KeepOnRunning()
+				})
+
+				It("sends heartbeat without processes", func() {
+					handler.SendErr = errors.New("stop")
+
+					err := agent.Run()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("stop"))
+
+					sendInputs := handler.SendInputs()
+					Expect(sendInputs).To(HaveLen(1))
+					Expect(sendInputs[0].Topic).To(Equal(boshhandler.Heartbeat))
+					Expect(sendInputs[0].Message.(Heartbeat).Processes).To(BeNil())
+				})
+			})
+
 			Context("when the agent fails to get vitals for a heartbeat", func() {
 				BeforeEach(func() {
 					platform.FakeVitalsService.GetErr = errors.New("fake-vitals-service-error")
@@ -287,6 +375,183 @@ func init() {
 					Message: expectedAlert,
 				}))
 			})
+
+			It("sends vitals alerts to health manager once vitals cross thresholds", func() {
+				handler.KeepOnRunning()
+
+				platform.FakeVitalsService.GetVitals = boshvitals.Vitals{
+					Mem: boshvitals.MemoryVitals{Percent: "95"},
+				}
+
+				uuidGenerator.GeneratedUUID = "fake-uuid"
+
+				vitalsChecker = boshalert.NewVitalsChecker(
+					boshalert.VitalsOptions{
+						Thresholds: []boshalert.ThresholdOptions{{Vital: "mem.percent", Raise: 90}},
+					},
+					1,
+					settingsService,
+					uuidGenerator,
+					timeService,
+				)
+
+				agent = New(
+					logger,
+					handler,
+					localHandler,
+					platform,
+					actionDispatcher,
+					jobSupervisor,
+					specService,
+					syslogServer,
+					5*time.Hour,
+					settingsService,
+					uuidGenerator,
+					timeService,
+					vitalsChecker,
+					vitalsHistory,
+					10*time.Second,
+				)
+
+				// Fail the first time handler.Send is called for an alert (ignore heartbeats)
+				handler.SendCallback = func(input fakembus.SendInput) {
+					if input.Topic == boshhandler.Alert {
+						handler.SendErr = errors.New("stop")
+					}
+				}
+
+				errCh := make(chan error)
+				go func() { errCh <- agent.Run() }()
+
+				Eventually(timeService.WatcherCount).Should(Equal(1))
+				timeService.Increment(10 * time.Second)
+
+				var err error
+				Eventually(errCh).Should(Receive(&err))
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Sending vitals alert"))
+
+				expectedAlert := boshalert.Alert{
+					ID:        "fake-uuid",
+					Severity:  boshalert.SeverityWarning,
+					Title:     "mem.percent - threshold exceeded",
+					Summary:   "mem.percent is 95 which is above 90",
+					CreatedAt: timeService.Now().Unix(),
+				}
+
+				Expect(handler.SendInputs()).To(ContainElement(fakembus.SendInput{
+					Target:  boshhandler.HealthMonitor,
+					Topic:   boshhandler.Alert,
+					Message: expectedAlert,
+				}))
+			})
+
+			It("records vitals in history periodically", func() {
+				handler.KeepOnRunning()
+
+				platform.FakeVitalsService.GetVitals = boshvitals.Vitals{
+					Mem: boshvitals.MemoryVitals{Percent: "42"},
+				}
+
+				agent = New(
+					logger,
+					handler,
+					localHandler,
+					platform,
+					actionDispatcher,
+					jobSupervisor,
+					specService,
+					syslogServer,
+					5*time.Hour,
+					settingsService,
+					uuidGenerator,
+					timeService,
+					vitalsChecker,
+					vitalsHistory,
+					5*time.Second,
+				)
+
+				go agent.Run()
+
+				Eventually(timeService.WatcherCount).Should(Equal(1))
+				Expect(vitalsHistory.AddedVitals()).To(BeEmpty())
+
+				timeService.Increment(5 * time.Second)
+				Eventually(vitalsHistory.AddedVitals).Should(HaveLen(1))
+
+				timeService.Increment(5 * time.Second)
+				Eventually(vitalsHistory.AddedVitals).Should(HaveLen(2))
+
+				Expect(vitalsHistory.AddedVitals()[1]).To(Equal(boshvitals.Vitals{
+					Mem: boshvitals.MemoryVitals{Percent: "42"},
+				}))
+			})
+
+			It("checks the same vitals sample that is recorded in history", func() {
+				handler.KeepOnRunning()
+
+				platform.FakeVitalsService.GetVitals = boshvitals.Vitals{
+					Mem: boshvitals.MemoryVitals{Percent: "95"},
+				}
+
+				uuidGenerator.GeneratedUUID = "fake-uuid"
+
+				vitalsChecker = boshalert.NewVitalsChecker(
+					boshalert.VitalsOptions{
+						Thresholds: []boshalert.ThresholdOptions{{Vital: "mem.percent", Raise: 90}},
+					},
+					1,
+					settingsService,
+					uuidGenerator,
+					timeService,
+				)
+
+				agent = New(
+					logger,
+					handler,
+					localHandler,
+					platform,
+					actionDispatcher,
+					jobSupervisor,
+					specService,
+					syslogServer,
+					5*time.Hour,
+					settingsService,
+					uuidGenerator,
+					timeService,
+					vitalsChecker,
+					vitalsHistory,
+					5*time.Second,
+				)
+
+				go agent.Run()
+
+				// Single ticker drives both history and threshold checks
+				Eventually(timeService.WatcherCount).Should(Equal(1))
+				Consistently(timeService.WatcherCount).Should(Equal(1))
+
+				timeService.Increment(5 * time.Second)
+				Eventually(vitalsHistory.AddedVitals).Should(HaveLen(1))
+
+				alertSendInputs := func() []fakembus.SendInput {
+					var inputs []fakembus.SendInput
+					for _, input := range handler.SendInputs() {
+						if input.Topic == boshhandler.Alert {
+							inputs = append(inputs, input)
+						}
+					}
+					return inputs
+				}
+
+				Eventually(alertSendInputs).Should(HaveLen(1))
+				Expect(alertSendInputs()[0].Message).To(Equal(boshalert.Alert{
+					ID:        "fake-uuid",
+					Severity:  boshalert.SeverityWarning,
+					Title:     "mem.percent - threshold exceeded",
+					Summary:   "mem.percent is 95 which is above 90",
+					CreatedAt: timeService.Now().Unix(),
+				}))
+			})
 		})
 	})
 }
@@ -0,0 +1,207 @@
+package alert
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal-golang/clock"
+
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+const (
+	// Threshold is cleared once vital drops below 90% of raise value
+	// unless clear value is configured
+	DefaultThresholdClearRatio = 0.9
+)
+
+var vitalNameExpression = regexp.MustCompile(
+	`^(cpu\.(user|sys|wait)|(mem|swap)\.percent|load\.(1m|5m|15m)(_per_core)?|disk\.[^.]+\.(percent|inode_percent))$`,
+)
+
+// VitalsOptions are checked against every vitals sample
+// taken at vitals history interval
+type VitalsOptions struct {
+	// Vitals are not checked when no thresholds are configured
+	Thresholds []ThresholdOptions
+}
+
+type ThresholdOptions struct {
+	// One of cpu.user, cpu.sys, cpu.wait, mem.percent, swap.percent,
+	// load.1m, load.5m, load.15m (or load.1m_per_core etc. to divide load by number of cores),
+	// disk.<system|ephemeral|persistent>.percent or disk.<...>.inode_percent
+	Vital string
+
+	// Alert is raised once vital goes above this value
+	Raise float64
+
+	// Alert is resolved once vital drops below this value again
+	// so that vital hovering around Raise does not cause flapping.
+	// Defaults to DefaultThresholdClearRatio of Raise when not set
+	Clear float64
+
+	// Severity of raised alert; defaults to SeverityWarning when not set
+	Severity SeverityLevel
+}
+
+func (o VitalsOptions) Validate() error {
+	for _, threshold := range o.Thresholds {
+		if !vitalNameExpression.MatchString(threshold.Vital) {
+			return bosherr.Errorf("Unknown vital '%s'", threshold.Vital)
+		}
+
+		if threshold.Clear > threshold.Raise {
+			return bosherr.Errorf("Clear value %s of vital '%s' must not be above raise value %s",
+				formatVital(threshold.Clear), threshold.Vital, formatVital(threshold.Raise))
+		}
+	}
+
+	return nil
+}
+
+func (o ThresholdOptions) clear() float64 {
+	if o.Clear == 0 {
+		return o.Raise * DefaultThresholdClearRatio
+	}
+	return o.Clear
+}
+
+func (o ThresholdOptions) severity() SeverityLevel {
+	if o.Severity == 0 {
+		return SeverityWarning
+	}
+	return o.Severity
+}
+
+// VitalsChecker remembers which thresholds were crossed
+// and returns alerts only when vital crosses or clears threshold
+type VitalsChecker interface {
+	Check(vitals boshvitals.Vitals) ([]Alert, error)
+}
+
+type vitalsChecker struct {
+	thresholds      []ThresholdOptions
+	numCPU          int
+	settingsService boshsettings.Service
+	uuidGenerator   boshuuid.Generator
+	timeService     clock.Clock
+
+	// Indexed the same as thresholds
+	raised []bool
+}
+
+func NewVitalsChecker(
+	options VitalsOptions,
+	numCPU int,
+	settingsService boshsettings.Service,
+	uuidGenerator boshuuid.Generator,
+	timeService clock.Clock,
+) VitalsChecker {
+	return &vitalsChecker{
+		thresholds:      options.Thresholds,
+		numCPU:          numCPU,
+		settingsService: settingsService,
+		uuidGenerator:   uuidGenerator,
+		timeService:     timeService,
+		raised:          make([]bool, len(options.Thresholds)),
+	}
+}
+
+func (c *vitalsChecker) Check(vitals boshvitals.Vitals) ([]Alert, error) {
+	values := c.values(vitals)
+	alerts := []Alert{}
+
+	for i, threshold := range c.thresholds {
+		// Vital might not be known at the moment (e.g. persistent disk is not mounted)
+		value, found := values[threshold.Vital]
+		if !found {
+			continue
+		}
+
+		var alert Alert
+		var err error
+
+		switch {
+		case !c.raised[i] && value > threshold.Raise:
+			alert, err = c.alert(
+				threshold.severity(),
+				"threshold exceeded",
+				fmt.Sprintf("%s is %s which is above %s", threshold.Vital, formatVital(value), formatVital(threshold.Raise)),
+			)
+
+		case c.raised[i] && value < threshold.clear():
+			alert, err = c.alert(
+				SeverityWarning,
+				"threshold cleared",
+				fmt.Sprintf("%s is %s which is below %s again", threshold.Vital, formatVital(value), formatVital(threshold.clear())),
+			)
+
+		default:
+			continue
+		}
+
+		if err != nil {
+			// State is kept so that alert is built again on next check
+			return alerts, bosherr.WrapErrorf(err, "Building alert for vital '%s'", threshold.Vital)
+		}
+
+		c.raised[i] = !c.raised[i]
+		alerts = append(alerts, c.titled(alert, threshold.Vital))
+	}
+
+	return alerts, nil
+}
+
+func (c *vitalsChecker) alert(severity SeverityLevel, event, summary string) (Alert, error) {
+	uuid, err := c.uuidGenerator.Generate()
+	if err != nil {
+		return Alert{}, bosherr.WrapError(err, "Generating uuid")
+	}
+
+	return Alert{
+		ID:        uuid,
+		Severity:  severity,
+		Title:     event,
+		Summary:   summary,
+		CreatedAt: c.timeService.Now().Unix(),
+	}, nil
+}
+
+// titled names vital and VM's IPs in the title the same way monit alerts name their service
+func (c *vitalsChecker) titled(alert Alert, vital string) Alert {
+	settings := c.settingsService.GetSettings()
+
+	ips := settings.Networks.IPs()
+	sort.Strings(ips)
+
+	if len(ips) > 0 {
+		vital = fmt.Sprintf("%s (%s)", vital, strings.Join(ips, ", "))
+	}
+
+	alert.Title = fmt.Sprintf("%s - %s", vital, alert.Title)
+
+	return alert
+}
+
+// values adds load per core to vitals so that thresholds do not depend on VM size
+func (c *vitalsChecker) values(vitals boshvitals.Vitals) map[string]float64 {
+	values := vitals.Values()
+
+	for _, period := range []string{"1m", "5m", "15m"} {
+		if load, found := values["load."+period]; found && c.numCPU > 0 {
+			values["load."+period+"_per_core"] = load / float64(c.numCPU)
+		}
+	}
+
+	return values
+}
+
+func formatVital(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,215 @@
+package alert_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/alert"
+
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+	"github.com/pivotal-golang/clock/fakeclock"
+)
+
+var _ = Describe("VitalsOptions", func() {
+	Describe("Validate", func() {
+		It("accepts known vitals", func() {
+			options := VitalsOptions{
+				Thresholds: []ThresholdOptions{
+					{Vital: "cpu.wait", Raise: 50},
+					{Vital: "mem.percent", Raise: 90},
+					{Vital: "swap.percent", Raise: 50},
+					{Vital: "load.1m_per_core", Raise: 2},
+					{Vital: "load.15m", Raise: 8},
+					{Vital: "disk.persistent.percent", Raise: 90, Clear: 85},
+					{Vital: "disk.ephemeral.inode_percent", Raise: 95},
+				},
+			}
+			Expect(options.Validate()).ToNot(HaveOccurred())
+		})
+
+		It("returns error for unknown vital", func() {
+			options := VitalsOptions{Thresholds: []ThresholdOptions{{Vital: "mem.kb", Raise: 90}}}
+
+			err := options.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Unknown vital 'mem.kb'"))
+		})
+
+		It("returns error when clear value is above raise value", func() {
+			options := VitalsOptions{Thresholds: []ThresholdOptions{{Vital: "mem.percent", Raise: 90, Clear: 95}}}
+
+			err := options.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Clear value 95 of vital 'mem.percent' must not be above raise value 90"))
+		})
+	})
+})
+
+var _ = Describe("vitalsChecker", func() {
+	var (
+		settingsService *fakesettings.FakeSettingsService
+		uuidGenerator   *fakeuuid.FakeGenerator
+		timeService     *fakeclock.FakeClock
+		options         VitalsOptions
+		checker         VitalsChecker
+	)
+
+	BeforeEach(func() {
+		settingsService = &fakesettings.FakeSettingsService{}
+		uuidGenerator = &fakeuuid.FakeGenerator{GeneratedUUID: "fake-uuid"}
+		timeService = fakeclock.NewFakeClock(time.Now())
+		options = VitalsOptions{
+			Thresholds: []ThresholdOptions{
+				{Vital: "disk.persistent.percent", Raise: 90, Clear: 85, Severity: SeverityCritical},
+				{Vital: "swap.percent", Raise: 50},
+				{Vital: "load.1m_per_core", Raise: 2},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		checker = NewVitalsChecker(options, 4, settingsService, uuidGenerator, timeService)
+	})
+
+	persistentDisk := func(percent string) boshvitals.Vitals {
+		return boshvitals.Vitals{
+			Disk: boshvitals.DiskVitals{
+				"persistent": boshvitals.SpecificDiskVitals{Percent: percent},
+			},
+		}
+	}
+
+	It("returns no alerts while vitals are below thresholds", func() {
+		alerts, err := checker.Check(boshvitals.Vitals{
+			Load: []string{"7.99", "0", "0"},
+			Swap: boshvitals.MemoryVitals{Percent: "50"},
+			Disk: boshvitals.DiskVitals{
+				"persistent": boshvitals.SpecificDiskVitals{Percent: "90"},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("raises alert once vital goes above threshold", func() {
+		alerts, err := checker.Check(persistentDisk("91"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(Equal([]Alert{
+			{
+				ID:        "fake-uuid",
+				Severity:  SeverityCritical,
+				Title:     "disk.persistent.percent - threshold exceeded",
+				Summary:   "disk.persistent.percent is 91 which is above 90",
+				CreatedAt: timeService.Now().Unix(),
+			},
+		}))
+	})
+
+	It("compares load per core with threshold", func() {
+		alerts, err := checker.Check(boshvitals.Vitals{Load: []string{"8.40", "1.00", "0.50"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(HaveLen(1))
+		Expect(alerts[0].Severity).To(Equal(SeverityWarning))
+		Expect(alerts[0].Summary).To(Equal("load.1m_per_core is 2.1 which is above 2"))
+	})
+
+	It("does not raise alert again while vital stays above clear value", func() {
+		alerts, err := checker.Check(persistentDisk("91"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(HaveLen(1))
+
+		for _, percent := range []string{"95", "89", "85", "91"} {
+			alerts, err = checker.Check(persistentDisk(percent))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(alerts).To(BeEmpty())
+		}
+	})
+
+	It("resolves alert once vital drops below clear value and raises it again afterwards", func() {
+		_, err := checker.Check(persistentDisk("91"))
+		Expect(err).ToNot(HaveOccurred())
+
+		timeService.Increment(time.Minute)
+
+		alerts, err := checker.Check(persistentDisk("84"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(Equal([]Alert{
+			{
+				ID:        "fake-uuid",
+				Severity:  SeverityWarning,
+				Title:     "disk.persistent.percent - threshold cleared",
+				Summary:   "disk.persistent.percent is 84 which is below 85 again",
+				CreatedAt: timeService.Now().Unix(),
+			},
+		}))
+
+		alerts, err = checker.Check(persistentDisk("89"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+
+		alerts, err = checker.Check(persistentDisk("91"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(HaveLen(1))
+		Expect(alerts[0].Title).To(Equal("disk.persistent.percent - threshold exceeded"))
+	})
+
+	It("clears threshold below DefaultThresholdClearRatio of raise value when clear value is not set", func() {
+		_, err := checker.Check(boshvitals.Vitals{Swap: boshvitals.MemoryVitals{Percent: "60"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		alerts, err := checker.Check(boshvitals.Vitals{Swap: boshvitals.MemoryVitals{Percent: "45"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+
+		alerts, err = checker.Check(boshvitals.Vitals{Swap: boshvitals.MemoryVitals{Percent: "44"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(HaveLen(1))
+		Expect(alerts[0].Summary).To(Equal("swap.percent is 44 which is below 45 again"))
+	})
+
+	It("keeps state of vitals that are not collected at the moment", func() {
+		_, err := checker.Check(persistentDisk("91"))
+		Expect(err).ToNot(HaveOccurred())
+
+		alerts, err := checker.Check(boshvitals.Vitals{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(BeEmpty())
+
+		alerts, err = checker.Check(persistentDisk("80"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(HaveLen(1))
+		Expect(alerts[0].Title).To(Equal("disk.persistent.percent - threshold cleared"))
+	})
+
+	It("includes IPs of the VM in the title", func() {
+		settingsService.Settings.Networks = boshsettings.Networks{
+			"fake-net1": boshsettings.Network{IP: "10.0.0.2"},
+			"fake-net2": boshsettings.Network{IP: "10.0.0.1"},
+		}
+
+		alerts, err := checker.Check(persistentDisk("91"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts[0].Title).To(Equal("disk.persistent.percent (10.0.0.1, 10.0.0.2) - threshold exceeded"))
+	})
+
+	It("raises alert on next check when it could not be built", func() {
+		uuidGenerator.GenerateError = errors.New("fake-generate-err")
+
+		alerts, err := checker.Check(persistentDisk("91"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-generate-err"))
+		Expect(alerts).To(BeEmpty())
+
+		uuidGenerator.GenerateError = nil
+
+		alerts, err = checker.Check(persistentDisk("91"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(alerts).To(HaveLen(1))
+	})
+})
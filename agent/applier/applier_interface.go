@@ -2,9 +2,13 @@ package applier
 
 import (
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type Applier interface {
 	Prepare(desiredApplySpec boshas.ApplySpec) error
-	Apply(currentApplySpec, desiredApplySpec boshas.ApplySpec) error
+
+	// Apply skips steps recorded in checkpoints by earlier (interrupted) apply
+	// of the same desired spec; it stops and returns boshtask.ErrCancelled once cancelCh is closed
+	Apply(currentApplySpec, desiredApplySpec boshas.ApplySpec, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints, cancelCh <-chan struct{}) error
 }
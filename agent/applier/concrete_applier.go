@@ -1,15 +1,27 @@
 package applier
 
 import (
+	"fmt"
+
 	as "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/jobs"
+	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
+// Steps of apply recorded with checkpoints
+const (
+	applyStepJobsApplied        = "jobs_applied"
+	applyStepPackagesApplied    = "packages_applied"
+	applyStepJobsConfigured     = "jobs_configured"
+	applyStepSupervisorReloaded = "job_supervisor_reloaded"
+)
+
 type concreteApplier struct {
 	jobApplier        jobs.Applier
 	packageApplier    packages.Applier
@@ -52,15 +64,109 @@ func (a *concreteApplier) Prepare(desiredApplySpec as.ApplySpec) error {
 	return nil
 }
 
-func (a *concreteApplier) Apply(currentApplySpec, desiredApplySpec as.ApplySpec) error {
+// Apply reports progress in the following proportions:
+// applying jobs up to 30%, applying packages up to 80%,
+// configuring jobs and reloading job supervisor up to 100%.
+// Apply can be cancelled until jobs are configured; bundles that were
+// installed only for the desired apply spec are removed on cancellation
+// and bundles of the current apply spec are enabled again
+// before its jobs are given back to job supervisor.
+// Each step is recorded with checkpoints once it is completed
+// so that apply resumed after agent restart continues with the next step.
+func (a *concreteApplier) Apply(currentApplySpec, desiredApplySpec as.ApplySpec, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints, cancelCh <-chan struct{}) error {
+	// Nothing needs to be cleaned up before jobs are removed from job supervisor
+	if boshtask.IsCancelled(cancelCh) {
+		return boshtask.ErrCancelled
+	}
+
+	err := a.apply(currentApplySpec, desiredApplySpec, reporter, checkpoints, cancelCh)
+	if err != nil && boshtask.IsCancelled(cancelCh) {
+		cleanUpErr := a.cleanUpCancelledApply(currentApplySpec)
+		if cleanUpErr != nil {
+			return bosherr.NewMultiError(err, cleanUpErr)
+		}
+	}
+
+	return err
+}
+
+func (a *concreteApplier) apply(currentApplySpec, desiredApplySpec as.ApplySpec, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints, cancelCh <-chan struct{}) error {
+	jobs := desiredApplySpec.Jobs()
+
+	if !checkpoints.IsCompleted(applyStepJobsApplied) {
+		err := a.applyJobs(currentApplySpec, desiredApplySpec, reporter, cancelCh)
+		if err != nil {
+			return err
+		}
+
+		err = checkpoints.Complete(applyStepJobsApplied)
+		if err != nil {
+			return bosherr.WrapError(err, "Recording applied jobs")
+		}
+	}
+
+	if !checkpoints.IsCompleted(applyStepPackagesApplied) {
+		err := a.applyPackages(currentApplySpec, desiredApplySpec, reporter, cancelCh)
+		if err != nil {
+			return err
+		}
+
+		err = checkpoints.Complete(applyStepPackagesApplied)
+		if err != nil {
+			return bosherr.WrapError(err, "Recording applied packages")
+		}
+	}
+
+	if boshtask.IsCancelled(cancelCh) {
+		return boshtask.ErrCancelled
+	}
+
+	if !checkpoints.IsCompleted(applyStepJobsConfigured) {
+		reporter.ReportProgress("Configuring jobs", 80)
+
+		err := a.configureJobs(jobs)
+		if err != nil {
+			return err
+		}
+
+		err = checkpoints.Complete(applyStepJobsConfigured)
+		if err != nil {
+			return bosherr.WrapError(err, "Recording configured jobs")
+		}
+	}
+
+	if !checkpoints.IsCompleted(applyStepSupervisorReloaded) {
+		reporter.ReportProgress("Reloading job supervisor", 90)
+
+		err := a.jobSupervisor.Reload()
+		if err != nil {
+			return bosherr.WrapError(err, "Reloading jobSupervisor")
+		}
+
+		err = checkpoints.Complete(applyStepSupervisorReloaded)
+		if err != nil {
+			return bosherr.WrapError(err, "Recording reloaded job supervisor")
+		}
+	}
+
+	return a.setUpLogrotate(desiredApplySpec)
+}
+
+func (a *concreteApplier) applyJobs(currentApplySpec, desiredApplySpec as.ApplySpec, reporter boshtask.ProgressReporter, cancelCh <-chan struct{}) error {
 	err := a.jobSupervisor.RemoveAllJobs()
 	if err != nil {
 		return bosherr.WrapError(err, "Removing all jobs")
 	}
 
 	jobs := desiredApplySpec.Jobs()
-	for _, job := range jobs {
-		err = a.jobApplier.Apply(job)
+	for i, job := range jobs {
+		if boshtask.IsCancelled(cancelCh) {
+			return boshtask.ErrCancelled
+		}
+
+		reporter.ReportProgress(fmt.Sprintf("Applying job %d/%d", i+1, len(jobs)), 30*i/len(jobs))
+
+		err = a.jobApplier.Apply(job, cancelCh)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Applying job %s", job.Name)
 		}
@@ -71,33 +177,87 @@ func (a *concreteApplier) Apply(currentApplySpec, desiredApplySpec as.ApplySpec)
 		return bosherr.WrapError(err, "Keeping only needed jobs")
 	}
 
-	for _, pkg := range desiredApplySpec.Packages() {
-		err = a.packageApplier.Apply(pkg)
+	return nil
+}
+
+func (a *concreteApplier) applyPackages(currentApplySpec, desiredApplySpec as.ApplySpec, reporter boshtask.ProgressReporter, cancelCh <-chan struct{}) error {
+	pkgs := desiredApplySpec.Packages()
+	for i, pkg := range pkgs {
+		if boshtask.IsCancelled(cancelCh) {
+			return boshtask.ErrCancelled
+		}
+
+		reporter.ReportProgress(fmt.Sprintf("Applying package %d/%d", i+1, len(pkgs)), 30+50*i/len(pkgs))
+
+		err := a.packageApplier.Apply(pkg, cancelCh)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Applying package %s", pkg.Name)
 		}
 	}
 
-	err = a.packageApplier.KeepOnly(append(currentApplySpec.Packages(), desiredApplySpec.Packages()...))
+	err := a.packageApplier.KeepOnly(append(currentApplySpec.Packages(), desiredApplySpec.Packages()...))
 	if err != nil {
 		return bosherr.WrapError(err, "Keeping only needed packages")
 	}
 
+	return nil
+}
+
+func (a *concreteApplier) configureJobs(jobs []models.Job) error {
 	for i := 0; i < len(jobs); i++ {
 		job := jobs[len(jobs)-1-i]
 
-		err = a.jobApplier.Configure(job, i)
+		err := a.jobApplier.Configure(job, i)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Configuring job %s", job.Name)
 		}
 	}
 
+	return nil
+}
+
+// cleanUpCancelledApply enables bundles of current apply spec again
+// since desired bundles with the same names have replaced them when applied;
+// current bundles are still installed so nothing is downloaded.
+// Only then desired bundles are removed: disabling a bundle that is
+// not enabled anymore leaves enabled current bundle in place.
+func (a *concreteApplier) cleanUpCancelledApply(currentApplySpec as.ApplySpec) error {
+	for _, job := range currentApplySpec.Jobs() {
+		err := a.jobApplier.Apply(job, nil)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Enabling job %s of current apply spec", job.Name)
+		}
+	}
+
+	for _, pkg := range currentApplySpec.Packages() {
+		err := a.packageApplier.Apply(pkg, nil)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Enabling package %s of current apply spec", pkg.Name)
+		}
+	}
+
+	err := a.jobApplier.KeepOnly(currentApplySpec.Jobs())
+	if err != nil {
+		return bosherr.WrapError(err, "Removing jobs of cancelled apply")
+	}
+
+	err = a.packageApplier.KeepOnly(currentApplySpec.Packages())
+	if err != nil {
+		return bosherr.WrapError(err, "Removing packages of cancelled apply")
+	}
+
+	// Jobs were removed from job supervisor before apply was cancelled
+	err = a.configureJobs(currentApplySpec.Jobs())
+	if err != nil {
+		return bosherr.WrapError(err, "Configuring jobs of current apply spec")
+	}
+
 	err = a.jobSupervisor.Reload()
 	if err != nil {
 		return bosherr.WrapError(err, "Reloading jobSupervisor")
 	}
 
-	return a.setUpLogrotate(desiredApplySpec)
+	return nil
 }
 
 func (a *concreteApplier) setUpLogrotate(applySpec as.ApplySpec) error {
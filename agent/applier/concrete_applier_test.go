@@ -12,6 +12,8 @@ import (
 	fakejobs "github.com/cloudfoundry/bosh-agent/agent/applier/jobs/fakes"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	fakepackages "github.com/cloudfoundry/bosh-agent/agent/applier/packages/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -55,6 +57,9 @@ func init() {
 			packageApplier    *fakepackages.FakeApplier
 			logRotateDelegate *FakeLogRotateDelegate
 			jobSupervisor     *fakejobsuper.FakeJobSupervisor
+			reporter          *faketask.FakeProgressReporter
+			checkpoints       *faketask.FakeCheckpoints
+			cancelCh          chan struct{}
 			applier           Applier
 		)
 
@@ -63,6 +68,9 @@ func init() {
 			packageApplier = fakepackages.NewFakeApplier()
 			logRotateDelegate = &FakeLogRotateDelegate{}
 			jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
+			reporter = faketask.NewFakeProgressReporter()
+			checkpoints = faketask.NewFakeCheckpoints()
+			cancelCh = make(chan struct{})
 			applier = NewConcreteApplier(
 				jobApplier,
 				packageApplier,
@@ -121,7 +129,7 @@ func init() {
 
 		Describe("Apply", func() {
 			It("removes all jobs from job supervisor", func() {
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{}, reporter, checkpoints, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(jobSupervisor.RemovedAllJobs).To(BeTrue())
@@ -135,6 +143,9 @@ func init() {
 				applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{job}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 
 				// check that jobs were not applied before removing all other jobs
@@ -144,17 +155,268 @@ func init() {
 			It("returns error if removing all jobs from job supervisor fails", func() {
 				jobSupervisor.RemovedAllJobsErr = errors.New("fake-remove-all-jobs-error")
 
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{}, reporter, checkpoints, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-all-jobs-error"))
 			})
 
+			It("reports progress of applying jobs and packages", func() {
+				err := applier.Apply(
+					&fakeas.FakeApplySpec{},
+					&fakeas.FakeApplySpec{
+						JobResults:     []models.Job{buildJob(), buildJob()},
+						PackageResults: []models.Package{buildPackage()},
+					},
+					reporter,
+					checkpoints,
+					cancelCh,
+				)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(reporter.Stages()).To(Equal([]string{
+					"Applying job 1/2",
+					"Applying job 2/2",
+					"Applying package 1/1",
+					"Configuring jobs",
+					"Reloading job supervisor",
+				}))
+			})
+
+			It("passes cancel channel to job and package appliers", func() {
+				err := applier.Apply(
+					&fakeas.FakeApplySpec{},
+					&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}, PackageResults: []models.Package{buildPackage()}},
+					reporter,
+					checkpoints,
+					cancelCh,
+				)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(jobApplier.ApplyCancelCh).To(Equal((<-chan struct{})(cancelCh)))
+				Expect(packageApplier.ApplyCancelCh).To(Equal((<-chan struct{})(cancelCh)))
+			})
+
+			It("records each completed step with checkpoints", func() {
+				err := applier.Apply(
+					&fakeas.FakeApplySpec{},
+					&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}, PackageResults: []models.Package{buildPackage()}},
+					reporter,
+					checkpoints,
+					cancelCh,
+				)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(checkpoints.CompletedSteps).To(Equal([]string{
+					"jobs_applied",
+					"packages_applied",
+					"jobs_configured",
+					"job_supervisor_reloaded",
+				}))
+			})
+
+			It("skips steps completed by interrupted apply", func() {
+				checkpoints = faketask.NewFakeCheckpoints("jobs_applied", "packages_applied")
+
+				job := buildJob()
+
+				err := applier.Apply(
+					&fakeas.FakeApplySpec{},
+					&fakeas.FakeApplySpec{JobResults: []models.Job{job}, PackageResults: []models.Package{buildPackage()}},
+					reporter,
+					checkpoints,
+					cancelCh,
+				)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(jobSupervisor.RemovedAllJobs).To(BeFalse())
+				Expect(jobApplier.AppliedJobs).To(BeEmpty())
+				Expect(packageApplier.AppliedPackages).To(BeEmpty())
+
+				Expect(jobApplier.ConfiguredJobs).To(Equal([]models.Job{job}))
+				Expect(jobSupervisor.Reloaded).To(BeTrue())
+				Expect(reporter.Stages()).To(Equal([]string{"Configuring jobs", "Reloading job supervisor"}))
+			})
+
+			It("returns error without configuring jobs when recording applied packages fails", func() {
+				checkpoints = faketask.NewFakeCheckpoints("jobs_applied")
+				checkpoints.CompleteErr = errors.New("fake-complete-err")
+
+				err := applier.Apply(
+					&fakeas.FakeApplySpec{},
+					&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}},
+					reporter,
+					checkpoints,
+					cancelCh,
+				)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-complete-err"))
+
+				Expect(jobApplier.ConfiguredJobs).To(BeEmpty())
+			})
+
+			Context("when cancelled before starting", func() {
+				BeforeEach(func() {
+					close(cancelCh)
+				})
+
+				It("returns cancelled error without touching jobs", func() {
+					err := applier.Apply(
+						&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}},
+						&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}},
+						reporter,
+						checkpoints,
+						cancelCh,
+					)
+					Expect(err).To(Equal(boshtask.ErrCancelled))
+
+					Expect(jobSupervisor.RemovedAllJobs).To(BeFalse())
+					Expect(jobApplier.AppliedJobs).To(BeEmpty())
+					Expect(jobApplier.KeepOnlyJobs).To(BeNil())
+				})
+			})
+
+			Context("when cancelled while applying jobs", func() {
+				var (
+					currentJob, desiredJob1, desiredJob2 models.Job
+					currentPkg, desiredPkg               models.Package
+				)
+
+				act := func() error {
+					return applier.Apply(
+						&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}, PackageResults: []models.Package{currentPkg}},
+						&fakeas.FakeApplySpec{JobResults: []models.Job{desiredJob1, desiredJob2}, PackageResults: []models.Package{desiredPkg}},
+						reporter,
+						checkpoints,
+						cancelCh,
+					)
+				}
+
+				BeforeEach(func() {
+					currentJob, desiredJob1, desiredJob2 = buildJob(), buildJob(), buildJob()
+					currentPkg, desiredPkg = buildPackage(), buildPackage()
+
+					jobApplier.ApplyCallBack = func(_ models.Job) {
+						close(cancelCh)
+						jobApplier.ApplyCallBack = nil
+					}
+				})
+
+				It("stops without applying remaining jobs and returns cancelled error", func() {
+					err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+
+					// Current job and package are enabled again while cleaning up
+					Expect(jobApplier.AppliedJobs).To(Equal([]models.Job{desiredJob1, currentJob}))
+					Expect(packageApplier.AppliedPackages).To(Equal([]models.Package{currentPkg}))
+				})
+
+				It("removes jobs and packages that were installed only for desired spec", func() {
+					err := act()
+					Expect(err).To(HaveOccurred())
+
+					Expect(jobApplier.KeepOnlyJobs).To(Equal([]models.Job{currentJob}))
+					Expect(packageApplier.KeptOnlyPackages).To(Equal([]models.Package{currentPkg}))
+				})
+
+				It("gives jobs of current spec back to job supervisor since all jobs were removed from it", func() {
+					err := act()
+					Expect(err).To(HaveOccurred())
+
+					Expect(jobSupervisor.RemovedAllJobs).To(BeTrue())
+					Expect(jobApplier.ConfiguredJobs).To(Equal([]models.Job{currentJob}))
+					Expect(jobSupervisor.Reloaded).To(BeTrue())
+				})
+
+				It("returns clean up error along with cancelled error", func() {
+					packageApplier.KeepOnlyErr = errors.New("fake-keep-only-err")
+
+					err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+					Expect(err.Error()).To(ContainSubstring("fake-keep-only-err"))
+				})
+			})
+
+			Context("when cancelled after jobs and packages of another version were applied", func() {
+				var (
+					currentJob, desiredJob models.Job
+					currentPkg, desiredPkg models.Package
+				)
+
+				BeforeEach(func() {
+					currentJob = models.Job{Name: "fake-job", Version: "fake-current-version"}
+					desiredJob = models.Job{Name: "fake-job", Version: "fake-desired-version"}
+					currentPkg = models.Package{Name: "fake-pkg", Version: "fake-current-version"}
+					desiredPkg = models.Package{Name: "fake-pkg", Version: "fake-desired-version"}
+
+					// Current job and package are enabled before apply
+					jobApplier.EnabledJobs[currentJob.Name] = currentJob
+					packageApplier.EnabledPackages[currentPkg.Name] = currentPkg
+
+					packageApplier.ApplyCallBack = func(_ models.Package) {
+						close(cancelCh)
+						packageApplier.ApplyCallBack = nil
+					}
+				})
+
+				It("enables current job and package again before giving current job back to job supervisor", func() {
+					var enabledJobsWhenReloaded map[string]models.Job
+
+					jobSupervisor.ReloadCallBack = func() {
+						enabledJobsWhenReloaded = map[string]models.Job{}
+						for name, job := range jobApplier.EnabledJobs {
+							enabledJobsWhenReloaded[name] = job
+						}
+					}
+
+					err := applier.Apply(
+						&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}, PackageResults: []models.Package{currentPkg}},
+						&fakeas.FakeApplySpec{JobResults: []models.Job{desiredJob}, PackageResults: []models.Package{desiredPkg}},
+						reporter,
+						checkpoints,
+						cancelCh,
+					)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+
+					Expect(jobApplier.EnabledJobs).To(Equal(map[string]models.Job{"fake-job": currentJob}))
+					Expect(packageApplier.EnabledPackages).To(Equal(map[string]models.Package{"fake-pkg": currentPkg}))
+
+					Expect(jobApplier.ConfiguredJobs).To(Equal([]models.Job{currentJob}))
+					Expect(jobSupervisor.Reloaded).To(BeTrue())
+					Expect(enabledJobsWhenReloaded).To(Equal(map[string]models.Job{"fake-job": currentJob}))
+				})
+
+				It("returns error when current job cannot be enabled again", func() {
+					jobApplier.ApplyCallBack = func(job models.Job) {
+						if job.Version == currentJob.Version {
+							jobApplier.ApplyError = errors.New("fake-enable-err")
+						}
+					}
+
+					err := applier.Apply(
+						&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}},
+						&fakeas.FakeApplySpec{JobResults: []models.Job{desiredJob}, PackageResults: []models.Package{desiredPkg}},
+						reporter,
+						checkpoints,
+						cancelCh,
+					)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-enable-err"))
+					Expect(jobSupervisor.Reloaded).To(BeFalse())
+				})
+			})
+
 			It("apply applies jobs", func() {
 				job := buildJob()
 
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{job}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(jobApplier.AppliedJobs).To(Equal([]models.Job{job}))
@@ -168,6 +430,9 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{job}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-apply-job-error"))
@@ -180,6 +445,9 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{desiredJob}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).ToNot(HaveOccurred())
 
@@ -195,6 +463,9 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{desiredJob}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
@@ -207,6 +478,9 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{pkg1, pkg2}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.AppliedPackages).To(Equal([]models.Package{pkg1, pkg2}))
@@ -220,6 +494,9 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{pkg}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-apply-package-error"))
@@ -232,6 +509,9 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{currentPkg}},
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{desiredPkg}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.KeptOnlyPackages).To(Equal([]models.Package{currentPkg, desiredPkg}))
@@ -246,6 +526,9 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{currentPkg}},
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{desiredPkg}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
@@ -256,7 +539,7 @@ func init() {
 				job2 := models.Job{Name: "fake-job-name-2", Version: "fake-version-name-2"}
 				jobs := []models.Job{job1, job2}
 
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{JobResults: jobs})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{JobResults: jobs}, reporter, checkpoints, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(jobApplier.ConfiguredJobs).To(Equal([]models.Job{job2, job1}))
 				Expect(jobApplier.ConfiguredJobIndices).To(Equal([]int{0, 1}))
@@ -268,7 +551,7 @@ func init() {
 				jobs := []models.Job{}
 				jobSupervisor.ReloadErr = errors.New("error reloading monit")
 
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{JobResults: jobs})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{JobResults: jobs}, reporter, checkpoints, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("error reloading monit"))
 			})
@@ -281,6 +564,9 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{job}},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("error configuring job"))
@@ -290,6 +576,9 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{MaxLogFileSizeResult: "fake-size"},
+					reporter,
+					checkpoints,
+					cancelCh,
 				)
 				Expect(err).ToNot(HaveOccurred())
 
@@ -303,7 +592,7 @@ func init() {
 			It("apply errs if setup logrotate fails", func() {
 				logRotateDelegate.SetupLogrotateErr = errors.New("fake-set-up-logrotate-error")
 
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{}, reporter, checkpoints, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-set-up-logrotate-error"))
 			})
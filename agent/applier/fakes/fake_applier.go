@@ -2,6 +2,7 @@ package fakes
 
 import (
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeApplier struct {
@@ -12,6 +13,10 @@ type FakeApplier struct {
 	Applied               bool
 	ApplyCurrentApplySpec boshas.ApplySpec
 	ApplyDesiredApplySpec boshas.ApplySpec
+	ApplyReporter         boshtask.ProgressReporter
+	ApplyCheckpoints      boshtask.Checkpoints
+	ApplyCancelCh         <-chan struct{}
+	ApplyCallBack         func()
 	ApplyError            error
 }
 
@@ -25,9 +30,17 @@ func (s *FakeApplier) Prepare(desiredApplySpec boshas.ApplySpec) error {
 	return s.PrepareError
 }
 
-func (s *FakeApplier) Apply(currentApplySpec, desiredApplySpec boshas.ApplySpec) error {
+func (s *FakeApplier) Apply(currentApplySpec, desiredApplySpec boshas.ApplySpec, reporter boshtask.ProgressReporter, checkpoints boshtask.Checkpoints, cancelCh <-chan struct{}) error {
 	s.Applied = true
 	s.ApplyCurrentApplySpec = currentApplySpec
 	s.ApplyDesiredApplySpec = desiredApplySpec
+	s.ApplyReporter = reporter
+	s.ApplyCheckpoints = checkpoints
+	s.ApplyCancelCh = cancelCh
+
+	if s.ApplyCallBack != nil {
+		s.ApplyCallBack()
+	}
+
 	return s.ApplyError
 }
@@ -6,7 +6,8 @@ import (
 
 type Applier interface {
 	Prepare(job models.Job) error
-	Apply(job models.Job) error
+	// Apply stops downloading job and its packages and returns boshtask.ErrCancelled once cancelCh is closed
+	Apply(job models.Job, cancelCh <-chan struct{}) error
 	Configure(job models.Job, jobIndex int) error
 	KeepOnly(jobs []models.Job) error
 }
@@ -8,8 +8,15 @@ type FakeApplier struct {
 	PreparedJobs []models.Job
 	PrepareError error
 
-	AppliedJobs []models.Job
-	ApplyError  error
+	AppliedJobs   []models.Job
+	ApplyCancelCh <-chan struct{}
+
+	// Job last applied with each name as real applier enables it in place of previous one;
+	// KeepOnly disables enabled jobs that are not kept
+	EnabledJobs map[string]models.Job
+
+	ApplyError    error
+	ApplyCallBack func(job models.Job)
 
 	ConfiguredJobs       []models.Job
 	ConfiguredJobIndices []int
@@ -22,6 +29,7 @@ type FakeApplier struct {
 func NewFakeApplier() *FakeApplier {
 	return &FakeApplier{
 		AppliedJobs: []models.Job{},
+		EnabledJobs: map[string]models.Job{},
 	}
 }
 
@@ -30,8 +38,18 @@ func (s *FakeApplier) Prepare(job models.Job) error {
 	return s.PrepareError
 }
 
-func (s *FakeApplier) Apply(job models.Job) error {
+func (s *FakeApplier) Apply(job models.Job, cancelCh <-chan struct{}) error {
 	s.AppliedJobs = append(s.AppliedJobs, job)
+	s.ApplyCancelCh = cancelCh
+
+	if s.ApplyError == nil {
+		s.EnabledJobs[job.Name] = job
+	}
+
+	if s.ApplyCallBack != nil {
+		s.ApplyCallBack(job)
+	}
+
 	return s.ApplyError
 }
 
@@ -43,5 +61,23 @@ func (s *FakeApplier) Configure(job models.Job, jobIndex int) error {
 
 func (s *FakeApplier) KeepOnly(jobs []models.Job) error {
 	s.KeepOnlyJobs = jobs
-	return s.KeepOnlyErr
+
+	if s.KeepOnlyErr != nil {
+		return s.KeepOnlyErr
+	}
+
+	for name, enabledJob := range s.EnabledJobs {
+		kept := false
+		for _, job := range jobs {
+			if job.Name == enabledJob.Name && job.Version == enabledJob.Version {
+				kept = true
+			}
+		}
+
+		if !kept {
+			delete(s.EnabledJobs, name)
+		}
+	}
+
+	return nil
 }
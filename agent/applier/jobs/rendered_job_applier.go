@@ -9,6 +9,7 @@ import (
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -50,6 +51,10 @@ func NewRenderedJobApplier(
 }
 
 func (s renderedJobApplier) Prepare(job models.Job) error {
+	return s.prepare(job, nil)
+}
+
+func (s renderedJobApplier) prepare(job models.Job, cancelCh <-chan struct{}) error {
 	s.logger.Debug(logTag, "Preparing job %v", job)
 
 	jobBundle, err := s.jobsBc.Get(job)
@@ -63,7 +68,7 @@ func (s renderedJobApplier) Prepare(job models.Job) error {
 	}
 
 	if !jobInstalled {
-		err := s.downloadAndInstall(job, jobBundle)
+		err := s.downloadAndInstall(job, jobBundle, cancelCh)
 		if err != nil {
 			return err
 		}
@@ -72,10 +77,10 @@ func (s renderedJobApplier) Prepare(job models.Job) error {
 	return nil
 }
 
-func (s *renderedJobApplier) Apply(job models.Job) error {
+func (s *renderedJobApplier) Apply(job models.Job, cancelCh <-chan struct{}) error {
 	s.logger.Debug(logTag, "Applying job %v", job)
 
-	err := s.Prepare(job)
+	err := s.prepare(job, cancelCh)
 	if err != nil {
 		return bosherr.WrapError(err, "Preparing job")
 	}
@@ -90,10 +95,10 @@ func (s *renderedJobApplier) Apply(job models.Job) error {
 		return bosherr.WrapError(err, "Enabling job")
 	}
 
-	return s.applyPackages(job)
+	return s.applyPackages(job, cancelCh)
 }
 
-func (s *renderedJobApplier) downloadAndInstall(job models.Job, jobBundle boshbc.Bundle) error {
+func (s *renderedJobApplier) downloadAndInstall(job models.Job, jobBundle boshbc.Bundle, cancelCh <-chan struct{}) error {
 	tmpDir, err := s.fs.TempDir("bosh-agent-applier-jobs-RenderedJobApplier-Apply")
 	if err != nil {
 		return bosherr.WrapError(err, "Getting temp dir")
@@ -101,7 +106,7 @@ func (s *renderedJobApplier) downloadAndInstall(job models.Job, jobBundle boshbc
 
 	defer s.fs.RemoveAll(tmpDir)
 
-	file, err := s.blobstore.Get(job.Source.BlobstoreID, job.Source.Sha1)
+	file, err := boshagentblob.CancellableGet(s.blobstore, job.Source.BlobstoreID, job.Source.Sha1, cancelCh)
 	if err != nil {
 		return bosherr.WrapError(err, "Getting job source from blobstore")
 	}
@@ -137,11 +142,11 @@ func (s *renderedJobApplier) downloadAndInstall(job models.Job, jobBundle boshbc
 
 // applyPackages keeps job specific packages directory up-to-date with installed packages.
 // (e.g. /var/vcap/jobs/job-a/packages/pkg-a has symlinks to /var/vcap/packages/pkg-a)
-func (s *renderedJobApplier) applyPackages(job models.Job) error {
+func (s *renderedJobApplier) applyPackages(job models.Job, cancelCh <-chan struct{}) error {
 	packageApplier := s.packageApplierProvider.JobSpecific(job.Name)
 
 	for _, pkg := range job.Packages {
-		err := packageApplier.Apply(pkg)
+		err := packageApplier.Apply(pkg, cancelCh)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Applying package %s for job %s", pkg.Name, job.Name)
 		}
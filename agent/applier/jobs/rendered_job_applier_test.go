@@ -11,6 +11,7 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/jobs"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	fakepackages "github.com/cloudfoundry/bosh-agent/agent/applier/packages/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
@@ -367,7 +368,23 @@ func init() {
 			})
 
 			Describe("Apply", func() {
-				act := func() error { return applier.Apply(job) }
+				var cancelCh chan struct{}
+
+				BeforeEach(func() {
+					cancelCh = make(chan struct{})
+				})
+
+				act := func() error { return applier.Apply(job, cancelCh) }
+
+				It("passes cancel channel to job specific package applier", func() {
+					packageApplier := fakepackages.NewFakeApplier()
+					packageApplierProvider.JobSpecificAppliers[job.Name] = packageApplier
+					bundle.Installed = true
+
+					err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(packageApplier.ApplyCancelCh).To(Equal((<-chan struct{})(cancelCh)))
+				})
 
 				It("return an error if getting file bundle fails", func() {
 					jobsBc.GetErr = errors.New("fake-get-bundle-error")
@@ -424,6 +441,17 @@ func init() {
 						Expect(bundle.ActionsCalled).To(Equal([]string{"Install", "Enable"}))
 					})
 
+					It("does not download or install job when cancelled", func() {
+						close(cancelCh)
+
+						err := act()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+
+						Expect(blobstore.GetBlobIDs).To(BeEmpty())
+						Expect(bundle.ActionsCalled).To(BeEmpty())
+					})
+
 					It("returns error when job enable fails", func() {
 						bundle.EnableError = errors.New("fake-enable-error")
 
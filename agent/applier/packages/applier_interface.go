@@ -6,6 +6,7 @@ import (
 
 type Applier interface {
 	Prepare(pkg models.Package) error
-	Apply(pkg models.Package) error
+	// Apply stops downloading package and returns boshtask.ErrCancelled once cancelCh is closed
+	Apply(pkg models.Package, cancelCh <-chan struct{}) error
 	KeepOnly(pkgs []models.Package) error
 }
@@ -3,6 +3,7 @@ package packages
 import (
 	bc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
@@ -43,6 +44,10 @@ func NewCompiledPackageApplier(
 }
 
 func (s compiledPackageApplier) Prepare(pkg models.Package) error {
+	return s.prepare(pkg, nil)
+}
+
+func (s compiledPackageApplier) prepare(pkg models.Package, cancelCh <-chan struct{}) error {
 	s.logger.Debug(logTag, "Preparing package %v", pkg)
 
 	pkgBundle, err := s.packagesBc.Get(pkg)
@@ -56,7 +61,7 @@ func (s compiledPackageApplier) Prepare(pkg models.Package) error {
 	}
 
 	if !pkgInstalled {
-		err := s.downloadAndInstall(pkg, pkgBundle)
+		err := s.downloadAndInstall(pkg, pkgBundle, cancelCh)
 		if err != nil {
 			return err
 		}
@@ -65,10 +70,10 @@ func (s compiledPackageApplier) Prepare(pkg models.Package) error {
 	return nil
 }
 
-func (s compiledPackageApplier) Apply(pkg models.Package) error {
+func (s compiledPackageApplier) Apply(pkg models.Package, cancelCh <-chan struct{}) error {
 	s.logger.Debug(logTag, "Applying package %v", pkg)
 
-	err := s.Prepare(pkg)
+	err := s.prepare(pkg, cancelCh)
 	if err != nil {
 		return err
 	}
@@ -86,7 +91,7 @@ func (s compiledPackageApplier) Apply(pkg models.Package) error {
 	return nil
 }
 
-func (s *compiledPackageApplier) downloadAndInstall(pkg models.Package, pkgBundle bc.Bundle) error {
+func (s *compiledPackageApplier) downloadAndInstall(pkg models.Package, pkgBundle bc.Bundle, cancelCh <-chan struct{}) error {
 	tmpDir, err := s.fs.TempDir("bosh-agent-applier-packages-CompiledPackageApplier-Apply")
 	if err != nil {
 		return bosherr.WrapError(err, "Getting temp dir")
@@ -94,7 +99,7 @@ func (s *compiledPackageApplier) downloadAndInstall(pkg models.Package, pkgBundl
 
 	defer s.fs.RemoveAll(tmpDir)
 
-	file, err := s.blobstore.Get(pkg.Source.BlobstoreID, pkg.Source.Sha1)
+	file, err := boshagentblob.CancellableGet(s.blobstore, pkg.Source.BlobstoreID, pkg.Source.Sha1, cancelCh)
 	if err != nil {
 		return bosherr.WrapError(err, "Fetching package blob")
 	}
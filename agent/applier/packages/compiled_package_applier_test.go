@@ -10,6 +10,7 @@ import (
 	fakebc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection/fakes"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -207,7 +208,13 @@ func init() {
 			})
 
 			Describe("Apply", func() {
-				act := func() error { return applier.Apply(pkg) }
+				var cancelCh chan struct{}
+
+				BeforeEach(func() {
+					cancelCh = make(chan struct{})
+				})
+
+				act := func() error { return applier.Apply(pkg, cancelCh) }
 
 				It("return an error if getting file bundle fails", func() {
 					packagesBc.GetErr = errors.New("fake-get-bundle-error")
@@ -262,6 +269,17 @@ func init() {
 						Expect(bundle.ActionsCalled).To(Equal([]string{"Install", "Enable"}))
 					})
 
+					It("does not download or install package when cancelled", func() {
+						close(cancelCh)
+
+						err := act()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+
+						Expect(blobstore.GetBlobIDs).To(BeEmpty())
+						Expect(bundle.ActionsCalled).To(BeEmpty())
+					})
+
 					It("returns error when package enable fails", func() {
 						bundle.EnableError = errors.New("fake-enable-error")
 
@@ -11,7 +11,13 @@ type FakeApplier struct {
 	PrepareError     error
 
 	AppliedPackages []models.Package
+	ApplyCancelCh   <-chan struct{}
 	ApplyError      error
+	ApplyCallBack   func(pkg models.Package)
+
+	// Package last applied with each name as real applier enables it in place of previous one;
+	// KeepOnly disables enabled packages that are not kept
+	EnabledPackages map[string]models.Package
 
 	KeptOnlyPackages []models.Package
 	KeepOnlyErr      error
@@ -20,6 +26,7 @@ type FakeApplier struct {
 func NewFakeApplier() *FakeApplier {
 	return &FakeApplier{
 		AppliedPackages: []models.Package{},
+		EnabledPackages: map[string]models.Package{},
 	}
 }
 
@@ -29,14 +36,42 @@ func (s *FakeApplier) Prepare(pkg models.Package) error {
 	return s.PrepareError
 }
 
-func (s *FakeApplier) Apply(pkg models.Package) error {
+func (s *FakeApplier) Apply(pkg models.Package, cancelCh <-chan struct{}) error {
 	s.ActionsCalled = append(s.ActionsCalled, "Apply")
 	s.AppliedPackages = append(s.AppliedPackages, pkg)
+	s.ApplyCancelCh = cancelCh
+
+	if s.ApplyError == nil {
+		s.EnabledPackages[pkg.Name] = pkg
+	}
+
+	if s.ApplyCallBack != nil {
+		s.ApplyCallBack(pkg)
+	}
+
 	return s.ApplyError
 }
 
 func (s *FakeApplier) KeepOnly(pkgs []models.Package) error {
 	s.ActionsCalled = append(s.ActionsCalled, "KeepOnly")
 	s.KeptOnlyPackages = pkgs
-	return s.KeepOnlyErr
+
+	if s.KeepOnlyErr != nil {
+		return s.KeepOnlyErr
+	}
+
+	for name, enabledPkg := range s.EnabledPackages {
+		kept := false
+		for _, pkg := range pkgs {
+			if pkg.Name == enabledPkg.Name && pkg.Version == enabledPkg.Version {
+				kept = true
+			}
+		}
+
+		if !kept {
+			delete(s.EnabledPackages, name)
+		}
+	}
+
+	return nil
 }
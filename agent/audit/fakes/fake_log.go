@@ -0,0 +1,30 @@
+package fakes
+
+import (
+	"sync"
+
+	boshaudit "github.com/cloudfoundry/bosh-agent/agent/audit"
+)
+
+type FakeLog struct {
+	AppendErr error
+
+	// Records are appended from task goroutines
+	lock    sync.Mutex
+	records []boshaudit.Record
+}
+
+func (l *FakeLog) Append(record boshaudit.Record) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.records = append(l.records, record)
+	return l.AppendErr
+}
+
+func (l *FakeLog) Records() []boshaudit.Record {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.records
+}
@@ -0,0 +1,256 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	// Request was not acted upon (e.g. action is unknown or disabled by policy);
+	// other outcomes are task states such as done or failed
+	OutcomeRejected = "rejected"
+
+	// Asynchronous action was accepted and its task is about to start;
+	// another record with the same task ID follows once the task ends
+	// so that requests interrupted by agent restart still leave a trace
+	OutcomeAccepted = "accepted"
+
+	DefaultMaxSizeKB  = 10 * 1024
+	DefaultMaxBackups = 5
+
+	// Size of chunks read from the end of the log when looking for last record
+	lastRecordChunkSize = 4096
+)
+
+// Options control rotation of audit log. Once log would grow above MaxSizeKB
+// it is renamed to <path>.1 (previous <path>.1 becomes <path>.2 and so on)
+// and records continue in a new file. First record of the new file
+// still refers to the last record of <path>.1 so that hash chain stays intact
+// across rotated files; only the oldest kept file refers to a removed record.
+type Options struct {
+	// Defaults to DefaultMaxSizeKB when not set
+	MaxSizeKB int
+
+	// Number of rotated files kept; defaults to DefaultMaxBackups when not set
+	MaxBackups int
+}
+
+// Record describes who asked agent to run an action and how it went.
+// Payload itself is never recorded since it might contain secrets.
+type Record struct {
+	Method    string `json:"method"`
+	ReplyTo   string `json:"reply_to,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	TaskID    string `json:"task_id,omitempty"`
+
+	// FinishedAt of accepted record is when task was accepted
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Outcome    string    `json:"outcome"`
+
+	PayloadSHA256 string `json:"payload_sha256"`
+
+	// Each record includes hash of the preceding line
+	// so that removed or modified records can be detected
+	PreviousRecordSHA256 string `json:"previous_record_sha256"`
+}
+
+type Log interface {
+	Append(Record) error
+}
+
+func PayloadSHA256(payload []byte) string {
+	return sha256Hex(payload)
+}
+
+type fileLog struct {
+	fs         boshsys.FileSystem
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	// Guards lastRecordHash, size and appending to the file
+	lock           sync.Mutex
+	lastRecordHash string
+	size           int64
+	loaded         bool
+}
+
+// NewFileLog returns log that appends records as JSON lines to a file at given path
+func NewFileLog(options Options, fs boshsys.FileSystem, path string) Log {
+	maxSizeKB := options.MaxSizeKB
+	if maxSizeKB <= 0 {
+		maxSizeKB = DefaultMaxSizeKB
+	}
+
+	maxBackups := options.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+
+	return &fileLog{
+		fs:         fs,
+		path:       path,
+		maxSize:    int64(maxSizeKB) * 1024,
+		maxBackups: maxBackups,
+	}
+}
+
+func (l *fileLog) Append(record Record) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.loaded {
+		err := l.load()
+		if err != nil {
+			return err
+		}
+	}
+
+	record.PreviousRecordSHA256 = l.lastRecordHash
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling audit record")
+	}
+
+	line := append(recordJSON, '\n')
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		err = l.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = l.fs.MkdirAll(filepath.Dir(l.path), os.FileMode(0750))
+	if err != nil {
+		return bosherr.WrapError(err, "Creating audit log directory")
+	}
+
+	file, err := l.fs.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.FileMode(0600))
+	if err != nil {
+		return bosherr.WrapError(err, "Opening audit log")
+	}
+
+	defer file.Close()
+
+	_, err = file.Write(line)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing audit record")
+	}
+
+	l.lastRecordHash = sha256Hex(recordJSON)
+	l.size += int64(len(line))
+
+	return nil
+}
+
+// rotate keeps at most maxBackups previous files; must be called with lock held
+func (l *fileLog) rotate() error {
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		backupPath := l.backupPath(i)
+
+		if l.fs.FileExists(backupPath) {
+			err := l.fs.Rename(backupPath, l.backupPath(i+1))
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Rotating audit log %s", backupPath)
+			}
+		}
+	}
+
+	err := l.fs.Rename(l.path, l.backupPath(1))
+	if err != nil {
+		return bosherr.WrapError(err, "Rotating audit log")
+	}
+
+	l.size = 0
+
+	return nil
+}
+
+func (l *fileLog) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// load finds hash of last record left by previous agent run
+// without reading the whole log; must be called with lock held
+func (l *fileLog) load() error {
+	if !l.fs.FileExists(l.path) {
+		l.loaded = true
+		return nil
+	}
+
+	file, err := l.fs.OpenFile(l.path, os.O_RDONLY, os.FileMode(0600))
+	if err != nil {
+		return bosherr.WrapError(err, "Opening audit log")
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return bosherr.WrapError(err, "Checking audit log size")
+	}
+
+	lastLine, err := readLastLine(file, info.Size())
+	if err != nil {
+		return bosherr.WrapError(err, "Reading audit log")
+	}
+
+	if len(lastLine) > 0 {
+		l.lastRecordHash = sha256Hex(lastLine)
+	}
+
+	l.size = info.Size()
+	l.loaded = true
+
+	return nil
+}
+
+// readLastLine reads chunks backwards from the end of file
+// until it finds the newline preceding last line
+func readLastLine(file boshsys.File, size int64) ([]byte, error) {
+	var tail []byte
+
+	for offset := size; offset > 0; {
+		chunkSize := int64(lastRecordChunkSize)
+		if chunkSize > offset {
+			chunkSize = offset
+		}
+
+		offset -= chunkSize
+
+		chunk := make([]byte, chunkSize)
+
+		_, err := file.ReadAt(chunk, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		tail = append(chunk, tail...)
+
+		lastLine := bytes.TrimRight(tail, "\n")
+
+		if i := bytes.LastIndexByte(lastLine, '\n'); i >= 0 {
+			return lastLine[i+1:], nil
+		}
+	}
+
+	return bytes.TrimRight(tail, "\n"), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
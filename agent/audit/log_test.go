@@ -0,0 +1,259 @@
+package audit_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/audit"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("fileLog", func() {
+	var (
+		logDir  string
+		logPath string
+		fs      boshsys.FileSystem
+		log     Log
+	)
+
+	BeforeEach(func() {
+		var err error
+		logDir, err = ioutil.TempDir("", "audit-log-test")
+		Expect(err).ToNot(HaveOccurred())
+
+		logPath = filepath.Join(logDir, "log", "audit.log")
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		log = NewFileLog(Options{}, fs, logPath)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(logDir)
+	})
+
+	readLines := func() []string {
+		contents, err := fs.ReadFileString(logPath)
+		Expect(err).ToNot(HaveOccurred())
+		return strings.Split(strings.TrimRight(contents, "\n"), "\n")
+	}
+
+	sha256Hex := func(line string) string {
+		sum := sha256.Sum256([]byte(line))
+		return hex.EncodeToString(sum[:])
+	}
+
+	It("appends records as JSON lines to a file that only owner can read", func() {
+		startedAt := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+		err := log.Append(Record{
+			Method:        "fake-method-1",
+			ReplyTo:       "fake-reply-to",
+			TaskID:        "fake-task-id",
+			StartedAt:     startedAt,
+			FinishedAt:    startedAt.Add(time.Second),
+			Outcome:       "done",
+			PayloadSHA256: "fake-payload-sha",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = log.Append(Record{Method: "fake-method-2", Outcome: OutcomeRejected})
+		Expect(err).ToNot(HaveOccurred())
+
+		lines := readLines()
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(MatchJSON(`{
+			"method": "fake-method-1",
+			"reply_to": "fake-reply-to",
+			"task_id": "fake-task-id",
+			"started_at": "2015-01-01T00:00:00Z",
+			"finished_at": "2015-01-01T00:00:01Z",
+			"outcome": "done",
+			"payload_sha256": "fake-payload-sha",
+			"previous_record_sha256": ""
+		}`))
+
+		var secondRecord Record
+		err = json.Unmarshal([]byte(lines[1]), &secondRecord)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secondRecord.Method).To(Equal("fake-method-2"))
+		Expect(secondRecord.PreviousRecordSHA256).To(Equal(sha256Hex(lines[0])))
+
+		info, err := os.Stat(logPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("continues hash chain of records written before agent restart", func() {
+		err := log.Append(Record{Method: "fake-method-1"})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = NewFileLog(Options{}, fs, logPath).Append(Record{Method: "fake-method-2"})
+		Expect(err).ToNot(HaveOccurred())
+
+		lines := readLines()
+		Expect(lines).To(HaveLen(2))
+
+		var secondRecord Record
+		err = json.Unmarshal([]byte(lines[1]), &secondRecord)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secondRecord.PreviousRecordSHA256).To(Equal(sha256Hex(lines[0])))
+	})
+
+	It("continues hash chain from last record when it is longer than chunks read from the end of the log", func() {
+		longMethod := strings.Repeat("m", 10000)
+
+		err := log.Append(Record{Method: "fake-method-1"})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = log.Append(Record{Method: longMethod})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = NewFileLog(Options{}, fs, logPath).Append(Record{Method: "fake-method-3"})
+		Expect(err).ToNot(HaveOccurred())
+
+		lines := readLines()
+		Expect(lines).To(HaveLen(3))
+
+		var thirdRecord Record
+		err = json.Unmarshal([]byte(lines[2]), &thirdRecord)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(thirdRecord.PreviousRecordSHA256).To(Equal(sha256Hex(lines[1])))
+	})
+
+	It("reads only the end of existing log to find last record", func() {
+		fakeFs := fakesys.NewFakeFileSystem()
+		fakeFs.WriteFileString("/fake-audit.log", "fake-record-1\nfake-record-2\n")
+		fakeFs.ReadFileError = errors.New("fake-read-err")
+
+		existingLog := fakesys.NewFakeFile("/fake-audit.log", fakeFs)
+		existingLog.Contents = []byte("fake-record-1\nfake-record-2\n")
+		fakeFs.RegisterOpenFile("/fake-audit.log", existingLog)
+
+		err := NewFileLog(Options{}, fakeFs, "/fake-audit.log").Append(Record{Method: "fake-method"})
+		Expect(err).ToNot(HaveOccurred())
+
+		var record Record
+		err = json.Unmarshal(bytes.TrimRight(existingLog.Contents, "\n"), &record)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(record.PreviousRecordSHA256).To(Equal(sha256Hex("fake-record-2")))
+	})
+
+	It("returns error when existing log cannot be read", func() {
+		fakeFs := fakesys.NewFakeFileSystem()
+		fakeFs.WriteFileString("/fake-audit.log", "fake-record\n")
+
+		existingLog := fakesys.NewFakeFile("/fake-audit.log", fakeFs)
+		existingLog.Contents = []byte("fake-record\n")
+		existingLog.ReadAtErr = errors.New("fake-read-err")
+		fakeFs.RegisterOpenFile("/fake-audit.log", existingLog)
+
+		err := NewFileLog(Options{}, fakeFs, "/fake-audit.log").Append(Record{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-read-err"))
+	})
+
+	It("returns error when log cannot be opened", func() {
+		fakeFs := fakesys.NewFakeFileSystem()
+		fakeFs.OpenFileErr = errors.New("fake-open-err")
+
+		err := NewFileLog(Options{}, fakeFs, "/fake-audit.log").Append(Record{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-open-err"))
+	})
+
+	Context("when log grows above max size", func() {
+		// Each record is a bit over 1KB so that every record goes to a new file
+		largeRecord := func(i int) Record {
+			return Record{Method: fmt.Sprintf("fake-method-%d-%s", i, strings.Repeat("m", 1024))}
+		}
+
+		readLinesOf := func(path string) []string {
+			contents, err := fs.ReadFileString(path)
+			Expect(err).ToNot(HaveOccurred())
+			return strings.Split(strings.TrimRight(contents, "\n"), "\n")
+		}
+
+		previousRecordHash := func(line string) string {
+			var record Record
+			err := json.Unmarshal([]byte(line), &record)
+			Expect(err).ToNot(HaveOccurred())
+			return record.PreviousRecordSHA256
+		}
+
+		BeforeEach(func() {
+			log = NewFileLog(Options{MaxSizeKB: 1, MaxBackups: 2}, fs, logPath)
+		})
+
+		It("continues records in a new file that refers to last record of rotated file", func() {
+			err := log.Append(largeRecord(1))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = log.Append(largeRecord(2))
+			Expect(err).ToNot(HaveOccurred())
+
+			rotatedLines := readLinesOf(logPath + ".1")
+			Expect(rotatedLines).To(HaveLen(1))
+			Expect(rotatedLines[0]).To(ContainSubstring("fake-method-1-"))
+
+			lines := readLines()
+			Expect(lines).To(HaveLen(1))
+			Expect(lines[0]).To(ContainSubstring("fake-method-2-"))
+			Expect(previousRecordHash(lines[0])).To(Equal(sha256Hex(rotatedLines[0])))
+		})
+
+		It("keeps only max backups of rotated files", func() {
+			for i := 1; i <= 4; i++ {
+				err := log.Append(largeRecord(i))
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			Expect(readLinesOf(logPath + ".2")[0]).To(ContainSubstring("fake-method-2-"))
+			Expect(readLinesOf(logPath + ".1")[0]).To(ContainSubstring("fake-method-3-"))
+			Expect(readLines()[0]).To(ContainSubstring("fake-method-4-"))
+			Expect(fs.FileExists(logPath + ".3")).To(BeFalse())
+		})
+
+		It("rotates log written before agent restart", func() {
+			err := log.Append(largeRecord(1))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = NewFileLog(Options{MaxSizeKB: 1, MaxBackups: 2}, fs, logPath).Append(largeRecord(2))
+			Expect(err).ToNot(HaveOccurred())
+
+			rotatedLines := readLinesOf(logPath + ".1")
+			Expect(rotatedLines).To(HaveLen(1))
+			Expect(previousRecordHash(readLines()[0])).To(Equal(sha256Hex(rotatedLines[0])))
+		})
+
+		It("does not rotate log that is still small enough", func() {
+			err := log.Append(Record{Method: "fake-method-1"})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = log.Append(Record{Method: "fake-method-2"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(readLines()).To(HaveLen(2))
+			Expect(fs.FileExists(logPath + ".1")).To(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("PayloadSHA256", func() {
+	It("returns hex encoded sha256 of the payload", func() {
+		Expect(PayloadSHA256([]byte("fake-payload"))).To(HaveLen(64))
+		Expect(PayloadSHA256([]byte("fake-payload"))).ToNot(Equal(PayloadSHA256([]byte("other-payload"))))
+	})
+})
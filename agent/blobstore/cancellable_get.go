@@ -0,0 +1,45 @@
+package blobstore
+
+import (
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type getResult struct {
+	fileName string
+	err      error
+}
+
+// CancellableGet downloads blob like boshblob.Blobstore.Get but returns
+// boshtask.ErrCancelled as soon as cancelCh is closed. Blobstore clients
+// cannot be interrupted so download that is abandoned this way
+// is cleaned up once it finishes in the background.
+func CancellableGet(blobstore boshblob.Blobstore, blobID, fingerprint string, cancelCh <-chan struct{}) (string, error) {
+	if boshtask.IsCancelled(cancelCh) {
+		return "", bosherr.WrapErrorf(boshtask.ErrCancelled, "Getting blob %s", blobID)
+	}
+
+	// Buffered so that abandoned download does not block
+	resultCh := make(chan getResult, 1)
+
+	go func() {
+		fileName, err := blobstore.Get(blobID, fingerprint)
+		resultCh <- getResult{fileName: fileName, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result.fileName, result.err
+
+	case <-cancelCh:
+		go func() {
+			result := <-resultCh
+			if result.err == nil {
+				_ = blobstore.CleanUp(result.fileName)
+			}
+		}()
+
+		return "", bosherr.WrapErrorf(boshtask.ErrCancelled, "Getting blob %s", blobID)
+	}
+}
@@ -0,0 +1,98 @@
+package blobstore_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+)
+
+type blockingBlobstore struct {
+	*fakeblob.FakeBlobstore
+
+	getStartedCh chan struct{}
+	releaseCh    chan struct{}
+
+	cleanedUpFile   string
+	cleanedUpFileCh chan struct{}
+}
+
+func (bs *blockingBlobstore) Get(blobID, fingerprint string) (string, error) {
+	close(bs.getStartedCh)
+	<-bs.releaseCh
+	return "/fake-downloaded-file", nil
+}
+
+func (bs *blockingBlobstore) CleanUp(fileName string) error {
+	bs.cleanedUpFile = fileName
+	close(bs.cleanedUpFileCh)
+	return nil
+}
+
+var _ = Describe("CancellableGet", func() {
+	var (
+		blobstore *fakeblob.FakeBlobstore
+		cancelCh  chan struct{}
+	)
+
+	BeforeEach(func() {
+		blobstore = fakeblob.NewFakeBlobstore()
+		cancelCh = make(chan struct{})
+	})
+
+	It("returns downloaded blob", func() {
+		blobstore.GetFileName = "/fake-downloaded-file"
+
+		fileName, err := CancellableGet(blobstore, "fake-blob-id", "fake-sha1", cancelCh)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileName).To(Equal("/fake-downloaded-file"))
+
+		Expect(blobstore.GetBlobIDs).To(Equal([]string{"fake-blob-id"}))
+		Expect(blobstore.GetFingerprints).To(Equal([]string{"fake-sha1"}))
+	})
+
+	It("returns error when download fails", func() {
+		blobstore.GetError = errors.New("fake-get-err")
+
+		_, err := CancellableGet(blobstore, "fake-blob-id", "fake-sha1", cancelCh)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-get-err"))
+	})
+
+	It("does not start download when already cancelled", func() {
+		close(cancelCh)
+
+		_, err := CancellableGet(blobstore, "fake-blob-id", "fake-sha1", cancelCh)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+
+		Expect(blobstore.GetBlobIDs).To(BeEmpty())
+	})
+
+	It("stops waiting for download when cancelled and cleans up abandoned download", func() {
+		blockingBlobstore := &blockingBlobstore{
+			FakeBlobstore:   blobstore,
+			getStartedCh:    make(chan struct{}),
+			releaseCh:       make(chan struct{}),
+			cleanedUpFileCh: make(chan struct{}),
+		}
+
+		go func() {
+			<-blockingBlobstore.getStartedCh
+			close(cancelCh)
+		}()
+
+		_, err := CancellableGet(blockingBlobstore, "fake-blob-id", "fake-sha1", cancelCh)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+
+		close(blockingBlobstore.releaseCh)
+
+		Eventually(blockingBlobstore.cleanedUpFileCh).Should(BeClosed())
+		Expect(blockingBlobstore.cleanedUpFile).To(Equal("/fake-downloaded-file"))
+	})
+})
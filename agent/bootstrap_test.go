@@ -21,6 +21,7 @@ import (
 	fakedisk "github.com/cloudfoundry/bosh-agent/platform/disk/fakes"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	sigar "github.com/cloudfoundry/gosigar"
+	"github.com/pivotal-golang/clock"
 
 	devicepathresolver "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 
@@ -31,6 +32,7 @@ import (
 	boshnet "github.com/cloudfoundry/bosh-agent/platform/net"
 	bosharp "github.com/cloudfoundry/bosh-agent/platform/net/arp"
 	boship "github.com/cloudfoundry/bosh-agent/platform/net/ip"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
 	boshudev "github.com/cloudfoundry/bosh-agent/platform/udevdevice"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -351,7 +353,8 @@ func init() {
 				compressor := boshcmd.NewTarballCompressor(runner, fs)
 				copier := boshcmd.NewCpCopier(runner, fs, logger)
 
-				sigarCollector := boshsigar.NewSigarStatsCollector(&sigar.ConcreteSigar{})
+				ioStatsSampler := boshstats.NewIOStatsSampler(fs, "/proc", clock.NewClock())
+				sigarCollector := boshsigar.NewSigarStatsCollector(&sigar.ConcreteSigar{}, ioStatsSampler)
 
 				vitalsService := boshvitals.NewService(sigarCollector, dirProvider)
 
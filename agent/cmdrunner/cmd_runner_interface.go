@@ -15,6 +15,12 @@ type CmdResult struct {
 	ExitStatus int
 }
 
+// CmdRunner saves command's stdout and stderr to log files.
+// Output is also written to command's own Stdout and Stderr if they are set.
 type CmdRunner interface {
 	RunCommand(jobName, taskName string, cmd boshsys.Command) (*CmdResult, error)
+
+	// RunCancellableCommand is like RunCommand but terminates the command
+	// and returns boshtask.ErrCancelled once cancelCh is closed
+	RunCancellableCommand(jobName, taskName string, cmd boshsys.Command, cancelCh <-chan struct{}) (*CmdResult, error)
 }
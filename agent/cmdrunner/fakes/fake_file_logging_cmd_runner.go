@@ -2,6 +2,7 @@ package fakes
 
 import (
 	boshcmdrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
@@ -11,6 +12,11 @@ type FakeFileLoggingCmdRunner struct {
 	RunCommandTaskName string
 	RunCommandResult   *boshcmdrunner.CmdResult
 	RunCommandErr      error
+	RunCommandCancelCh <-chan struct{}
+
+	// Written to command's own Stdout and Stderr when they are set
+	RunCommandStdout string
+	RunCommandStderr string
 }
 
 func NewFakeFileLoggingCmdRunner() *FakeFileLoggingCmdRunner {
@@ -21,5 +27,25 @@ func (f *FakeFileLoggingCmdRunner) RunCommand(jobName, taskName string, cmd bosh
 	f.RunCommandJobName = jobName
 	f.RunCommandTaskName = taskName
 	f.RunCommands = append(f.RunCommands, cmd)
+
+	if cmd.Stdout != nil {
+		cmd.Stdout.Write([]byte(f.RunCommandStdout))
+	}
+
+	if cmd.Stderr != nil {
+		cmd.Stderr.Write([]byte(f.RunCommandStderr))
+	}
+
 	return f.RunCommandResult, f.RunCommandErr
 }
+
+func (f *FakeFileLoggingCmdRunner) RunCancellableCommand(jobName, taskName string, cmd boshsys.Command, cancelCh <-chan struct{}) (*boshcmdrunner.CmdResult, error) {
+	f.RunCommandCancelCh = cancelCh
+
+	result, err := f.RunCommand(jobName, taskName, cmd)
+	if boshtask.IsCancelled(cancelCh) {
+		return nil, boshtask.ErrCancelled
+	}
+
+	return result, err
+}
@@ -3,10 +3,13 @@ package cmdrunner
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 	"unicode/utf8"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
@@ -14,6 +17,9 @@ import (
 const (
 	fileOpenFlag int         = os.O_RDWR | os.O_CREATE | os.O_TRUNC
 	fileOpenPerm os.FileMode = os.FileMode(0640)
+
+	// Time given to cancelled command to exit after SIGTERM before it is killed
+	cancelKillGracePeriod = 10 * time.Second
 )
 
 type FileLoggingCmdRunner struct {
@@ -62,6 +68,46 @@ func NewFileLoggingCmdRunner(
 }
 
 func (f FileLoggingCmdRunner) RunCommand(jobName string, taskName string, cmd boshsys.Command) (*CmdResult, error) {
+	return f.runCommand(jobName, taskName, cmd, func(cmd boshsys.Command) (int, error) {
+		_, _, exitStatus, err := f.cmdRunner.RunComplexCommand(cmd)
+		return exitStatus, err
+	})
+}
+
+func (f FileLoggingCmdRunner) RunCancellableCommand(jobName string, taskName string, cmd boshsys.Command, cancelCh <-chan struct{}) (*CmdResult, error) {
+	var cancelled bool
+
+	result, err := f.runCommand(jobName, taskName, cmd, func(cmd boshsys.Command) (int, error) {
+		process, err := f.cmdRunner.RunComplexCommandAsync(cmd)
+		if err != nil {
+			return -1, err
+		}
+
+		processExitedCh := process.Wait()
+
+		select {
+		case result := <-processExitedCh:
+			return result.ExitStatus, result.Error
+
+		case <-cancelCh:
+			cancelled = true
+
+			// Command is considered cancelled even if it did not exit nicely
+			_ = process.TerminateNicely(cancelKillGracePeriod)
+
+			result := <-processExitedCh
+			return result.ExitStatus, result.Error
+		}
+	})
+
+	if cancelled {
+		return nil, bosherr.WrapErrorf(boshtask.ErrCancelled, "Running task %s", taskName)
+	}
+
+	return result, err
+}
+
+func (f FileLoggingCmdRunner) runCommand(jobName string, taskName string, cmd boshsys.Command, run func(boshsys.Command) (int, error)) (*CmdResult, error) {
 	logsDir := filepath.Join(f.baseDir, jobName)
 
 	err := f.fs.RemoveAll(logsDir)
@@ -83,7 +129,7 @@ func (f FileLoggingCmdRunner) RunCommand(jobName string, taskName string, cmd bo
 	}
 	defer stdoutFile.Close()
 
-	cmd.Stdout = stdoutFile
+	cmd.Stdout = f.teeOutput(stdoutFile, cmd.Stdout)
 
 	stderrFile, err := f.fs.OpenFile(stderrPath, fileOpenFlag, fileOpenPerm)
 	if err != nil {
@@ -91,10 +137,10 @@ func (f FileLoggingCmdRunner) RunCommand(jobName string, taskName string, cmd bo
 	}
 	defer stderrFile.Close()
 
-	cmd.Stderr = stderrFile
+	cmd.Stderr = f.teeOutput(stderrFile, cmd.Stderr)
 
 	// Stdout/stderr are redirected to the files
-	_, _, exitStatus, runErr := f.cmdRunner.RunComplexCommand(cmd)
+	exitStatus, runErr := run(cmd)
 
 	stdout, isStdoutTruncated, err := f.getTruncatedOutput(stdoutFile, f.truncateLength)
 	if err != nil {
@@ -123,6 +169,13 @@ func (f FileLoggingCmdRunner) RunCommand(jobName string, taskName string, cmd bo
 	return result, nil
 }
 
+func (f FileLoggingCmdRunner) teeOutput(file boshsys.File, cmdWriter io.Writer) io.Writer {
+	if cmdWriter == nil {
+		return file
+	}
+	return io.MultiWriter(file, cmdWriter)
+}
+
 func (f FileLoggingCmdRunner) getTruncatedOutput(file boshsys.File, truncateLength int64) ([]byte, bool, error) {
 	isTruncated := false
 
@@ -1,13 +1,16 @@
 package cmdrunner_test
 
 import (
+	"bytes"
 	"errors"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
@@ -127,6 +130,22 @@ var _ = Describe("FileLoggingCmdRunner", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(stdout).To(Equal("fake-stderr"))
 			})
+
+			It("also writes output to writers given with the command", func() {
+				var stdout, stderr bytes.Buffer
+				cmd.Stdout = &stdout
+				cmd.Stderr = &stderr
+
+				_, err := runner.RunCommand("fake-log-dir-name", "fake-log-file-name", cmd)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(stdout.String()).To(Equal("fake-stdout"))
+				Expect(stderr.String()).To(Equal("fake-stderr"))
+
+				savedStdout, err := fs.ReadFileString("/fake-base-dir/fake-log-dir-name/fake-log-file-name.stdout.log")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(savedStdout).To(Equal("fake-stdout"))
+			})
 		})
 
 		Context("when comamnd fails", func() {
@@ -291,4 +310,56 @@ var _ = Describe("FileLoggingCmdRunner", func() {
 			})
 		})
 	})
+
+	Describe("RunCancellableCommand", func() {
+		var (
+			process  *fakesys.FakeProcess
+			cancelCh chan struct{}
+		)
+
+		BeforeEach(func() {
+			process = &fakesys.FakeProcess{
+				WaitResult: boshsys.Result{ExitStatus: 0},
+			}
+			cmdRunner.AddProcess("fake-cmd fake-args", process)
+
+			cancelCh = make(chan struct{})
+		})
+
+		It("runs command asynchronously with output redirected to log files", func() {
+			result, err := runner.RunCancellableCommand("fake-log-dir-name", "fake-log-file-name", cmd, cancelCh)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.ExitStatus).To(Equal(0))
+
+			Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+			Expect(cmdRunner.RunComplexCommands[0].Stdout).ToNot(BeNil())
+			Expect(cmdRunner.RunComplexCommands[0].Stderr).ToNot(BeNil())
+
+			Expect(process.TerminatedNicely).To(BeFalse())
+		})
+
+		It("returns an error if command fails", func() {
+			process.WaitResult = boshsys.Result{ExitStatus: 1, Error: errors.New("fake-run-err")}
+
+			_, err := runner.RunCancellableCommand("fake-log-dir-name", "fake-log-file-name", cmd, cancelCh)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Command exited with 1"))
+		})
+
+		It("terminates command and returns cancelled error when cancelled", func() {
+			process.TerminatedNicelyCallBack = func(p *fakesys.FakeProcess) {
+				p.WaitCh <- boshsys.Result{ExitStatus: 143, Error: errors.New("fake-terminated-err")}
+			}
+
+			close(cancelCh)
+
+			result, err := runner.RunCancellableCommand("fake-log-dir-name", "fake-log-file-name", cmd, cancelCh)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+			Expect(result).To(BeNil())
+
+			Expect(process.TerminatedNicely).To(BeTrue())
+			Expect(process.TerminateNicelyKillGracePeriod).To(Equal(10 * time.Second))
+		})
+	})
 })
@@ -2,10 +2,12 @@ package compiler
 
 import (
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type Compiler interface {
-	Compile(pkg Package, deps []boshmodels.Package) (blobID, sha1 string, err error)
+	// Compile stops and returns boshtask.ErrCancelled once cancelCh is closed
+	Compile(pkg Package, deps []boshmodels.Package, reporter boshtask.ProgressReporter, cancelCh <-chan struct{}) (blobID, sha1 string, err error)
 }
 
 type Package struct {
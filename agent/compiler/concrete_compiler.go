@@ -1,13 +1,16 @@
 package compiler
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
 	boshcmdrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
@@ -48,21 +51,29 @@ func NewConcreteCompiler(
 	}
 }
 
-func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (string, string, error) {
+// Compile reports progress in the following proportions:
+// installing dependencies up to 40%, packaging script up to 80%,
+// compressing and uploading compiled package up to 100%.
+// Compile can be cancelled until compiled package is uploaded.
+func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package, reporter boshtask.ProgressReporter, cancelCh <-chan struct{}) (string, string, error) {
 	err := c.packageApplier.KeepOnly([]boshmodels.Package{})
 	if err != nil {
 		return "", "", bosherr.WrapError(err, "Removing packages")
 	}
 
-	for _, dep := range deps {
-		err := c.packageApplier.Apply(dep)
+	for i, dep := range deps {
+		reporter.ReportProgress(fmt.Sprintf("Downloading package %d/%d", i+1, len(deps)), 40*i/len(deps))
+
+		err := c.packageApplier.Apply(dep, cancelCh)
 		if err != nil {
 			return "", "", bosherr.WrapErrorf(err, "Installing dependent package: '%s'", dep.Name)
 		}
 	}
 
+	reporter.ReportProgress("Fetching package source", 40)
+
 	compilePath := filepath.Join(c.compileDirProvider.CompileDir(), pkg.Name)
-	err = c.fetchAndUncompress(pkg, compilePath)
+	err = c.fetchAndUncompress(pkg, compilePath, cancelCh)
 	if err != nil {
 		return "", "", bosherr.WrapErrorf(err, "Fetching package %s", pkg.Name)
 	}
@@ -84,6 +95,16 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (strin
 		return "", "", bosherr.WrapError(err, "Setting up new package bundle")
 	}
 
+	// Partially compiled package of cancelled compilation
+	// must not be left behind so that it is not mistaken for installed one
+	uploaded := false
+	defer func() {
+		if !uploaded && boshtask.IsCancelled(cancelCh) {
+			_ = compiledPkgBundle.Disable()
+			_ = compiledPkgBundle.Uninstall()
+		}
+	}()
+
 	_, enablePath, err := compiledPkgBundle.Enable()
 	if err != nil {
 		return "", "", bosherr.WrapError(err, "Enabling new package bundle")
@@ -92,6 +113,11 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (strin
 	scriptPath := filepath.Join(compilePath, "packaging")
 
 	if c.fs.FileExists(scriptPath) {
+		reporter.ReportProgress("Running packaging script", 50)
+
+		stdoutWriter := boshtask.NewOutputWriter(reporter, boshtask.OutputStdout)
+		stderrWriter := boshtask.NewOutputWriter(reporter, boshtask.OutputStderr)
+
 		command := boshsys.Command{
 			Name: "bash",
 			Args: []string{"-x", "packaging"},
@@ -102,14 +128,26 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (strin
 				"BOSH_PACKAGE_VERSION": pkg.Version,
 			},
 			WorkingDir: compilePath,
+			Stdout:     stdoutWriter,
+			Stderr:     stderrWriter,
 		}
 
-		_, err := c.runner.RunCommand("compilation", "packaging", command)
+		_, err := c.runner.RunCancellableCommand("compilation", "packaging", command, cancelCh)
+
+		stdoutWriter.Close()
+		stderrWriter.Close()
+
 		if err != nil {
 			return "", "", bosherr.WrapError(err, "Running packaging script")
 		}
 	}
 
+	if boshtask.IsCancelled(cancelCh) {
+		return "", "", boshtask.ErrCancelled
+	}
+
+	reporter.ReportProgress("Compressing compiled package", 80)
+
 	tmpPackageTar, err := c.compressor.CompressFilesInDir(installPath)
 	if err != nil {
 		return "", "", bosherr.WrapError(err, "Compressing compiled package")
@@ -117,11 +155,19 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (strin
 
 	defer c.compressor.CleanUp(tmpPackageTar)
 
+	if boshtask.IsCancelled(cancelCh) {
+		return "", "", boshtask.ErrCancelled
+	}
+
+	reporter.ReportProgress("Uploading compiled package", 90)
+
 	uploadedBlobID, sha1, err := c.blobstore.Create(tmpPackageTar)
 	if err != nil {
 		return "", "", bosherr.WrapError(err, "Uploading compiled package")
 	}
 
+	uploaded = true
+
 	err = compiledPkgBundle.Disable()
 	if err != nil {
 		return "", "", bosherr.WrapError(err, "Disabling compiled package")
@@ -140,13 +186,13 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (strin
 	return uploadedBlobID, sha1, nil
 }
 
-func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string) error {
+func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string, cancelCh <-chan struct{}) error {
 	// Do not verify integrity of the download via SHA1
 	// because Director might have stored non-matching SHA1.
 	// This will be fixed in future by explicitly asking to verify SHA1
 	// instead of doing that by default like all other downloads.
 	// (Ruby agent mistakenly never checked SHA1.)
-	depFilePath, err := c.blobstore.Get(pkg.BlobstoreID, "")
+	depFilePath, err := boshagentblob.CancellableGet(c.blobstore, pkg.BlobstoreID, "", cancelCh)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Fetching package blob %s", pkg.BlobstoreID)
 	}
@@ -12,6 +12,8 @@ import (
 	fakepackages "github.com/cloudfoundry/bosh-agent/agent/applier/packages/fakes"
 	fakecmdrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner/fakes"
 	. "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	fakeblobstore "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -64,6 +66,8 @@ func init() {
 			runner         *fakecmdrunner.FakeFileLoggingCmdRunner
 			packageApplier *fakepackages.FakeApplier
 			packagesBc     *fakebc.FakeBundleCollection
+			reporter       *faketask.FakeProgressReporter
+			cancelCh       chan struct{}
 		)
 
 		BeforeEach(func() {
@@ -73,6 +77,8 @@ func init() {
 			runner = fakecmdrunner.NewFakeFileLoggingCmdRunner()
 			packageApplier = fakepackages.NewFakeApplier()
 			packagesBc = fakebc.NewFakeBundleCollection()
+			reporter = faketask.NewFakeProgressReporter()
+			cancelCh = make(chan struct{})
 
 			compiler = NewConcreteCompiler(
 				compressor,
@@ -114,7 +120,7 @@ func init() {
 				blobstore.CreateBlobID = "fake-blob-id"
 				blobstore.CreateFingerprint = "fake-blob-sha1"
 
-				blobID, sha1, err := compiler.Compile(pkg, pkgDeps)
+				blobID, sha1, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(blobID).To(Equal("fake-blob-id"))
@@ -122,7 +128,7 @@ func init() {
 			})
 
 			It("cleans up all packages before and after applying dependent packages", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.ActionsCalled).To(Equal([]string{"KeepOnly", "Apply", "Apply", "KeepOnly"}))
 				Expect(packageApplier.KeptOnlyPackages).To(BeEmpty())
@@ -131,13 +137,13 @@ func init() {
 			It("returns an error if cleaning up packages fails", func() {
 				packageApplier.KeepOnlyErr = errors.New("fake-keep-only-error")
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
 			})
 
 			It("fetches source package from blobstore without checking SHA1 by default because of Director bug", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(blobstore.GetBlobIDs[0]).To(Equal("blobstore_id"))
@@ -145,7 +151,7 @@ func init() {
 			})
 
 			PIt("(Pending Tracker Story: <https://www.pivotaltracker.com/story/show/94524232>) fetches source package from blobstore and checks SHA1 by default in future", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(blobstore.GetBlobIDs[0]).To(Equal("blobstore_id"))
@@ -155,7 +161,7 @@ func init() {
 			It("returns an error if removing compile target directory during uncompression fails", func() {
 				fs.RegisterRemoveAllError("/fake-compile-dir/pkg_name", errors.New("fake-remove-error"))
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-error"))
 			})
@@ -163,7 +169,7 @@ func init() {
 			It("returns an error if creating compile target directory during uncompression fails", func() {
 				fs.RegisterMkdirAllError("/fake-compile-dir/pkg_name", errors.New("fake-mkdir-error"))
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-mkdir-error"))
 			})
@@ -171,7 +177,7 @@ func init() {
 			It("returns an error if removing temporary compile target directory during uncompression fails", func() {
 				fs.RegisterRemoveAllError("/fake-compile-dir/pkg_name-bosh-agent-unpack", errors.New("fake-remove-error"))
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-error"))
 			})
@@ -179,25 +185,36 @@ func init() {
 			It("returns an error if creating temporary compile target directory during uncompression fails", func() {
 				fs.RegisterMkdirAllError("/fake-compile-dir/pkg_name-bosh-agent-unpack", errors.New("fake-mkdir-error"))
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-mkdir-error"))
 			})
 
+			It("does not fetch source package when cancelled", func() {
+				close(cancelCh)
+
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+
+				Expect(blobstore.GetBlobIDs).To(BeEmpty())
+				Expect(packageApplier.ApplyCancelCh).To(Equal((<-chan struct{})(cancelCh)))
+			})
+
 			It("installs dependent packages", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.AppliedPackages).To(Equal(pkgDeps))
 			})
 
 			It("cleans up the compile directory", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(fs.FileExists("/fake-compile-dir/pkg_name")).To(BeFalse())
 			})
 
 			It("installs, enables and later cleans up bundle", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(bundle.ActionsCalled).To(Equal([]string{
 					"InstallWithoutContents",
@@ -215,7 +232,7 @@ func init() {
 				})
 
 				It("runs packaging script ", func() {
-					_, _, err := compiler.Compile(pkg, pkgDeps)
+					_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 					Expect(err).ToNot(HaveOccurred())
 
 					expectedCmd := boshsys.Command{
@@ -231,28 +248,91 @@ func init() {
 					}
 
 					Expect(len(runner.RunCommands)).To(Equal(1))
-					Expect(runner.RunCommands[0]).To(Equal(expectedCmd))
+
+					actualCmd := runner.RunCommands[0]
+					Expect(actualCmd.Stdout).ToNot(BeNil())
+					Expect(actualCmd.Stderr).ToNot(BeNil())
+
+					actualCmd.Stdout, actualCmd.Stderr = nil, nil
+					Expect(actualCmd).To(Equal(expectedCmd))
 					Expect(runner.RunCommandJobName).To(Equal("compilation"))
 					Expect(runner.RunCommandTaskName).To(Equal("packaging"))
+					Expect(runner.RunCommandCancelCh).To(Equal((<-chan struct{})(cancelCh)))
+				})
+
+				Context("when cancelled while running packaging script", func() {
+					BeforeEach(func() {
+						compressor.DecompressFileToDirCallBack = func() {
+							fs.WriteFileString("/fake-compile-dir/pkg_name/packaging", "hi")
+							close(cancelCh)
+						}
+					})
+
+					It("returns cancelled error without uploading compiled package", func() {
+						_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+
+						Expect(compressor.CompressFilesInDirDir).To(BeEmpty())
+						Expect(blobstore.CreateFileNames).To(BeEmpty())
+					})
+
+					It("removes partially compiled package and compile directory", func() {
+						_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
+						Expect(err).To(HaveOccurred())
+
+						Expect(bundle.ActionsCalled).To(Equal([]string{
+							"InstallWithoutContents",
+							"Enable",
+							"Disable",
+							"Uninstall",
+						}))
+						Expect(fs.FileExists("/fake-compile-dir/pkg_name")).To(BeFalse())
+					})
+				})
+
+				It("reports progress of each compilation stage", func() {
+					_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(reporter.Events).To(Equal([]boshtask.Event{
+						{Stage: "Downloading package 1/2", Percent: 0},
+						{Stage: "Downloading package 2/2", Percent: 20},
+						{Stage: "Fetching package source", Percent: 40},
+						{Stage: "Running packaging script", Percent: 50},
+						{Stage: "Compressing compiled package", Percent: 80},
+						{Stage: "Uploading compiled package", Percent: 90},
+					}))
+				})
+
+				It("reports output lines of packaging script", func() {
+					runner.RunCommandStdout = "fake-stdout-1\nfake-stdout-2"
+					runner.RunCommandStderr = "+ fake-command\n"
+
+					_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(reporter.OutputLines(boshtask.OutputStdout)).To(Equal([]string{"fake-stdout-1", "fake-stdout-2"}))
+					Expect(reporter.OutputLines(boshtask.OutputStderr)).To(Equal([]string{"+ fake-command"}))
 				})
 
 				It("propagates the error from packaging script", func() {
 					runner.RunCommandErr = errors.New("fake-packaging-error")
 
-					_, _, err := compiler.Compile(pkg, pkgDeps)
+					_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-packaging-error"))
 				})
 			})
 
 			It("does not run packaging script when script does not exist", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(runner.RunCommands).To(BeEmpty())
 			})
 
 			It("compresses compiled package", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 
 				// archive was downloaded from the blobstore and decompress to this temp dir
@@ -271,7 +351,7 @@ func init() {
 			It("uploads compressed package to blobstore", func() {
 				compressor.CompressFilesInDirTarballPath = "/tmp/compressed-compiled-package"
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(blobstore.CreateFileNames[0]).To(Equal("/tmp/compressed-compiled-package"))
 			})
@@ -279,7 +359,7 @@ func init() {
 			It("returs error if uploading compressed package fails", func() {
 				blobstore.CreateErr = errors.New("fake-create-err")
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-create-err"))
 			})
@@ -291,7 +371,7 @@ func init() {
 					beforeCleanUpTarballPath = compressor.CleanUpTarballPath
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, reporter, cancelCh)
 				Expect(err).ToNot(HaveOccurred())
 
 				// Compressed package is not cleaned up before blobstore upload
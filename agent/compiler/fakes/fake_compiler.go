@@ -3,14 +3,18 @@ package fakes
 import (
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeCompiler struct {
-	CompilePkg    boshcomp.Package
-	CompileDeps   []boshmodels.Package
-	CompileBlobID string
-	CompileSha1   string
-	CompileErr    error
+	CompilePkg      boshcomp.Package
+	CompileDeps     []boshmodels.Package
+	CompileReporter boshtask.ProgressReporter
+	CompileCancelCh <-chan struct{}
+	CompileCallBack func()
+	CompileBlobID   string
+	CompileSha1     string
+	CompileErr      error
 }
 
 func NewFakeCompiler() (c *FakeCompiler) {
@@ -18,9 +22,16 @@ func NewFakeCompiler() (c *FakeCompiler) {
 	return
 }
 
-func (c *FakeCompiler) Compile(pkg boshcomp.Package, deps []boshmodels.Package) (blobID, sha1 string, err error) {
+func (c *FakeCompiler) Compile(pkg boshcomp.Package, deps []boshmodels.Package, reporter boshtask.ProgressReporter, cancelCh <-chan struct{}) (blobID, sha1 string, err error) {
 	c.CompilePkg = pkg
 	c.CompileDeps = deps
+	c.CompileReporter = reporter
+	c.CompileCancelCh = cancelCh
+
+	if c.CompileCallBack != nil {
+		c.CompileCallBack()
+	}
+
 	blobID = c.CompileBlobID
 	sha1 = c.CompileSha1
 	err = c.CompileErr
@@ -3,11 +3,16 @@ package drain
 import (
 	"strconv"
 	"strings"
+	"time"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
+// Time given to cancelled drain script to exit after SIGTERM before it is killed
+const cancelKillGracePeriod = 10 * time.Second
+
 type ConcreteScript struct {
 	fs              boshsys.FileSystem
 	runner          boshsys.CmdRunner
@@ -35,7 +40,7 @@ func (script ConcreteScript) Path() string {
 	return script.drainScriptPath
 }
 
-func (script ConcreteScript) Run(params ScriptParams) (int, error) {
+func (script ConcreteScript) Run(params ScriptParams, cancelCh <-chan struct{}) (int, error) {
 	jobChange := params.JobChange()
 	hashChange := params.HashChange()
 	updatedPkgs := params.UpdatedPackages()
@@ -68,12 +73,29 @@ func (script ConcreteScript) Run(params ScriptParams) (int, error) {
 	command.Args = append(command.Args, jobChange, hashChange)
 	command.Args = append(command.Args, updatedPkgs...)
 
-	stdout, _, _, err := script.runner.RunComplexCommand(command)
+	process, err := script.runner.RunComplexCommandAsync(command)
 	if err != nil {
 		return 0, bosherr.WrapError(err, "Running drain script")
 	}
 
-	value, err := strconv.Atoi(strings.TrimSpace(stdout))
+	processExitedCh := process.Wait()
+
+	var result boshsys.Result
+
+	select {
+	case result = <-processExitedCh:
+	case <-cancelCh:
+		// Drain is considered cancelled even if script did not exit nicely
+		_ = process.TerminateNicely(cancelKillGracePeriod)
+		<-processExitedCh
+		return 0, bosherr.WrapError(boshtask.ErrCancelled, "Running drain script")
+	}
+
+	if result.Error != nil {
+		return 0, bosherr.WrapError(result.Error, "Running drain script")
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
 	if err != nil {
 		return 0, bosherr.WrapError(err, "Script did not return a signed integer")
 	}
@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/drain"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
@@ -45,10 +46,13 @@ var _ = Describe("ConcreteScript", func() {
 
 	Describe("Run", func() {
 		var (
-			params fakeParams
+			params   fakeParams
+			cancelCh chan struct{}
 		)
 
 		BeforeEach(func() {
+			cancelCh = make(chan struct{})
+
 			params = fakeParams{
 				jobChange:       "job_shutdown",
 				hashChange:      "hash_unchanged",
@@ -57,10 +61,11 @@ var _ = Describe("ConcreteScript", func() {
 		})
 
 		It("runs drain script", func() {
-			commandResult := fakesys.FakeCmdResult{Stdout: "1"}
-			runner.AddCmdResult("/fake/script job_shutdown hash_unchanged foo bar", commandResult)
+			runner.AddProcess("/fake/script job_shutdown hash_unchanged foo bar", &fakesys.FakeProcess{
+				WaitResult: boshsys.Result{Stdout: "1"},
+			})
 
-			_, err := script.Run(params)
+			_, err := script.Run(params, cancelCh)
 			Expect(err).ToNot(HaveOccurred())
 
 			expectedCmd := boshsys.Command{
@@ -76,44 +81,69 @@ var _ = Describe("ConcreteScript", func() {
 		})
 
 		It("returns parsed stdout", func() {
-			commandResult := fakesys.FakeCmdResult{Stdout: "1"}
-			runner.AddCmdResult("/fake/script job_shutdown hash_unchanged foo bar", commandResult)
+			runner.AddProcess("/fake/script job_shutdown hash_unchanged foo bar", &fakesys.FakeProcess{
+				WaitResult: boshsys.Result{Stdout: "1"},
+			})
 
-			value, err := script.Run(params)
+			value, err := script.Run(params, cancelCh)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(value).To(Equal(1))
 		})
 
 		It("returns parsed stdout after trimming", func() {
-			commandResult := fakesys.FakeCmdResult{Stdout: "-56\n"}
-			runner.AddCmdResult("/fake/script job_shutdown hash_unchanged foo bar", commandResult)
+			runner.AddProcess("/fake/script job_shutdown hash_unchanged foo bar", &fakesys.FakeProcess{
+				WaitResult: boshsys.Result{Stdout: "-56\n"},
+			})
 
-			value, err := script.Run(params)
+			value, err := script.Run(params, cancelCh)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(value).To(Equal(-56))
 		})
 
 		It("returns error with non integer stdout", func() {
-			commandResult := fakesys.FakeCmdResult{Stdout: "hello!"}
-			runner.AddCmdResult("/fake/script job_shutdown hash_unchanged foo bar", commandResult)
+			runner.AddProcess("/fake/script job_shutdown hash_unchanged foo bar", &fakesys.FakeProcess{
+				WaitResult: boshsys.Result{Stdout: "hello!"},
+			})
 
-			_, err := script.Run(params)
+			_, err := script.Run(params, cancelCh)
 			Expect(err).To(HaveOccurred())
 		})
 
 		It("returns error when running command errors", func() {
-			commandResult := fakesys.FakeCmdResult{Error: errors.New("woops")}
-			runner.AddCmdResult("/fake/script job_shutdown hash_unchanged foo bar", commandResult)
+			runner.AddProcess("/fake/script job_shutdown hash_unchanged foo bar", &fakesys.FakeProcess{
+				WaitResult: boshsys.Result{Error: errors.New("woops")},
+			})
 
-			_, err := script.Run(params)
+			_, err := script.Run(params, cancelCh)
 			Expect(err).To(HaveOccurred())
 		})
 
+		It("terminates drain script and returns cancelled error when cancelled", func() {
+			process := &fakesys.FakeProcess{
+				TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
+					p.WaitCh <- boshsys.Result{Stdout: "1"}
+				},
+			}
+			runner.AddProcess("/fake/script job_shutdown hash_unchanged foo bar", process)
+
+			close(cancelCh)
+
+			_, err := script.Run(params, cancelCh)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(boshtask.ErrCancelled.Error()))
+
+			Expect(process.TerminatedNicely).To(BeTrue())
+		})
+
 		Describe("job state", func() {
+			BeforeEach(func() {
+				runner.AddProcess("/fake/script job_shutdown hash_unchanged foo bar", &fakesys.FakeProcess{})
+			})
+
 			It("sets the BOSH_JOB_STATE env variable if job state is present", func() {
 				params.jobState = "fake-job-state"
 
-				_, err := script.Run(params)
+				_, err := script.Run(params, cancelCh)
 				Expect(err).To(HaveOccurred())
 
 				Expect(len(runner.RunComplexCommands)).To(Equal(1))
@@ -125,7 +155,7 @@ var _ = Describe("ConcreteScript", func() {
 			It("does not set the BOSH_JOB_STATE env variable if job state is empty", func() {
 				params.jobState = ""
 
-				_, err := script.Run(params)
+				_, err := script.Run(params, cancelCh)
 				Expect(err).To(HaveOccurred())
 
 				Expect(len(runner.RunComplexCommands)).To(Equal(1))
@@ -135,7 +165,7 @@ var _ = Describe("ConcreteScript", func() {
 			It("returns error when cannot get the job state and does not run drain script", func() {
 				params.jobStateErr = errors.New("fake-job-state-err")
 
-				_, err := script.Run(params)
+				_, err := script.Run(params, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-job-state-err"))
 
@@ -144,10 +174,14 @@ var _ = Describe("ConcreteScript", func() {
 		})
 
 		Describe("job next state", func() {
+			BeforeEach(func() {
+				runner.AddProcess("/fake/script job_shutdown hash_unchanged foo bar", &fakesys.FakeProcess{})
+			})
+
 			It("sets the BOSH_JOB_NEXT_STATE env variable if job next state is present", func() {
 				params.jobNextState = "fake-job-next-state"
 
-				_, err := script.Run(params)
+				_, err := script.Run(params, cancelCh)
 				Expect(err).To(HaveOccurred())
 
 				Expect(len(runner.RunComplexCommands)).To(Equal(1))
@@ -159,7 +193,7 @@ var _ = Describe("ConcreteScript", func() {
 			It("does not set the BOSH_JOB_NEXT_STATE env variable if job next state is empty", func() {
 				params.jobNextState = ""
 
-				_, err := script.Run(params)
+				_, err := script.Run(params, cancelCh)
 				Expect(err).To(HaveOccurred())
 
 				Expect(len(runner.RunComplexCommands)).To(Equal(1))
@@ -169,7 +203,7 @@ var _ = Describe("ConcreteScript", func() {
 			It("returns error when cannot get the job next state and does not run drain script", func() {
 				params.jobNextStateErr = errors.New("fake-job-next-state-err")
 
-				_, err := script.Run(params)
+				_, err := script.Run(params, cancelCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-job-next-state-err"))
 
@@ -180,8 +214,9 @@ var _ = Describe("ConcreteScript", func() {
 
 	Describe("Exists", func() {
 		It("returns bool", func() {
-			commandResult := fakesys.FakeCmdResult{Stdout: "1"}
-			runner.AddCmdResult("/fake/script job_shutdown hash_unchanged foo bar", commandResult)
+			runner.AddProcess("/fake/script job_shutdown hash_unchanged foo bar", &fakesys.FakeProcess{
+				WaitResult: boshsys.Result{Stdout: "1"},
+			})
 
 			Expect(script.Exists()).To(BeFalse())
 
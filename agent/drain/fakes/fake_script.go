@@ -2,6 +2,7 @@ package fakes
 
 import (
 	"github.com/cloudfoundry/bosh-agent/agent/drain"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeScript struct {
@@ -10,6 +11,11 @@ type FakeScript struct {
 	RunExitStatus int
 	RunError      error
 	RunParams     drain.ScriptParams
+	RunCancelCh   <-chan struct{}
+	RunCallBack   func()
+
+	// When set Run blocks until it is cancelled
+	RunWaitsForCancel bool
 }
 
 func NewFakeScript() (script *FakeScript) {
@@ -27,9 +33,20 @@ func (script *FakeScript) Path() string {
 	return "/fake/path"
 }
 
-func (script *FakeScript) Run(params drain.ScriptParams) (value int, err error) {
+func (script *FakeScript) Run(params drain.ScriptParams, cancelCh <-chan struct{}) (value int, err error) {
 	script.DidRun = true
 	script.RunParams = params
+	script.RunCancelCh = cancelCh
+
+	if script.RunCallBack != nil {
+		script.RunCallBack()
+	}
+
+	if script.RunWaitsForCancel {
+		<-cancelCh
+		return 0, boshtask.ErrCancelled
+	}
+
 	value = script.RunExitStatus
 	err = script.RunError
 	return
@@ -2,6 +2,7 @@ package drain
 
 type Script interface {
 	Exists() bool
-	Run(params ScriptParams) (value int, err error)
+	// Run terminates the script and returns boshtask.ErrCancelled once cancelCh is closed
+	Run(params ScriptParams, cancelCh <-chan struct{}) (value int, err error)
 	Path() string
 }
@@ -1,14 +1,16 @@
 package agent
 
 import (
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 )
 
 type Heartbeat struct {
-	Job      *string           `json:"job"`
-	Index    *int              `json:"index"`
-	JobState string            `json:"job_state"`
-	Vitals   boshvitals.Vitals `json:"vitals"`
+	Job       *string                `json:"job"`
+	Index     *int                   `json:"index"`
+	JobState  string                 `json:"job_state"`
+	Vitals    boshvitals.Vitals      `json:"vitals"`
+	Processes []boshjobsuper.Process `json:"processes,omitempty"`
 }
 
 //Heartbeat payload example:
@@ -26,8 +28,25 @@ type Heartbeat struct {
 //      "ephemeral": {"percent" => "5"},
 //      "persistent": {"percent" => "94"}
 //    },
+//    "disk_io": {
+//      "sda": {"read_iops":"0.2","write_iops":"12.5","read_bytes":"819","write_bytes":"204800","read_await":"0.50","write_await":"1.20"}
+//    },
+//    "net": {
+//      "eth0": {"rx_bytes":"5120","rx_packets":"40.0","rx_errors":"0.0","tx_bytes":"2048","tx_packets":"20.0","tx_errors":"0.0"}
+//    },
 //  "ntp": {
 //      "offset": "-0.06423",
 //      "timestamp": "14 Oct 11:13:19"
-//  }
+//  },
+//  "processes": [
+//    {
+//      "name": "cloud_controller_ng",
+//      "state": "running",
+//      "pid": 4242,
+//      "uptime": {"secs": 3600},
+//      "restarts": 0,
+//      "cpu": {"total": 2.5},
+//      "mem": {"kb": 145996, "percent": 3.5}
+//    }
+//  ]
 //}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+)
+
+const DefaultDuplicateRequestWindowSeconds = 5 * 60
+
+type RequestCacheOptions struct {
+	// Number of seconds response is remembered for after request is handled
+	// so that repeated deliveries of the request with the same request id
+	// get the same response instead of running the action again.
+	// Defaults to DefaultDuplicateRequestWindowSeconds when not set
+	DuplicateRequestWindowSeconds int
+}
+
+// RequestCache makes handling of identified requests idempotent
+type RequestCache interface {
+	// Respond calls respond func unless request with given id and method
+	// has been recently handled or is being handled;
+	// in that case response of the first request is returned
+	Respond(requestID, method string, respond func() boshhandler.Response) boshhandler.Response
+}
+
+type cachedResponse struct {
+	method   string
+	response boshhandler.Response
+
+	// Closed once response is known
+	respondedCh chan struct{}
+	respondedAt time.Time
+}
+
+type requestCache struct {
+	timeService clock.Clock
+	window      time.Duration
+
+	lock      sync.Mutex
+	responses map[string]*cachedResponse
+}
+
+func NewRequestCache(options RequestCacheOptions, timeService clock.Clock) RequestCache {
+	windowSeconds := options.DuplicateRequestWindowSeconds
+	if windowSeconds <= 0 {
+		windowSeconds = DefaultDuplicateRequestWindowSeconds
+	}
+
+	return &requestCache{
+		timeService: timeService,
+		window:      time.Duration(windowSeconds) * time.Second,
+		responses:   map[string]*cachedResponse{},
+	}
+}
+
+func (c *requestCache) Respond(requestID, method string, respond func() boshhandler.Response) boshhandler.Response {
+	c.lock.Lock()
+
+	c.forgetOldResponses()
+
+	cached, found := c.responses[requestID]
+	if found && cached.method == method {
+		c.lock.Unlock()
+
+		// Duplicate might arrive while first request is still being handled
+		<-cached.respondedCh
+		return cached.response
+	}
+
+	cached = &cachedResponse{
+		method:      method,
+		respondedCh: make(chan struct{}),
+	}
+	c.responses[requestID] = cached
+
+	c.lock.Unlock()
+
+	response := respond()
+
+	c.lock.Lock()
+	cached.response = response
+	cached.respondedAt = c.timeService.Now()
+	close(cached.respondedCh)
+	c.lock.Unlock()
+
+	return response
+}
+
+// Must be called with the lock held.
+func (c *requestCache) forgetOldResponses() {
+	oldestRespondedAt := c.timeService.Now().Add(-c.window)
+
+	for requestID, cached := range c.responses {
+		select {
+		case <-cached.respondedCh:
+			if cached.respondedAt.Before(oldestRespondedAt) {
+				delete(c.responses, requestID)
+			}
+		default:
+			// Requests that are still being handled are always kept
+		}
+	}
+}
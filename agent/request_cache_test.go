@@ -0,0 +1,96 @@
+package agent_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/agent"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+)
+
+func init() {
+	Describe("requestCache", func() {
+		var (
+			timeService *fakeclock.FakeClock
+			cache       RequestCache
+		)
+
+		BeforeEach(func() {
+			timeService = fakeclock.NewFakeClock(time.Now())
+			cache = NewRequestCache(RequestCacheOptions{DuplicateRequestWindowSeconds: 60}, timeService)
+		})
+
+		respondWith := func(value string, calls *int) func() boshhandler.Response {
+			return func() boshhandler.Response {
+				*calls++
+				return boshhandler.NewValueResponse(value)
+			}
+		}
+
+		It("returns response of the first request to its duplicates", func() {
+			var calls int
+
+			resp := cache.Respond("fake-request-id", "fake-method", respondWith("fake-value-1", &calls))
+			Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value-1")))
+
+			resp = cache.Respond("fake-request-id", "fake-method", respondWith("fake-value-2", &calls))
+			Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value-1")))
+
+			Expect(calls).To(Equal(1))
+		})
+
+		It("waits for the first request to be handled before responding to duplicate", func() {
+			releaseCh := make(chan struct{})
+			firstStartedCh := make(chan struct{})
+
+			go cache.Respond("fake-request-id", "fake-method", func() boshhandler.Response {
+				close(firstStartedCh)
+				<-releaseCh
+				return boshhandler.NewValueResponse("fake-value-1")
+			})
+
+			<-firstStartedCh
+
+			respCh := make(chan boshhandler.Response)
+			go func() {
+				respCh <- cache.Respond("fake-request-id", "fake-method", func() boshhandler.Response {
+					return boshhandler.NewValueResponse("fake-value-2")
+				})
+			}()
+
+			Consistently(respCh).ShouldNot(Receive())
+
+			close(releaseCh)
+			Eventually(respCh).Should(Receive(Equal(boshhandler.NewValueResponse("fake-value-1"))))
+		})
+
+		It("forgets responses once duplicate request window passes", func() {
+			var calls int
+
+			cache.Respond("fake-request-id", "fake-method", respondWith("fake-value-1", &calls))
+
+			timeService.Increment(60 * time.Second)
+			resp := cache.Respond("fake-request-id", "fake-method", respondWith("fake-value-2", &calls))
+			Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value-1")))
+
+			timeService.Increment(time.Second)
+			resp = cache.Respond("fake-request-id", "fake-method", respondWith("fake-value-3", &calls))
+			Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value-3")))
+
+			Expect(calls).To(Equal(2))
+		})
+
+		It("does not treat request with the same id but different method as duplicate", func() {
+			var calls int
+
+			cache.Respond("fake-request-id", "fake-method-1", respondWith("fake-value-1", &calls))
+			resp := cache.Respond("fake-request-id", "fake-method-2", respondWith("fake-value-2", &calls))
+			Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value-2")))
+
+			Expect(calls).To(Equal(2))
+		})
+	})
+}
@@ -1,38 +1,142 @@
 package task
 
 import (
+	"sort"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 )
 
-// Access to the currentTasks map should always be performed in the semaphore
+const (
+	DefaultMaxConcurrentTasks        = 4
+	DefaultMaxFinishedTasks          = 1000
+	DefaultMaxFinishedTaskAgeSeconds = 24 * 60 * 60
+)
+
+type Options struct {
+	// Maximum number of tasks that are run at the same time;
+	// tasks that touch the same resources are still run one after another.
+	// Defaults to DefaultMaxConcurrentTasks when not set
+	MaxConcurrentTasks int
+
+	// Maximum number of finished tasks that are remembered for get_task and list_tasks;
+	// oldest finished tasks are forgotten first.
+	// Defaults to DefaultMaxFinishedTasks when not set
+	MaxFinishedTasks int
+
+	// Finished tasks are forgotten once they have been finished for this long.
+	// Defaults to DefaultMaxFinishedTaskAgeSeconds when not set
+	MaxFinishedTaskAgeSeconds int
+
+	// When set to true summaries of finished tasks are saved
+	// to tasks_journal.json next to tasks.json
+	// so that list_tasks still reports them after agent restart
+	UseJournal bool
+
+	// Default number of seconds async actions are allowed to take keyed by action name
+	// (e.g. {"drain": 600}); timeout given in request takes precedence.
+	// Tasks of actions without timeout are allowed to run forever
+	ActionTimeoutsSeconds map[string]int
+}
+
+func (o Options) ActionTimeouts() map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+
+	for method, seconds := range o.ActionTimeoutsSeconds {
+		if seconds > 0 {
+			timeouts[method] = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return timeouts
+}
+
+// Access to the currentTasks map, queuedTasks, finishedTasks, timedOutTasks, lockedResources and runningTasks
+// should always be performed in the semaphore
 // Use the taskSem channel for that
 
 type asyncTaskService struct {
-	uuidGen boshuuid.Generator
-	logger  boshlog.Logger
+	journal     Journal
+	uuidGen     boshuuid.Generator
+	timeService clock.Clock
+	logger      boshlog.Logger
 
-	currentTasks map[string]Task
-	taskChan     chan Task
-	taskSem      chan func()
+	currentTasks    map[string]Task
+	queuedTasks     []Task
+	lockedResources map[Resource]struct{}
+	runningTasks    int
+	maxRunningTasks int
+
+	// Summaries of finished tasks in order of completion;
+	// tasks finished before agent restart only have summaries
+	finishedTasks      []Summary
+	maxFinishedTasks   int
+	maxFinishedTaskAge time.Duration
+
+	// Tasks that were reported as timed out while their funcs are still running
+	timedOutTasks map[string]Task
+
+	taskChan chan Task
+	taskSem  chan func()
 }
 
-func NewAsyncTaskService(uuidGen boshuuid.Generator, logger boshlog.Logger) (service Service) {
-	s := asyncTaskService{
-		uuidGen:      uuidGen,
-		logger:       logger,
-		currentTasks: make(map[string]Task),
-		taskChan:     make(chan Task),
-		taskSem:      make(chan func()),
+// NewAsyncTaskService returns task service that remembers finished tasks
+// according to retention options. Journal is optional and can be nil.
+func NewAsyncTaskService(
+	options Options,
+	journal Journal,
+	uuidGen boshuuid.Generator,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) (service Service) {
+	maxRunningTasks := options.MaxConcurrentTasks
+	if maxRunningTasks <= 0 {
+		maxRunningTasks = DefaultMaxConcurrentTasks
+	}
+
+	maxFinishedTasks := options.MaxFinishedTasks
+	if maxFinishedTasks <= 0 {
+		maxFinishedTasks = DefaultMaxFinishedTasks
+	}
+
+	maxFinishedTaskAgeSeconds := options.MaxFinishedTaskAgeSeconds
+	if maxFinishedTaskAgeSeconds <= 0 {
+		maxFinishedTaskAgeSeconds = DefaultMaxFinishedTaskAgeSeconds
+	}
+
+	s := &asyncTaskService{
+		journal:            journal,
+		uuidGen:            uuidGen,
+		timeService:        timeService,
+		logger:             logger,
+		currentTasks:       make(map[string]Task),
+		lockedResources:    make(map[Resource]struct{}),
+		timedOutTasks:      make(map[string]Task),
+		maxRunningTasks:    maxRunningTasks,
+		maxFinishedTasks:   maxFinishedTasks,
+		maxFinishedTaskAge: time.Duration(maxFinishedTaskAgeSeconds) * time.Second,
+
+		// Buffered so that scheduling never blocks on busy workers
+		taskChan: make(chan Task, maxRunningTasks),
+		taskSem:  make(chan func()),
+	}
+
+	s.loadJournal()
+
+	for i := 0; i < maxRunningTasks; i++ {
+		go s.processTasks()
 	}
 
-	go s.processTasks()
 	go s.processSemFuncs()
 
 	return s
 }
 
-func (service asyncTaskService) CreateTask(
+func (service *asyncTaskService) CreateTask(
 	taskFunc Func,
 	cancelFunc CancelFunc,
 	endFunc EndFunc,
@@ -45,7 +149,7 @@ func (service asyncTaskService) CreateTask(
 	return service.CreateTaskWithID(uuid, taskFunc, cancelFunc, endFunc), nil
 }
 
-func (service asyncTaskService) CreateTaskWithID(
+func (service *asyncTaskService) CreateTaskWithID(
 	id string,
 	taskFunc Func,
 	cancelFunc CancelFunc,
@@ -60,23 +164,27 @@ func (service asyncTaskService) CreateTaskWithID(
 	}
 }
 
-func (service asyncTaskService) StartTask(task Task) {
-	taskChan := make(chan Task)
+func (service *asyncTaskService) StartTask(task Task) {
+	recordedCh := make(chan struct{})
 
 	service.taskSem <- func() {
+		task.State = StateQueued
+		task.QueuedAt = service.timeService.Now()
 		service.currentTasks[task.ID] = task
-		taskChan <- task
+		service.queuedTasks = append(service.queuedTasks, task)
+		service.scheduleQueuedTasks()
+		close(recordedCh)
 	}
 
-	recordedTask := <-taskChan
-	service.taskChan <- recordedTask
+	<-recordedCh
 }
 
-func (service asyncTaskService) FindTaskWithID(id string) (Task, bool) {
+func (service *asyncTaskService) FindTaskWithID(id string) (Task, bool) {
 	taskChan := make(chan Task)
 	foundChan := make(chan bool)
 
 	service.taskSem <- func() {
+		service.forgetOldFinishedTasks()
 		task, found := service.currentTasks[id]
 		taskChan <- task
 		foundChan <- found
@@ -85,7 +193,156 @@ func (service asyncTaskService) FindTaskWithID(id string) (Task, bool) {
 	return <-taskChan, <-foundChan
 }
 
-func (service asyncTaskService) processSemFuncs() {
+func (service *asyncTaskService) ListTasks() []Summary {
+	summariesChan := make(chan []Summary)
+
+	service.taskSem <- func() {
+		service.forgetOldFinishedTasks()
+
+		summaries := append([]Summary{}, service.finishedTasks...)
+		for _, task := range service.currentTasks {
+			if task.State == StateQueued || task.State == StateRunning {
+				summaries = append(summaries, task.Summary())
+			}
+		}
+
+		summariesChan <- summaries
+	}
+
+	summaries := <-summariesChan
+
+	sort.Stable(summariesByQueuedAt(summaries))
+
+	return summaries
+}
+
+func (service *asyncTaskService) CancelTask(id string) error {
+	taskChan := make(chan Task)
+	foundChan := make(chan bool)
+
+	service.taskSem <- func() {
+		task, found := service.currentTasks[id]
+
+		switch {
+		case !found:
+		case task.State == StateQueued:
+			task.State = StateCancelled
+			task.Error = ErrCancelled
+			task.FinishedAt = service.timeService.Now()
+			service.recordFinishedTask(task)
+			service.removeQueuedTask(id)
+		case task.State == StateRunning:
+			task.cancelRequested = true
+			service.currentTasks[id] = task
+		}
+
+		taskChan <- task
+		foundChan <- found
+	}
+
+	task, found := <-taskChan, <-foundChan
+	if !found {
+		return bosherr.Errorf("Task with id %s could not be found", id)
+	}
+
+	switch task.State {
+	case StateCancelled:
+		// Task never started so there is nothing to stop
+		if task.EndFunc != nil {
+			task.EndFunc(task)
+		}
+		return nil
+
+	case StateRunning:
+		return task.Cancel()
+
+	default:
+		// Cancelling finished task has no effect
+		return nil
+	}
+}
+
+func (service *asyncTaskService) TimeOutTask(id string, err error) error {
+	taskChan := make(chan Task)
+	wasRunningChan := make(chan bool)
+
+	service.taskSem <- func() {
+		task, found := service.currentTasks[id]
+		wasRunning := found && task.State == StateRunning
+
+		// Task whose func has already returned is about to be recorded with its own outcome
+		if found && (task.State == StateQueued || (task.State == StateRunning && !task.finishing)) {
+			task.State = StateTimedOut
+			task.Error = err
+			task.FinishedAt = service.timeService.Now()
+			service.recordFinishedTask(task)
+
+			if wasRunning {
+				service.timedOutTasks[id] = task
+			} else {
+				service.removeQueuedTask(id)
+			}
+		}
+
+		taskChan <- task
+		wasRunningChan <- wasRunning
+	}
+
+	task, wasRunning := <-taskChan, <-wasRunningChan
+
+	if task.State != StateTimedOut {
+		// Task is unknown or has already finished (or is finishing)
+		return nil
+	}
+
+	service.logger.Info("Task Service", "Timed out task #%s: %s", id, err.Error())
+
+	if wasRunning {
+		// End func is called once task func returns
+		return task.Cancel()
+	}
+
+	// Task never started so there is nothing to stop
+	if task.EndFunc != nil {
+		task.EndFunc(task)
+	}
+
+	return nil
+}
+
+func (service *asyncTaskService) AddEvent(id string, event Event) {
+	service.taskSem <- func() {
+		task, found := service.currentTasks[id]
+		if !found {
+			return
+		}
+
+		task.Events = append(task.Events, event)
+		if len(task.Events) > MaxEvents {
+			task.Events = task.Events[len(task.Events)-MaxEvents:]
+		}
+
+		service.currentTasks[id] = task
+	}
+}
+
+func (service *asyncTaskService) AddOutput(id string, line OutputLine) {
+	service.taskSem <- func() {
+		task, found := service.currentTasks[id]
+		if !found {
+			return
+		}
+
+		task.Output = append(task.Output, line)
+		if len(task.Output) > MaxOutputLines {
+			task.Output = task.Output[len(task.Output)-MaxOutputLines:]
+		}
+
+		service.currentTasks[id] = task
+	}
+}
+
+func (service *asyncTaskService) processSemFuncs() {
 	defer service.logger.HandlePanic("Task Service Process Sem Funcs")
 
 	for {
@@ -94,28 +351,210 @@ func (service asyncTaskService) processSemFuncs() {
 	}
 }
 
-func (service asyncTaskService) processTasks() {
+func (service *asyncTaskService) processTasks() {
 	defer service.logger.HandlePanic("Task Service Process Tasks")
 
 	for {
 		task := <-service.taskChan
 
 		value, err := task.Func()
-		if err != nil {
+
+		timedOutTask, timedOut, cancelRequested := service.finishTask(task.ID)
+
+		switch {
+		case timedOut:
+			// Task was already reported as timed out so its result is discarded
+			task = timedOutTask
+			service.logger.Info("Task Service", "Timed out task #%s has stopped", task.ID)
+		case err != nil:
 			task.Error = err
 			task.State = StateFailed
-			service.logger.Error("Task Service", "Failed processing task #%s got: %s", task.ID, err.Error())
-		} else {
+			if cancelRequested {
+				task.State = StateCancelled
+				service.logger.Info("Task Service", "Cancelled task #%s got: %s", task.ID, err.Error())
+			} else {
+				service.logger.Error("Task Service", "Failed processing task #%s got: %s", task.ID, err.Error())
+			}
+		default:
 			task.Value = value
 			task.State = StateDone
 		}
 
+		if !timedOut {
+			task.FinishedAt = service.timeService.Now()
+		}
+
 		if task.EndFunc != nil {
 			task.EndFunc(task)
 		}
 
 		service.taskSem <- func() {
-			service.currentTasks[task.ID] = task
+			if timedOut {
+				delete(service.timedOutTasks, task.ID)
+			} else {
+				// Keep events and output that were added while task was running
+				task.Events = service.currentTasks[task.ID].Events
+				task.Output = service.currentTasks[task.ID].Output
+				service.recordFinishedTask(task)
+			}
+			service.unlockResources(task)
+			service.runningTasks--
+			service.scheduleQueuedTasks()
+		}
+	}
+}
+
+// finishTask decides outcome of task whose func has returned: either it was
+// already timed out or it is marked as finishing so that it can no longer be timed out
+func (service *asyncTaskService) finishTask(id string) (timedOutTask Task, timedOut bool, cancelRequested bool) {
+	doneCh := make(chan struct{})
+
+	service.taskSem <- func() {
+		timedOutTask, timedOut = service.timedOutTasks[id]
+
+		if !timedOut {
+			task := service.currentTasks[id]
+			task.finishing = true
+			service.currentTasks[id] = task
+			cancelRequested = task.cancelRequested
+		}
+
+		close(doneCh)
+	}
+
+	<-doneCh
+
+	return timedOutTask, timedOut, cancelRequested
+}
+
+// scheduleQueuedTasks hands queued tasks over to workers in FIFO order.
+// Task is kept in the queue if there are no free workers, if it conflicts
+// with a running task or if it conflicts with an earlier queued task
+// so that conflicting tasks never overtake each other.
+// Must be called from the semaphore.
+func (service *asyncTaskService) scheduleQueuedTasks() {
+	var stillQueuedTasks []Task
+	awaitedResources := map[Resource]struct{}{}
+
+	for _, task := range service.queuedTasks {
+		canRun := service.runningTasks < service.maxRunningTasks &&
+			!hasAnyResource(service.lockedResources, task.Resources) &&
+			!hasAnyResource(awaitedResources, task.Resources)
+
+		if !canRun {
+			for _, resource := range task.Resources {
+				awaitedResources[resource] = struct{}{}
+			}
+			stillQueuedTasks = append(stillQueuedTasks, task)
+			continue
+		}
+
+		for _, resource := range task.Resources {
+			service.lockedResources[resource] = struct{}{}
 		}
+		service.runningTasks++
+
+		task.State = StateRunning
+		task.StartedAt = service.timeService.Now()
+		service.currentTasks[task.ID] = task
+		service.taskChan <- task
+	}
+
+	service.queuedTasks = stillQueuedTasks
+}
+
+// Must be called from the semaphore.
+func (service *asyncTaskService) removeQueuedTask(id string) {
+	for i, task := range service.queuedTasks {
+		if task.ID == id {
+			service.queuedTasks = append(service.queuedTasks[:i], service.queuedTasks[i+1:]...)
+			break
+		}
+	}
+
+	// Tasks waiting behind cancelled task might be able to run now
+	service.scheduleQueuedTasks()
+}
+
+// Must be called from the semaphore.
+func (service *asyncTaskService) recordFinishedTask(task Task) {
+	service.currentTasks[task.ID] = task
+	service.finishedTasks = append(service.finishedTasks, task.Summary())
+	service.forgetOldFinishedTasks()
+	service.saveJournal()
+}
+
+// forgetOldFinishedTasks drops finished tasks that are over the age or count limit
+// so that long-lived agents do not keep every task they have ever run.
+// Must be called from the semaphore.
+func (service *asyncTaskService) forgetOldFinishedTasks() {
+	oldestFinishedAt := service.timeService.Now().Add(-service.maxFinishedTaskAge)
+
+	var forgotten int
+
+	for _, summary := range service.finishedTasks {
+		tooMany := len(service.finishedTasks)-forgotten > service.maxFinishedTasks
+		tooOld := summary.FinishedAt != nil && summary.FinishedAt.Before(oldestFinishedAt)
+
+		if !tooMany && !tooOld {
+			break
+		}
+
+		delete(service.currentTasks, summary.AgentTaskID)
+		forgotten++
+	}
+
+	if forgotten > 0 {
+		service.finishedTasks = append([]Summary{}, service.finishedTasks[forgotten:]...)
+	}
+}
+
+func (service *asyncTaskService) loadJournal() {
+	if service.journal == nil {
+		return
+	}
+
+	summaries, err := service.journal.Load()
+	if err != nil {
+		// Losing task history does not prevent agent from running new tasks
+		service.logger.Error("Task Service", "Failed loading task journal: %s", err.Error())
+		return
 	}
+
+	service.finishedTasks = summaries
+	service.forgetOldFinishedTasks()
 }
+
+// Must be called from the semaphore.
+func (service *asyncTaskService) saveJournal() {
+	if service.journal == nil {
+		return
+	}
+
+	err := service.journal.Save(service.finishedTasks)
+	if err != nil {
+		service.logger.Error("Task Service", "Failed saving task journal: %s", err.Error())
+	}
+}
+
+// Must be called from the semaphore.
+func (service *asyncTaskService) unlockResources(task Task) {
+	for _, resource := range task.Resources {
+		delete(service.lockedResources, resource)
+	}
+}
+
+func hasAnyResource(resources map[Resource]struct{}, wanted []Resource) bool {
+	for _, resource := range wanted {
+		if _, found := resources[resource]; found {
+			return true
+		}
+	}
+	return false
+}
+
+type summariesByQueuedAt []Summary
+
+func (s summariesByQueuedAt) Len() int           { return len(s) }
+func (s summariesByQueuedAt) Less(i, j int) bool { return s[i].QueuedAt.Before(s[j].QueuedAt) }
+func (s summariesByQueuedAt) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
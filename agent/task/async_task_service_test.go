@@ -8,7 +8,10 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/pivotal-golang/clock/fakeclock"
+
 	. "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
 )
@@ -16,19 +19,23 @@ import (
 func init() {
 	Describe("asyncTaskService", func() {
 		var (
-			uuidGen *fakeuuid.FakeGenerator
-			service Service
+			uuidGen     *fakeuuid.FakeGenerator
+			timeService *fakeclock.FakeClock
+			logger      boshlog.Logger
+			service     Service
 		)
 
 		BeforeEach(func() {
 			uuidGen = &fakeuuid.FakeGenerator{}
-			service = NewAsyncTaskService(uuidGen, boshlog.NewLogger(boshlog.LevelNone))
+			timeService = fakeclock.NewFakeClock(time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC))
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			service = NewAsyncTaskService(Options{}, nil, uuidGen, timeService, logger)
 		})
 
 		Describe("StartTask", func() {
 			startAndWaitForTaskCompletion := func(task Task) Task {
 				service.StartTask(task)
-				for task.State == StateRunning {
+				for task.State == StateRunning || task.State == StateQueued {
 					time.Sleep(time.Nanosecond)
 					task, _ = service.FindTaskWithID(task.ID)
 				}
@@ -154,6 +161,665 @@ func init() {
 					time.Sleep(200 * time.Millisecond)
 				}
 			})
+			Context("when tasks declare resources", func() {
+				var (
+					startedCh chan string
+					releaseCh chan struct{}
+				)
+
+				BeforeEach(func() {
+					startedCh = make(chan string, 10)
+					releaseCh = make(chan struct{})
+				})
+
+				startBlockingTask := func(id string, resources ...Resource) {
+					started, release := startedCh, releaseCh
+
+					runFunc := func() (interface{}, error) {
+						started <- id
+						<-release
+						return nil, nil
+					}
+
+					task := service.CreateTaskWithID(id, runFunc, nil, nil)
+					task.Resources = resources
+					service.StartTask(task)
+				}
+
+				It("runs tasks that do not share resources at the same time", func() {
+					startBlockingTask("fake-task-id-1", ResourceJobs)
+					startBlockingTask("fake-task-id-2", ResourceDisks)
+
+					var startedIDs []string
+					for i := 0; i < 2; i++ {
+						var id string
+						Eventually(startedCh).Should(Receive(&id))
+						startedIDs = append(startedIDs, id)
+					}
+					Expect(startedIDs).To(ConsistOf("fake-task-id-1", "fake-task-id-2"))
+
+					task, _ := service.FindTaskWithID("fake-task-id-2")
+					Expect(task.State).To(Equal(StateRunning))
+
+					close(releaseCh)
+				})
+
+				It("queues tasks that share resources and runs them in order they were started", func() {
+					startBlockingTask("fake-task-id-1", ResourceJobs)
+					startBlockingTask("fake-task-id-2", ResourceJobs, ResourceDisks)
+					startBlockingTask("fake-task-id-3", ResourceDisks)
+
+					Eventually(startedCh).Should(Receive(Equal("fake-task-id-1")))
+					Consistently(startedCh).ShouldNot(Receive())
+
+					task, _ := service.FindTaskWithID("fake-task-id-2")
+					Expect(task.State).To(Equal(StateQueued))
+
+					// Third task does not overtake second task even though disks are free
+					task, _ = service.FindTaskWithID("fake-task-id-3")
+					Expect(task.State).To(Equal(StateQueued))
+
+					releaseCh <- struct{}{}
+					Eventually(startedCh).Should(Receive(Equal("fake-task-id-2")))
+
+					releaseCh <- struct{}{}
+					Eventually(startedCh).Should(Receive(Equal("fake-task-id-3")))
+
+					releaseCh <- struct{}{}
+					Eventually(func() State {
+						task, _ := service.FindTaskWithID("fake-task-id-3")
+						return task.State
+					}).Should(Equal(StateDone))
+				})
+
+				It("does not run more tasks than allowed at the same time", func() {
+					service = NewAsyncTaskService(Options{MaxConcurrentTasks: 1}, nil, uuidGen, timeService, logger)
+
+					startBlockingTask("fake-task-id-1", ResourceJobs)
+					startBlockingTask("fake-task-id-2", ResourceDisks)
+
+					Eventually(startedCh).Should(Receive(Equal("fake-task-id-1")))
+					Consistently(startedCh).ShouldNot(Receive())
+
+					task, _ := service.FindTaskWithID("fake-task-id-2")
+					Expect(task.State).To(Equal(StateQueued))
+
+					releaseCh <- struct{}{}
+					Eventually(startedCh).Should(Receive(Equal("fake-task-id-2")))
+
+					close(releaseCh)
+				})
+			})
+		})
+
+		Describe("CancelTask", func() {
+			It("cancels running task and marks it as cancelled when it fails", func() {
+				cancelCh := make(chan struct{})
+				runFunc := func() (interface{}, error) { <-cancelCh; return nil, errors.New("fake-run-err") }
+				cancelFunc := func(Task) error { close(cancelCh); return nil }
+
+				var endedTask Task
+				endedCh := make(chan struct{})
+				endFunc := func(task Task) { endedTask = task; close(endedCh) }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, cancelFunc, endFunc)
+				service.StartTask(task)
+
+				Eventually(func() State {
+					task, _ = service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateRunning))
+
+				err := service.CancelTask("fake-task-id")
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(endedCh).Should(BeClosed())
+				Expect(endedTask.State).To(Equal(StateCancelled))
+
+				task, _ = service.FindTaskWithID("fake-task-id")
+				Expect(task.State).To(Equal(StateCancelled))
+				Expect(task.Error).To(MatchError("fake-run-err"))
+			})
+
+			It("marks task as done if it succeeds even though it was asked to cancel", func() {
+				cancelCh := make(chan struct{})
+				runFunc := func() (interface{}, error) { <-cancelCh; return "fake-value", nil }
+				cancelFunc := func(Task) error { close(cancelCh); return nil }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, cancelFunc, nil)
+				service.StartTask(task)
+
+				Eventually(func() State {
+					task, _ = service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateRunning))
+
+				err := service.CancelTask("fake-task-id")
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(func() State {
+					task, _ = service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateDone))
+
+				Expect(task.Value).To(Equal("fake-value"))
+			})
+
+			It("returns error if cancelling running task fails", func() {
+				releaseCh := make(chan struct{})
+				defer close(releaseCh)
+
+				runFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+				cancelFunc := func(Task) error { return errors.New("fake-cancel-err") }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, cancelFunc, nil)
+				service.StartTask(task)
+
+				Eventually(func() State {
+					task, _ = service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateRunning))
+
+				err := service.CancelTask("fake-task-id")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-cancel-err"))
+			})
+
+			It("removes queued task without running it and lets later tasks run", func() {
+				releaseCh := make(chan struct{})
+				blockingFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+
+				blockingTask := service.CreateTaskWithID("fake-blocking-task-id", blockingFunc, nil, nil)
+				blockingTask.Resources = []Resource{ResourceJobs}
+				service.StartTask(blockingTask)
+
+				cancelCalled := false
+				ranQueuedTask := false
+				queuedFunc := func() (interface{}, error) { ranQueuedTask = true; return nil, nil }
+				cancelFunc := func(Task) error { cancelCalled = true; return nil }
+
+				var endedTask Task
+				endFunc := func(task Task) { endedTask = task }
+
+				queuedTask := service.CreateTaskWithID("fake-queued-task-id", queuedFunc, cancelFunc, endFunc)
+				queuedTask.Resources = []Resource{ResourceJobs}
+				service.StartTask(queuedTask)
+
+				err := service.CancelTask("fake-queued-task-id")
+				Expect(err).ToNot(HaveOccurred())
+
+				queuedTask, _ = service.FindTaskWithID("fake-queued-task-id")
+				Expect(queuedTask.State).To(Equal(StateCancelled))
+				Expect(queuedTask.Error).To(Equal(ErrCancelled))
+				Expect(endedTask.State).To(Equal(StateCancelled))
+				Expect(cancelCalled).To(BeFalse())
+
+				close(releaseCh)
+
+				Eventually(func() State {
+					blockingTask, _ = service.FindTaskWithID("fake-blocking-task-id")
+					return blockingTask.State
+				}).Should(Equal(StateDone))
+
+				Consistently(func() bool { return ranQueuedTask }).Should(BeFalse())
+			})
+
+			It("does nothing for finished task", func() {
+				cancelCalled := false
+				runFunc := func() (interface{}, error) { return nil, nil }
+				cancelFunc := func(Task) error { cancelCalled = true; return nil }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, cancelFunc, nil)
+				service.StartTask(task)
+
+				Eventually(func() State {
+					task, _ = service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateDone))
+
+				err := service.CancelTask("fake-task-id")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cancelCalled).To(BeFalse())
+			})
+
+			It("returns error for unknown task", func() {
+				err := service.CancelTask("fake-unknown-task-id")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Task with id fake-unknown-task-id could not be found"))
+			})
+		})
+
+		Describe("TimeOutTask", func() {
+			It("reports running task as timed out right away and asks it to stop", func() {
+				releaseCh := make(chan struct{})
+				runFunc := func() (interface{}, error) { <-releaseCh; return "fake-value", nil }
+
+				cancelCalled := false
+				cancelFunc := func(Task) error { cancelCalled = true; return nil }
+
+				var endedTask Task
+				endedCh := make(chan struct{})
+				endFunc := func(task Task) { endedTask = task; close(endedCh) }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, cancelFunc, endFunc)
+				service.StartTask(task)
+
+				timeoutErr := errors.New("fake-timeout-err")
+				err := service.TimeOutTask("fake-task-id", timeoutErr)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cancelCalled).To(BeTrue())
+
+				task, _ = service.FindTaskWithID("fake-task-id")
+				Expect(task.State).To(Equal(StateTimedOut))
+				Expect(task.Error).To(Equal(timeoutErr))
+
+				close(releaseCh)
+				<-endedCh
+
+				Expect(endedTask.State).To(Equal(StateTimedOut))
+				Expect(endedTask.Value).To(BeNil())
+
+				Consistently(func() State {
+					task, _ = service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateTimedOut))
+
+				Expect(service.ListTasks()).To(HaveLen(1))
+			})
+
+			It("keeps resources of timed out task locked until it stops", func() {
+				releaseCh := make(chan struct{})
+				blockingFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+
+				blockingTask := service.CreateTaskWithID("fake-blocking-task-id", blockingFunc, nil, nil)
+				blockingTask.Resources = []Resource{ResourceJobs}
+				service.StartTask(blockingTask)
+
+				ranCh := make(chan struct{})
+				queuedFunc := func() (interface{}, error) { close(ranCh); return nil, nil }
+
+				queuedTask := service.CreateTaskWithID("fake-queued-task-id", queuedFunc, nil, nil)
+				queuedTask.Resources = []Resource{ResourceJobs}
+				service.StartTask(queuedTask)
+
+				err := service.TimeOutTask("fake-blocking-task-id", errors.New("fake-timeout-err"))
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(ranCh).ShouldNot(BeClosed())
+
+				close(releaseCh)
+				Eventually(ranCh).Should(BeClosed())
+			})
+
+			It("returns error if cancelling timed out task fails", func() {
+				releaseCh := make(chan struct{})
+				defer close(releaseCh)
+
+				runFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+				cancelFunc := func(Task) error { return errors.New("fake-cancel-err") }
+
+				service.StartTask(service.CreateTaskWithID("fake-task-id", runFunc, cancelFunc, nil))
+
+				err := service.TimeOutTask("fake-task-id", errors.New("fake-timeout-err"))
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-cancel-err"))
+			})
+
+			It("removes queued task without running it", func() {
+				releaseCh := make(chan struct{})
+				defer close(releaseCh)
+
+				blockingFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+
+				blockingTask := service.CreateTaskWithID("fake-blocking-task-id", blockingFunc, nil, nil)
+				blockingTask.Resources = []Resource{ResourceJobs}
+				service.StartTask(blockingTask)
+
+				var endedTask Task
+				endFunc := func(task Task) { endedTask = task }
+
+				queuedTask := service.CreateTaskWithID("fake-queued-task-id", blockingFunc, nil, endFunc)
+				queuedTask.Resources = []Resource{ResourceJobs}
+				service.StartTask(queuedTask)
+
+				err := service.TimeOutTask("fake-queued-task-id", errors.New("fake-timeout-err"))
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(endedTask.State).To(Equal(StateTimedOut))
+
+				queuedTask, _ = service.FindTaskWithID("fake-queued-task-id")
+				Expect(queuedTask.State).To(Equal(StateTimedOut))
+			})
+
+			It("does nothing for task whose func has already returned", func() {
+				runFunc := func() (interface{}, error) { return "fake-value", nil }
+
+				cancelCalled := false
+				cancelFunc := func(Task) error { cancelCalled = true; return nil }
+
+				var timeOutErr error
+				endedCh := make(chan struct{})
+				endFunc := func(task Task) {
+					// Deadline passes after task func returned but before task is recorded as done
+					timeOutErr = service.TimeOutTask("fake-task-id", errors.New("fake-timeout-err"))
+					close(endedCh)
+				}
+
+				service.StartTask(service.CreateTaskWithID("fake-task-id", runFunc, cancelFunc, endFunc))
+				<-endedCh
+
+				Expect(timeOutErr).ToNot(HaveOccurred())
+				Expect(cancelCalled).To(BeFalse())
+
+				Eventually(func() State {
+					task, _ := service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateDone))
+
+				summaries := service.ListTasks()
+				Expect(summaries).To(HaveLen(1))
+				Expect(summaries[0].State).To(Equal(StateDone))
+			})
+
+			It("does nothing for finished or unknown task", func() {
+				runFunc := func() (interface{}, error) { return nil, nil }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+				service.StartTask(task)
+
+				Eventually(func() State {
+					task, _ = service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateDone))
+
+				err := service.TimeOutTask("fake-task-id", errors.New("fake-timeout-err"))
+				Expect(err).ToNot(HaveOccurred())
+
+				task, _ = service.FindTaskWithID("fake-task-id")
+				Expect(task.State).To(Equal(StateDone))
+
+				err = service.TimeOutTask("fake-unknown-task-id", errors.New("fake-timeout-err"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Describe("ListTasks", func() {
+			waitForTaskState := func(id string, state State) {
+				Eventually(func() State {
+					task, _ := service.FindTaskWithID(id)
+					return task.State
+				}).Should(Equal(state))
+			}
+
+			It("returns summaries of queued, running and finished tasks in order they were started", func() {
+				releaseCh := make(chan struct{})
+				defer close(releaseCh)
+
+				failingFunc := func() (interface{}, error) { return nil, errors.New("fake-error") }
+				blockingFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+
+				failingTask := service.CreateTaskWithID("fake-failing-task-id", failingFunc, nil, nil)
+				failingTask.Method = "fake-method-1"
+				service.StartTask(failingTask)
+				waitForTaskState("fake-failing-task-id", StateFailed)
+
+				timeService.Increment(time.Minute)
+
+				runningTask := service.CreateTaskWithID("fake-running-task-id", blockingFunc, nil, nil)
+				runningTask.Method = "fake-method-2"
+				runningTask.Resources = []Resource{ResourceJobs}
+				service.StartTask(runningTask)
+
+				timeService.Increment(time.Minute)
+
+				queuedTask := service.CreateTaskWithID("fake-queued-task-id", blockingFunc, nil, nil)
+				queuedTask.Method = "fake-method-3"
+				queuedTask.Resources = []Resource{ResourceJobs}
+				service.StartTask(queuedTask)
+
+				startedAt := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+				runningStartedAt := startedAt.Add(time.Minute)
+
+				Expect(service.ListTasks()).To(Equal([]Summary{
+					{
+						AgentTaskID: "fake-failing-task-id",
+						Method:      "fake-method-1",
+						State:       StateFailed,
+						QueuedAt:    startedAt,
+						StartedAt:   &startedAt,
+						FinishedAt:  &startedAt,
+						Error:       "fake-error",
+					},
+					{
+						AgentTaskID: "fake-running-task-id",
+						Method:      "fake-method-2",
+						State:       StateRunning,
+						QueuedAt:    runningStartedAt,
+						StartedAt:   &runningStartedAt,
+					},
+					{
+						AgentTaskID: "fake-queued-task-id",
+						Method:      "fake-method-3",
+						State:       StateQueued,
+						QueuedAt:    startedAt.Add(2 * time.Minute),
+					},
+				}))
+			})
+
+			It("forgets oldest finished tasks when there are too many of them", func() {
+				service = NewAsyncTaskService(Options{MaxFinishedTasks: 2}, nil, uuidGen, timeService, logger)
+
+				runFunc := func() (interface{}, error) { return nil, nil }
+
+				for i := 1; i <= 3; i++ {
+					id := fmt.Sprintf("fake-task-id-%d", i)
+					service.StartTask(service.CreateTaskWithID(id, runFunc, nil, nil))
+					waitForTaskState(id, StateDone)
+				}
+
+				summaries := service.ListTasks()
+				Expect(summaries).To(HaveLen(2))
+				Expect(summaries[0].AgentTaskID).To(Equal("fake-task-id-2"))
+				Expect(summaries[1].AgentTaskID).To(Equal("fake-task-id-3"))
+
+				_, found := service.FindTaskWithID("fake-task-id-1")
+				Expect(found).To(BeFalse())
+			})
+
+			It("forgets finished tasks once they are too old", func() {
+				service = NewAsyncTaskService(Options{MaxFinishedTaskAgeSeconds: 60}, nil, uuidGen, timeService, logger)
+
+				releaseCh := make(chan struct{})
+				defer close(releaseCh)
+
+				runFunc := func() (interface{}, error) { return nil, nil }
+				blockingFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+
+				service.StartTask(service.CreateTaskWithID("fake-finished-task-id", runFunc, nil, nil))
+				waitForTaskState("fake-finished-task-id", StateDone)
+
+				service.StartTask(service.CreateTaskWithID("fake-running-task-id", blockingFunc, nil, nil))
+
+				timeService.Increment(time.Minute)
+				Expect(service.ListTasks()).To(HaveLen(2))
+
+				timeService.Increment(time.Second)
+				summaries := service.ListTasks()
+				Expect(summaries).To(HaveLen(1))
+				Expect(summaries[0].AgentTaskID).To(Equal("fake-running-task-id"))
+
+				_, found := service.FindTaskWithID("fake-finished-task-id")
+				Expect(found).To(BeFalse())
+			})
+
+			Context("when journal is used", func() {
+				var (
+					journal *faketask.FakeJournal
+				)
+
+				BeforeEach(func() {
+					journal = &faketask.FakeJournal{}
+				})
+
+				It("includes tasks finished before service was created", func() {
+					finishedAt := timeService.Now()
+					journal.LoadSummaries = []Summary{
+						{AgentTaskID: "fake-old-task-id", State: StateDone, FinishedAt: &finishedAt},
+					}
+
+					service = NewAsyncTaskService(Options{}, journal, uuidGen, timeService, logger)
+
+					Expect(service.ListTasks()).To(Equal(journal.LoadSummaries))
+				})
+
+				It("does not include journaled tasks that are too old", func() {
+					finishedAt := timeService.Now().Add(-2 * time.Minute)
+					journal.LoadSummaries = []Summary{
+						{AgentTaskID: "fake-old-task-id", State: StateDone, FinishedAt: &finishedAt},
+					}
+
+					service = NewAsyncTaskService(Options{MaxFinishedTaskAgeSeconds: 60}, journal, uuidGen, timeService, logger)
+
+					Expect(service.ListTasks()).To(BeEmpty())
+				})
+
+				It("starts without history if journal cannot be loaded", func() {
+					journal.LoadErr = errors.New("fake-load-err")
+
+					service = NewAsyncTaskService(Options{}, journal, uuidGen, timeService, logger)
+
+					Expect(service.ListTasks()).To(BeEmpty())
+				})
+
+				It("saves summaries of finished tasks", func() {
+					service = NewAsyncTaskService(Options{}, journal, uuidGen, timeService, logger)
+
+					runFunc := func() (interface{}, error) { return nil, nil }
+					task := service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+					task.Method = "fake-method"
+					service.StartTask(task)
+					waitForTaskState("fake-task-id", StateDone)
+
+					Eventually(journal.SavedSummaries).Should(HaveLen(1))
+					Expect(journal.SavedSummaries()[0].AgentTaskID).To(Equal("fake-task-id"))
+					Expect(journal.SavedSummaries()[0].Method).To(Equal("fake-method"))
+					Expect(journal.SavedSummaries()[0].State).To(Equal(StateDone))
+				})
+
+				It("keeps running tasks if journal cannot be saved", func() {
+					journal.SaveErr = errors.New("fake-save-err")
+
+					service = NewAsyncTaskService(Options{}, journal, uuidGen, timeService, logger)
+
+					runFunc := func() (interface{}, error) { return nil, nil }
+					service.StartTask(service.CreateTaskWithID("fake-task-id-1", runFunc, nil, nil))
+					waitForTaskState("fake-task-id-1", StateDone)
+
+					service.StartTask(service.CreateTaskWithID("fake-task-id-2", runFunc, nil, nil))
+					waitForTaskState("fake-task-id-2", StateDone)
+				})
+			})
+		})
+
+		Describe("AddEvent", func() {
+			It("keeps events of a running task and after it finishes", func() {
+				releaseCh := make(chan struct{})
+				runFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+				service.StartTask(task)
+
+				service.AddEvent("fake-task-id", Event{Stage: "fake-stage-1", Percent: 10})
+				service.AddEvent("fake-task-id", Event{Stage: "fake-stage-2", Percent: 20})
+
+				task, _ = service.FindTaskWithID("fake-task-id")
+				Expect(task.Events).To(Equal([]Event{
+					{Stage: "fake-stage-1", Percent: 10},
+					{Stage: "fake-stage-2", Percent: 20},
+				}))
+
+				close(releaseCh)
+
+				Eventually(func() State {
+					task, _ = service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateDone))
+
+				Expect(task.Events).To(HaveLen(2))
+			})
+
+			It("keeps only most recent events", func() {
+				releaseCh := make(chan struct{})
+				defer close(releaseCh)
+
+				runFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+				service.StartTask(task)
+
+				for i := 0; i < MaxEvents+5; i++ {
+					service.AddEvent("fake-task-id", Event{Stage: fmt.Sprintf("fake-stage-%d", i)})
+				}
+
+				task, _ = service.FindTaskWithID("fake-task-id")
+				Expect(task.Events).To(HaveLen(MaxEvents))
+				Expect(task.Events[0].Stage).To(Equal("fake-stage-5"))
+				Expect(task.Events[MaxEvents-1].Stage).To(Equal(fmt.Sprintf("fake-stage-%d", MaxEvents+4)))
+			})
+
+			It("ignores events for unknown tasks", func() {
+				service.AddEvent("fake-unknown-task-id", Event{Stage: "fake-stage"})
+
+				_, found := service.FindTaskWithID("fake-unknown-task-id")
+				Expect(found).To(BeFalse())
+			})
+		})
+
+		Describe("AddOutput", func() {
+			It("keeps output of a running task and after it finishes", func() {
+				releaseCh := make(chan struct{})
+				runFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+				service.StartTask(task)
+
+				service.AddOutput("fake-task-id", OutputLine{Sequence: 1, Stream: OutputStdout, Line: "fake-line-1"})
+				service.AddOutput("fake-task-id", OutputLine{Sequence: 2, Stream: OutputStderr, Line: "fake-line-2"})
+
+				task, _ = service.FindTaskWithID("fake-task-id")
+				Expect(task.Output).To(Equal([]OutputLine{
+					{Sequence: 1, Stream: OutputStdout, Line: "fake-line-1"},
+					{Sequence: 2, Stream: OutputStderr, Line: "fake-line-2"},
+				}))
+
+				close(releaseCh)
+
+				Eventually(func() State {
+					task, _ = service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateDone))
+
+				Expect(task.Output).To(HaveLen(2))
+			})
+
+			It("keeps only tail of output", func() {
+				releaseCh := make(chan struct{})
+				defer close(releaseCh)
+
+				runFunc := func() (interface{}, error) { <-releaseCh; return nil, nil }
+
+				task := service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+				service.StartTask(task)
+
+				for i := 1; i <= MaxOutputLines+5; i++ {
+					service.AddOutput("fake-task-id", OutputLine{Sequence: i})
+				}
+
+				task, _ = service.FindTaskWithID("fake-task-id")
+				Expect(task.Output).To(HaveLen(MaxOutputLines))
+				Expect(task.Output[0].Sequence).To(Equal(6))
+				Expect(task.Output[MaxOutputLines-1].Sequence).To(Equal(MaxOutputLines + 5))
+			})
 		})
 
 		Describe("CreateTask", func() {
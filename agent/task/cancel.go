@@ -0,0 +1,66 @@
+package task
+
+import (
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// ErrCancelled is returned by cancellable operations
+// that stopped early because their task was cancelled
+var ErrCancelled = bosherr.Error("Task was cancelled")
+
+// IsCancelled checks without blocking whether cancelCh was closed.
+// Nil channel is never cancelled.
+func IsCancelled(cancelCh <-chan struct{}) bool {
+	select {
+	case <-cancelCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// Canceller gives each run of an action its own cancel channel
+// so that cancel request only affects the run that is in progress.
+// Actions are created once and reused across tasks, hence
+// cancel request must not outlive the run it was meant for.
+type Canceller struct {
+	cancelCh chan struct{}
+	lock     sync.Mutex
+}
+
+func NewCanceller() *Canceller {
+	return &Canceller{}
+}
+
+// Start returns channel closed when Cancel is called before stop.
+// Call stop once cancellable operations finish.
+func (c *Canceller) Start() (cancelCh <-chan struct{}, stop func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	runCancelCh := make(chan struct{})
+	c.cancelCh = runCancelCh
+
+	return runCancelCh, func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		if c.cancelCh == runCancelCh {
+			c.cancelCh = nil
+		}
+	}
+}
+
+// Cancel closes cancel channel of the run in progress;
+// it does nothing when there is no run in progress
+func (c *Canceller) Cancel() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.cancelCh != nil {
+		close(c.cancelCh)
+		c.cancelCh = nil
+	}
+}
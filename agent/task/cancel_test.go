@@ -0,0 +1,58 @@
+package task_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/task"
+)
+
+var _ = Describe("Canceller", func() {
+	var (
+		canceller *Canceller
+	)
+
+	BeforeEach(func() {
+		canceller = NewCanceller()
+	})
+
+	It("closes cancel channel of run in progress when cancelled", func() {
+		cancelCh, stop := canceller.Start()
+		defer stop()
+
+		Expect(IsCancelled(cancelCh)).To(BeFalse())
+
+		canceller.Cancel()
+		Expect(IsCancelled(cancelCh)).To(BeTrue())
+
+		canceller.Cancel()
+		Expect(IsCancelled(cancelCh)).To(BeTrue())
+	})
+
+	It("does not cancel next run when cancelled after previous run stopped", func() {
+		_, stop := canceller.Start()
+		stop()
+
+		canceller.Cancel()
+
+		cancelCh, stop := canceller.Start()
+		defer stop()
+
+		Expect(IsCancelled(cancelCh)).To(BeFalse())
+	})
+
+	It("does not cancel next run when cancelled before it started", func() {
+		canceller.Cancel()
+
+		cancelCh, stop := canceller.Start()
+		defer stop()
+
+		Expect(IsCancelled(cancelCh)).To(BeFalse())
+	})
+})
+
+var _ = Describe("IsCancelled", func() {
+	It("returns false for nil channel", func() {
+		Expect(IsCancelled(nil)).To(BeFalse())
+	})
+})
@@ -0,0 +1,66 @@
+package task
+
+import (
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// Checkpoints let persistent tasks record steps they have completed
+// so that a task resumed after agent restart skips them instead of doing them again.
+// Action's Run method receives it when Checkpoints is its first argument
+// (or the one following ProgressReporter).
+type Checkpoints interface {
+	IsCompleted(step string) bool
+	Complete(step string) error
+}
+
+type managerCheckpoints struct {
+	taskID  string
+	manager Manager
+
+	lock           sync.Mutex
+	completedSteps map[string]struct{}
+}
+
+// NewCheckpoints returns checkpoints of the task saved via manager;
+// completedSteps are checkpoints recorded before agent restart
+func NewCheckpoints(taskID string, manager Manager, completedSteps []string) Checkpoints {
+	checkpoints := &managerCheckpoints{
+		taskID:         taskID,
+		manager:        manager,
+		completedSteps: map[string]struct{}{},
+	}
+
+	for _, step := range completedSteps {
+		checkpoints.completedSteps[step] = struct{}{}
+	}
+
+	return checkpoints
+}
+
+func (c *managerCheckpoints) IsCompleted(step string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, completed := c.completedSteps[step]
+	return completed
+}
+
+func (c *managerCheckpoints) Complete(step string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, completed := c.completedSteps[step]; completed {
+		return nil
+	}
+
+	err := c.manager.AddCheckpoint(c.taskID, step)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Saving checkpoint %s", step)
+	}
+
+	c.completedSteps[step] = struct{}{}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package task_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
+)
+
+func init() {
+	Describe("Checkpoints", func() {
+		var (
+			manager     *faketask.FakeManager
+			checkpoints boshtask.Checkpoints
+		)
+
+		BeforeEach(func() {
+			manager = faketask.NewFakeManager()
+
+			err := manager.AddInfo(boshtask.Info{TaskID: "fake-task-id"})
+			Expect(err).ToNot(HaveOccurred())
+
+			checkpoints = boshtask.NewCheckpoints("fake-task-id", manager, []string{"fake-step-1"})
+		})
+
+		It("considers steps recorded before restart completed", func() {
+			Expect(checkpoints.IsCompleted("fake-step-1")).To(BeTrue())
+			Expect(checkpoints.IsCompleted("fake-step-2")).To(BeFalse())
+		})
+
+		It("saves completed step via manager", func() {
+			err := checkpoints.Complete("fake-step-2")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checkpoints.IsCompleted("fake-step-2")).To(BeTrue())
+
+			taskInfos, err := manager.GetInfos()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(taskInfos[0].Checkpoints).To(Equal([]string{"fake-step-2"}))
+		})
+
+		It("does not save step that is already completed", func() {
+			err := checkpoints.Complete("fake-step-1")
+			Expect(err).ToNot(HaveOccurred())
+
+			taskInfos, err := manager.GetInfos()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(taskInfos[0].Checkpoints).To(BeEmpty())
+		})
+
+		It("returns error and does not consider step completed when saving fails", func() {
+			manager.AddCheckpointErr = errors.New("fake-add-checkpoint-err")
+
+			err := checkpoints.Complete("fake-step-2")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Saving checkpoint fake-step-2: fake-add-checkpoint-err"))
+			Expect(checkpoints.IsCompleted("fake-step-2")).To(BeFalse())
+		})
+	})
+}
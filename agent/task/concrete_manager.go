@@ -96,6 +96,25 @@ func (m *concreteManager) RemoveInfo(taskID string) error {
 	return <-errCh
 }
 
+func (m *concreteManager) AddCheckpoint(taskID string, step string) error {
+	errCh := make(chan error)
+
+	m.fsSem <- func() {
+		taskInfo, found := m.taskInfos[taskID]
+		if !found {
+			errCh <- bosherr.Errorf("Task info for task %s not found", taskID)
+			return
+		}
+
+		taskInfo.Checkpoints = append(taskInfo.Checkpoints, step)
+		m.taskInfos[taskID] = taskInfo
+
+		err := m.writeInfos(m.taskInfos)
+		errCh <- err
+	}
+	return <-errCh
+}
+
 func (m *concreteManager) processFsFuncs() {
 	defer m.logger.HandlePanic("Task Manager Process Fs Funcs")
 
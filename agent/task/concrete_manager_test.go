@@ -209,5 +209,51 @@ func init() {
 				Expect(err.Error()).To(ContainSubstring("fake-write-error"))
 			})
 		})
+
+		Describe("AddCheckpoint", func() {
+			BeforeEach(func() {
+				err := manager.AddInfo(boshtask.Info{
+					TaskID:  "fake-task-id",
+					Method:  "fake-method",
+					Payload: []byte("fake-payload"),
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("saves checkpoints of the task in order", func() {
+				err := manager.AddCheckpoint("fake-task-id", "fake-step-1")
+				Expect(err).ToNot(HaveOccurred())
+
+				err = manager.AddCheckpoint("fake-task-id", "fake-step-2")
+				Expect(err).ToNot(HaveOccurred())
+
+				reloadedManager := boshtask.NewManager(logger, fs, "/dir/path")
+
+				taskInfos, err := reloadedManager.GetInfos()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(taskInfos).To(Equal([]boshtask.Info{
+					{
+						TaskID:      "fake-task-id",
+						Method:      "fake-method",
+						Payload:     []byte("fake-payload"),
+						Checkpoints: []string{"fake-step-1", "fake-step-2"},
+					},
+				}))
+			})
+
+			It("returns an error when task info does not exist", func() {
+				err := manager.AddCheckpoint("fake-unknown-task-id", "fake-step")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Task info for task fake-unknown-task-id not found"))
+			})
+
+			It("returns an error when failing to save checkpoint", func() {
+				fs.WriteFileError = errors.New("fake-write-error")
+
+				err := manager.AddCheckpoint("fake-task-id", "fake-step")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+			})
+		})
 	})
 }
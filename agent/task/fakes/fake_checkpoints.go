@@ -0,0 +1,28 @@
+package fakes
+
+type FakeCheckpoints struct {
+	CompletedSteps []string
+	CompleteErr    error
+}
+
+func NewFakeCheckpoints(completedSteps ...string) *FakeCheckpoints {
+	return &FakeCheckpoints{CompletedSteps: completedSteps}
+}
+
+func (c *FakeCheckpoints) IsCompleted(step string) bool {
+	for _, completedStep := range c.CompletedSteps {
+		if completedStep == step {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *FakeCheckpoints) Complete(step string) error {
+	if c.CompleteErr != nil {
+		return c.CompleteErr
+	}
+
+	c.CompletedSteps = append(c.CompletedSteps, step)
+	return nil
+}
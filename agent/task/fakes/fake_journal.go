@@ -0,0 +1,35 @@
+package fakes
+
+import (
+	"sync"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+)
+
+type FakeJournal struct {
+	LoadSummaries []boshtask.Summary
+	LoadErr       error
+
+	saveLock       sync.Mutex
+	savedSummaries []boshtask.Summary
+	SaveErr        error
+}
+
+func (j *FakeJournal) Load() ([]boshtask.Summary, error) {
+	return j.LoadSummaries, j.LoadErr
+}
+
+func (j *FakeJournal) Save(summaries []boshtask.Summary) error {
+	j.saveLock.Lock()
+	defer j.saveLock.Unlock()
+
+	j.savedSummaries = summaries
+	return j.SaveErr
+}
+
+func (j *FakeJournal) SavedSummaries() []boshtask.Summary {
+	j.saveLock.Lock()
+	defer j.saveLock.Unlock()
+
+	return j.savedSummaries
+}
@@ -1,11 +1,16 @@
 package fakes
 
-import boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+import (
+	"errors"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+)
 
 type FakeManager struct {
 	taskIDToTaskInfo map[string]boshtask.Info
 
-	AddInfoErr error
+	AddInfoErr       error
+	AddCheckpointErr error
 }
 
 func NewFakeManager() *FakeManager {
@@ -29,3 +34,18 @@ func (m *FakeManager) RemoveInfo(taskID string) error {
 	delete(m.taskIDToTaskInfo, taskID)
 	return nil
 }
+
+func (m *FakeManager) AddCheckpoint(taskID string, step string) error {
+	if m.AddCheckpointErr != nil {
+		return m.AddCheckpointErr
+	}
+
+	taskInfo, found := m.taskIDToTaskInfo[taskID]
+	if !found {
+		return errors.New("Task info not found")
+	}
+
+	taskInfo.Checkpoints = append(taskInfo.Checkpoints, step)
+	m.taskIDToTaskInfo[taskID] = taskInfo
+	return nil
+}
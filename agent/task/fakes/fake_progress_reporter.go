@@ -0,0 +1,60 @@
+package fakes
+
+import (
+	"sync"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+)
+
+type FakeProgressReporter struct {
+	Events []boshtask.Event
+
+	ReportProgressCallBack func(stage string)
+
+	output     []boshtask.OutputLine
+	outputLock sync.Mutex
+}
+
+func NewFakeProgressReporter() *FakeProgressReporter {
+	return &FakeProgressReporter{}
+}
+
+func (r *FakeProgressReporter) ReportProgress(stage string, percent int) {
+	r.Events = append(r.Events, boshtask.Event{Stage: stage, Percent: percent})
+
+	if r.ReportProgressCallBack != nil {
+		r.ReportProgressCallBack(stage)
+	}
+}
+
+func (r *FakeProgressReporter) Stages() []string {
+	var stages []string
+	for _, event := range r.Events {
+		stages = append(stages, event.Stage)
+	}
+	return stages
+}
+
+func (r *FakeProgressReporter) ReportOutput(stream boshtask.OutputStream, line string) {
+	r.outputLock.Lock()
+	defer r.outputLock.Unlock()
+
+	r.output = append(r.output, boshtask.OutputLine{Stream: stream, Line: line})
+}
+
+func (r *FakeProgressReporter) Output() []boshtask.OutputLine {
+	r.outputLock.Lock()
+	defer r.outputLock.Unlock()
+
+	return r.output
+}
+
+func (r *FakeProgressReporter) OutputLines(stream boshtask.OutputStream) []string {
+	var lines []string
+	for _, line := range r.Output() {
+		if line.Stream == stream {
+			lines = append(lines, line.Line)
+		}
+	}
+	return lines
+}
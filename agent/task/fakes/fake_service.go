@@ -1,18 +1,36 @@
 package fakes
 
 import (
+	"sync"
+
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeService struct {
 	StartedTasks        map[string]boshtask.Task
+	StartTaskCallBack   func(task boshtask.Task)
+	AddedEvents         map[string][]boshtask.Event
+	AddedOutput         map[string][]boshtask.OutputLine
 	CreateTaskErr       error
 	CreateTaskWithIDErr error
+
+	CancelledTaskIDs []string
+	CancelTaskErr    error
+
+	ListedTasks []boshtask.Summary
+
+	// Tasks are timed out from other goroutines
+	timeOutLock      sync.Mutex
+	timedOutTaskIDs  []string
+	timedOutTaskErrs []error
+	TimeOutTaskErr   error
 }
 
 func NewFakeService() *FakeService {
 	return &FakeService{
 		StartedTasks: make(map[string]boshtask.Task),
+		AddedEvents:  make(map[string][]boshtask.Event),
+		AddedOutput:  make(map[string][]boshtask.OutputLine),
 	}
 }
 
@@ -44,9 +62,56 @@ func (s *FakeService) CreateTaskWithID(
 
 func (s *FakeService) StartTask(task boshtask.Task) {
 	s.StartedTasks[task.ID] = task
+
+	if s.StartTaskCallBack != nil {
+		s.StartTaskCallBack(task)
+	}
 }
 
 func (s *FakeService) FindTaskWithID(id string) (boshtask.Task, bool) {
 	task, found := s.StartedTasks[id]
 	return task, found
 }
+
+func (s *FakeService) ListTasks() []boshtask.Summary {
+	return s.ListedTasks
+}
+
+func (s *FakeService) CancelTask(id string) error {
+	s.CancelledTaskIDs = append(s.CancelledTaskIDs, id)
+	if s.CancelTaskErr != nil {
+		return s.CancelTaskErr
+	}
+	return s.StartedTasks[id].Cancel()
+}
+
+func (s *FakeService) TimeOutTask(id string, err error) error {
+	s.timeOutLock.Lock()
+	defer s.timeOutLock.Unlock()
+
+	s.timedOutTaskIDs = append(s.timedOutTaskIDs, id)
+	s.timedOutTaskErrs = append(s.timedOutTaskErrs, err)
+	return s.TimeOutTaskErr
+}
+
+func (s *FakeService) TimedOutTaskIDs() []string {
+	s.timeOutLock.Lock()
+	defer s.timeOutLock.Unlock()
+
+	return s.timedOutTaskIDs
+}
+
+func (s *FakeService) TimedOutTaskErrs() []error {
+	s.timeOutLock.Lock()
+	defer s.timeOutLock.Unlock()
+
+	return s.timedOutTaskErrs
+}
+
+func (s *FakeService) AddEvent(id string, event boshtask.Event) {
+	s.AddedEvents[id] = append(s.AddedEvents[id], event)
+}
+
+func (s *FakeService) AddOutput(id string, line boshtask.OutputLine) {
+	s.AddedOutput[id] = append(s.AddedOutput[id], line)
+}
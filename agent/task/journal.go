@@ -0,0 +1,60 @@
+package task
+
+import (
+	"encoding/json"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// Journal keeps summaries of finished tasks across agent restarts
+type Journal interface {
+	Load() ([]Summary, error)
+	Save([]Summary) error
+}
+
+type fileJournal struct {
+	fs          boshsys.FileSystem
+	journalPath string
+}
+
+func NewFileJournal(fs boshsys.FileSystem, journalPath string) Journal {
+	return fileJournal{
+		fs:          fs,
+		journalPath: journalPath,
+	}
+}
+
+func (j fileJournal) Load() ([]Summary, error) {
+	var summaries []Summary
+
+	if !j.fs.FileExists(j.journalPath) {
+		return summaries, nil
+	}
+
+	journalJSON, err := j.fs.ReadFile(j.journalPath)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading task journal")
+	}
+
+	err = json.Unmarshal(journalJSON, &summaries)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling task journal")
+	}
+
+	return summaries, nil
+}
+
+func (j fileJournal) Save(summaries []Summary) error {
+	journalJSON, err := json.Marshal(summaries)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling task journal")
+	}
+
+	err = j.fs.WriteFile(j.journalPath, journalJSON)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing task journal")
+	}
+
+	return nil
+}
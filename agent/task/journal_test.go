@@ -0,0 +1,79 @@
+package task_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/task"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("fileJournal", func() {
+	var (
+		fs      *fakesys.FakeFileSystem
+		journal Journal
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		journal = NewFileJournal(fs, "/dir/path/tasks_journal.json")
+	})
+
+	Describe("Load", func() {
+		It("returns no summaries when journal does not exist", func() {
+			summaries, err := journal.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(summaries).To(BeEmpty())
+		})
+
+		It("returns summaries that were saved", func() {
+			finishedAt := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+			savedSummaries := []Summary{
+				{
+					AgentTaskID: "fake-task-id",
+					Method:      "fake-method",
+					State:       StateDone,
+					QueuedAt:    finishedAt,
+					FinishedAt:  &finishedAt,
+				},
+			}
+
+			err := journal.Save(savedSummaries)
+			Expect(err).ToNot(HaveOccurred())
+
+			summaries, err := NewFileJournal(fs, "/dir/path/tasks_journal.json").Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(summaries).To(Equal(savedSummaries))
+		})
+
+		It("returns error when journal cannot be read", func() {
+			fs.WriteFileString("/dir/path/tasks_journal.json", "[]")
+			fs.ReadFileError = errors.New("fake-read-err")
+
+			_, err := journal.Load()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-read-err"))
+		})
+
+		It("returns error when journal is not valid json", func() {
+			fs.WriteFileString("/dir/path/tasks_journal.json", "invalid-json")
+
+			_, err := journal.Load()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling task journal"))
+		})
+	})
+
+	Describe("Save", func() {
+		It("returns error when journal cannot be written", func() {
+			fs.WriteFileError = errors.New("fake-write-err")
+
+			err := journal.Save([]Summary{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-write-err"))
+		})
+	})
+})
@@ -9,6 +9,9 @@ type Info struct {
 	TaskID  string
 	Method  string
 	Payload []byte
+
+	// Steps completed so far; resumed task skips them
+	Checkpoints []string
 }
 
 type ManagerProvider interface {
@@ -19,4 +22,7 @@ type Manager interface {
 	GetInfos() ([]Info, error)
 	AddInfo(taskInfo Info) error
 	RemoveInfo(taskID string) error
+
+	// AddCheckpoint records completed step of the task with previously added info
+	AddCheckpoint(taskID string, step string) error
 }
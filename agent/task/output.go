@@ -0,0 +1,84 @@
+package task
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MaxOutputLines is the number of most recent output lines kept for each task
+const MaxOutputLines = 100
+
+// Lines longer than that are reported in parts so that a script
+// printing without line breaks is still reported while it runs
+const maxOutputLineLength = 4096
+
+type OutputStream string
+
+const (
+	OutputStdout OutputStream = "stdout"
+	OutputStderr OutputStream = "stderr"
+)
+
+// OutputLine is a line printed by a script while task was running.
+// Sequence numbers start at 1 and increase with every line of the task
+// so that clients can tell whether they missed any lines.
+type OutputLine struct {
+	Sequence int          `json:"sequence"`
+	Time     int64        `json:"time"`
+	Stream   OutputStream `json:"stream"`
+	Line     string       `json:"line"`
+}
+
+// OutputWriter reports every complete line written to it.
+// Close reports last line even if it does not end with a line break.
+type OutputWriter struct {
+	reporter ProgressReporter
+	stream   OutputStream
+
+	buf  []byte
+	lock sync.Mutex
+}
+
+func NewOutputWriter(reporter ProgressReporter, stream OutputStream) *OutputWriter {
+	return &OutputWriter{reporter: reporter, stream: stream}
+}
+
+func (w *OutputWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.buf = append(w.buf, data...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.report(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+
+	for len(w.buf) >= maxOutputLineLength {
+		w.report(w.buf[:maxOutputLineLength])
+		w.buf = w.buf[maxOutputLineLength:]
+	}
+
+	return len(data), nil
+}
+
+func (w *OutputWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.buf) > 0 {
+		w.report(w.buf)
+		w.buf = nil
+	}
+
+	return nil
+}
+
+func (w *OutputWriter) report(line []byte) {
+	w.reporter.ReportOutput(w.stream, string(bytes.TrimSuffix(line, []byte("\r"))))
+}
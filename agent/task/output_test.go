@@ -0,0 +1,56 @@
+package task_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
+)
+
+var _ = Describe("OutputWriter", func() {
+	var (
+		reporter *faketask.FakeProgressReporter
+		writer   *OutputWriter
+	)
+
+	BeforeEach(func() {
+		reporter = faketask.NewFakeProgressReporter()
+		writer = NewOutputWriter(reporter, OutputStderr)
+	})
+
+	It("reports every complete line", func() {
+		writer.Write([]byte("fake-line-1\nfake-"))
+		Expect(reporter.OutputLines(OutputStderr)).To(Equal([]string{"fake-line-1"}))
+
+		writer.Write([]byte("line-2\r\nfake-line-3\n"))
+		Expect(reporter.OutputLines(OutputStderr)).To(Equal([]string{"fake-line-1", "fake-line-2", "fake-line-3"}))
+	})
+
+	It("reports incomplete last line once closed", func() {
+		writer.Write([]byte("fake-line-1\nfake-line-2"))
+		Expect(reporter.OutputLines(OutputStderr)).To(Equal([]string{"fake-line-1"}))
+
+		Expect(writer.Close()).To(Succeed())
+		Expect(reporter.OutputLines(OutputStderr)).To(Equal([]string{"fake-line-1", "fake-line-2"}))
+	})
+
+	It("reports very long lines in parts", func() {
+		writer.Write([]byte(strings.Repeat("a", 5000)))
+
+		lines := reporter.OutputLines(OutputStderr)
+		Expect(lines).To(HaveLen(1))
+		Expect(lines[0]).To(HaveLen(4096))
+
+		writer.Close()
+		Expect(reporter.OutputLines(OutputStderr)[1]).To(HaveLen(5000 - 4096))
+	})
+
+	It("returns length of written data", func() {
+		n, err := writer.Write([]byte("fake-line"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(9))
+	})
+})
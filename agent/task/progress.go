@@ -0,0 +1,22 @@
+package task
+
+// MaxEvents is the number of most recent progress events kept for each task
+const MaxEvents = 20
+
+// Event describes a stage task has reached while running.
+// Percent is an estimate of how much of the whole task is complete.
+type Event struct {
+	Time    int64  `json:"time"`
+	Stage   string `json:"stage"`
+	Percent int    `json:"percent"`
+}
+
+// ProgressReporter is used by long running tasks to report what they are doing.
+// Action's Run method receives it when ProgressReporter is its first argument.
+type ProgressReporter interface {
+	ReportProgress(stage string, percent int)
+
+	// ReportOutput is given every line printed by scripts task runs
+	// (e.g. errand or packaging script) while they are still running
+	ReportOutput(stream OutputStream, line string)
+}
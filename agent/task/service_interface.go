@@ -8,4 +8,21 @@ type Service interface {
 	// Records that task to run later
 	StartTask(Task)
 	FindTaskWithID(string) (Task, bool)
+
+	// Lists queued, running and remembered finished tasks, oldest first
+	ListTasks() []Summary
+
+	// Removes queued task from the queue or asks running task to stop;
+	// running task that fails afterwards ends up cancelled
+	CancelTask(string) error
+
+	// Immediately reports queued or running task as timed out with given error
+	// and asks running task to stop; task keeps its resources until it stops
+	TimeOutTask(string, error) error
+
+	// Appends event to task's bounded progress history
+	AddEvent(string, Event)
+
+	// Appends line to task's bounded output tail
+	AddOutput(string, OutputLine)
 }
@@ -1,5 +1,9 @@
 package task
 
+import (
+	"time"
+)
+
 type Func func() (value interface{}, err error)
 
 type CancelFunc func(task Task) error
@@ -9,20 +13,59 @@ type EndFunc func(task Task)
 type State string
 
 const (
+	StateQueued  State = "queued"
 	StateRunning State = "running"
 	StateDone    State = "done"
 	StateFailed  State = "failed"
+
+	// Task failed after being asked to stop via cancel_task
+	StateCancelled State = "cancelled"
+
+	// Task did not finish before its deadline
+	StateTimedOut State = "timed_out"
+)
+
+// Resource identifies a class of VM state that a task modifies.
+// Tasks that share a resource are never run at the same time.
+type Resource string
+
+const (
+	ResourceJobs        Resource = "jobs"
+	ResourceDisks       Resource = "disks"
+	ResourceNetwork     Resource = "network"
+	ResourceCompilation Resource = "compilation"
+
+	// Logs are collected one at a time since fetch_logs tasks share cancellation state
+	ResourceLogs Resource = "logs"
 )
 
 type Task struct {
-	ID    string
-	State State
-	Value interface{}
-	Error error
+	ID     string
+	Method string
+	State  State
+	Value  interface{}
+	Error  error
+
+	QueuedAt   time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	Resources []Resource
+
+	// Most recent progress events, oldest first
+	Events []Event
+
+	// Most recent output lines, oldest first
+	Output []OutputLine
 
 	Func       Func
 	CancelFunc CancelFunc
 	EndFunc    EndFunc
+
+	cancelRequested bool
+
+	// Set once task func returned so that task is no longer timed out
+	finishing bool
 }
 
 func (t Task) Cancel() error {
@@ -32,7 +75,72 @@ func (t Task) Cancel() error {
 	return nil
 }
 
+func (t Task) StateValue() StateValue {
+	value := StateValue{
+		AgentTaskID: t.ID,
+		State:       t.State,
+	}
+
+	if len(t.Events) > 0 {
+		lastEvent := t.Events[len(t.Events)-1]
+		value.Stage = lastEvent.Stage
+		value.Percent = lastEvent.Percent
+		value.Events = t.Events
+	}
+
+	value.Output = t.Output
+
+	return value
+}
+
 type StateValue struct {
-	AgentTaskID string `json:"agent_task_id"`
-	State       State  `json:"state"`
+	AgentTaskID string       `json:"agent_task_id"`
+	State       State        `json:"state"`
+	Stage       string       `json:"stage,omitempty"`
+	Percent     int          `json:"percent,omitempty"`
+	Events      []Event      `json:"events,omitempty"`
+	Output      []OutputLine `json:"output,omitempty"`
+}
+
+// Summary briefly describes a task without its result value
+// so that it can be listed and saved to task journal
+func (t Task) Summary() Summary {
+	summary := Summary{
+		AgentTaskID: t.ID,
+		Method:      t.Method,
+		State:       t.State,
+		QueuedAt:    t.QueuedAt,
+	}
+
+	if !t.StartedAt.IsZero() {
+		startedAt := t.StartedAt
+		summary.StartedAt = &startedAt
+	}
+
+	if !t.FinishedAt.IsZero() {
+		finishedAt := t.FinishedAt
+		summary.FinishedAt = &finishedAt
+	}
+
+	if t.Error != nil {
+		summary.Error = t.Error.Error()
+		if len(summary.Error) > MaxSummaryErrorLength {
+			summary.Error = summary.Error[:MaxSummaryErrorLength] + "..."
+		}
+	}
+
+	return summary
+}
+
+// Long error messages (e.g. packaging script output) are cut off in summaries
+const MaxSummaryErrorLength = 500
+
+type Summary struct {
+	AgentTaskID string     `json:"agent_task_id"`
+	Method      string     `json:"method"`
+	State       State      `json:"state"`
+	QueuedAt    time.Time  `json:"queued_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
 }
@@ -2,6 +2,8 @@ package task_test
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -18,6 +20,46 @@ var _ = Describe("Task", func() {
 		task = Task{}
 	})
 
+	Describe("StateValue", func() {
+		It("includes only id and state when task has not reported progress", func() {
+			task = Task{ID: "fake-task-id", State: StateRunning}
+
+			Expect(task.StateValue()).To(Equal(StateValue{
+				AgentTaskID: "fake-task-id",
+				State:       StateRunning,
+			}))
+		})
+
+		It("includes latest stage, percent and event history", func() {
+			events := []Event{
+				{Time: 1, Stage: "fake-stage-1", Percent: 10},
+				{Time: 2, Stage: "fake-stage-2", Percent: 60},
+			}
+			task = Task{ID: "fake-task-id", State: StateRunning, Events: events}
+
+			Expect(task.StateValue()).To(Equal(StateValue{
+				AgentTaskID: "fake-task-id",
+				State:       StateRunning,
+				Stage:       "fake-stage-2",
+				Percent:     60,
+				Events:      events,
+			}))
+		})
+
+		It("includes output seen so far", func() {
+			output := []OutputLine{
+				{Sequence: 1, Time: 1, Stream: OutputStdout, Line: "fake-line"},
+			}
+			task = Task{ID: "fake-task-id", State: StateRunning, Output: output}
+
+			Expect(task.StateValue()).To(Equal(StateValue{
+				AgentTaskID: "fake-task-id",
+				State:       StateRunning,
+				Output:      output,
+			}))
+		})
+	})
+
 	Describe("Cancel", func() {
 		It("runs cancel function", func() {
 			cancelCalled := false
@@ -41,4 +83,50 @@ var _ = Describe("Task", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
+
+	Describe("Summary", func() {
+		It("includes only queued time for task that has not started", func() {
+			queuedAt := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+			task = Task{ID: "fake-task-id", Method: "fake-method", State: StateQueued, QueuedAt: queuedAt}
+
+			Expect(task.Summary()).To(Equal(Summary{
+				AgentTaskID: "fake-task-id",
+				Method:      "fake-method",
+				State:       StateQueued,
+				QueuedAt:    queuedAt,
+			}))
+		})
+
+		It("includes start and finish times and error message of finished task", func() {
+			queuedAt := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+			startedAt := queuedAt.Add(time.Second)
+			finishedAt := queuedAt.Add(time.Minute)
+
+			task = Task{
+				ID:         "fake-task-id",
+				Method:     "fake-method",
+				State:      StateFailed,
+				Error:      errors.New("fake-error"),
+				QueuedAt:   queuedAt,
+				StartedAt:  startedAt,
+				FinishedAt: finishedAt,
+			}
+
+			Expect(task.Summary()).To(Equal(Summary{
+				AgentTaskID: "fake-task-id",
+				Method:      "fake-method",
+				State:       StateFailed,
+				QueuedAt:    queuedAt,
+				StartedAt:   &startedAt,
+				FinishedAt:  &finishedAt,
+				Error:       "fake-error",
+			}))
+		})
+
+		It("cuts off long error messages", func() {
+			task = Task{Error: errors.New(strings.Repeat("e", MaxSummaryErrorLength+1))}
+
+			Expect(task.Summary().Error).To(Equal(strings.Repeat("e", MaxSummaryErrorLength) + "..."))
+		})
+	})
 })
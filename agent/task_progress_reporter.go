@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	// Output lines are published in batches from a separate goroutine
+	// so that scripts are never slowed down by message bus
+	taskOutputFlushInterval = time.Second
+	taskOutputMaxBatchLines = 100
+
+	// Lines are dropped from publishing (though not from get_task)
+	// once this many are waiting to be published
+	taskOutputMaxPendingLines = 1000
+)
+
+// taskProgressReporter records progress events and output so that they are returned by get_task
+// and publishes them as task_progress and task_output messages
+type taskProgressReporter struct {
+	taskID      string
+	taskService boshtask.Service
+	notifier    boshnotif.Notifier
+	timeService clock.Clock
+	logger      boshlog.Logger
+
+	// Stdout and stderr lines are reported from different goroutines
+	outputSequence int
+	pendingOutput  []boshtask.OutputLine
+	droppedOutput  int
+	flushingOutput bool
+	outputLock     sync.Mutex
+}
+
+func (r *taskProgressReporter) ReportProgress(stage string, percent int) {
+	event := boshtask.Event{
+		Time:    r.timeService.Now().Unix(),
+		Stage:   stage,
+		Percent: percent,
+	}
+
+	r.taskService.AddEvent(r.taskID, event)
+
+	err := r.notifier.NotifyTaskProgress(r.taskID, event)
+	if err != nil {
+		// Progress is informational; failing to deliver it should not fail the task
+		r.logger.Error(actionDispatcherLogTag, "Failed to send progress of task %s: %s", r.taskID, err.Error())
+	}
+}
+
+// ReportOutput does not wait for line to be published
+func (r *taskProgressReporter) ReportOutput(stream boshtask.OutputStream, line string) {
+	r.outputLock.Lock()
+	defer r.outputLock.Unlock()
+
+	r.outputSequence++
+
+	outputLine := boshtask.OutputLine{
+		Sequence: r.outputSequence,
+		Time:     r.timeService.Now().Unix(),
+		Stream:   stream,
+		Line:     line,
+	}
+
+	r.taskService.AddOutput(r.taskID, outputLine)
+
+	if len(r.pendingOutput) >= taskOutputMaxPendingLines {
+		r.droppedOutput++
+		return
+	}
+
+	r.pendingOutput = append(r.pendingOutput, outputLine)
+
+	if !r.flushingOutput {
+		r.flushingOutput = true
+		go r.flushOutput()
+	}
+}
+
+// flushOutput publishes pending lines every flush interval until there are none left
+func (r *taskProgressReporter) flushOutput() {
+	for {
+		r.timeService.Sleep(taskOutputFlushInterval)
+
+		r.outputLock.Lock()
+
+		lines, dropped := r.pendingOutput, r.droppedOutput
+		r.pendingOutput, r.droppedOutput = nil, 0
+
+		if len(lines) == 0 {
+			r.flushingOutput = false
+			r.outputLock.Unlock()
+			return
+		}
+
+		r.outputLock.Unlock()
+
+		if dropped > 0 {
+			r.logger.Error(actionDispatcherLogTag, "Dropped %d output line(s) of task %s that could not be sent in time", dropped, r.taskID)
+		}
+
+		for len(lines) > 0 {
+			batch := lines
+			if len(batch) > taskOutputMaxBatchLines {
+				batch = batch[:taskOutputMaxBatchLines]
+			}
+
+			lines = lines[len(batch):]
+
+			err := r.notifier.NotifyTaskOutput(r.taskID, batch)
+			if err != nil {
+				r.logger.Error(actionDispatcherLogTag, "Failed to send output of task %s: %s", r.taskID, err.Error())
+			}
+		}
+	}
+}
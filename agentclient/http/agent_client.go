@@ -145,7 +145,11 @@ func (c *agentClient) sendAsyncTaskMessage(method string, arguments []interface{
 			return false, bosherr.WrapError(err, "Getting task state")
 		}
 
-		if taskState != "running" {
+		if taskState == "cancelled" {
+			return false, bosherr.Errorf("Task %s was cancelled", method)
+		}
+
+		if taskState != "running" && taskState != "queued" {
 			var ok bool
 			value, ok = response.Value.(map[string]interface{})
 			if !ok {
@@ -198,6 +198,17 @@ var _ = Describe("AgentClient", func() {
 				}))
 			})
 
+			It("returns error when task was cancelled", func() {
+				fakeHTTPClient = fakehttpclient.NewFakeHTTPClient()
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"cancelled"}}`, 200, nil)
+				agentClient = NewAgentClient("http://localhost:6305", "fake-uuid", 0, fakeHTTPClient, boshlog.NewLogger(boshlog.LevelNone))
+
+				err := agentClient.Apply(spec)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Task apply was cancelled"))
+			})
+
 			It("waits for the task to be finished", func() {
 				err := agentClient.Apply(spec)
 				Expect(err).ToNot(HaveOccurred())
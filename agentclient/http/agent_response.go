@@ -124,7 +124,7 @@ func (r *TaskResponse) TaskID() (string, error) {
 // TaskState returns the state of the task reported by agent.
 //
 // Agent response to get_task can be in different format based on task state.
-// If task state is running agent responds
+// If task state is running, queued or cancelled agent responds
 // with value as { agent_task_id: "task-id", state: "running" }
 // Otherwise the value is a string like "stopped".
 func (r *TaskResponse) TaskState() (string, error) {
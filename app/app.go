@@ -2,17 +2,18 @@ package app
 
 import (
 	"path/filepath"
+	"runtime"
 	"time"
 
-	sigar "github.com/cloudfoundry/gosigar"
-
 	boshagent "github.com/cloudfoundry/bosh-agent/agent"
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
 	boshapplier "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	boshaj "github.com/cloudfoundry/bosh-agent/agent/applier/jobs"
 	boshap "github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshaudit "github.com/cloudfoundry/bosh-agent/agent/audit"
 	boshrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
 	boshdrain "github.com/cloudfoundry/bosh-agent/agent/drain"
@@ -21,11 +22,13 @@ import (
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshmonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
 	boshmbus "github.com/cloudfoundry/bosh-agent/mbus"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
-	boshsigar "github.com/cloudfoundry/bosh-agent/sigar"
 	boshsyslog "github.com/cloudfoundry/bosh-agent/syslog"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -42,9 +45,10 @@ type App interface {
 }
 
 type app struct {
-	logger   boshlog.Logger
-	agent    boshagent.Agent
-	platform boshplatform.Platform
+	logger        boshlog.Logger
+	agent         boshagent.Agent
+	platform      boshplatform.Platform
+	metricsServer *boshmetrics.Server
 }
 
 func New(logger boshlog.Logger) App {
@@ -64,11 +68,17 @@ func (app *app) Setup(args []string) error {
 
 	dirProvider := boshdirs.NewProvider(opts.BaseDirectory)
 
-	// Pulled outside of the platform provider so bosh-init will not pull in
-	// sigar when cross compiling linux -> darwin
-	sigarCollector := boshsigar.NewSigarStatsCollector(&sigar.ConcreteSigar{})
+	err = config.VitalsAlerts.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating vitals alerts")
+	}
 
-	platformProvider := boshplatform.NewProvider(app.logger, dirProvider, sigarCollector, config.Platform)
+	statsCollector, err := app.buildStatsCollector(config.Stats)
+	if err != nil {
+		return bosherr.WrapError(err, "Building stats collector")
+	}
+
+	platformProvider := boshplatform.NewProvider(app.logger, dirProvider, statsCollector, config.Platform)
 	app.platform, err = platformProvider.Get(opts.PlatformName)
 	if err != nil {
 		return bosherr.WrapError(err, "Getting platform")
@@ -98,7 +108,11 @@ func (app *app) Setup(args []string) error {
 		return bosherr.WrapError(err, "Running bootstrap")
 	}
 
-	mbusHandlerProvider := boshmbus.NewHandlerProvider(settingsService, app.logger)
+	timeService := clock.NewClock()
+
+	metricsRegistry := boshmetrics.NewRegistry()
+
+	mbusHandlerProvider := boshmbus.NewHandlerProvider(settingsService, config.Mbus, timeService, metricsRegistry, app.logger)
 
 	mbusHandler, err := mbusHandlerProvider.Get(app.platform, dirProvider)
 	if err != nil {
@@ -113,6 +127,8 @@ func (app *app) Setup(args []string) error {
 		return bosherr.WrapError(err, "Getting blobstore")
 	}
 
+	blobstore = boshmetrics.NewInstrumentedBlobstore(blobstore, app.platform.GetFs(), metricsRegistry)
+
 	monitClientProvider := boshmonit.NewProvider(app.platform, app.logger)
 
 	monitClient, err := monitClientProvider.Get()
@@ -126,6 +142,7 @@ func (app *app) Setup(args []string) error {
 		app.logger,
 		dirProvider,
 		mbusHandler,
+		metricsRegistry,
 	)
 
 	jobSupervisor, err := jobSupervisorProvider.Get(opts.JobSupervisor)
@@ -139,7 +156,18 @@ func (app *app) Setup(args []string) error {
 
 	uuidGen := boshuuid.NewGenerator()
 
-	taskService := boshtask.NewAsyncTaskService(uuidGen, app.logger)
+	var taskJournal boshtask.Journal
+	if config.Tasks.UseJournal {
+		taskJournal = boshtask.NewFileJournal(
+			app.platform.GetFs(),
+			filepath.Join(dirProvider.BoshDir(), "tasks_journal.json"),
+		)
+	}
+
+	taskService := boshtask.NewAsyncTaskService(config.Tasks, taskJournal, uuidGen, timeService, app.logger)
+
+	metricsRegistry.RegisterCollector(boshmetrics.NewTasksCollector(taskService))
+	metricsRegistry.RegisterCollector(boshmetrics.NewVitalsCollector(app.platform.GetVitalsService(), app.logger))
 
 	taskManager := boshtask.NewManagerProvider().NewManager(
 		app.logger,
@@ -159,6 +187,14 @@ func (app *app) Setup(args []string) error {
 		dirProvider,
 	)
 
+	vitalsHistory := boshvitals.NewHistory(
+		config.VitalsHistory,
+		app.platform.GetFs(),
+		filepath.Join(dirProvider.BoshDir(), "vitals_history.json"),
+		timeService,
+		app.logger,
+	)
+
 	actionFactory := boshaction.NewFactory(
 		settingsService,
 		app.platform,
@@ -170,7 +206,9 @@ func (app *app) Setup(args []string) error {
 		jobSupervisor,
 		specService,
 		drainScriptProvider,
+		vitalsHistory,
 		app.logger,
+		config.Actions,
 	)
 
 	actionRunner := boshaction.NewRunner()
@@ -181,15 +219,22 @@ func (app *app) Setup(args []string) error {
 		taskManager,
 		actionFactory,
 		actionRunner,
+		notifier,
+		timeService,
+		config.Tasks.ActionTimeouts(),
+		boshagent.NewRequestCache(config.RequestCache, timeService),
+		boshaudit.NewFileLog(config.AuditLog, app.platform.GetFs(), filepath.Join(dirProvider.BoshDir(), "log", "audit.log")),
+		metricsRegistry,
 	)
 
 	syslogServer := boshsyslog.NewServer(33331, app.logger)
 
-	timeService := clock.NewClock()
+	localHandler := mbusHandlerProvider.GetLocal(config.LocalSocket, app.platform, dirProvider)
 
 	app.agent = boshagent.New(
 		app.logger,
 		mbusHandler,
+		localHandler,
 		app.platform,
 		actionDispatcher,
 		jobSupervisor,
@@ -199,12 +244,28 @@ func (app *app) Setup(args []string) error {
 		settingsService,
 		uuidGen,
 		timeService,
+		boshalert.NewVitalsChecker(config.VitalsAlerts, runtime.NumCPU(), settingsService, uuidGen, timeService),
+		vitalsHistory,
+		config.VitalsHistory.Interval(),
 	)
 
+	if config.Metrics.Enabled() {
+		app.metricsServer = boshmetrics.NewServer(config.Metrics, metricsRegistry, app.logger)
+	}
+
 	return nil
 }
 
 func (app *app) Run() error {
+	if app.metricsServer != nil {
+		err := app.metricsServer.Start()
+		if err != nil {
+			return bosherr.WrapError(err, "Starting metrics server")
+		}
+
+		defer app.metricsServer.Stop()
+	}
+
 	err := app.agent.Run()
 	if err != nil {
 		return bosherr.WrapError(err, "Running agent")
@@ -280,6 +341,24 @@ func (app *app) buildApplierAndCompiler(
 	return applier, compiler
 }
 
+// Pulled outside of the platform provider so bosh-init will not pull in
+// sigar when cross compiling linux -> darwin; building with nosigar tag
+// leaves out sigar collector altogether (see sigar_stats_collector.go)
+func (app *app) buildStatsCollector(options boshstats.Options) (boshstats.Collector, error) {
+	fs := boshsys.NewOsFileSystem(app.logger)
+
+	switch options.CollectorType() {
+	case boshstats.CollectorSigar:
+		return newSigarStatsCollector(fs)
+
+	case boshstats.CollectorProc:
+		return boshstats.NewProcStatsCollector(fs, "/proc", clock.NewClock()), nil
+
+	default:
+		return nil, bosherr.Errorf("Unknown stats collector '%s'", options.Collector)
+	}
+}
+
 func (app *app) loadConfig(path string) (Config, error) {
 	// Use one off copy of file system to read configuration file
 	fs := boshsys.NewOsFileSystem(app.logger)
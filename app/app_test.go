@@ -111,6 +111,36 @@ func init() {
 			Expect(app.GetPlatform().GetDevicePathResolver()).To(Equal(devicepathresolver.NewIdentityDevicePathResolver()))
 		})
 
+		Context("when stats collector is unknown", func() {
+			BeforeEach(func() {
+				agentConfJSON = `{
+					"Stats": { "Collector": "fake-collector" },
+					"Infrastructure": { "Settings": { "Sources": [{ "Type": "CDROM", "FileName": "/fake-file-name" }] } }
+				}`
+			})
+
+			It("returns error", func() {
+				err := app.Setup([]string{"bosh-agent", "-P", "dummy", "-C", agentConfPath, "-b", baseDir})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Unknown stats collector 'fake-collector'"))
+			})
+		})
+
+		Context("when vitals alert threshold is invalid", func() {
+			BeforeEach(func() {
+				agentConfJSON = `{
+					"VitalsAlerts": { "Thresholds": [{ "Vital": "fake-vital", "Raise": 90 }] },
+					"Infrastructure": { "Settings": { "Sources": [{ "Type": "CDROM", "FileName": "/fake-file-name" }] } }
+				}`
+			})
+
+			It("returns error", func() {
+				err := app.Setup([]string{"bosh-agent", "-P", "dummy", "-C", agentConfPath, "-b", baseDir})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Unknown vital 'fake-vital'"))
+			})
+		})
+
 		Context("when DevicePathResolutionType is 'virtio'", func() {
 			BeforeEach(func() {
 				agentConfJSON = `{
@@ -3,8 +3,17 @@ package app
 import (
 	"encoding/json"
 
+	boshagent "github.com/cloudfoundry/bosh-agent/agent"
+	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	boshaudit "github.com/cloudfoundry/bosh-agent/agent/audit"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshinf "github.com/cloudfoundry/bosh-agent/infrastructure"
+	boshmbus "github.com/cloudfoundry/bosh-agent/mbus"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
@@ -12,6 +21,16 @@ import (
 type Config struct {
 	Platform       boshplatform.Options
 	Infrastructure boshinf.Options
+	Tasks          boshtask.Options
+	RequestCache   boshagent.RequestCacheOptions
+	AuditLog       boshaudit.Options
+	Actions        boshaction.Options
+	Mbus           boshmbus.Options
+	LocalSocket    boshmbus.UnixSocketOptions
+	Metrics        boshmetrics.Options
+	Stats          boshstats.Options
+	VitalsAlerts   boshalert.VitalsOptions
+	VitalsHistory  boshvitals.HistoryOptions
 }
 
 func LoadConfigFromPath(fs boshsys.FileSystem, path string) (Config, error) {
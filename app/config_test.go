@@ -4,8 +4,17 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	boshagent "github.com/cloudfoundry/bosh-agent/agent"
+	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
+	boshaudit "github.com/cloudfoundry/bosh-agent/agent/audit"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshinf "github.com/cloudfoundry/bosh-agent/infrastructure"
+	boshmbus "github.com/cloudfoundry/bosh-agent/mbus"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
@@ -58,6 +67,51 @@ var _ = Describe("LoadConfigFromPath", func() {
 				  "UseServerName": true,
 				  "UseRegistry": true
 				}
+			},
+			"Tasks": {
+				"MaxConcurrentTasks": 2,
+				"MaxFinishedTasks": 100,
+				"MaxFinishedTaskAgeSeconds": 3600,
+				"UseJournal": true,
+				"ActionTimeoutsSeconds": {"drain": 600}
+			},
+			"RequestCache": {
+				"DuplicateRequestWindowSeconds": 60
+			},
+			"AuditLog": {
+				"MaxSizeKB": 1024,
+				"MaxBackups": 3
+			},
+			"Actions": {
+				"AllowedActions": ["get_state", "list_disk"],
+				"DeniedActions": ["ssh"]
+			},
+			"Mbus": {
+				"ReconnectInitialDelayMilliseconds": 250,
+				"ReconnectMaxDelaySeconds": 30,
+				"OutboxMaxAlerts": 500
+			},
+			"LocalSocket": {
+				"Path": "/fake-agent.sock",
+				"AllowedActions": ["ping", "get_state"]
+			},
+			"Metrics": {
+				"Port": 9190
+			},
+			"Stats": {
+				"Collector": "proc"
+			},
+			"VitalsAlerts": {
+				"Thresholds": [
+					{"Vital": "disk.persistent.percent", "Raise": 90, "Clear": 85, "Severity": 2},
+					{"Vital": "load.1m_per_core", "Raise": 2}
+				]
+			},
+			"VitalsHistory": {
+				"IntervalSeconds": 5,
+				"MaxSamples": 720,
+				"Persist": true,
+				"PersistIntervalSeconds": 120
 			}
 		}`)
 
@@ -99,6 +153,51 @@ var _ = Describe("LoadConfigFromPath", func() {
 					UseRegistry:   true,
 				},
 			},
+			Tasks: boshtask.Options{
+				MaxConcurrentTasks:        2,
+				MaxFinishedTasks:          100,
+				MaxFinishedTaskAgeSeconds: 3600,
+				UseJournal:                true,
+				ActionTimeoutsSeconds:     map[string]int{"drain": 600},
+			},
+			RequestCache: boshagent.RequestCacheOptions{
+				DuplicateRequestWindowSeconds: 60,
+			},
+			AuditLog: boshaudit.Options{
+				MaxSizeKB:  1024,
+				MaxBackups: 3,
+			},
+			Actions: boshaction.Options{
+				AllowedActions: []string{"get_state", "list_disk"},
+				DeniedActions:  []string{"ssh"},
+			},
+			Mbus: boshmbus.Options{
+				ReconnectInitialDelayMilliseconds: 250,
+				ReconnectMaxDelaySeconds:          30,
+				OutboxMaxAlerts:                   500,
+			},
+			LocalSocket: boshmbus.UnixSocketOptions{
+				Path:           "/fake-agent.sock",
+				AllowedActions: []string{"ping", "get_state"},
+			},
+			Metrics: boshmetrics.Options{
+				Port: 9190,
+			},
+			Stats: boshstats.Options{
+				Collector: "proc",
+			},
+			VitalsAlerts: boshalert.VitalsOptions{
+				Thresholds: []boshalert.ThresholdOptions{
+					{Vital: "disk.persistent.percent", Raise: 90, Clear: 85, Severity: boshalert.SeverityCritical},
+					{Vital: "load.1m_per_core", Raise: 2},
+				},
+			},
+			VitalsHistory: boshvitals.HistoryOptions{
+				IntervalSeconds:        5,
+				MaxSamples:             720,
+				Persist:                true,
+				PersistIntervalSeconds: 120,
+			},
 		}))
 	})
 
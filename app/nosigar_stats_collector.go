@@ -0,0 +1,16 @@
+//go:build nosigar
+// +build nosigar
+
+package app
+
+import (
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// Agent built with nosigar tag (e.g. CGO_ENABLED=0 GOOS=darwin go build -tags nosigar)
+// does not link gosigar and only supports proc stats collector
+func newSigarStatsCollector(_ boshsys.FileSystem) (boshstats.Collector, error) {
+	return nil, bosherr.Errorf("Stats collector '%s' is not available since agent was built with nosigar tag", boshstats.CollectorSigar)
+}
@@ -0,0 +1,18 @@
+//go:build !nosigar
+// +build !nosigar
+
+package app
+
+import (
+	"github.com/pivotal-golang/clock"
+
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
+	boshsigar "github.com/cloudfoundry/bosh-agent/sigar"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	sigar "github.com/cloudfoundry/gosigar"
+)
+
+func newSigarStatsCollector(fs boshsys.FileSystem) (boshstats.Collector, error) {
+	ioStatsSampler := boshstats.NewIOStatsSampler(fs, "/proc", clock.NewClock())
+	return boshsigar.NewSigarStatsCollector(&sigar.ConcreteSigar{}, ioStatsSampler), nil
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	// Room left in every chunk message for its fields other than data
+	chunkEnvelopeLength = 256
+)
+
+// Chunk carries part of response that is too long to be sent as one message.
+// Client concatenates decoded data of chunks 0..count-1 to get response JSON
+// and can check it against sha1 of the whole response.
+type Chunk struct {
+	Index int    `json:"index"`
+	Count int    `json:"count"`
+	SHA1  string `json:"sha1"`
+	Data  []byte `json:"data"`
+}
+
+type chunkResponse struct {
+	Chunk Chunk `json:"chunk"`
+}
+
+// ChunkResponse splits marshalled response into messages
+// that are each at most maxMessageLength long
+func ChunkResponse(respJSON []byte, maxMessageLength int) ([][]byte, error) {
+	if maxMessageLength <= chunkEnvelopeLength {
+		return nil, bosherr.Errorf("Maximum message length %d is too short for chunks", maxMessageLength)
+	}
+
+	// Data is base64 encoded which makes it 4/3 times longer
+	maxDataLength := (maxMessageLength - chunkEnvelopeLength) / 4 * 3
+
+	count := (len(respJSON) + maxDataLength - 1) / maxDataLength
+	if count == 0 {
+		count = 1
+	}
+
+	respSHA1 := fmt.Sprintf("%x", sha1.Sum(respJSON))
+
+	messages := [][]byte{}
+
+	for i := 0; i < count; i++ {
+		end := (i + 1) * maxDataLength
+		if end > len(respJSON) {
+			end = len(respJSON)
+		}
+
+		message, err := json.Marshal(chunkResponse{
+			Chunk: Chunk{
+				Index: i,
+				Count: count,
+				SHA1:  respSHA1,
+				Data:  respJSON[i*maxDataLength : end],
+			},
+		})
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Marshalling chunk")
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// JoinChunks reassembles response JSON from chunk messages given in any order
+func JoinChunks(messages [][]byte) ([]byte, error) {
+	chunks := map[int]Chunk{}
+	count := 0
+
+	for _, message := range messages {
+		var resp chunkResponse
+
+		err := json.Unmarshal(message, &resp)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Unmarshalling chunk")
+		}
+
+		chunks[resp.Chunk.Index] = resp.Chunk
+		count = resp.Chunk.Count
+	}
+
+	respJSON := []byte{}
+	expectedSHA1 := ""
+
+	for i := 0; i < count; i++ {
+		chunk, found := chunks[i]
+		if !found {
+			return nil, bosherr.Errorf("Missing chunk %d of %d", i, count)
+		}
+
+		respJSON = append(respJSON, chunk.Data...)
+		expectedSHA1 = chunk.SHA1
+	}
+
+	actualSHA1 := fmt.Sprintf("%x", sha1.Sum(respJSON))
+	if actualSHA1 != expectedSHA1 {
+		return nil, bosherr.Errorf("Expected response sha1 %s but got %s", expectedSHA1, actualSHA1)
+	}
+
+	return respJSON, nil
+}
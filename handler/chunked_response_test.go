@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/handler"
+)
+
+var _ = Describe("ChunkResponse", func() {
+	respJSON := []byte(`{"value":"` + strings.Repeat("fake-output-", 1000) + `"}`)
+
+	It("splits response into messages no longer than max length", func() {
+		messages, err := ChunkResponse(respJSON, 1024)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(messages)).To(BeNumerically(">", 1))
+
+		for _, message := range messages {
+			Expect(len(message)).To(BeNumerically("<=", 1024))
+		}
+	})
+
+	It("splits response into chunks that can be joined back in any order", func() {
+		messages, err := ChunkResponse(respJSON, 1024)
+		Expect(err).ToNot(HaveOccurred())
+
+		reversedMessages := [][]byte{}
+		for i := len(messages) - 1; i >= 0; i-- {
+			reversedMessages = append(reversedMessages, messages[i])
+		}
+
+		joinedJSON, err := JoinChunks(reversedMessages)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(joinedJSON).To(Equal(respJSON))
+	})
+
+	It("describes position of every chunk", func() {
+		messages, err := ChunkResponse([]byte(`{"value":"fake-value"}`), 1024)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(messages).To(HaveLen(1))
+		Expect(messages[0]).To(MatchJSON(`{
+			"chunk": {
+				"index": 0,
+				"count": 1,
+				"sha1": "d33f8a90a958f9513ac446c98835d649f2ea731e",
+				"data": "eyJ2YWx1ZSI6ImZha2UtdmFsdWUifQ=="
+			}
+		}`))
+	})
+
+	It("returns error when max length leaves no room for data", func() {
+		_, err := ChunkResponse(respJSON, 100)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("JoinChunks", func() {
+	It("returns error when chunk is missing", func() {
+		messages, err := ChunkResponse([]byte(strings.Repeat("a", 2000)), 1024)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = JoinChunks(messages[1:])
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Missing chunk 0"))
+	})
+
+	It("returns error when joined data does not match sha1", func() {
+		_, err := JoinChunks([][]byte{[]byte(`{"chunk":{"index":0,"count":1,"sha1":"fake-sha1","data":"YQ=="}}`)})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected response sha1 fake-sha1"))
+	})
+})
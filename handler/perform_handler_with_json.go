@@ -24,7 +24,7 @@ func PerformHandlerWithJSON(rawJSON []byte, handler Func, maxResponseLength int,
 	request.Payload = rawJSON
 
 	logger.Info(mbusHandlerLogTag, "Received request with action %s", request.Method)
-	logger.DebugWithDetails(mbusHandlerLogTag, "Payload", request.Payload)
+	logger.DebugWithDetails(mbusHandlerLogTag, "Payload", RedactJSON(request.Payload))
 
 	response := handler(request)
 	if response == nil {
@@ -38,7 +38,7 @@ func PerformHandlerWithJSON(rawJSON []byte, handler Func, maxResponseLength int,
 	}
 
 	logger.Info(mbusHandlerLogTag, "Responding")
-	logger.DebugWithDetails(mbusHandlerLogTag, "Payload", respJSON)
+	logger.DebugWithDetails(mbusHandlerLogTag, "Payload", RedactJSON(respJSON))
 
 	return respJSON, request, nil
 }
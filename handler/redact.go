@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+const RedactedValue = "<redacted>"
+
+// Values of keys that contain any of these strings are never logged
+// (e.g. ssh user passwords, blobstore secret access keys, certificate private keys)
+var secretKeyParts = []string{"password", "secret", "private_key", "json_key", "credentials", "token"}
+
+// RedactJSON returns JSON with values of secret-looking keys replaced at any depth.
+// Since secrets cannot be found in a payload that cannot be parsed
+// such payload is not returned at all.
+func RedactJSON(rawJSON []byte) []byte {
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(rawJSON))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&value)
+	if err != nil {
+		return []byte(RedactedValue)
+	}
+
+	redactedJSON, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return []byte(RedactedValue)
+	}
+
+	return redactedJSON
+}
+
+func redactValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, nestedValue := range typedValue {
+			if isSecretKey(key) && nestedValue != nil {
+				typedValue[key] = RedactedValue
+			} else {
+				typedValue[key] = redactValue(nestedValue)
+			}
+		}
+		return typedValue
+
+	case []interface{}:
+		for i, nestedValue := range typedValue {
+			typedValue[i] = redactValue(nestedValue)
+		}
+		return typedValue
+
+	default:
+		return value
+	}
+}
+
+func isSecretKey(key string) bool {
+	lowerKey := strings.ToLower(key)
+
+	for _, secretKeyPart := range secretKeyParts {
+		if strings.Contains(lowerKey, secretKeyPart) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,35 @@
+package handler_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/handler"
+)
+
+var _ = Describe("RedactJSON", func() {
+	It("redacts ssh passwords in request arguments", func() {
+		redacted := RedactJSON([]byte(`{"method":"ssh","arguments":["setup",{"user":"fake-user","password":"fake-password","public_key":"fake-public-key"}],"reply_to":"fake-reply-to"}`))
+		Expect(redacted).To(MatchJSON(`{"method":"ssh","arguments":["setup",{"user":"fake-user","password":"<redacted>","public_key":"fake-public-key"}],"reply_to":"fake-reply-to"}`))
+	})
+
+	It("redacts blobstore credentials and private keys at any depth", func() {
+		redacted := RedactJSON([]byte(`{"value":{"blobstore":{"options":{"access_key_id":"fake-id","secret_access_key":"fake-secret","credentials":{"user":"fake-user"}}},"cert":{"private_key":"fake-key"},"list":[{"Password":"fake-password"}]}}`))
+		Expect(redacted).To(MatchJSON(`{"value":{"blobstore":{"options":{"access_key_id":"fake-id","secret_access_key":"<redacted>","credentials":"<redacted>"}},"cert":{"private_key":"<redacted>"},"list":[{"Password":"<redacted>"}]}}`))
+	})
+
+	It("keeps empty secret values", func() {
+		redacted := RedactJSON([]byte(`{"password":null}`))
+		Expect(redacted).To(MatchJSON(`{"password":null}`))
+	})
+
+	It("keeps numbers as they are", func() {
+		redacted := RedactJSON([]byte(`{"value":12345678901234567890}`))
+		Expect(string(redacted)).To(Equal(`{"value":12345678901234567890}`))
+	})
+
+	It("does not return payload that cannot be parsed", func() {
+		redacted := RedactJSON([]byte(`fake-invalid-json "password":"fake-password"`))
+		Expect(string(redacted)).To(Equal("<redacted>"))
+	})
+})
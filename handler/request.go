@@ -12,6 +12,10 @@ type Request struct {
 	ReplyTo string `json:"reply_to"`
 	Method  string
 	Payload []byte
+
+	// Identifies request so that its repeated deliveries are not acted upon twice;
+	// empty if requester does not identify its requests
+	RequestID string `json:"request_id"`
 }
 
 func (r Request) GetPayload() []byte {
@@ -22,9 +22,17 @@ func (r valueResponse) Shorten() Response {
 	return r
 }
 
+// CodedError lets callers tell kinds of failures apart
+// without parsing exception messages
+type CodedError interface {
+	error
+	Code() string
+}
+
 type exceptionResponse struct {
 	Exception struct {
 		Message string `json:"message,omitempty"`
+		Code    string `json:"code,omitempty"`
 	} `json:"exception"`
 
 	err error
@@ -33,6 +41,7 @@ type exceptionResponse struct {
 func NewExceptionResponse(err error) (resp Response) {
 	r := exceptionResponse{}
 	r.Exception.Message = err.Error()
+	r.Exception.Code = errorCode(err)
 	r.err = err
 	return r
 }
@@ -41,9 +50,17 @@ func (r exceptionResponse) Shorten() Response {
 	if typedErr, ok := r.err.(bosherr.ShortenableError); ok {
 		sr := exceptionResponse{}
 		sr.Exception.Message = typedErr.ShortError()
+		sr.Exception.Code = r.Exception.Code
 		sr.err = typedErr
 		return sr
 	}
 
 	return r
 }
+
+func errorCode(err error) string {
+	if codedErr, ok := err.(CodedError); ok {
+		return codedErr.Code()
+	}
+	return ""
+}
@@ -22,6 +22,12 @@ func (e *testShortError) ShortError() string {
 	return msg
 }
 
+type testCodedError struct{}
+
+func (e testCodedError) Error() string { return "fake-msg" }
+
+func (e testCodedError) Code() string { return "fake-code" }
+
 var _ = Describe("NewValueResponse", func() {
 	It("can be serialized to JSON", func() {
 		resp := NewValueResponse("fake-value")
@@ -91,4 +97,16 @@ var _ = Describe("NewExceptionResponse", func() {
 			)
 		})
 	})
+
+	Context("with error that has a code", func() {
+		It("includes code when serialized to JSON", func() {
+			resp := NewExceptionResponse(testCodedError{})
+			boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"fake-msg","code":"fake-code"}}`)
+		})
+
+		It("keeps code when shortened", func() {
+			resp := NewExceptionResponse(testCodedError{})
+			boshassert.MatchesJSONString(GinkgoT(), resp.Shorten(), `{"exception":{"message":"fake-msg","code":"fake-code"}}`)
+		})
+	})
 })
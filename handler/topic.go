@@ -3,7 +3,9 @@ package handler
 type Topic string
 
 const (
-	Heartbeat = Topic("heartbeat")
-	Alert     = Topic("alert")
-	Shutdown  = Topic("shutdown")
+	Heartbeat    = Topic("heartbeat")
+	Alert        = Topic("alert")
+	Shutdown     = Topic("shutdown")
+	TaskProgress = Topic("task_progress")
+	TaskOutput   = Topic("task_output")
 )
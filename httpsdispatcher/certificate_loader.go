@@ -0,0 +1,89 @@
+package httpsdispatcher
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// CertificateLoader serves certificate read from files and reads files again
+// when they change so that certificate can be rotated without restarting the agent
+type CertificateLoader struct {
+	certPath string
+	keyPath  string
+	logger   boshlog.Logger
+	logTag   string
+
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lock        sync.Mutex
+}
+
+func NewCertificateLoader(certPath, keyPath string, logger boshlog.Logger) *CertificateLoader {
+	return &CertificateLoader{
+		certPath: certPath,
+		keyPath:  keyPath,
+		logger:   logger,
+		logTag:   "CertificateLoader",
+	}
+}
+
+// Load reads certificate unless files have not changed since they were last read
+func (l *CertificateLoader) Load() (*tls.Certificate, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	certInfo, err := os.Stat(l.certPath)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Checking certificate file")
+	}
+
+	keyInfo, err := os.Stat(l.keyPath)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Checking private key file")
+	}
+
+	if l.cert != nil && certInfo.ModTime().Equal(l.certModTime) && keyInfo.ModTime().Equal(l.keyModTime) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certPath, l.keyPath)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Loading certificate and private key")
+	}
+
+	if l.cert != nil {
+		l.logger.Info(l.logTag, "Loaded rotated certificate from %s", l.certPath)
+	}
+
+	l.cert = &cert
+	l.certModTime = certInfo.ModTime()
+	l.keyModTime = keyInfo.ModTime()
+
+	return l.cert, nil
+}
+
+// GetCertificate is meant for tls.Config; previously loaded certificate
+// keeps being served while rotated files cannot be loaded (e.g. only one of them was replaced)
+func (l *CertificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, err := l.Load()
+	if err != nil {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+
+		if l.cert == nil {
+			return nil, err
+		}
+
+		l.logger.Error(l.logTag, "Reloading certificate, serving previous one: %s", err.Error())
+
+		return l.cert, nil
+	}
+
+	return cert, nil
+}
@@ -0,0 +1,147 @@
+package httpsdispatcher_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *rsa.PrivateKey
+	certPEM string
+	keyPEM  string
+}
+
+func newTestCert(subject pkix.Name, parent *testCert, isCA bool) testCert {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(certDER)
+	Expect(err).ToNot(HaveOccurred())
+
+	return testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})),
+		keyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+	}
+}
+
+// writeTestCert makes sure files look changed even if they are written within same clock tick
+func writeTestCert(cert testCert, certPath, keyPath string, modTime time.Time) {
+	Expect(ioutil.WriteFile(certPath, []byte(cert.certPEM), 0600)).To(Succeed())
+	Expect(ioutil.WriteFile(keyPath, []byte(cert.keyPEM), 0600)).To(Succeed())
+	Expect(os.Chtimes(certPath, modTime, modTime)).To(Succeed())
+	Expect(os.Chtimes(keyPath, modTime, modTime)).To(Succeed())
+}
+
+var _ = Describe("CertificateLoader", func() {
+	var (
+		dir      string
+		certPath string
+		keyPath  string
+		cert     testCert
+		loader   *boshdispatcher.CertificateLoader
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "certificate-loader")
+		Expect(err).ToNot(HaveOccurred())
+
+		certPath = filepath.Join(dir, "agent.cert")
+		keyPath = filepath.Join(dir, "agent.key")
+
+		cert = newTestCert(pkix.Name{CommonName: "fake-agent-1"}, nil, false)
+		writeTestCert(cert, certPath, keyPath, time.Now().Add(-time.Minute))
+
+		loader = boshdispatcher.NewCertificateLoader(certPath, keyPath, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	loadedCommonName := func() string {
+		loadedCert, err := loader.GetCertificate(&tls.ClientHelloInfo{})
+		Expect(err).ToNot(HaveOccurred())
+
+		parsedCert, err := x509.ParseCertificate(loadedCert.Certificate[0])
+		Expect(err).ToNot(HaveOccurred())
+
+		return parsedCert.Subject.CommonName
+	}
+
+	It("loads certificate from files", func() {
+		Expect(loadedCommonName()).To(Equal("fake-agent-1"))
+	})
+
+	It("loads rotated certificate once files change", func() {
+		Expect(loadedCommonName()).To(Equal("fake-agent-1"))
+
+		rotatedCert := newTestCert(pkix.Name{CommonName: "fake-agent-2"}, nil, false)
+		writeTestCert(rotatedCert, certPath, keyPath, time.Now())
+
+		Expect(loadedCommonName()).To(Equal("fake-agent-2"))
+	})
+
+	It("keeps serving previous certificate when rotated files cannot be loaded", func() {
+		Expect(loadedCommonName()).To(Equal("fake-agent-1"))
+
+		// Only certificate was replaced so far
+		rotatedCert := newTestCert(pkix.Name{CommonName: "fake-agent-2"}, nil, false)
+		Expect(ioutil.WriteFile(certPath, []byte(rotatedCert.certPEM), 0600)).To(Succeed())
+		Expect(os.Chtimes(certPath, time.Now(), time.Now())).To(Succeed())
+
+		Expect(loadedCommonName()).To(Equal("fake-agent-1"))
+
+		_, err := loader.Load()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Loading certificate and private key"))
+	})
+
+	It("returns error when files cannot be loaded initially", func() {
+		os.Remove(keyPath)
+
+		_, err := loader.GetCertificate(&tls.ClientHelloInfo{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Checking private key file"))
+	})
+})
@@ -2,46 +2,77 @@ package httpsdispatcher
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"net"
 	"net/http"
 	"net/url"
 
+	"github.com/cloudfoundry/bosh-agent/bootstrapper/auth"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
+const (
+	DefaultCertPath = "agent.cert"
+	DefaultKeyPath  = "agent.key"
+)
+
+type Options struct {
+	// Paths to certificate and private key served to clients.
+	// Default to DefaultCertPath and DefaultKeyPath when not set
+	CertPath string
+	KeyPath  string
+
+	// When set clients must present certificate signed by this PEM encoded CA
+	ClientCA string
+
+	// Distinguished names client certificate subject must match one of
+	// (e.g. "o=bosh,cn=director"); any subject is allowed when not set
+	AllowedNames []string
+}
+
+func (o Options) ClientCertificateRequired() bool {
+	return o.ClientCA != ""
+}
+
 type HTTPSDispatcher struct {
 	httpServer *http.Server
 	mux        *http.ServeMux
 	host       string
+	options    Options
 	listener   net.Listener
 	logger     boshlog.Logger
 }
 
 type HTTPHandlerFunc func(writer http.ResponseWriter, request *http.Request)
 
-func NewHTTPSDispatcher(baseURL *url.URL, logger boshlog.Logger) *HTTPSDispatcher {
+func NewHTTPSDispatcher(baseURL *url.URL, options Options, logger boshlog.Logger) *HTTPSDispatcher {
 	tlsConfig := &tls.Config{
-		// SSLv3 is insecure due to BEAST and POODLE attacks
-		MinVersion: tls.VersionTLS10,
-		// Both 3DES & RC4 ciphers can be exploited
+		// SSLv3, TLSv1.0 and TLSv1.1 are considered weak
+		MinVersion: tls.VersionTLS12,
+		// 3DES, RC4 and CBC ciphers can be exploited
 		// Using Mozilla's "Modern" recommended settings (where they overlap with golang support)
 		CipherSuites: []uint16{
-			tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
 			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
 			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
 		},
 		PreferServerCipherSuites: true,
 	}
-	return NewHTTPSDispatcherWithConfig(tlsConfig, baseURL, logger)
+	return NewHTTPSDispatcherWithConfig(tlsConfig, baseURL, options, logger)
 }
 
-func NewHTTPSDispatcherWithConfig(tlsConfig *tls.Config, baseURL *url.URL, logger boshlog.Logger) *HTTPSDispatcher {
+func NewHTTPSDispatcherWithConfig(tlsConfig *tls.Config, baseURL *url.URL, options Options, logger boshlog.Logger) *HTTPSDispatcher {
+	if options.CertPath == "" {
+		options.CertPath = DefaultCertPath
+	}
+
+	if options.KeyPath == "" {
+		options.KeyPath = DefaultKeyPath
+	}
+
 	httpServer := &http.Server{
 		TLSConfig: tlsConfig,
 	}
@@ -52,26 +83,37 @@ func NewHTTPSDispatcherWithConfig(tlsConfig *tls.Config, baseURL *url.URL, logge
 		httpServer: httpServer,
 		mux:        mux,
 		host:       baseURL.Host,
+		options:    options,
 		logger:     logger,
 	}
 }
 
 func (h *HTTPSDispatcher) Start() error {
-	tcpListener, err := net.Listen("tcp", h.host)
-	if err != nil {
-		return bosherr.WrapError(err, "Starting HTTP listener")
-	}
-	h.listener = tcpListener
+	config := h.httpServer.TLSConfig
+	config.NextProtos = []string{"http/1.1"}
 
-	cert, err := tls.LoadX509KeyPair("agent.cert", "agent.key")
+	// Certificate is read again on handshake once files change
+	certLoader := NewCertificateLoader(h.options.CertPath, h.options.KeyPath, h.logger)
+
+	_, err := certLoader.Load()
 	if err != nil {
 		return bosherr.WrapError(err, "Loading agent SSL cert")
 	}
 
-	// update the server config with the cert
-	config := h.httpServer.TLSConfig
-	config.NextProtos = []string{"http/1.1"}
-	config.Certificates = []tls.Certificate{cert}
+	config.GetCertificate = certLoader.GetCertificate
+
+	if h.options.ClientCertificateRequired() {
+		err = h.requireClientCertificate(config)
+		if err != nil {
+			return bosherr.WrapError(err, "Configuring client certificate authentication")
+		}
+	}
+
+	tcpListener, err := net.Listen("tcp", h.host)
+	if err != nil {
+		return bosherr.WrapError(err, "Starting HTTP listener")
+	}
+	h.listener = tcpListener
 
 	tlsListener := tls.NewListener(tcpListener, config)
 
@@ -85,6 +127,42 @@ func (h *HTTPSDispatcher) Stop() {
 	}
 }
 
+func (h *HTTPSDispatcher) requireClientCertificate(config *tls.Config) error {
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM([]byte(h.options.ClientCA)) {
+		return bosherr.Error("Parsing client CA certificate")
+	}
+
+	allowedNames := []pkix.Name{}
+	parser := auth.NewDistinguishedNamesParser()
+
+	for _, dn := range h.options.AllowedNames {
+		name, err := parser.Parse(dn)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing allowed name '%s'", dn)
+		}
+		allowedNames = append(allowedNames, *name)
+	}
+
+	// Name that is parsed from "*" matches any subject
+	if len(allowedNames) == 0 {
+		allowedNames = append(allowedNames, pkix.Name{})
+	}
+
+	verifier := &auth.CertificateVerifier{AllowedNames: allowedNames}
+
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	config.ClientCAs = clientCAs
+	config.VerifyPeerCertificate = func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 {
+			return bosherr.Error("No verified client certificate")
+		}
+		return verifier.Verify(verifiedChains[0])
+	}
+
+	return nil
+}
+
 func (h *HTTPSDispatcher) AddRoute(route string, handler HTTPHandlerFunc) {
 	h.mux.HandleFunc(route, handler)
 }
@@ -2,8 +2,13 @@ package httpsdispatcher_test
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -22,7 +27,7 @@ var _ = Describe("HTTPSDispatcher", func() {
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 		serverURL, err := url.Parse("https://127.0.0.1:7788")
 		Expect(err).ToNot(HaveOccurred())
-		dispatcher = boshdispatcher.NewHTTPSDispatcher(serverURL, logger)
+		dispatcher = boshdispatcher.NewHTTPSDispatcher(serverURL, boshdispatcher.Options{}, logger)
 		go dispatcher.Start()
 		time.Sleep(1 * time.Second)
 	})
@@ -71,7 +76,7 @@ var _ = Describe("HTTPSDispatcher", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
-	It("does allow connections using TLSv1", func() {
+	It("does not allow connections using TLSv1", func() {
 		handler := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) }
 		dispatcher.AddRoute("/example", handler)
 
@@ -82,10 +87,10 @@ var _ = Describe("HTTPSDispatcher", func() {
 		}
 		client := getHTTPClientWithConfig(tlsConfig)
 		_, err := client.Get("https://127.0.0.1:7788/example")
-		Expect(err).ToNot(HaveOccurred())
+		Expect(err).To(HaveOccurred())
 	})
 
-	It("does allow connections using TLSv1.1", func() {
+	It("does not allow connections using TLSv1.1", func() {
 		handler := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) }
 		dispatcher.AddRoute("/example", handler)
 
@@ -96,7 +101,7 @@ var _ = Describe("HTTPSDispatcher", func() {
 		}
 		client := getHTTPClientWithConfig(tlsConfig)
 		_, err := client.Get("https://127.0.0.1:7788/example")
-		Expect(err).ToNot(HaveOccurred())
+		Expect(err).To(HaveOccurred())
 	})
 
 	It("does allow connections using TLSv1.2", func() {
@@ -119,6 +124,8 @@ var _ = Describe("HTTPSDispatcher", func() {
 
 		tlsConfig := &tls.Config{
 			InsecureSkipVerify: true,
+			// Cipher suites of TLSv1.3 cannot be configured
+			MaxVersion: tls.VersionTLS12,
 			CipherSuites: []uint16{
 				tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
 				tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
@@ -135,6 +142,8 @@ var _ = Describe("HTTPSDispatcher", func() {
 
 		tlsConfig := &tls.Config{
 			InsecureSkipVerify: true,
+			// Cipher suites of TLSv1.3 cannot be configured
+			MaxVersion: tls.VersionTLS12,
 			CipherSuites: []uint16{
 				tls.TLS_RSA_WITH_RC4_128_SHA,
 				tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
@@ -146,12 +155,14 @@ var _ = Describe("HTTPSDispatcher", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
-	It("does allow connections using AES ciphers", func() {
+	It("does not allow connections using CBC ciphers", func() {
 		handler := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) }
 		dispatcher.AddRoute("/example", handler)
 
 		tlsConfig := &tls.Config{
 			InsecureSkipVerify: true,
+			// Cipher suites of TLSv1.3 cannot be configured
+			MaxVersion: tls.VersionTLS12,
 			CipherSuites: []uint16{
 				tls.TLS_RSA_WITH_AES_128_CBC_SHA,
 				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
@@ -159,8 +170,26 @@ var _ = Describe("HTTPSDispatcher", func() {
 				tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
 				tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
 				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			},
+		}
+		client := getHTTPClientWithConfig(tlsConfig)
+		_, err := client.Get("https://127.0.0.1:7788/example")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("does allow connections using AES GCM ciphers", func() {
+		handler := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) }
+		dispatcher.AddRoute("/example", handler)
+
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: true,
+			// Cipher suites of TLSv1.3 cannot be configured
+			MaxVersion: tls.VersionTLS12,
+			CipherSuites: []uint16{
 				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
 				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
 			},
 		}
 		client := getHTTPClientWithConfig(tlsConfig)
@@ -178,9 +207,8 @@ func getHTTPClient() http.Client {
 			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
 			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
 		},
-		// SSLv3 and TLSv1.0 are considered weak
-		// TLS1.1 does not support GCM, so it won't actually be used
-		MinVersion: tls.VersionTLS11,
+		// SSLv3, TLSv1.0 and TLSv1.1 are considered weak
+		MinVersion: tls.VersionTLS12,
 		MaxVersion: tls.VersionTLS12,
 	}
 	return getHTTPClientWithConfig(tlsConfig)
@@ -190,3 +218,156 @@ func getHTTPClientWithConfig(tlsConfig *tls.Config) http.Client {
 	httpTransport := &http.Transport{TLSClientConfig: tlsConfig}
 	return http.Client{Transport: httpTransport}
 }
+
+var _ = Describe("HTTPSDispatcher with client certificates", func() {
+	var (
+		dir        string
+		ca         testCert
+		serverCert testCert
+		options    boshdispatcher.Options
+		dispatcher *boshdispatcher.HTTPSDispatcher
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "https-dispatcher")
+		Expect(err).ToNot(HaveOccurred())
+
+		ca = newTestCert(pkix.Name{CommonName: "fake-ca"}, nil, true)
+		serverCert = newTestCert(pkix.Name{CommonName: "fake-agent"}, &ca, false)
+
+		options = boshdispatcher.Options{
+			CertPath:     filepath.Join(dir, "agent.cert"),
+			KeyPath:      filepath.Join(dir, "agent.key"),
+			ClientCA:     ca.certPEM,
+			AllowedNames: []string{"o=bosh,cn=director"},
+		}
+
+		writeTestCert(serverCert, options.CertPath, options.KeyPath, time.Now().Add(-time.Minute))
+	})
+
+	startDispatcher := func() {
+		serverURL, err := url.Parse("https://127.0.0.1:7789")
+		Expect(err).ToNot(HaveOccurred())
+
+		dispatcher = boshdispatcher.NewHTTPSDispatcher(serverURL, options, boshlog.NewLogger(boshlog.LevelNone))
+		dispatcher.AddRoute("/example", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) })
+
+		go dispatcher.Start()
+		time.Sleep(1 * time.Second)
+	}
+
+	AfterEach(func() {
+		if dispatcher != nil {
+			dispatcher.Stop()
+			time.Sleep(1 * time.Second)
+		}
+		os.RemoveAll(dir)
+	})
+
+	clientWithCert := func(clientCert *testCert) http.Client {
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(ca.cert)
+
+		tlsConfig := &tls.Config{RootCAs: rootCAs}
+
+		if clientCert != nil {
+			tlsConfig.Certificates = []tls.Certificate{{
+				Certificate: [][]byte{clientCert.cert.Raw},
+				PrivateKey:  clientCert.key,
+			}}
+		}
+
+		return getHTTPClientWithConfig(tlsConfig)
+	}
+
+	It("allows clients presenting certificate signed by CA with allowed name", func() {
+		startDispatcher()
+
+		clientCert := newTestCert(pkix.Name{Organization: []string{"bosh"}, CommonName: "director"}, &ca, false)
+		client := clientWithCert(&clientCert)
+
+		response, err := client.Get("https://127.0.0.1:7789/example")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(200))
+	})
+
+	It("does not allow clients without certificate", func() {
+		startDispatcher()
+
+		client := clientWithCert(nil)
+
+		_, err := client.Get("https://127.0.0.1:7789/example")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("does not allow clients presenting certificate with name that is not allowed", func() {
+		startDispatcher()
+
+		clientCert := newTestCert(pkix.Name{Organization: []string{"bosh"}, CommonName: "intruder"}, &ca, false)
+		client := clientWithCert(&clientCert)
+
+		_, err := client.Get("https://127.0.0.1:7789/example")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("does not allow clients presenting certificate signed by another CA", func() {
+		startDispatcher()
+
+		otherCA := newTestCert(pkix.Name{CommonName: "fake-other-ca"}, nil, true)
+		clientCert := newTestCert(pkix.Name{Organization: []string{"bosh"}, CommonName: "director"}, &otherCA, false)
+		client := clientWithCert(&clientCert)
+
+		_, err := client.Get("https://127.0.0.1:7789/example")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("allows any name signed by CA when allowed names are not set", func() {
+		options.AllowedNames = nil
+		startDispatcher()
+
+		clientCert := newTestCert(pkix.Name{CommonName: "anyone"}, &ca, false)
+		client := clientWithCert(&clientCert)
+
+		response, err := client.Get("https://127.0.0.1:7789/example")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(200))
+	})
+
+	It("serves rotated certificate without restarting", func() {
+		startDispatcher()
+
+		clientCert := newTestCert(pkix.Name{Organization: []string{"bosh"}, CommonName: "director"}, &ca, false)
+
+		rotatedCert := newTestCert(pkix.Name{CommonName: "fake-rotated-agent"}, &ca, false)
+		writeTestCert(rotatedCert, options.CertPath, options.KeyPath, time.Now())
+
+		client := clientWithCert(&clientCert)
+
+		response, err := client.Get("https://127.0.0.1:7789/example")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.TLS.PeerCertificates[0].Subject.CommonName).To(Equal("fake-rotated-agent"))
+	})
+
+	It("fails to start when allowed name cannot be parsed", func() {
+		options.AllowedNames = []string{"fake-field=value"}
+
+		serverURL, err := url.Parse("https://127.0.0.1:7789")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = boshdispatcher.NewHTTPSDispatcher(serverURL, options, boshlog.NewLogger(boshlog.LevelNone)).Start()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Parsing allowed name 'fake-field=value'"))
+	})
+
+	It("fails to start when certificate cannot be loaded", func() {
+		options.CertPath = filepath.Join(dir, "missing.cert")
+
+		serverURL, err := url.Parse("https://127.0.0.1:7789")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = boshdispatcher.NewHTTPSDispatcher(serverURL, options, boshlog.NewLogger(boshlog.LevelNone)).Start()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Loading agent SSL cert"))
+	})
+})
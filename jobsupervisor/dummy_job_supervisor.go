@@ -30,6 +30,10 @@ func (s *dummyJobSupervisor) Status() (status string) {
 	return s.status
 }
 
+func (s *dummyJobSupervisor) Processes() ([]Process, error) {
+	return []Process{}, nil
+}
+
 func (s *dummyJobSupervisor) AddJob(jobName string, jobIndex int, configPath string) error {
 	return nil
 }
@@ -48,6 +48,10 @@ func (d *dummyNatsJobSupervisor) Status() string {
 	return d.status
 }
 
+func (d *dummyNatsJobSupervisor) Processes() ([]Process, error) {
+	return []Process{}, nil
+}
+
 func (d *dummyNatsJobSupervisor) MonitorJobFailures(handler JobFailureHandler) error {
 	d.jobFailureHandler = handler
 
@@ -6,8 +6,9 @@ import (
 )
 
 type FakeJobSupervisor struct {
-	Reloaded  bool
-	ReloadErr error
+	Reloaded       bool
+	ReloadErr      error
+	ReloadCallBack func()
 
 	AddJobArgs []AddJobArgs
 
@@ -25,6 +26,9 @@ type FakeJobSupervisor struct {
 
 	StatusStatus string
 
+	ProcessesProcesses []boshjobsuper.Process
+	ProcessesErr       error
+
 	JobFailureAlert *boshalert.MonitAlert
 }
 
@@ -40,6 +44,11 @@ func NewFakeJobSupervisor() *FakeJobSupervisor {
 
 func (m *FakeJobSupervisor) Reload() error {
 	m.Reloaded = true
+
+	if m.ReloadCallBack != nil {
+		m.ReloadCallBack()
+	}
+
 	return m.ReloadErr
 }
 
@@ -77,6 +86,10 @@ func (m *FakeJobSupervisor) Status() string {
 	return m.StatusStatus
 }
 
+func (m *FakeJobSupervisor) Processes() ([]boshjobsuper.Process, error) {
+	return m.ProcessesProcesses, m.ProcessesErr
+}
+
 func (m *FakeJobSupervisor) MonitorJobFailures(handler boshjobsuper.JobFailureHandler) error {
 	if m.JobFailureAlert != nil {
 		handler(*m.JobFailureAlert)
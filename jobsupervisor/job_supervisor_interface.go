@@ -21,9 +21,41 @@ type JobSupervisor interface {
 
 	Status() string
 
+	// Processes returns state and resource usage of every job process
+	Processes() ([]Process, error)
+
 	// Job management
 	AddJob(jobName string, jobIndex int, configPath string) error
 	RemoveAllJobs() error
 
 	MonitorJobFailures(handler JobFailureHandler) error
 }
+
+type Process struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+
+	// Zero when process is not running
+	PID    int           `json:"pid"`
+	Uptime ProcessUptime `json:"uptime"`
+
+	// Number of times process was restarted since agent started
+	Restarts int `json:"restarts"`
+
+	// Include children of the process
+	CPU    ProcessCPU    `json:"cpu"`
+	Memory ProcessMemory `json:"mem"`
+}
+
+type ProcessUptime struct {
+	Secs uint64 `json:"secs"`
+}
+
+type ProcessCPU struct {
+	Total float64 `json:"total"`
+}
+
+type ProcessMemory struct {
+	Kb      uint64  `json:"kb"`
+	Percent float64 `json:"percent"`
+}
@@ -25,6 +25,22 @@ type serviceTag struct {
 	Name    string   `xml:"name,attr"`
 	Status  int      `xml:"status"`
 	Monitor int      `xml:"monitor"`
+
+	// Only set for process services that are running
+	PID    int              `xml:"pid"`
+	Uptime uint64           `xml:"uptime"`
+	Memory serviceMemoryTag `xml:"memory"`
+	CPU    serviceCPUTag    `xml:"cpu"`
+}
+
+// Totals include children of the process
+type serviceMemoryTag struct {
+	KilobyteTotal uint64  `xml:"kilobytetotal"`
+	PercentTotal  float64 `xml:"percenttotal"`
+}
+
+type serviceCPUTag struct {
+	PercentTotal float64 `xml:"percenttotal"`
 }
 
 type serviceGroupsTag struct {
@@ -84,8 +100,15 @@ func (status status) ServicesInGroup(name string) (services []Service) {
 	for _, serviceTag := range status.Services.Services {
 		if serviceGroupTag.Contains(serviceTag.Name) {
 			service := Service{
+				Name:      serviceTag.Name,
 				Monitored: serviceTag.Monitor > 0,
 				Status:    serviceTag.StatusString(),
+
+				PID:           serviceTag.PID,
+				UptimeSeconds: serviceTag.Uptime,
+				MemoryKb:      serviceTag.Memory.KilobyteTotal,
+				MemoryPercent: serviceTag.Memory.PercentTotal,
+				CPUPercent:    serviceTag.CPU.PercentTotal,
 			}
 
 			services = append(services, service)
@@ -6,6 +6,15 @@ type Status interface {
 }
 
 type Service struct {
+	Name      string
 	Monitored bool
 	Status    string
+
+	// Resource usage of the process and its children
+	// as of last time monit checked it
+	PID           int
+	UptimeSeconds uint64
+	MemoryKb      uint64
+	MemoryPercent float64
+	CPUPercent    float64
 }
@@ -14,40 +14,46 @@ import (
 )
 
 var _ = Describe("status", func() {
+	getStatus := func(fixturePath string) Status {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := io.Copy(w, bytes.NewReader(readFixture(fixturePath)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.Method).To(Equal("GET"))
+			Expect(r.URL.Path).To(Equal("/_status2"))
+			Expect(r.URL.Query().Get("format")).To(Equal("xml"))
+		})
+
+		ts := httptest.NewServer(handler)
+		defer ts.Close()
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		httpClient := http.DefaultClient
+
+		client := NewHTTPClient(
+			ts.Listener.Addr().String(),
+			"fake-user",
+			"fake-pass",
+			httpClient,
+			httpClient,
+			logger,
+		)
+
+		status, err := client.Status()
+		Expect(err).ToNot(HaveOccurred())
+
+		return status
+	}
+
 	Describe("ServicesInGroup", func() {
 		It("returns list of service", func() {
-			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				_, err := io.Copy(w, bytes.NewReader(readFixture(statusWithMultipleServiceFixturePath)))
-				Expect(err).ToNot(HaveOccurred())
-				Expect(r.Method).To(Equal("GET"))
-				Expect(r.URL.Path).To(Equal("/_status2"))
-				Expect(r.URL.Query().Get("format")).To(Equal("xml"))
-			})
-
-			ts := httptest.NewServer(handler)
-			defer ts.Close()
-
-			logger := boshlog.NewLogger(boshlog.LevelNone)
-
-			httpClient := http.DefaultClient
-
-			client := NewHTTPClient(
-				ts.Listener.Addr().String(),
-				"fake-user",
-				"fake-pass",
-				httpClient,
-				httpClient,
-				logger,
-			)
-
-			status, err := client.Status()
-			Expect(err).ToNot(HaveOccurred())
+			status := getStatus(statusWithMultipleServiceFixturePath)
 
 			expectedServices := []Service{
-				Service{Monitored: true, Status: "running"},
-				Service{Monitored: false, Status: "unknown"},
-				Service{Monitored: true, Status: "starting"},
-				Service{Monitored: true, Status: "failing"},
+				Service{Name: "running-service", Monitored: true, Status: "running"},
+				Service{Name: "unmonitored-service", Monitored: false, Status: "unknown"},
+				Service{Name: "starting-service", Monitored: true, Status: "starting"},
+				Service{Name: "failing-service", Monitored: true, Status: "failing"},
 			}
 
 			services := status.ServicesInGroup("vcap")
@@ -57,5 +63,22 @@ var _ = Describe("status", func() {
 				Expect(expectedService).To(Equal(services[i]))
 			}
 		})
+
+		It("returns resource usage of processes and their children", func() {
+			status := getStatus(statusFixturePath)
+
+			Expect(status.ServicesInGroup("vcap")).To(Equal([]Service{
+				Service{
+					Name:          "dummy",
+					Monitored:     true,
+					Status:        "running",
+					PID:           1,
+					UptimeSeconds: 880183,
+					MemoryKb:      4004,
+					MemoryPercent: 0.1,
+					CPUPercent:    2.5,
+				},
+			}))
+		})
 	})
 })
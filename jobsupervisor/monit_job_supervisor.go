@@ -3,12 +3,14 @@ package jobsupervisor
 import (
 	"fmt"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/pivotal/go-smtpd/smtpd"
 
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
 	boshmonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -27,6 +29,17 @@ type monitJobSupervisor struct {
 	jobFailuresServerPort int
 
 	reloadOptions MonitReloadOptions
+
+	reloadAttempts boshmetrics.Counter
+
+	// Shared by copies of supervisor since its methods have value receivers
+	restarts *processRestarts
+}
+
+// processRestarts counts restarts monit reported in alerts by service name
+type processRestarts struct {
+	counts map[string]int
+	lock   sync.Mutex
 }
 
 type MonitReloadOptions struct {
@@ -49,6 +62,7 @@ func NewMonitJobSupervisor(
 	dirProvider boshdir.Provider,
 	jobFailuresServerPort int,
 	reloadOptions MonitReloadOptions,
+	registry *boshmetrics.Registry,
 ) JobSupervisor {
 	return monitJobSupervisor{
 		fs:          fs,
@@ -60,6 +74,13 @@ func NewMonitJobSupervisor(
 		jobFailuresServerPort: jobFailuresServerPort,
 
 		reloadOptions: reloadOptions,
+
+		reloadAttempts: registry.Counter(
+			"bosh_agent_monit_reload_attempts_total",
+			"Number of times `monit reload` was executed.",
+		),
+
+		restarts: &processRestarts{counts: map[string]int{}},
 	}
 }
 
@@ -75,6 +96,8 @@ func (m monitJobSupervisor) Reload() error {
 	// so it's ideal for MaxCheckTries * DelayBetweenCheckTries to be greater than 1 sec
 	// because monit incarnation id is just a timestamp with 1 sec resolution.
 	for reloadI := 0; reloadI < m.reloadOptions.MaxTries; reloadI++ {
+		m.reloadAttempts.Inc()
+
 		// Exit code or output cannot be trusted
 		_, _, _, err := m.runner.RunCommand("monit", "reload")
 		if err != nil {
@@ -181,6 +204,32 @@ func (m monitJobSupervisor) Status() (status string) {
 	return
 }
 
+func (m monitJobSupervisor) Processes() ([]Process, error) {
+	monitStatus, err := m.client.Status()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting monit status")
+	}
+
+	processes := []Process{}
+
+	for _, service := range monitStatus.ServicesInGroup("vcap") {
+		processes = append(processes, Process{
+			Name:     service.Name,
+			State:    service.Status,
+			PID:      service.PID,
+			Uptime:   ProcessUptime{Secs: service.UptimeSeconds},
+			Restarts: m.restarts.Count(service.Name),
+			CPU:      ProcessCPU{Total: service.CPUPercent},
+			Memory: ProcessMemory{
+				Kb:      service.MemoryKb,
+				Percent: service.MemoryPercent,
+			},
+		})
+	}
+
+	return processes, nil
+}
+
 func (m monitJobSupervisor) getIncarnation() (int, error) {
 	monitStatus, err := m.client.Status()
 	if err != nil {
@@ -212,10 +261,18 @@ func (m monitJobSupervisor) RemoveAllJobs() error {
 }
 
 func (m monitJobSupervisor) MonitorJobFailures(handler JobFailureHandler) (err error) {
+	// Monit does not keep track of restarts so they are counted as it alerts about them
+	countingHandler := func(alert boshalert.MonitAlert) error {
+		if alert.Action == "restart" {
+			m.restarts.Add(alert.Service)
+		}
+		return handler(alert)
+	}
+
 	alertHandler := func(smtpd.Connection, smtpd.MailAddress) (env smtpd.Envelope, err error) {
 		env = &alertEnvelope{
 			new(smtpd.BasicEnvelope),
-			handler,
+			countingHandler,
 			new(boshalert.MonitAlert),
 		}
 		return
@@ -232,3 +289,17 @@ func (m monitJobSupervisor) MonitorJobFailures(handler JobFailureHandler) (err e
 	}
 	return
 }
+
+func (r *processRestarts) Add(serviceName string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.counts[serviceName]++
+}
+
+func (r *processRestarts) Count(serviceName string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.counts[serviceName]
+}
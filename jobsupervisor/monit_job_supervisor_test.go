@@ -14,6 +14,7 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshmonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
 	fakemonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit/fakes"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -27,6 +28,7 @@ var _ = Describe("monitJobSupervisor", func() {
 		logger                boshlog.Logger
 		dirProvider           boshdir.Provider
 		jobFailuresServerPort int
+		registry              *boshmetrics.Registry
 		monit                 JobSupervisor
 	)
 
@@ -44,6 +46,7 @@ var _ = Describe("monitJobSupervisor", func() {
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 		dirProvider = boshdir.NewProvider("/var/vcap")
 		jobFailuresServerPort = getJobFailureServerPort()
+		registry = boshmetrics.NewRegistry()
 
 		monit = NewMonitJobSupervisor(
 			fs,
@@ -57,6 +60,7 @@ var _ = Describe("monitJobSupervisor", func() {
 				MaxCheckTries:          10,
 				DelayBetweenCheckTries: 0 * time.Millisecond,
 			},
+			registry,
 		)
 	})
 
@@ -126,6 +130,21 @@ var _ = Describe("monitJobSupervisor", func() {
 			Expect(client.StatusCalledTimes).To(Equal(1 + 30)) // old incarnation + new incarnation checks
 		})
 
+		It("counts every time monit reload is executed", func() {
+			client.Incarnations = []int{1, 1, 1, 2}
+			client.StatusStatus = fakemonit.FakeMonitStatus{Incarnation: 1}
+
+			err := monit.Reload()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(registry.Families()).To(ContainElement(boshmetrics.Family{
+				Name:    "bosh_agent_monit_reload_attempts_total",
+				Help:    "Number of times `monit reload` was executed.",
+				Type:    boshmetrics.TypeCounter,
+				Samples: []boshmetrics.Sample{{Labels: []boshmetrics.Label{}, Value: 1}},
+			}))
+		})
+
 		It("is successful if the incarnation id is different (does not matter if < or >)", func() {
 			client.Incarnations = []int{2, 2, 1} // different and less than old one
 			client.StatusStatus = fakemonit.FakeMonitStatus{
@@ -229,6 +248,86 @@ var _ = Describe("monitJobSupervisor", func() {
 		})
 	})
 
+	Describe("Processes", func() {
+		It("returns state and resource usage of vcap services", func() {
+			client.StatusStatus = fakemonit.FakeMonitStatus{
+				Services: []boshmonit.Service{
+					boshmonit.Service{
+						Name:          "fake-running-process",
+						Monitored:     true,
+						Status:        "running",
+						PID:           123,
+						UptimeSeconds: 456,
+						MemoryKb:      2048,
+						MemoryPercent: 1.5,
+						CPUPercent:    2.5,
+					},
+					boshmonit.Service{
+						Name:      "fake-failing-process",
+						Monitored: true,
+						Status:    "failing",
+					},
+				},
+			}
+
+			processes, err := monit.Processes()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(processes).To(Equal([]Process{
+				Process{
+					Name:   "fake-running-process",
+					State:  "running",
+					PID:    123,
+					Uptime: ProcessUptime{Secs: 456},
+					CPU:    ProcessCPU{Total: 2.5},
+					Memory: ProcessMemory{Kb: 2048, Percent: 1.5},
+				},
+				Process{
+					Name:  "fake-failing-process",
+					State: "failing",
+				},
+			}))
+		})
+
+		It("returns error when monit status cannot be fetched", func() {
+			client.StatusErr = errors.New("fake-monit-client-error")
+
+			_, err := monit.Processes()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-monit-client-error"))
+		})
+
+		It("counts restarts monit alerted about", func() {
+			handledAlerts := make(chan boshalert.MonitAlert, 2)
+
+			go monit.MonitorJobFailures(func(alert boshalert.MonitAlert) error {
+				handledAlerts <- alert
+				return nil
+			})
+
+			for _, action := range []string{"restart", "alert", "restart"} {
+				msg := fmt.Sprintf("Message-id: <1304319946.0@localhost>\n Service: nats\n Event: does not exist\n Action: %s", action)
+
+				err := doJobFailureEmail(msg, jobFailuresServerPort)
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(handledAlerts).Should(Receive())
+			}
+
+			client.StatusStatus = fakemonit.FakeMonitStatus{
+				Services: []boshmonit.Service{
+					boshmonit.Service{Name: "nats", Monitored: true, Status: "running"},
+					boshmonit.Service{Name: "other", Monitored: true, Status: "running"},
+				},
+			}
+
+			processes, err := monit.Processes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(processes[0].Restarts).To(Equal(2))
+			Expect(processes[1].Restarts).To(Equal(0))
+		})
+	})
+
 	Describe("MonitorJobFailures", func() {
 		It("monitor job failures", func() {
 			var handledAlert boshalert.MonitAlert
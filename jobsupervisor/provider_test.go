@@ -9,6 +9,7 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	fakemonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit/fakes"
 	fakembus "github.com/cloudfoundry/bosh-agent/mbus/fakes"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -23,6 +24,7 @@ func init() {
 			dirProvider           boshdir.Provider
 			jobFailuresServerPort int
 			handler               *fakembus.FakeHandler
+			registry              *boshmetrics.Registry
 			provider              Provider
 		)
 
@@ -33,6 +35,7 @@ func init() {
 			dirProvider = boshdir.NewProvider("/fake-base-dir")
 			jobFailuresServerPort = 2825
 			handler = &fakembus.FakeHandler{}
+			registry = boshmetrics.NewRegistry()
 
 			provider = NewProvider(
 				platform,
@@ -40,6 +43,7 @@ func init() {
 				logger,
 				dirProvider,
 				handler,
+				registry,
 			)
 		})
 
@@ -59,6 +63,7 @@ func init() {
 					MaxCheckTries:          6,
 					DelayBetweenCheckTries: 5 * time.Second,
 				},
+				registry,
 			)
 			Expect(actualSupervisor).To(Equal(expectedSupervisor))
 		})
@@ -0,0 +1,74 @@
+package mbus
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type Backoff interface {
+	// Next returns delay before next attempt and increases following delays
+	Next() time.Duration
+
+	// Reset makes following delays start from initial delay again
+	Reset()
+}
+
+type exponentialBackoff struct {
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	delay        time.Duration
+	rand         *rand.Rand
+	lock         sync.Mutex
+}
+
+// NewExponentialBackoff doubles delay after every attempt up to maxDelay.
+// Returned delays are randomly picked between half of the delay and the delay
+// so that agents disconnected at the same time do not reconnect all at once.
+func NewExponentialBackoff(initialDelay, maxDelay time.Duration, rand *rand.Rand) Backoff {
+	if maxDelay < initialDelay {
+		maxDelay = initialDelay
+	}
+
+	return &exponentialBackoff{
+		initialDelay: initialDelay,
+		maxDelay:     maxDelay,
+		delay:        initialDelay,
+		rand:         rand,
+	}
+}
+
+func (b *exponentialBackoff) Next() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delay := b.delay
+
+	b.delay *= 2
+	if b.delay > b.maxDelay {
+		b.delay = b.maxDelay
+	}
+
+	halfDelay := delay / 2
+	if halfDelay <= 0 {
+		return delay
+	}
+
+	return halfDelay + time.Duration(b.rand.Int63n(int64(delay-halfDelay)+1))
+}
+
+func (b *exponentialBackoff) Reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.delay = b.initialDelay
+}
+
+// newReconnectBackoff is used by transports that reconnect to message bus
+func newReconnectBackoff(options Options) Backoff {
+	return NewExponentialBackoff(
+		options.ReconnectInitialDelay(),
+		options.ReconnectMaxDelay(),
+		rand.New(rand.NewSource(time.Now().UnixNano())),
+	)
+}
@@ -0,0 +1,66 @@
+package mbus_test
+
+import (
+	"math/rand"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+)
+
+var _ = Describe("exponentialBackoff", func() {
+	var (
+		backoff Backoff
+	)
+
+	BeforeEach(func() {
+		backoff = NewExponentialBackoff(100*time.Millisecond, time.Second, rand.New(rand.NewSource(1)))
+	})
+
+	It("doubles delay after every attempt up to max delay", func() {
+		expectedDelays := []time.Duration{
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			800 * time.Millisecond,
+			time.Second,
+			time.Second,
+		}
+
+		for _, expectedDelay := range expectedDelays {
+			delay := backoff.Next()
+			Expect(delay).To(BeNumerically(">=", expectedDelay/2))
+			Expect(delay).To(BeNumerically("<=", expectedDelay))
+		}
+	})
+
+	It("randomizes delays", func() {
+		delays := map[time.Duration]bool{}
+
+		for i := 0; i < 10; i++ {
+			delays[backoff.Next()] = true
+			backoff.Reset()
+		}
+
+		Expect(len(delays)).To(BeNumerically(">", 1))
+	})
+
+	It("starts from initial delay after reset", func() {
+		backoff.Next()
+		backoff.Next()
+		backoff.Next()
+
+		backoff.Reset()
+
+		Expect(backoff.Next()).To(BeNumerically("<=", 100*time.Millisecond))
+	})
+
+	It("uses initial delay as max delay when max delay is smaller", func() {
+		backoff = NewExponentialBackoff(time.Second, time.Millisecond, rand.New(rand.NewSource(1)))
+
+		backoff.Next()
+		Expect(backoff.Next()).To(BeNumerically("<=", time.Second))
+	})
+})
@@ -2,11 +2,12 @@ package mbus
 
 import (
 	"net/url"
+	"sync"
 
-	"github.com/cloudfoundry/yagnats"
+	"github.com/pivotal-golang/clock"
 
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
-	boshmicro "github.com/cloudfoundry/bosh-agent/micro"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -14,17 +15,74 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
+// HandlerDeps are given to every handler factory
+// so that transports do not need to be wired in by the agent
+type HandlerDeps struct {
+	SettingsService boshsettings.Service
+	Options         Options
+	TimeService     clock.Clock
+	MetricsRegistry *boshmetrics.Registry
+	Logger          boshlog.Logger
+	Platform        boshplatform.Platform
+	DirProvider     boshdir.Provider
+}
+
+// HandlerFactory builds handler for message bus URL
+// with the scheme factory was registered for
+type HandlerFactory func(mbusURL *url.URL, deps HandlerDeps) (boshhandler.Handler, error)
+
+var (
+	handlerFactories     = map[string]HandlerFactory{}
+	handlerFactoriesLock sync.RWMutex
+)
+
+// RegisterHandlerFactory makes HandlerProvider use factory for mbus URLs
+// with given scheme. Transports call it from init; it panics when
+// scheme is already taken so that two transports cannot silently replace each other.
+func RegisterHandlerFactory(scheme string, factory HandlerFactory) {
+	handlerFactoriesLock.Lock()
+	defer handlerFactoriesLock.Unlock()
+
+	if factory == nil {
+		panic("mbus: RegisterHandlerFactory factory is nil for scheme " + scheme)
+	}
+
+	if _, found := handlerFactories[scheme]; found {
+		panic("mbus: RegisterHandlerFactory called twice for scheme " + scheme)
+	}
+
+	handlerFactories[scheme] = factory
+}
+
+func handlerFactory(scheme string) (HandlerFactory, bool) {
+	handlerFactoriesLock.RLock()
+	defer handlerFactoriesLock.RUnlock()
+
+	factory, found := handlerFactories[scheme]
+
+	return factory, found
+}
+
 type HandlerProvider struct {
 	settingsService boshsettings.Service
+	options         Options
+	timeService     clock.Clock
+	registry        *boshmetrics.Registry
 	logger          boshlog.Logger
 	handler         boshhandler.Handler
 }
 
 func NewHandlerProvider(
 	settingsService boshsettings.Service,
+	options Options,
+	timeService clock.Clock,
+	registry *boshmetrics.Registry,
 	logger boshlog.Logger,
 ) (p HandlerProvider) {
 	p.settingsService = settingsService
+	p.options = options
+	p.timeService = timeService
+	p.registry = registry
 	p.logger = logger
 	return
 }
@@ -44,16 +102,39 @@ func (p HandlerProvider) Get(
 		return
 	}
 
-	switch mbusURL.Scheme {
-	case "nats":
-		handler = NewNatsHandler(p.settingsService, yagnats.NewClient(), p.logger)
-	case "https":
-		handler = boshmicro.NewHTTPSHandler(mbusURL, p.logger, platform.GetFs(), dirProvider)
-	default:
+	factory, found := handlerFactory(mbusURL.Scheme)
+	if !found {
 		err = bosherr.Errorf("Message Bus Handler with scheme %s could not be found", mbusURL.Scheme)
+		return
+	}
+
+	deps := HandlerDeps{
+		SettingsService: p.settingsService,
+		Options:         p.options,
+		TimeService:     p.timeService,
+		MetricsRegistry: p.registry,
+		Logger:          p.logger,
+		Platform:        platform,
+		DirProvider:     dirProvider,
+	}
+
+	handler, err = factory(mbusURL, deps)
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Building Message Bus Handler with scheme %s", mbusURL.Scheme)
+		return
 	}
 
 	p.handler = handler
 
 	return
 }
+
+// GetLocal returns handler for requests of local clients (e.g. operator scripts)
+// that runs alongside message bus handler
+func (p HandlerProvider) GetLocal(
+	options UnixSocketOptions,
+	platform boshplatform.Platform,
+	dirProvider boshdir.Provider,
+) boshhandler.Handler {
+	return NewUnixSocketHandler(options, platform.GetFs(), dirProvider, p.logger)
+}
@@ -1,26 +1,45 @@
 package mbus_test
 
 import (
+	"errors"
 	gourl "net/url"
 	"reflect"
+	"time"
 
 	"github.com/cloudfoundry/yagnats"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
 	. "github.com/cloudfoundry/bosh-agent/mbus"
+	fakembus "github.com/cloudfoundry/bosh-agent/mbus/fakes"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	"github.com/cloudfoundry/bosh-agent/micro"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
+// Factories can only be registered once per process
+// so tests replace what registered factory does
+var fakeSchemeFactory HandlerFactory
+
+func init() {
+	RegisterHandlerFactory("fake-scheme", func(mbusURL *gourl.URL, deps HandlerDeps) (boshhandler.Handler, error) {
+		return fakeSchemeFactory(mbusURL, deps)
+	})
+}
+
 var _ = Describe("HandlerProvider", func() {
 	var (
 		settingsService *fakesettings.FakeSettingsService
 		platform        *fakeplatform.FakePlatform
 		dirProvider     boshdir.Provider
+		timeService     *fakeclock.FakeClock
 		logger          boshlog.Logger
 		provider        HandlerProvider
 	)
@@ -30,7 +49,8 @@ var _ = Describe("HandlerProvider", func() {
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 		platform = fakeplatform.NewFakePlatform()
 		dirProvider = boshdir.NewProvider("/var/vcap")
-		provider = NewHandlerProvider(settingsService, logger)
+		timeService = fakeclock.NewFakeClock(time.Now())
+		provider = NewHandlerProvider(settingsService, Options{}, timeService, boshmetrics.NewRegistry(), logger)
 	})
 
 	Describe("Get", func() {
@@ -40,10 +60,21 @@ var _ = Describe("HandlerProvider", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			// yagnats.NewClient returns new object every time
-			expectedHandler := NewNatsHandler(settingsService, yagnats.NewClient(), logger)
+			expectedHandler := NewNatsHandler(settingsService, yagnats.NewClient(), nil, nil, timeService, boshmetrics.NewRegistry(), logger)
 			Expect(reflect.TypeOf(handler)).To(Equal(reflect.TypeOf(expectedHandler)))
 		})
 
+		It("returns nats handler for nats+tls and tls schemes", func() {
+			for _, mbusURL := range []string{"nats+tls://lol:4222", "tls://lol:4222"} {
+				settingsService.Settings.Mbus = mbusURL
+				handler, err := NewHandlerProvider(settingsService, Options{}, timeService, boshmetrics.NewRegistry(), logger).Get(platform, dirProvider)
+				Expect(err).ToNot(HaveOccurred())
+
+				expectedHandler := NewNatsHandler(settingsService, yagnats.NewClient(), nil, nil, timeService, boshmetrics.NewRegistry(), logger)
+				Expect(reflect.TypeOf(handler)).To(Equal(reflect.TypeOf(expectedHandler)))
+			}
+		})
+
 		It("returns https handler", func() {
 			url, err := gourl.Parse("https://lol")
 			Expect(err).ToNot(HaveOccurred())
@@ -51,7 +82,78 @@ var _ = Describe("HandlerProvider", func() {
 			settingsService.Settings.Mbus = "https://lol"
 			handler, err := provider.Get(platform, dirProvider)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(handler).To(Equal(micro.NewHTTPSHandler(url, logger, platform.GetFs(), dirProvider)))
+			Expect(handler).To(Equal(micro.NewHTTPSHandler(url, boshdispatcher.Options{}, logger, platform.GetFs(), dirProvider)))
+		})
+
+		It("returns https handler configured with certificates from settings", func() {
+			url, err := gourl.Parse("https://lol")
+			Expect(err).ToNot(HaveOccurred())
+
+			settingsService.Settings.Mbus = "https://lol"
+			settingsService.Settings.Env.Bosh.Mbus.HTTPS = boshsettings.HTTPSEnv{
+				CertPath:     "/fake-cert-path",
+				KeyPath:      "/fake-key-path",
+				ClientCA:     "fake-client-ca",
+				AllowedNames: []string{"o=bosh,cn=director"},
+			}
+
+			handler, err := provider.Get(platform, dirProvider)
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedOptions := boshdispatcher.Options{
+				CertPath:     "/fake-cert-path",
+				KeyPath:      "/fake-key-path",
+				ClientCA:     "fake-client-ca",
+				AllowedNames: []string{"o=bosh,cn=director"},
+			}
+			Expect(handler).To(Equal(micro.NewHTTPSHandler(url, expectedOptions, logger, platform.GetFs(), dirProvider)))
+		})
+
+		It("returns websocket handler for ws and wss schemes", func() {
+			for _, mbusURL := range []string{"ws://lol/agent", "wss://lol/agent"} {
+				settingsService.Settings.Mbus = mbusURL
+				handler, err := NewHandlerProvider(settingsService, Options{}, timeService, boshmetrics.NewRegistry(), logger).Get(platform, dirProvider)
+				Expect(err).ToNot(HaveOccurred())
+
+				expectedHandler := NewWebSocketHandler(settingsService, nil, timeService, boshmetrics.NewRegistry(), logger)
+				Expect(reflect.TypeOf(handler)).To(Equal(reflect.TypeOf(expectedHandler)))
+			}
+		})
+
+		Context("when handler factory is registered", func() {
+			It("returns handler built by factory registered for the scheme", func() {
+				registeredHandler := fakembus.NewFakeHandler()
+
+				var registeredURL *gourl.URL
+
+				fakeSchemeFactory = func(mbusURL *gourl.URL, deps HandlerDeps) (boshhandler.Handler, error) {
+					Expect(deps.SettingsService).To(Equal(settingsService))
+					Expect(deps.Platform).To(Equal(platform))
+					Expect(deps.DirProvider).To(Equal(dirProvider))
+					Expect(deps.TimeService).To(Equal(timeService))
+					registeredURL = mbusURL
+					return registeredHandler, nil
+				}
+
+				settingsService.Settings.Mbus = "fake-scheme://lol:1234"
+
+				handler, err := provider.Get(platform, dirProvider)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(handler).To(Equal(registeredHandler))
+				Expect(registeredURL.Host).To(Equal("lol:1234"))
+			})
+
+			It("returns error if factory fails", func() {
+				fakeSchemeFactory = func(*gourl.URL, HandlerDeps) (boshhandler.Handler, error) {
+					return nil, errors.New("fake-factory-err")
+				}
+
+				settingsService.Settings.Mbus = "fake-scheme://lol"
+
+				_, err := provider.Get(platform, dirProvider)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-factory-err"))
+			})
 		})
 
 		It("returns an error if not supported", func() {
@@ -60,4 +162,27 @@ var _ = Describe("HandlerProvider", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Describe("GetLocal", func() {
+		It("returns unix socket handler using platform file system", func() {
+			options := UnixSocketOptions{Path: "/fake-agent.sock"}
+
+			handler := provider.GetLocal(options, platform, dirProvider)
+			Expect(handler).To(Equal(NewUnixSocketHandler(options, platform.GetFs(), dirProvider, logger)))
+		})
+	})
+})
+
+var _ = Describe("RegisterHandlerFactory", func() {
+	factory := func(*gourl.URL, HandlerDeps) (boshhandler.Handler, error) {
+		return fakembus.NewFakeHandler(), nil
+	}
+
+	It("panics when scheme is already registered", func() {
+		Expect(func() { RegisterHandlerFactory("nats", factory) }).To(Panic())
+	})
+
+	It("panics when factory is nil", func() {
+		Expect(func() { RegisterHandlerFactory("fake-nil-scheme", nil) }).To(Panic())
+	})
 })
@@ -0,0 +1,28 @@
+package mbus
+
+import (
+	"net/url"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
+	boshmicro "github.com/cloudfoundry/bosh-agent/micro"
+)
+
+// HTTPS handler lives in micro package which cannot import mbus
+// so it is registered here on its behalf
+func init() {
+	RegisterHandlerFactory("https", newHTTPSHandlerFromURL)
+}
+
+func newHTTPSHandlerFromURL(mbusURL *url.URL, deps HandlerDeps) (boshhandler.Handler, error) {
+	httpsEnv := deps.SettingsService.GetSettings().Env.Bosh.Mbus.HTTPS
+
+	options := boshdispatcher.Options{
+		CertPath:     httpsEnv.CertPath,
+		KeyPath:      httpsEnv.KeyPath,
+		ClientCA:     httpsEnv.ClientCA,
+		AllowedNames: httpsEnv.AllowedNames,
+	}
+
+	return boshmicro.NewHTTPSHandler(mbusURL, options, deps.Logger, deps.Platform.GetFs(), deps.DirProvider), nil
+}
@@ -0,0 +1,219 @@
+package mbus
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	// DefaultMaxClockSkew is how far timestamp of signed message may be from agent's clock
+	DefaultMaxClockSkew = 60 * time.Second
+
+	// Room left in every signed message for its fields other than payload
+	signedMessageEnvelopeLength = 512
+
+	// Also used as AES-GCM nonce size
+	signedMessageNonceLength = 12
+)
+
+// SignedMessage wraps payload so that receiver can tell it was sent
+// by someone who knows the shared secret and that it was not sent before.
+// HMAC-SHA256 covers timestamp, nonce, encrypted flag and payload.
+// Encrypted payload is sealed with AES-256-GCM using nonce of the message.
+type SignedMessage struct {
+	Timestamp int64  `json:"timestamp"`
+	Nonce     string `json:"nonce"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+	Payload   []byte `json:"payload"`
+	HMAC      string `json:"hmac"`
+}
+
+type MessageSigner struct {
+	signingKey   []byte
+	aead         cipher.AEAD
+	maxClockSkew time.Duration
+	timeService  clock.Clock
+
+	// Nonces of accepted messages that are recent enough to be replayed
+	seenNonces map[string]time.Time
+	lock       sync.Mutex
+}
+
+// NewMessageSigner derives separate signing and encryption keys from the secret.
+// When encrypt is true payloads are encrypted and unencrypted messages are rejected.
+func NewMessageSigner(secret string, encrypt bool, maxClockSkew time.Duration, timeService clock.Clock) (*MessageSigner, error) {
+	if secret == "" {
+		return nil, bosherr.Error("Message signing secret must not be empty")
+	}
+
+	if maxClockSkew <= 0 {
+		maxClockSkew = DefaultMaxClockSkew
+	}
+
+	signer := &MessageSigner{
+		signingKey:   deriveMessageKey(secret, "signing"),
+		maxClockSkew: maxClockSkew,
+		timeService:  timeService,
+		seenNonces:   map[string]time.Time{},
+	}
+
+	if encrypt {
+		block, err := aes.NewCipher(deriveMessageKey(secret, "encryption"))
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Creating cipher")
+		}
+
+		signer.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Creating AEAD")
+		}
+	}
+
+	return signer, nil
+}
+
+// MaxPayloadLength is the longest payload that fits into a signed message of given length
+func (s *MessageSigner) MaxPayloadLength(maxMessageLength int) int {
+	// Payload is base64 encoded which makes it 4/3 times longer
+	maxLength := (maxMessageLength - signedMessageEnvelopeLength) / 4 * 3
+
+	if s.aead != nil {
+		maxLength -= s.aead.Overhead()
+	}
+
+	return maxLength
+}
+
+// Seal signs and optionally encrypts payload
+func (s *MessageSigner) Seal(payload []byte) ([]byte, error) {
+	nonce := make([]byte, signedMessageNonceLength)
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating nonce")
+	}
+
+	msg := SignedMessage{
+		Timestamp: s.timeService.Now().Unix(),
+		Nonce:     hex.EncodeToString(nonce),
+		Payload:   payload,
+	}
+
+	if s.aead != nil {
+		msg.Encrypted = true
+		msg.Payload = s.aead.Seal(nil, nonce, payload, nil)
+	}
+
+	msg.HMAC = hex.EncodeToString(s.sign(msg))
+
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling signed message")
+	}
+
+	return msgBytes, nil
+}
+
+// Open returns payload of signed message after checking its HMAC and freshness
+func (s *MessageSigner) Open(msgBytes []byte) ([]byte, error) {
+	var msg SignedMessage
+
+	err := json.Unmarshal(msgBytes, &msg)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling signed message")
+	}
+
+	if msg.HMAC == "" {
+		return nil, bosherr.Error("Message is not signed")
+	}
+
+	expectedHMAC, err := hex.DecodeString(msg.HMAC)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Decoding message HMAC")
+	}
+
+	if !hmac.Equal(s.sign(msg), expectedHMAC) {
+		return nil, bosherr.Error("Message HMAC does not match")
+	}
+
+	nonce, err := hex.DecodeString(msg.Nonce)
+	if err != nil || len(nonce) != signedMessageNonceLength {
+		return nil, bosherr.Errorf("Message nonce must be %d hex encoded bytes", signedMessageNonceLength)
+	}
+
+	if s.aead != nil && !msg.Encrypted {
+		return nil, bosherr.Error("Message is not encrypted")
+	}
+
+	err = s.checkFreshness(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if !msg.Encrypted {
+		return msg.Payload, nil
+	}
+
+	if s.aead == nil {
+		return nil, bosherr.Error("Message is encrypted but encryption is not enabled")
+	}
+
+	payload, err := s.aead.Open(nil, nonce, msg.Payload, nil)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Decrypting message payload")
+	}
+
+	return payload, nil
+}
+
+func (s *MessageSigner) checkFreshness(msg SignedMessage) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.timeService.Now()
+	sentAt := time.Unix(msg.Timestamp, 0)
+
+	if sentAt.Before(now.Add(-s.maxClockSkew)) || sentAt.After(now.Add(s.maxClockSkew)) {
+		return bosherr.Errorf("Message timestamp %d is more than %s away from agent's clock", msg.Timestamp, s.maxClockSkew)
+	}
+
+	// Messages this old are rejected by timestamp so their nonces can be forgotten
+	for nonce, seenSentAt := range s.seenNonces {
+		if seenSentAt.Before(now.Add(-s.maxClockSkew)) {
+			delete(s.seenNonces, nonce)
+		}
+	}
+
+	if _, found := s.seenNonces[msg.Nonce]; found {
+		return bosherr.Errorf("Message with nonce %s was already received", msg.Nonce)
+	}
+
+	s.seenNonces[msg.Nonce] = sentAt
+
+	return nil
+}
+
+func (s *MessageSigner) sign(msg SignedMessage) []byte {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%d\n%s\n%t\n", msg.Timestamp, msg.Nonce, msg.Encrypted)
+	mac.Write(msg.Payload)
+	return mac.Sum(nil)
+}
+
+func deriveMessageKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("bosh-agent message " + purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,190 @@
+package mbus_test
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+)
+
+var _ = Describe("MessageSigner", func() {
+	var (
+		timeService *fakeclock.FakeClock
+		signer      *MessageSigner
+		payload     []byte
+	)
+
+	BeforeEach(func() {
+		timeService = fakeclock.NewFakeClock(time.Now())
+		payload = []byte(`{"method":"ping","arguments":[],"reply_to":"fake-reply-to"}`)
+	})
+
+	newSigner := func(secret string, encrypt bool) *MessageSigner {
+		signer, err := NewMessageSigner(secret, encrypt, time.Minute, timeService)
+		Expect(err).ToNot(HaveOccurred())
+		return signer
+	}
+
+	seal := func(signer *MessageSigner, payload []byte) []byte {
+		msgBytes, err := signer.Seal(payload)
+		Expect(err).ToNot(HaveOccurred())
+		return msgBytes
+	}
+
+	It("returns error when secret is empty", func() {
+		_, err := NewMessageSigner("", false, time.Minute, timeService)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("when payloads are only signed", func() {
+		BeforeEach(func() {
+			signer = newSigner("fake-secret", false)
+		})
+
+		It("opens payload of message it sealed", func() {
+			openedPayload, err := signer.Open(seal(signer, payload))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(openedPayload).To(Equal(payload))
+		})
+
+		It("signs message with timestamp and nonce", func() {
+			var msg SignedMessage
+			Expect(json.Unmarshal(seal(signer, payload), &msg)).To(Succeed())
+
+			Expect(msg.Timestamp).To(Equal(timeService.Now().Unix()))
+			Expect(msg.Nonce).To(HaveLen(24))
+			Expect(msg.Encrypted).To(BeFalse())
+			Expect(msg.Payload).To(Equal(payload))
+			Expect(msg.HMAC).To(HaveLen(64))
+		})
+
+		It("rejects unsigned message", func() {
+			_, err := signer.Open(payload)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Message is not signed"))
+		})
+
+		It("rejects message signed with another secret", func() {
+			_, err := signer.Open(seal(newSigner("fake-other-secret", false), payload))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Message HMAC does not match"))
+		})
+
+		It("rejects message with tampered payload", func() {
+			var msg SignedMessage
+			Expect(json.Unmarshal(seal(signer, payload), &msg)).To(Succeed())
+
+			msg.Payload = []byte(`{"method":"stop","arguments":[],"reply_to":"fake-reply-to"}`)
+
+			msgBytes, err := json.Marshal(msg)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = signer.Open(msgBytes)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Message HMAC does not match"))
+		})
+
+		It("rejects message with tampered timestamp", func() {
+			var msg SignedMessage
+			Expect(json.Unmarshal(seal(signer, payload), &msg)).To(Succeed())
+
+			msg.Timestamp++
+
+			msgBytes, err := json.Marshal(msg)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = signer.Open(msgBytes)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Message HMAC does not match"))
+		})
+
+		It("rejects replayed message", func() {
+			msgBytes := seal(signer, payload)
+
+			_, err := signer.Open(msgBytes)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = signer.Open(msgBytes)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("was already received"))
+		})
+
+		It("rejects stale message", func() {
+			msgBytes := seal(signer, payload)
+
+			timeService.Increment(2 * time.Minute)
+
+			_, err := signer.Open(msgBytes)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("away from agent's clock"))
+		})
+
+		It("rejects message from the future", func() {
+			futureSigner, err := NewMessageSigner("fake-secret", false, time.Minute, fakeclock.NewFakeClock(timeService.Now().Add(2*time.Minute)))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = signer.Open(seal(futureSigner, payload))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("away from agent's clock"))
+		})
+
+		It("accepts message sent within allowed clock skew", func() {
+			msgBytes := seal(signer, payload)
+
+			timeService.Increment(30 * time.Second)
+
+			_, err := signer.Open(msgBytes)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rejects encrypted message", func() {
+			_, err := signer.Open(seal(newSigner("fake-secret", true), payload))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("encryption is not enabled"))
+		})
+	})
+
+	Context("when payloads are encrypted", func() {
+		BeforeEach(func() {
+			signer = newSigner("fake-secret", true)
+		})
+
+		It("opens payload of message it sealed", func() {
+			openedPayload, err := signer.Open(seal(signer, payload))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(openedPayload).To(Equal(payload))
+		})
+
+		It("does not reveal payload", func() {
+			var msg SignedMessage
+			Expect(json.Unmarshal(seal(signer, payload), &msg)).To(Succeed())
+
+			Expect(msg.Encrypted).To(BeTrue())
+			Expect(string(msg.Payload)).ToNot(ContainSubstring("ping"))
+		})
+
+		It("rejects message that is only signed", func() {
+			_, err := signer.Open(seal(newSigner("fake-secret", false), payload))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Message is not encrypted"))
+		})
+	})
+
+	Describe("MaxPayloadLength", func() {
+		It("leaves room for envelope and encryption", func() {
+			signer = newSigner("fake-secret", true)
+
+			maxPayloadLength := signer.MaxPayloadLength(1024)
+			Expect(maxPayloadLength).To(BeNumerically(">", 0))
+
+			msgBytes := seal(signer, []byte(strings.Repeat("a", maxPayloadLength)))
+			Expect(len(msgBytes)).To(BeNumerically("<=", 1024))
+		})
+	})
+})
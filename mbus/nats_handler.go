@@ -4,20 +4,27 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/yagnats"
+	"github.com/pivotal-golang/clock"
 
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 const (
+	// Longer responses are split into chunks
 	responseMaxLength = 1024 * 1024
 )
 
@@ -32,21 +39,84 @@ type Handler interface {
 type natsHandler struct {
 	settingsService boshsettings.Service
 	client          yagnats.NATSClient
+	outbox          Outbox
+	backoff         Backoff
+	timeService     clock.Clock
 	logger          boshlog.Logger
 	handlerFuncs    []boshhandler.Func
 	logTag          string
+
+	// Set when settings have signing secret
+	signer *MessageSigner
+
+	connected     bool
+	connections   int
+	connectedLock sync.RWMutex
+
+	connectedGauge   boshmetrics.Gauge
+	reconnectCounter boshmetrics.Counter
+	sendMetrics      sendMetrics
+
+	// Keeps messages in order while outbox is being flushed
+	sendLock sync.Mutex
+
+	// Closed once handler is stopped so that first connection is not retried anymore
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func init() {
+	for _, scheme := range []string{"nats", "nats+tls", "tls"} {
+		RegisterHandlerFactory(scheme, newNatsHandlerFromURL)
+	}
+}
+
+func newNatsHandlerFromURL(mbusURL *url.URL, deps HandlerDeps) (boshhandler.Handler, error) {
+	outbox := NewFileOutbox(
+		deps.Platform.GetFs(),
+		filepath.Join(deps.DirProvider.BoshDir(), "mbus_outbox.json"),
+		deps.Options.OutboxMaxAlerts,
+	)
+
+	return NewNatsHandler(
+		deps.SettingsService,
+		yagnats.NewClient(),
+		outbox,
+		newReconnectBackoff(deps.Options),
+		deps.TimeService,
+		deps.MetricsRegistry,
+		deps.Logger,
+	), nil
 }
 
 func NewNatsHandler(
 	settingsService boshsettings.Service,
 	client yagnats.NATSClient,
+	outbox Outbox,
+	backoff Backoff,
+	timeService clock.Clock,
+	registry *boshmetrics.Registry,
 	logger boshlog.Logger,
 ) Handler {
 	return &natsHandler{
 		settingsService: settingsService,
 		client:          client,
+		outbox:          outbox,
+		backoff:         backoff,
+		timeService:     timeService,
 		logger:          logger,
 		logTag:          "NATS Handler",
+		stopCh:          make(chan struct{}),
+
+		connectedGauge: registry.Gauge(
+			"bosh_agent_mbus_connected",
+			"Whether agent is connected to message bus (1) or not (0).",
+		),
+		reconnectCounter: registry.Counter(
+			"bosh_agent_mbus_reconnects_total",
+			"Number of times agent connected to message bus again after losing connection.",
+		),
+		sendMetrics: newSendMetrics(registry),
 	}
 }
 
@@ -66,23 +136,83 @@ func (h *natsHandler) Run(handlerFunc boshhandler.Func) error {
 func (h *natsHandler) Start(handlerFunc boshhandler.Func) error {
 	h.RegisterAdditionalFunc(handlerFunc)
 
-	connProvider, err := h.getConnectionInfo()
+	connInfo, err := h.getConnectionInfo()
 	if err != nil {
 		return bosherr.WrapError(err, "Getting connection info")
 	}
 
+	settings := h.settingsService.GetSettings()
+
+	signingEnv := settings.Env.Bosh.Mbus.Signing
+	if signingEnv.Secret != "" {
+		maxClockSkew := time.Duration(signingEnv.MaxClockSkewSeconds) * time.Second
+
+		h.signer, err = NewMessageSigner(signingEnv.Secret, signingEnv.Encrypt, maxClockSkew, h.timeService)
+		if err != nil {
+			return bosherr.WrapError(err, "Building message signer")
+		}
+
+		h.logger.Info(h.logTag, "Only accepting signed requests (encrypted=%t)", signingEnv.Encrypt)
+	}
+
+	// Client reconnects and resubscribes on its own once connection is lost
+	connProvider := NewReconnectingConnectionProvider(
+		connInfo,
+		h.backoff,
+		h.timeService,
+		h.handleDisconnected,
+		h.handleConnectionProvided,
+	)
+
+	subject := fmt.Sprintf("agent.%s", settings.AgentID)
+
 	err = h.client.Connect(connProvider)
 	if err != nil {
-		return bosherr.WrapError(err, "Connecting")
+		// Agent keeps running while NATS is unreachable at boot
+		// and messages are sent once first connection succeeds
+		h.logger.Error(h.logTag, "Connecting, retrying in background: %s", err.Error())
+		go h.keepConnecting(connProvider, subject)
+		return nil
 	}
 
-	settings := h.settingsService.GetSettings()
+	return h.subscribe(subject)
+}
 
-	subject := fmt.Sprintf("agent.%s", settings.AgentID)
+// keepConnecting retries first connection until it succeeds or handler is stopped;
+// connection provider spaces out attempts with backoff
+func (h *natsHandler) keepConnecting(connProvider *ReconnectingConnectionProvider, subject string) {
+	for {
+		select {
+		case <-h.stopCh:
+			return
+		default:
+		}
 
+		err := h.client.Connect(connProvider)
+		if err == nil {
+			break
+		}
+
+		h.logger.Error(h.logTag, "Connecting: %s", err.Error())
+	}
+
+	select {
+	case <-h.stopCh:
+		h.client.Disconnect()
+		return
+	default:
+	}
+
+	err := h.subscribe(subject)
+	if err != nil {
+		h.logger.Error(h.logTag, err.Error())
+	}
+}
+
+func (h *natsHandler) subscribe(subject string) error {
 	h.logger.Info(h.logTag, "Subscribing to %s", subject)
 
-	_, err = h.client.Subscribe(subject, func(natsMsg *yagnats.Message) {
+	_, err := h.client.Subscribe(subject, func(natsMsg *yagnats.Message) {
 		for _, handlerFunc := range h.handlerFuncs {
 			h.handleNatsMsg(natsMsg, handlerFunc)
 		}
@@ -92,6 +222,8 @@ func (h *natsHandler) Start(handlerFunc boshhandler.Func) error {
 		return bosherr.WrapErrorf(err, "Subscribing to %s", subject)
 	}
 
+	h.handleConnected()
+
 	return nil
 }
 
@@ -101,30 +233,220 @@ func (h *natsHandler) RegisterAdditionalFunc(handlerFunc boshhandler.Func) {
 	h.handlerFuncs = append(h.handlerFuncs, handlerFunc)
 }
 
-func (h natsHandler) Send(target boshhandler.Target, topic boshhandler.Topic, message interface{}) error {
+// Send does not fail when NATS is unreachable; alerts and heartbeats are put
+// into outbox instead and other messages are dropped
+func (h *natsHandler) Send(target boshhandler.Target, topic boshhandler.Topic, message interface{}) error {
 	bytes, err := json.Marshal(message)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Marshalling message (target=%s, topic=%s): %#v", target, topic, message)
 	}
 
 	h.logger.Info(h.logTag, "Sending %s message '%s'", target, topic)
-	h.logger.DebugWithDetails(h.logTag, "Message Payload", string(bytes))
+	h.logger.DebugWithDetails(h.logTag, "Message Payload", string(boshhandler.RedactJSON(bytes)))
 
 	settings := h.settingsService.GetSettings()
 
 	subject := fmt.Sprintf("%s.agent.%s.%s", target, topic, settings.AgentID)
-	return h.client.Publish(subject, bytes)
+
+	if !KeptInOutbox(topic) {
+		h.sendWithoutOutbox(target, topic, subject, bytes)
+		return nil
+	}
+
+	h.sendLock.Lock()
+	defer h.sendLock.Unlock()
+
+	outboxMessage := OutboxMessage{Topic: topic, Subject: subject, Payload: bytes}
+
+	if !h.isConnected() {
+		h.logger.Info(h.logTag, "Not connected, adding %s message '%s' to outbox", target, topic)
+		return h.addToOutbox(outboxMessage)
+	}
+
+	// Messages must not overtake the ones that are still waiting in the outbox
+	queuedMessages, err := h.outbox.Messages()
+	if err != nil {
+		return bosherr.WrapError(err, "Getting outbox messages")
+	}
+
+	if len(queuedMessages) > 0 {
+		err = h.addToOutbox(outboxMessage)
+		if err != nil {
+			return err
+		}
+
+		h.sendOutboxMessages()
+
+		return nil
+	}
+
+	err = h.client.Publish(subject, bytes)
+	if err != nil {
+		h.logger.Error(h.logTag, "Publishing %s message '%s', adding it to outbox: %s", target, topic, err.Error())
+		h.sendMetrics.Failed(topic)
+		return h.addToOutbox(outboxMessage)
+	}
+
+	h.sendMetrics.Sent(topic)
+
+	return nil
+}
+
+// sendWithoutOutbox does not wait for outbox to be flushed
+// since messages that are not kept in outbox do not need to be ordered after them
+func (h *natsHandler) sendWithoutOutbox(target boshhandler.Target, topic boshhandler.Topic, subject string, bytes []byte) {
+	if !h.isConnected() {
+		h.logger.Info(h.logTag, "Not connected, dropping %s message '%s'", target, topic)
+		h.sendMetrics.Dropped(topic)
+		return
+	}
+
+	err := h.client.Publish(subject, bytes)
+	if err != nil {
+		h.logger.Error(h.logTag, "Publishing %s message '%s', dropping it: %s", target, topic, err.Error())
+		h.sendMetrics.Failed(topic)
+		h.sendMetrics.Dropped(topic)
+		return
+	}
+
+	h.sendMetrics.Sent(topic)
 }
 
-func (h natsHandler) Stop() {
+func (h *natsHandler) Stop() {
+	h.stopOnce.Do(func() { close(h.stopCh) })
 	h.client.Disconnect()
 }
 
-func (h natsHandler) handleNatsMsg(natsMsg *yagnats.Message, handlerFunc boshhandler.Func) {
+func (h *natsHandler) addToOutbox(message OutboxMessage) error {
+	err := h.outbox.Add(message)
+	if err != nil {
+		h.sendMetrics.Dropped(message.Topic)
+		return bosherr.WrapErrorf(err, "Adding message to outbox (subject=%s)", message.Subject)
+	}
+
+	h.sendMetrics.Queued(message.Topic)
+
+	return nil
+}
+
+func (h *natsHandler) handleDisconnected() {
+	h.setConnected(false)
+}
+
+func (h *natsHandler) handleConnectionProvided() {
+	h.connectedLock.Lock()
+	h.connections++
+	reconnected := h.connections > 1
+	h.connectedLock.Unlock()
+
+	if reconnected {
+		h.reconnectCounter.Inc()
+	}
+
+	h.handleConnected()
+}
+
+func (h *natsHandler) handleConnected() {
+	h.setConnected(true)
+
+	// Publishing waits for client to start serving new connection
+	go h.flushOutbox()
+}
+
+func (h *natsHandler) flushOutbox() {
+	h.sendLock.Lock()
+	defer h.sendLock.Unlock()
+
+	h.sendOutboxMessages()
+}
+
+// sendOutboxMessages sends queued messages and forgets the ones that were sent
+// so that they are not sent again if connection is lost midway.
+// Must be called with sendLock held.
+func (h *natsHandler) sendOutboxMessages() {
+	messages, err := h.outbox.Messages()
+	if err != nil {
+		h.logger.Error(h.logTag, "Getting outbox messages: %s", err.Error())
+		return
+	}
+
+	if len(messages) > 0 {
+		h.logger.Info(h.logTag, "Sending %d message(s) from outbox", len(messages))
+	}
+
+	sent := 0
+
+	for _, message := range messages {
+		if !h.isConnected() {
+			break
+		}
+
+		err = h.client.Publish(message.Subject, message.Payload)
+		if err != nil {
+			h.logger.Error(h.logTag, "Publishing outbox message (subject=%s): %s", message.Subject, err.Error())
+			h.sendMetrics.Failed(message.Topic)
+			break
+		}
+
+		h.sendMetrics.Sent(message.Topic)
+		sent++
+	}
+
+	err = h.outbox.Remove(sent)
+	if err != nil {
+		h.logger.Error(h.logTag, "Removing sent messages from outbox: %s", err.Error())
+	}
+}
+
+func (h *natsHandler) isConnected() bool {
+	h.connectedLock.RLock()
+	defer h.connectedLock.RUnlock()
+
+	return h.connected
+}
+
+func (h *natsHandler) setConnected(connected bool) {
+	h.connectedLock.Lock()
+	defer h.connectedLock.Unlock()
+
+	h.connected = connected
+
+	if connected {
+		h.connectedGauge.Set(1)
+	} else {
+		h.connectedGauge.Set(0)
+	}
+}
+
+func (h *natsHandler) handleNatsMsg(natsMsg *yagnats.Message, handlerFunc boshhandler.Func) {
+	identifyingHandlerFunc := func(req boshhandler.Request) boshhandler.Response {
+		// Reply subjects are unique per request so redelivered messages share them
+		if req.RequestID == "" {
+			req.RequestID = req.ReplyTo
+		}
+		return handlerFunc(req)
+	}
+
+	payload := natsMsg.Payload
+	maxLength := responseMaxLength
+
+	if h.signer != nil {
+		var err error
+
+		// Unsigned, tampered, stale and replayed requests are dropped without a response
+		payload, err = h.signer.Open(natsMsg.Payload)
+		if err != nil {
+			h.logger.Error(h.logTag, "Rejecting request: %s", err)
+			return
+		}
+
+		maxLength = h.signer.MaxPayloadLength(responseMaxLength)
+	}
+
 	respBytes, req, err := boshhandler.PerformHandlerWithJSON(
-		natsMsg.Payload,
-		handlerFunc,
-		responseMaxLength,
+		payload,
+		identifyingHandlerFunc,
+		boshhandler.UnlimitedResponseLength,
 		h.logger,
 	)
 	if err != nil {
@@ -132,12 +454,43 @@ func (h natsHandler) handleNatsMsg(natsMsg *yagnats.Message, handlerFunc boshhan
 		return
 	}
 
-	if len(respBytes) > 0 {
-		h.client.Publish(req.ReplyTo, respBytes)
+	if len(respBytes) == 0 {
+		return
+	}
+
+	if len(respBytes) <= maxLength {
+		h.publishResponse(req.ReplyTo, respBytes)
+		return
+	}
+
+	chunks, err := boshhandler.ChunkResponse(respBytes, maxLength)
+	if err != nil {
+		h.logger.Error(h.logTag, "Chunking response: %s", err)
+		return
+	}
+
+	h.logger.Info(h.logTag, "Responding with %d chunks", len(chunks))
+
+	for _, chunk := range chunks {
+		h.publishResponse(req.ReplyTo, chunk)
+	}
+}
+
+func (h *natsHandler) publishResponse(replyTo string, respBytes []byte) {
+	if h.signer != nil {
+		var err error
+
+		respBytes, err = h.signer.Seal(respBytes)
+		if err != nil {
+			h.logger.Error(h.logTag, "Signing response: %s", err)
+			return
+		}
 	}
+
+	h.client.Publish(replyTo, respBytes)
 }
 
-func (h natsHandler) runUntilInterrupted() {
+func (h *natsHandler) runUntilInterrupted() {
 	defer h.client.Disconnect()
 
 	keepRunning := true
@@ -153,7 +506,7 @@ func (h natsHandler) runUntilInterrupted() {
 	}
 }
 
-func (h natsHandler) getConnectionInfo() (*yagnats.ConnectionInfo, error) {
+func (h *natsHandler) getConnectionInfo() (*yagnats.ConnectionInfo, error) {
 	settings := h.settingsService.GetSettings()
 
 	natsURL, err := url.Parse(settings.Mbus)
@@ -174,5 +527,19 @@ func (h natsHandler) getConnectionInfo() (*yagnats.ConnectionInfo, error) {
 		connInfo.Username = user.Username()
 	}
 
+	if isNatsTLSScheme(natsURL.Scheme) {
+		host, _, err := net.SplitHostPort(natsURL.Host)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Parsing Nats host")
+		}
+
+		tlsConfig, err := natsTLSConfig(settings.Env.Bosh.Mbus.Cert, host)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Building TLS config")
+		}
+
+		connInfo.Dial = natsTLSDial(tlsConfig)
+	}
+
 	return connInfo, nil
 }
@@ -1,17 +1,26 @@
 package mbus_test
 
 import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudfoundry/yagnats"
 	"github.com/cloudfoundry/yagnats/fakeyagnats"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	. "github.com/cloudfoundry/bosh-agent/mbus"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 func init() {
@@ -19,6 +28,11 @@ func init() {
 		var (
 			settingsService *fakesettings.FakeSettingsService
 			client          *fakeyagnats.FakeYagnats
+			fs              *fakesys.FakeFileSystem
+			outbox          Outbox
+			backoff         Backoff
+			timeService     *fakeclock.FakeClock
+			registry        *boshmetrics.Registry
 			logger          boshlog.Logger
 			handler         boshhandler.Handler
 		)
@@ -32,7 +46,12 @@ func init() {
 			}
 			logger = boshlog.NewLogger(boshlog.LevelNone)
 			client = fakeyagnats.New()
-			handler = NewNatsHandler(settingsService, client, logger)
+			fs = fakesys.NewFakeFileSystem()
+			outbox = NewFileOutbox(fs, "/fake-outbox.json", 10)
+			backoff = NewExponentialBackoff(time.Second, time.Minute, rand.New(rand.NewSource(1)))
+			timeService = fakeclock.NewFakeClock(time.Now())
+			registry = boshmetrics.NewRegistry()
+			handler = NewNatsHandler(settingsService, client, outbox, backoff, timeService, registry, logger)
 		})
 
 		Describe("Start", func() {
@@ -59,9 +78,10 @@ func init() {
 				})
 
 				Expect(receivedRequest).To(Equal(boshhandler.Request{
-					ReplyTo: "reply to me!",
-					Method:  "ping",
-					Payload: expectedPayload,
+					ReplyTo:   "reply to me!",
+					Method:    "ping",
+					Payload:   expectedPayload,
+					RequestID: "reply to me!",
 				}))
 
 				Expect(client.PublishedMessageCount()).To(Equal(1))
@@ -70,6 +90,24 @@ func init() {
 				Expect(messages[0].Payload).To(Equal([]byte(`{"value":"expected value"}`)))
 			})
 
+			It("identifies requests by explicit request id instead of reply subject", func() {
+				var receivedRequest boshhandler.Request
+
+				handler.Start(func(req boshhandler.Request) (resp boshhandler.Response) {
+					receivedRequest = req
+					return boshhandler.NewValueResponse("expected value")
+				})
+				defer handler.Stop()
+
+				subscription := client.Subscriptions("agent.my-agent-id")[0]
+				subscription.Callback(&yagnats.Message{
+					Subject: "agent.my-agent-id",
+					Payload: []byte(`{"method":"ping","arguments":[],"reply_to":"reply to me!","request_id":"fake-request-id"}`),
+				})
+
+				Expect(receivedRequest.RequestID).To(Equal("fake-request-id"))
+			})
+
 			It("does not respond if the response is nil", func() {
 				err := handler.Start(func(req boshhandler.Request) (resp boshhandler.Response) {
 					return nil
@@ -86,7 +124,7 @@ func init() {
 				Expect(client.PublishedMessageCount()).To(Equal(0))
 			})
 
-			It("responds with an error if the response is bigger than 1MB", func() {
+			It("responds in chunks if the response is bigger than 1MB", func() {
 				err := handler.Start(func(req boshhandler.Request) (resp boshhandler.Response) {
 					// gets inflated by json.Marshal when enveloping
 					size := 0
@@ -122,10 +160,18 @@ func init() {
 
 				Expect(client.PublishedMessageCount()).To(Equal(1))
 				messages := client.PublishedMessages("fake-reply-to")
-				Expect(len(messages)).To(Equal(2))
+				Expect(len(messages)).To(Equal(3))
 				Expect(messages[0].Payload).To(MatchRegexp("value"))
-				Expect(messages[1].Payload).To(Equal([]byte(
-					`{"exception":{"message":"Response exceeded maximum allowed length"}}`)))
+
+				chunks := [][]byte{}
+				for _, message := range messages[1:] {
+					Expect(len(message.Payload)).To(BeNumerically("<=", 1024*1024))
+					chunks = append(chunks, message.Payload)
+				}
+
+				respJSON, err := boshhandler.JoinChunks(chunks)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(respJSON).To(Equal([]byte(`{"value":"` + strings.Repeat("A", 1024*1024) + `"}`)))
 			})
 
 			It("can add additional handler funcs to receive requests", func() {
@@ -152,15 +198,17 @@ func init() {
 
 				// Expected requests received by both handlers
 				Expect(firstHandlerReq).To(Equal(boshhandler.Request{
-					ReplyTo: "fake-reply-to",
-					Method:  "ping",
-					Payload: expectedPayload,
+					ReplyTo:   "fake-reply-to",
+					Method:    "ping",
+					Payload:   expectedPayload,
+					RequestID: "fake-reply-to",
 				}))
 
 				Expect(secondHandlerRequest).To(Equal(boshhandler.Request{
-					ReplyTo: "fake-reply-to",
-					Method:  "ping",
-					Payload: expectedPayload,
+					ReplyTo:   "fake-reply-to",
+					Method:    "ping",
+					Payload:   expectedPayload,
+					RequestID: "fake-reply-to",
 				}))
 
 				// Bosh handler responses were sent
@@ -171,12 +219,87 @@ func init() {
 				Expect(messages[1].Payload).To(Equal([]byte(`{"value":"second-handler-resp"}`)))
 			})
 
+			Context("when signing secret is set", func() {
+				var (
+					directorSigner *MessageSigner
+					receivedReqs   []boshhandler.Request
+				)
+
+				BeforeEach(func() {
+					settingsService.Settings.Env.Bosh.Mbus.Signing = boshsettings.SigningEnv{
+						Secret:  "fake-secret",
+						Encrypt: true,
+					}
+
+					var err error
+					directorSigner, err = NewMessageSigner("fake-secret", true, time.Minute, timeService)
+					Expect(err).ToNot(HaveOccurred())
+
+					receivedReqs = nil
+
+					err = handler.Start(func(req boshhandler.Request) (resp boshhandler.Response) {
+						receivedReqs = append(receivedReqs, req)
+						return boshhandler.NewValueResponse("expected value")
+					})
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				AfterEach(func() {
+					handler.Stop()
+				})
+
+				deliver := func(payload []byte) {
+					subscription := client.Subscriptions("agent.my-agent-id")[0]
+					subscription.Callback(&yagnats.Message{Subject: "agent.my-agent-id", Payload: payload})
+				}
+
+				It("handles signed requests and signs responses", func() {
+					payload := []byte(`{"method":"ping","arguments":[],"reply_to":"fake-reply-to"}`)
+
+					msgBytes, err := directorSigner.Seal(payload)
+					Expect(err).ToNot(HaveOccurred())
+
+					deliver(msgBytes)
+
+					Expect(receivedReqs).To(HaveLen(1))
+					Expect(receivedReqs[0].Method).To(Equal("ping"))
+					Expect(receivedReqs[0].Payload).To(Equal(payload))
+
+					messages := client.PublishedMessages("fake-reply-to")
+					Expect(messages).To(HaveLen(1))
+					Expect(string(messages[0].Payload)).ToNot(ContainSubstring("expected value"))
+
+					respBytes, err := directorSigner.Open(messages[0].Payload)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(respBytes).To(Equal([]byte(`{"value":"expected value"}`)))
+				})
+
+				It("rejects unsigned requests without responding", func() {
+					deliver([]byte(`{"method":"ping","arguments":[],"reply_to":"fake-reply-to"}`))
+
+					Expect(receivedReqs).To(BeEmpty())
+					Expect(client.PublishedMessageCount()).To(Equal(0))
+				})
+
+				It("rejects replayed requests", func() {
+					msgBytes, err := directorSigner.Seal([]byte(`{"method":"ping","arguments":[],"reply_to":"fake-reply-to"}`))
+					Expect(err).ToNot(HaveOccurred())
+
+					deliver(msgBytes)
+					deliver(msgBytes)
+
+					Expect(receivedReqs).To(HaveLen(1))
+					Expect(client.PublishedMessages("fake-reply-to")).To(HaveLen(1))
+				})
+			})
+
 			It("has the correct connection info", func() {
 				err := handler.Start(func(req boshhandler.Request) (res boshhandler.Response) { return })
 				Expect(err).ToNot(HaveOccurred())
 				defer handler.Stop()
 
-				Expect(client.ConnectedConnectionProvider()).To(Equal(&yagnats.ConnectionInfo{
+				connProvider := client.ConnectedConnectionProvider().(*ReconnectingConnectionProvider)
+				Expect(connProvider.ConnectionInfo).To(Equal(&yagnats.ConnectionInfo{
 					Addr:     "127.0.0.1:1234",
 					Username: "fake-username",
 					Password: "fake-password",
@@ -185,7 +308,7 @@ func init() {
 
 			It("does not err when no username and password", func() {
 				settingsService.Settings.Mbus = "nats://127.0.0.1:1234"
-				handler = NewNatsHandler(settingsService, client, logger)
+				handler = NewNatsHandler(settingsService, client, outbox, backoff, timeService, boshmetrics.NewRegistry(), logger)
 
 				err := handler.Start(func(req boshhandler.Request) (res boshhandler.Response) { return })
 				Expect(err).ToNot(HaveOccurred())
@@ -194,7 +317,7 @@ func init() {
 
 			It("errs when has username without password", func() {
 				settingsService.Settings.Mbus = "nats://foo@127.0.0.1:1234"
-				handler = NewNatsHandler(settingsService, client, logger)
+				handler = NewNatsHandler(settingsService, client, outbox, backoff, timeService, boshmetrics.NewRegistry(), logger)
 
 				err := handler.Start(func(req boshhandler.Request) (res boshhandler.Response) { return })
 				Expect(err).To(HaveOccurred())
@@ -203,19 +326,29 @@ func init() {
 		})
 
 		Describe("Send", func() {
+			noopHandlerFunc := func(req boshhandler.Request) (res boshhandler.Response) { return }
+
+			alert := map[string]string{"id": "fake-alert"}
+
+			topicMetricValue := func(name string, topic boshhandler.Topic) float64 {
+				for _, family := range registry.Families() {
+					for _, sample := range family.Samples {
+						if family.Name == name && sample.Labels[0].Value == string(topic) {
+							return sample.Value
+						}
+					}
+				}
+				return 0
+			}
+
 			It("sends the message over nats to a subject that includes the target and topic", func() {
-				errCh := make(chan error, 1)
+				err := handler.Start(noopHandlerFunc)
+				Expect(err).ToNot(HaveOccurred())
+				defer handler.Stop()
 
 				payload := map[string]string{"key1": "value1", "keyA": "valueA"}
 
-				go func() {
-					errCh <- handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, payload)
-				}()
-
-				var err error
-				select {
-				case err = <-errCh:
-				}
+				err = handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, payload)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(client.PublishedMessageCount()).To(Equal(1))
@@ -224,6 +357,277 @@ func init() {
 				Expect(messages[0].Payload).To(Equal(
 					[]byte("{\"key1\":\"value1\",\"keyA\":\"valueA\"}"),
 				))
+
+				Expect(outbox.Messages()).To(BeEmpty())
+			})
+
+			It("adds message to outbox when not connected", func() {
+				err := handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(client.PublishedMessageCount()).To(Equal(0))
+				Expect(outbox.Messages()).To(Equal([]OutboxMessage{
+					{
+						Topic:   boshhandler.Alert,
+						Subject: "hm.agent.alert.my-agent-id",
+						Payload: json.RawMessage(`{"id":"fake-alert"}`),
+					},
+				}))
+			})
+
+			It("drops messages other than alerts and heartbeats when not connected", func() {
+				err := handler.Send(boshhandler.Director, boshhandler.TaskProgress, map[string]string{"id": "fake-progress"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(client.PublishedMessageCount()).To(Equal(0))
+				Expect(outbox.Messages()).To(BeEmpty())
+				Expect(fs.FileExists("/fake-outbox.json")).To(BeFalse())
+			})
+
+			It("adds message to outbox when publishing fails", func() {
+				err := handler.Start(noopHandlerFunc)
+				Expect(err).ToNot(HaveOccurred())
+				defer handler.Stop()
+
+				client.WhenPublishing("hm.agent.alert.my-agent-id", func(*yagnats.Message) error {
+					return errors.New("fake-publish-err")
+				})
+
+				err = handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(outbox.Messages()).To(HaveLen(1))
+			})
+
+			It("sends queued messages before the new one once publishing succeeds again", func() {
+				err := handler.Start(noopHandlerFunc)
+				Expect(err).ToNot(HaveOccurred())
+				defer handler.Stop()
+
+				client.WhenPublishing("hm.agent.alert.my-agent-id", func(*yagnats.Message) error {
+					return errors.New("fake-publish-err")
+				})
+
+				err = handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+				Expect(err).ToNot(HaveOccurred())
+
+				client.WhenPublishing("hm.agent.alert.my-agent-id", func(*yagnats.Message) error { return nil })
+
+				err = handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(client.PublishedMessages("hm.agent.alert.my-agent-id")).To(HaveLen(1))
+				Expect(client.PublishedMessages("hm.agent.heartbeat.my-agent-id")).To(HaveLen(1))
+				Expect(outbox.Messages()).To(BeEmpty())
+			})
+
+			It("sends messages left in outbox once started", func() {
+				err := handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{"id": "heartbeat-1"})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{"id": "heartbeat-2"})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = handler.Start(noopHandlerFunc)
+				Expect(err).ToNot(HaveOccurred())
+				defer handler.Stop()
+
+				Eventually(outbox.Messages).Should(BeEmpty())
+
+				Expect(client.PublishedMessages("hm.agent.alert.my-agent-id")).To(HaveLen(1))
+
+				heartbeats := client.PublishedMessages("hm.agent.heartbeat.my-agent-id")
+				Expect(heartbeats).To(HaveLen(1))
+				Expect(heartbeats[0].Payload).To(Equal([]byte(`{"id":"heartbeat-2"}`)))
+			})
+
+			It("counts messages queued while not connected and sent once started", func() {
+				err := handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = handler.Send(boshhandler.Director, boshhandler.TaskProgress, map[string]string{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(topicMetricValue("bosh_agent_mbus_messages_queued_total", boshhandler.Heartbeat)).To(Equal(float64(1)))
+				Expect(topicMetricValue("bosh_agent_mbus_messages_dropped_total", boshhandler.TaskProgress)).To(Equal(float64(1)))
+				Expect(topicMetricValue("bosh_agent_mbus_messages_sent_total", boshhandler.Heartbeat)).To(Equal(float64(0)))
+
+				err = handler.Start(noopHandlerFunc)
+				Expect(err).ToNot(HaveOccurred())
+				defer handler.Stop()
+
+				Eventually(func() float64 {
+					return topicMetricValue("bosh_agent_mbus_messages_sent_total", boshhandler.Heartbeat)
+				}).Should(Equal(float64(1)))
+			})
+
+			It("counts messages that failed to be published", func() {
+				err := handler.Start(noopHandlerFunc)
+				Expect(err).ToNot(HaveOccurred())
+				defer handler.Stop()
+
+				client.WhenPublishing("hm.agent.alert.my-agent-id", func(*yagnats.Message) error {
+					return errors.New("fake-publish-err")
+				})
+
+				err = handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(topicMetricValue("bosh_agent_mbus_send_failures_total", boshhandler.Alert)).To(Equal(float64(1)))
+				Expect(topicMetricValue("bosh_agent_mbus_messages_queued_total", boshhandler.Alert)).To(Equal(float64(1)))
+				Expect(topicMetricValue("bosh_agent_mbus_messages_sent_total", boshhandler.Alert)).To(Equal(float64(0)))
+			})
+
+			It("returns error when message cannot be added to outbox", func() {
+				fs.WriteFileError = errors.New("fake-write-err")
+
+				err := handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-write-err"))
+			})
+		})
+
+		Describe("reconnecting", func() {
+			var (
+				server *natsServer
+			)
+
+			BeforeEach(func() {
+				server = newNatsServer()
+				settingsService.Settings.Mbus = "nats://fake-username:fake-password@" + server.Addr()
+				handler = NewNatsHandler(settingsService, yagnats.NewClient(), outbox, backoff, timeService, registry, logger)
+			})
+
+			AfterEach(func() {
+				server.Stop()
+			})
+
+			metricValue := func(name string) float64 {
+				for _, family := range registry.Families() {
+					if family.Name == name && len(family.Samples) == 1 {
+						return family.Samples[0].Value
+					}
+				}
+				return 0
+			}
+
+			containsPrefix := func(lines []string, prefix string) bool {
+				for _, line := range lines {
+					if strings.HasPrefix(line, prefix) {
+						return true
+					}
+				}
+				return false
+			}
+
+			receivePublishedPayloads := func(count int) []string {
+				payloads := []string{}
+
+				for len(payloads) < count {
+					var line string
+					Eventually(server.lines, 5*time.Second).Should(Receive(&line))
+
+					if strings.HasPrefix(line, "PUB ") {
+						var payload string
+						Eventually(server.lines).Should(Receive(&payload))
+						payloads = append(payloads, payload)
+					}
+				}
+
+				return payloads
+			}
+
+			It("resubscribes and sends messages queued while disconnected once NATS is back", func() {
+				err := handler.Start(func(req boshhandler.Request) (res boshhandler.Response) { return })
+				Expect(err).ToNot(HaveOccurred())
+
+				server.Stop()
+
+				// Reconnection attempt failed and next one is waiting for backoff delay
+				Eventually(timeService.WatcherCount, 5*time.Second).Should(Equal(1))
+
+				for _, message := range []struct {
+					topic boshhandler.Topic
+					id    string
+				}{
+					{boshhandler.Heartbeat, "heartbeat-1"},
+					{boshhandler.Alert, "alert-1"},
+					{boshhandler.Heartbeat, "heartbeat-2"},
+					{boshhandler.Alert, "alert-2"},
+				} {
+					err = handler.Send(boshhandler.HealthMonitor, message.topic, map[string]string{"id": message.id})
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				server.Restart()
+				timeService.Increment(time.Minute)
+
+				Eventually(server.lines, 5*time.Second).Should(Receive(HavePrefix("SUB agent.my-agent-id")))
+
+				Expect(receivePublishedPayloads(3)).To(Equal([]string{
+					`{"id":"alert-1"}`,
+					`{"id":"alert-2"}`,
+					`{"id":"heartbeat-2"}`,
+				}))
+
+				Eventually(outbox.Messages).Should(BeEmpty())
+
+				handler.Stop()
+			})
+
+			It("keeps connecting with backoff when NATS is unreachable at start", func() {
+				server.Stop()
+
+				err := handler.Start(func(req boshhandler.Request) (res boshhandler.Response) { return })
+				Expect(err).ToNot(HaveOccurred())
+				defer handler.Stop()
+
+				// First attempt failed and next one is waiting for backoff delay
+				Eventually(timeService.WatcherCount, 5*time.Second).Should(Equal(1))
+
+				err = handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, map[string]string{"id": "alert-1"})
+				Expect(err).ToNot(HaveOccurred())
+
+				server.Restart()
+				timeService.Increment(time.Minute)
+
+				// Outbox may be flushed before subscribing
+				lines := []string{}
+				for len(lines) < 2 || !(containsPrefix(lines, "SUB agent.my-agent-id") && containsPrefix(lines, `{"id":"alert-1"}`)) {
+					var line string
+					Eventually(server.lines, 5*time.Second).Should(Receive(&line))
+					lines = append(lines, line)
+				}
+			})
+
+			It("counts reconnects and reports whether it is connected", func() {
+				err := handler.Start(func(req boshhandler.Request) (res boshhandler.Response) { return })
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(server.lines, 5*time.Second).Should(Receive(HavePrefix("SUB agent.my-agent-id")))
+				Expect(metricValue("bosh_agent_mbus_connected")).To(Equal(float64(1)))
+				Expect(metricValue("bosh_agent_mbus_reconnects_total")).To(Equal(float64(0)))
+
+				server.Stop()
+
+				Eventually(timeService.WatcherCount, 5*time.Second).Should(Equal(1))
+				Expect(metricValue("bosh_agent_mbus_connected")).To(Equal(float64(0)))
+
+				server.Restart()
+				timeService.Increment(time.Minute)
+
+				Eventually(server.lines, 5*time.Second).Should(Receive(HavePrefix("SUB agent.my-agent-id")))
+				Eventually(func() float64 { return metricValue("bosh_agent_mbus_reconnects_total") }).Should(Equal(float64(1)))
+				Eventually(func() float64 { return metricValue("bosh_agent_mbus_connected") }).Should(Equal(float64(1)))
+
+				err = handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{"id": "heartbeat-1"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivePublishedPayloads(1)).To(Equal([]string{`{"id":"heartbeat-1"}`}))
+
+				handler.Stop()
 			})
 		})
 	})
@@ -0,0 +1,118 @@
+package mbus
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	natsDialTimeout = 5 * time.Second
+
+	// Longest INFO line server is expected to send before upgrading to TLS
+	natsMaxInfoLength = 64 * 1024
+)
+
+func isNatsTLSScheme(scheme string) bool {
+	return scheme == "nats+tls" || scheme == "tls"
+}
+
+// natsTLSConfig only trusts server certificates signed by the given CA
+// (system CAs are not used) and presents client certificate to the server
+func natsTLSConfig(cert boshsettings.CertKeyPair, serverName string) (*tls.Config, error) {
+	if cert.CA == "" {
+		return nil, bosherr.Error("No CA certificate set for TLS connection")
+	}
+
+	if cert.Certificate == "" || cert.PrivateKey == "" {
+		return nil, bosherr.Error("No client certificate and private key set for TLS connection")
+	}
+
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM([]byte(cert.CA)) {
+		return nil, bosherr.Error("Parsing CA certificate")
+	}
+
+	clientCert, err := tls.X509KeyPair([]byte(cert.Certificate), []byte(cert.PrivateKey))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing client certificate and private key")
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:      caPool,
+		Certificates: []tls.Certificate{clientCert},
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	return tlsConfig, nil
+}
+
+// natsTLSDial returns dial func for yagnats.ConnectionInfo.
+// NATS server greets clients with INFO in plain text before
+// connection is upgraded to TLS, hence INFO is read before TLS handshake.
+func natsTLSDial(tlsConfig *tls.Config) func(network, address string) (net.Conn, error) {
+	return func(network, address string) (net.Conn, error) {
+		conn, err := net.DialTimeout(network, address, natsDialTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		err = conn.SetDeadline(time.Now().Add(natsDialTimeout))
+		if err != nil {
+			conn.Close()
+			return nil, bosherr.WrapError(err, "Setting handshake deadline")
+		}
+
+		err = readNatsInfo(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		tlsConn := tls.Client(conn, tlsConfig)
+
+		err = tlsConn.Handshake()
+		if err != nil {
+			conn.Close()
+			return nil, bosherr.WrapError(err, "Performing TLS handshake")
+		}
+
+		err = conn.SetDeadline(time.Time{})
+		if err != nil {
+			conn.Close()
+			return nil, bosherr.WrapError(err, "Clearing handshake deadline")
+		}
+
+		return tlsConn, nil
+	}
+}
+
+// readNatsInfo reads byte by byte so that no part of TLS handshake is consumed
+func readNatsInfo(conn net.Conn) error {
+	var line []byte
+	b := make([]byte, 1)
+
+	for len(line) < natsMaxInfoLength {
+		_, err := conn.Read(b)
+		if err != nil {
+			return bosherr.WrapError(err, "Reading INFO from server")
+		}
+
+		line = append(line, b[0])
+
+		if b[0] == '\n' {
+			if !bytes.HasPrefix(line, []byte("INFO")) {
+				return bosherr.Errorf("Expected INFO from server, got '%s'", bytes.TrimSpace(line))
+			}
+			return nil
+		}
+	}
+
+	return bosherr.Error("Reading INFO from server: line is too long")
+}
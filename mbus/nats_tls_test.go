@@ -0,0 +1,270 @@
+package mbus_test
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	mathrand "math/rand"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/yagnats"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *rsa.PrivateKey
+	certPEM string
+	keyPEM  string
+}
+
+func newTestCert(commonName string, parent *testCert, isCA bool) testCert {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(certDER)
+	Expect(err).ToNot(HaveOccurred())
+
+	return testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})),
+		keyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+	}
+}
+
+// tlsNatsServer accepts single connection the way NATS server with TLS does:
+// INFO is sent in plain text, then connection is upgraded to TLS requiring client certificate
+type tlsNatsServer struct {
+	listener net.Listener
+
+	// Lines (e.g. CONNECT, SUB) client sent after TLS handshake
+	lines chan string
+
+	// Common name of the client certificate
+	clientCommonNames chan string
+}
+
+func newTLSNatsServer(serverCert testCert, clientCA testCert) *tlsNatsServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+
+	server := &tlsNatsServer{
+		listener:          listener,
+		lines:             make(chan string, 10),
+		clientCommonNames: make(chan string, 1),
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{serverCert.cert.Raw},
+			PrivateKey:  serverCert.key,
+		}},
+		ClientCAs:  clientCAs,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}
+
+	go server.serve(tlsConfig)
+
+	return server
+}
+
+func (s *tlsNatsServer) serve(tlsConfig *tls.Config) {
+	defer GinkgoRecover()
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	fmt.Fprint(conn, "INFO {\"tls_required\":true}\r\n")
+
+	tlsConn := tls.Server(conn, tlsConfig)
+
+	err = tlsConn.Handshake()
+	if err != nil {
+		return
+	}
+
+	s.clientCommonNames <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+
+	reader := bufio.NewReader(tlsConn)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		s.lines <- strings.TrimSpace(line)
+
+		// Client is in verbose mode
+		fmt.Fprint(tlsConn, "+OK\r\n")
+	}
+}
+
+func (s *tlsNatsServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *tlsNatsServer) Close() {
+	s.listener.Close()
+}
+
+func init() {
+	Describe("natsHandler with TLS", func() {
+		var (
+			ca              testCert
+			settingsService *fakesettings.FakeSettingsService
+			server          *tlsNatsServer
+			timeService     *fakeclock.FakeClock
+			handler         boshhandler.Handler
+		)
+
+		BeforeEach(func() {
+			ca = newTestCert("fake-ca", nil, true)
+			serverCert := newTestCert("fake-nats-server", &ca, false)
+			clientCert := newTestCert("fake-agent", &ca, false)
+
+			server = newTLSNatsServer(serverCert, ca)
+
+			settingsService = &fakesettings.FakeSettingsService{
+				Settings: boshsettings.Settings{
+					AgentID: "my-agent-id",
+					Mbus:    "nats+tls://fake-username:fake-password@" + server.Addr(),
+					Env: boshsettings.Env{
+						Bosh: boshsettings.BoshEnv{
+							Mbus: boshsettings.MbusEnv{
+								Cert: boshsettings.CertKeyPair{
+									CA:          ca.certPEM,
+									Certificate: clientCert.certPEM,
+									PrivateKey:  clientCert.keyPEM,
+								},
+							},
+						},
+					},
+				},
+			}
+
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			outbox := NewFileOutbox(fakesys.NewFakeFileSystem(), "/fake-outbox.json", 10)
+			backoff := NewExponentialBackoff(time.Second, time.Minute, mathrand.New(mathrand.NewSource(1)))
+			timeService = fakeclock.NewFakeClock(time.Now())
+			handler = NewNatsHandler(settingsService, yagnats.NewClient(), outbox, backoff, timeService, boshmetrics.NewRegistry(), logger)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		noopHandlerFunc := func(req boshhandler.Request) (res boshhandler.Response) { return }
+
+		It("connects with client certificate and subscribes over TLS", func() {
+			err := handler.Start(noopHandlerFunc)
+			Expect(err).ToNot(HaveOccurred())
+			defer handler.Stop()
+
+			Eventually(server.clientCommonNames).Should(Receive(Equal("fake-agent")))
+
+			var connectLine string
+			Eventually(server.lines).Should(Receive(&connectLine))
+			Expect(connectLine).To(HavePrefix("CONNECT"))
+			Expect(connectLine).To(ContainSubstring(`"pass":"fake-password"`))
+
+			Eventually(server.lines).Should(Receive(HavePrefix("SUB agent.my-agent-id")))
+		})
+
+		It("supports tls scheme", func() {
+			settingsService.Settings.Mbus = "tls://fake-username:fake-password@" + server.Addr()
+
+			err := handler.Start(noopHandlerFunc)
+			Expect(err).ToNot(HaveOccurred())
+			defer handler.Stop()
+
+			Eventually(server.clientCommonNames).Should(Receive(Equal("fake-agent")))
+		})
+
+		It("does not connect when server certificate is not signed by pinned CA", func() {
+			otherCA := newTestCert("fake-other-ca", nil, true)
+			settingsService.Settings.Env.Bosh.Mbus.Cert.CA = otherCA.certPEM
+
+			err := handler.Start(noopHandlerFunc)
+			Expect(err).ToNot(HaveOccurred())
+			defer handler.Stop()
+
+			// Failed attempt is retried after backoff delay
+			Eventually(timeService.WatcherCount, 5*time.Second).Should(Equal(1))
+
+			Consistently(server.lines).ShouldNot(Receive())
+		})
+
+		It("returns error when CA is not set", func() {
+			settingsService.Settings.Env.Bosh.Mbus.Cert.CA = ""
+
+			err := handler.Start(noopHandlerFunc)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("No CA certificate set for TLS connection"))
+		})
+
+		It("returns error when client certificate is not set", func() {
+			settingsService.Settings.Env.Bosh.Mbus.Cert.Certificate = ""
+
+			err := handler.Start(noopHandlerFunc)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("No client certificate and private key set for TLS connection"))
+		})
+
+		It("returns error when client certificate cannot be parsed", func() {
+			settingsService.Settings.Env.Bosh.Mbus.Cert.PrivateKey = "fake-private-key"
+
+			err := handler.Start(noopHandlerFunc)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing client certificate and private key"))
+		})
+	})
+}
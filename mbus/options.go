@@ -0,0 +1,40 @@
+package mbus
+
+import (
+	"time"
+)
+
+const (
+	DefaultReconnectInitialDelayMilliseconds = 500
+	DefaultReconnectMaxDelaySeconds          = 60
+)
+
+type Options struct {
+	// Delay before retrying after failed attempt to reconnect to NATS or WebSocket controller;
+	// delay is doubled after every further failure up to ReconnectMaxDelaySeconds.
+	// Defaults to DefaultReconnectInitialDelayMilliseconds when not set
+	ReconnectInitialDelayMilliseconds int
+
+	// Longest delay between attempts to reconnect to NATS or WebSocket controller.
+	// Defaults to DefaultReconnectMaxDelaySeconds when not set
+	ReconnectMaxDelaySeconds int
+
+	// Maximum number of alerts kept in outbox while NATS is unreachable;
+	// oldest alerts are dropped first.
+	// Defaults to DefaultOutboxMaxAlerts when not set
+	OutboxMaxAlerts int
+}
+
+func (o Options) ReconnectInitialDelay() time.Duration {
+	if o.ReconnectInitialDelayMilliseconds <= 0 {
+		return DefaultReconnectInitialDelayMilliseconds * time.Millisecond
+	}
+	return time.Duration(o.ReconnectInitialDelayMilliseconds) * time.Millisecond
+}
+
+func (o Options) ReconnectMaxDelay() time.Duration {
+	if o.ReconnectMaxDelaySeconds <= 0 {
+		return DefaultReconnectMaxDelaySeconds * time.Second
+	}
+	return time.Duration(o.ReconnectMaxDelaySeconds) * time.Second
+}
@@ -0,0 +1,201 @@
+package mbus
+
+import (
+	"encoding/json"
+	"sync"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	DefaultOutboxMaxAlerts = 1000
+)
+
+type OutboxMessage struct {
+	Topic   boshhandler.Topic `json:"topic"`
+	Subject string            `json:"subject"`
+	Payload json.RawMessage   `json:"payload"`
+}
+
+// Outbox keeps alerts and heartbeats that could not be sent while disconnected
+// so that they can be sent once connection is back. Other messages
+// (e.g. task progress) are only useful while task is running and are not kept.
+type Outbox interface {
+	// Add queues alert or replaces previously queued heartbeat
+	Add(message OutboxMessage) error
+
+	// Messages returns queued messages in the order they should be sent:
+	// alerts in the order they were added, then latest heartbeat
+	Messages() ([]OutboxMessage, error)
+
+	// Remove forgets first count messages returned by Messages
+	Remove(count int) error
+}
+
+// KeptInOutbox tells whether messages of topic are queued while disconnected
+func KeptInOutbox(topic boshhandler.Topic) bool {
+	return topic == boshhandler.Alert || topic == boshhandler.Heartbeat
+}
+
+type fileOutboxContents struct {
+	Alerts []OutboxMessage `json:"alerts"`
+}
+
+type fileOutbox struct {
+	fs        boshsys.FileSystem
+	path      string
+	maxAlerts int
+
+	loaded    bool
+	alerts    []OutboxMessage
+	heartbeat *OutboxMessage
+	lock      sync.Mutex
+}
+
+// NewFileOutbox keeps at most maxAlerts alerts; oldest alerts are dropped first
+// when there is no room for new ones. Only alerts are written to path
+// since heartbeat is outdated by the time agent is restarted.
+func NewFileOutbox(fs boshsys.FileSystem, path string, maxAlerts int) Outbox {
+	if maxAlerts <= 0 {
+		maxAlerts = DefaultOutboxMaxAlerts
+	}
+
+	return &fileOutbox{
+		fs:        fs,
+		path:      path,
+		maxAlerts: maxAlerts,
+	}
+}
+
+func (o *fileOutbox) Add(message OutboxMessage) error {
+	if !KeptInOutbox(message.Topic) {
+		return bosherr.Errorf("Only alerts and heartbeats are kept in outbox, not '%s'", message.Topic)
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	err := o.load()
+	if err != nil {
+		return err
+	}
+
+	if message.Topic == boshhandler.Heartbeat {
+		o.heartbeat = &message
+		return nil
+	}
+
+	o.alerts = append(o.alerts, message)
+
+	if overflow := len(o.alerts) - o.maxAlerts; overflow > 0 {
+		o.alerts = append([]OutboxMessage{}, o.alerts[overflow:]...)
+	}
+
+	return o.save()
+}
+
+func (o *fileOutbox) Messages() ([]OutboxMessage, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	err := o.load()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := append([]OutboxMessage{}, o.alerts...)
+
+	if o.heartbeat != nil {
+		messages = append(messages, *o.heartbeat)
+	}
+
+	return messages, nil
+}
+
+func (o *fileOutbox) Remove(count int) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	err := o.load()
+	if err != nil {
+		return err
+	}
+
+	if count <= 0 {
+		return nil
+	}
+
+	if count > len(o.alerts) {
+		o.heartbeat = nil
+		count = len(o.alerts)
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	o.alerts = append([]OutboxMessage{}, o.alerts[count:]...)
+
+	return o.save()
+}
+
+// load reads alerts left by previous agent run once; must be called with lock held
+func (o *fileOutbox) load() error {
+	if o.loaded {
+		return nil
+	}
+
+	if o.fs.FileExists(o.path) {
+		bytes, err := o.fs.ReadFile(o.path)
+		if err != nil {
+			return bosherr.WrapError(err, "Reading outbox")
+		}
+
+		var contents fileOutboxContents
+
+		err = json.Unmarshal(bytes, &contents)
+		if err != nil {
+			return bosherr.WrapError(err, "Unmarshalling outbox")
+		}
+
+		o.alerts = contents.Alerts
+	}
+
+	o.loaded = true
+
+	return nil
+}
+
+// save replaces outbox file at once so that it is not left half written;
+// must be called with lock held
+func (o *fileOutbox) save() error {
+	if len(o.alerts) == 0 {
+		err := o.fs.RemoveAll(o.path)
+		if err != nil {
+			return bosherr.WrapError(err, "Removing outbox")
+		}
+
+		return nil
+	}
+
+	bytes, err := json.Marshal(fileOutboxContents{Alerts: o.alerts})
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling outbox")
+	}
+
+	tmpPath := o.path + ".tmp"
+
+	err = o.fs.WriteFile(tmpPath, bytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing outbox")
+	}
+
+	err = o.fs.Rename(tmpPath, o.path)
+	if err != nil {
+		return bosherr.WrapError(err, "Replacing outbox")
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package mbus_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("fileOutbox", func() {
+	var (
+		fs     *fakesys.FakeFileSystem
+		outbox Outbox
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		outbox = NewFileOutbox(fs, "/fake-outbox.json", 3)
+	})
+
+	message := func(topic boshhandler.Topic, id string) OutboxMessage {
+		return OutboxMessage{
+			Topic:   topic,
+			Subject: "hm.agent." + string(topic) + ".fake-agent-id",
+			Payload: json.RawMessage(`{"id":"` + id + `"}`),
+		}
+	}
+
+	Describe("Messages", func() {
+		It("returns no messages when nothing was added", func() {
+			Expect(outbox.Messages()).To(BeEmpty())
+		})
+
+		It("returns messages in the order they were added followed by latest heartbeat", func() {
+			Expect(outbox.Add(message(boshhandler.Heartbeat, "heartbeat-1"))).To(Succeed())
+			Expect(outbox.Add(message(boshhandler.Alert, "alert-1"))).To(Succeed())
+			Expect(outbox.Add(message(boshhandler.Heartbeat, "heartbeat-2"))).To(Succeed())
+			Expect(outbox.Add(message(boshhandler.Alert, "alert-2"))).To(Succeed())
+
+			Expect(outbox.Messages()).To(Equal([]OutboxMessage{
+				message(boshhandler.Alert, "alert-1"),
+				message(boshhandler.Alert, "alert-2"),
+				message(boshhandler.Heartbeat, "heartbeat-2"),
+			}))
+		})
+
+		It("drops oldest alerts when there are too many of them", func() {
+			for _, id := range []string{"alert-1", "alert-2", "alert-3", "alert-4"} {
+				Expect(outbox.Add(message(boshhandler.Alert, id))).To(Succeed())
+			}
+			Expect(outbox.Add(message(boshhandler.Heartbeat, "heartbeat-1"))).To(Succeed())
+
+			Expect(outbox.Messages()).To(Equal([]OutboxMessage{
+				message(boshhandler.Alert, "alert-2"),
+				message(boshhandler.Alert, "alert-3"),
+				message(boshhandler.Alert, "alert-4"),
+				message(boshhandler.Heartbeat, "heartbeat-1"),
+			}))
+		})
+
+		It("returns alerts but not heartbeat added by previous agent run", func() {
+			Expect(outbox.Add(message(boshhandler.Alert, "alert-1"))).To(Succeed())
+			Expect(outbox.Add(message(boshhandler.Heartbeat, "heartbeat-1"))).To(Succeed())
+
+			outbox = NewFileOutbox(fs, "/fake-outbox.json", 3)
+
+			Expect(outbox.Messages()).To(Equal([]OutboxMessage{
+				message(boshhandler.Alert, "alert-1"),
+			}))
+		})
+
+		It("returns error when outbox cannot be read", func() {
+			fs.WriteFileString("/fake-outbox.json", "fake-invalid-json")
+
+			_, err := outbox.Messages()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling outbox"))
+		})
+	})
+
+	Describe("Add", func() {
+		It("saves alerts to file", func() {
+			Expect(outbox.Add(message(boshhandler.Alert, "alert-1"))).To(Succeed())
+			Expect(outbox.Add(message(boshhandler.Heartbeat, "heartbeat-1"))).To(Succeed())
+
+			contents, err := fs.ReadFileString("/fake-outbox.json")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(MatchJSON(`{
+				"alerts": [{"topic":"alert","subject":"hm.agent.alert.fake-agent-id","payload":{"id":"alert-1"}}]
+			}`))
+
+			Expect(fs.RenameOldPaths).To(Equal([]string{"/fake-outbox.json.tmp"}))
+			Expect(fs.RenameNewPaths).To(Equal([]string{"/fake-outbox.json"}))
+		})
+
+		It("does not write file when only heartbeat is added", func() {
+			Expect(outbox.Add(message(boshhandler.Heartbeat, "heartbeat-1"))).To(Succeed())
+			Expect(fs.FileExists("/fake-outbox.json")).To(BeFalse())
+		})
+
+		It("returns error for messages other than alerts and heartbeats", func() {
+			err := outbox.Add(message(boshhandler.TaskProgress, "progress-1"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Only alerts and heartbeats are kept in outbox"))
+
+			Expect(outbox.Messages()).To(BeEmpty())
+		})
+
+		It("returns error when outbox cannot be saved", func() {
+			fs.WriteFileError = errors.New("fake-write-err")
+
+			err := outbox.Add(message(boshhandler.Alert, "alert-1"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-write-err"))
+		})
+	})
+
+	Describe("Remove", func() {
+		BeforeEach(func() {
+			Expect(outbox.Add(message(boshhandler.Alert, "alert-1"))).To(Succeed())
+			Expect(outbox.Add(message(boshhandler.Alert, "alert-2"))).To(Succeed())
+			Expect(outbox.Add(message(boshhandler.Heartbeat, "heartbeat-1"))).To(Succeed())
+		})
+
+		It("removes first messages", func() {
+			Expect(outbox.Remove(1)).To(Succeed())
+
+			Expect(outbox.Messages()).To(Equal([]OutboxMessage{
+				message(boshhandler.Alert, "alert-2"),
+				message(boshhandler.Heartbeat, "heartbeat-1"),
+			}))
+		})
+
+		It("removes heartbeat after all alerts", func() {
+			Expect(outbox.Remove(3)).To(Succeed())
+			Expect(outbox.Messages()).To(BeEmpty())
+			Expect(fs.FileExists("/fake-outbox.json")).To(BeFalse())
+
+			outbox = NewFileOutbox(fs, "/fake-outbox.json", 3)
+			Expect(outbox.Messages()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package mbus
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/yagnats"
+	"github.com/pivotal-golang/clock"
+)
+
+// ReconnectingConnectionProvider is given to yagnats client which asks it
+// for a new connection every time connection is lost. Repeated failures
+// are spaced out with backoff instead of retrying at a fixed rate.
+type ReconnectingConnectionProvider struct {
+	ConnectionInfo *yagnats.ConnectionInfo
+
+	backoff     Backoff
+	timeService clock.Clock
+
+	// Called before every connection attempt and after every successful one
+	onDisconnected func()
+	onConnected    func()
+
+	lastAttemptFailed bool
+	lock              sync.Mutex
+}
+
+func NewReconnectingConnectionProvider(
+	connectionInfo *yagnats.ConnectionInfo,
+	backoff Backoff,
+	timeService clock.Clock,
+	onDisconnected func(),
+	onConnected func(),
+) *ReconnectingConnectionProvider {
+	return &ReconnectingConnectionProvider{
+		ConnectionInfo: connectionInfo,
+		backoff:        backoff,
+		timeService:    timeService,
+		onDisconnected: onDisconnected,
+		onConnected:    onConnected,
+	}
+}
+
+func (p *ReconnectingConnectionProvider) ProvideConnection() (*yagnats.Connection, error) {
+	// Connections are only requested by one goroutine at a time
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.onDisconnected()
+
+	if p.lastAttemptFailed {
+		p.timeService.Sleep(p.backoff.Next())
+	}
+
+	conn, err := p.ConnectionInfo.ProvideConnection()
+	if err != nil {
+		p.lastAttemptFailed = true
+		return nil, err
+	}
+
+	p.lastAttemptFailed = false
+	p.backoff.Reset()
+
+	p.onConnected()
+
+	return conn, nil
+}
@@ -0,0 +1,189 @@
+package mbus_test
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/yagnats"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+)
+
+// natsServer accepts plain NATS connections acknowledging every command;
+// it can be stopped and started again on the same address to imitate NATS restart
+type natsServer struct {
+	addr     string
+	listener net.Listener
+	conns    []net.Conn
+
+	// Lines (e.g. CONNECT, SUB, PUB and payloads) sent by clients
+	lines chan string
+
+	lock sync.Mutex
+}
+
+func newNatsServer() *natsServer {
+	server := &natsServer{lines: make(chan string, 100)}
+	server.start("127.0.0.1:0")
+	return server
+}
+
+func (s *natsServer) start(addr string) {
+	listener, err := net.Listen("tcp", addr)
+	Expect(err).ToNot(HaveOccurred())
+
+	s.lock.Lock()
+	s.addr = listener.Addr().String()
+	s.listener = listener
+	s.lock.Unlock()
+
+	go s.serve(listener)
+}
+
+func (s *natsServer) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.lock.Lock()
+		s.conns = append(s.conns, conn)
+		s.lock.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *natsServer) serveConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimSpace(line)
+		s.lines <- line
+
+		for _, command := range []string{"CONNECT", "SUB", "UNSUB", "PUB"} {
+			if strings.HasPrefix(line, command+" ") {
+				fmt.Fprint(conn, "+OK\r\n")
+			}
+		}
+	}
+}
+
+func (s *natsServer) Addr() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.addr
+}
+
+// Stop closes connections and refuses new ones until restarted
+func (s *natsServer) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.listener.Close()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+
+	s.conns = nil
+}
+
+func (s *natsServer) Restart() {
+	s.start(s.Addr())
+}
+
+func init() {
+	Describe("ReconnectingConnectionProvider", func() {
+		var (
+			server      *natsServer
+			timeService *fakeclock.FakeClock
+			events      []string
+			provider    *ReconnectingConnectionProvider
+		)
+
+		BeforeEach(func() {
+			server = newNatsServer()
+			timeService = fakeclock.NewFakeClock(time.Now())
+			events = []string{}
+
+			provider = NewReconnectingConnectionProvider(
+				&yagnats.ConnectionInfo{Addr: server.Addr()},
+				NewExponentialBackoff(time.Second, time.Minute, rand.New(rand.NewSource(1))),
+				timeService,
+				func() { events = append(events, "disconnected") },
+				func() { events = append(events, "connected") },
+			)
+		})
+
+		AfterEach(func() {
+			server.Stop()
+		})
+
+		It("provides connection", func() {
+			conn, err := provider.ProvideConnection()
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Disconnect()
+
+			Expect(<-server.lines).To(HavePrefix("CONNECT"))
+			Expect(events).To(Equal([]string{"disconnected", "connected"}))
+		})
+
+		It("retries without delay when connection was lost after successful attempt", func() {
+			conn, err := provider.ProvideConnection()
+			Expect(err).ToNot(HaveOccurred())
+			conn.Disconnect()
+
+			conn, err = provider.ProvideConnection()
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Disconnect()
+
+			Expect(timeService.WatcherCount()).To(Equal(0))
+			Expect(events).To(Equal([]string{"disconnected", "connected", "disconnected", "connected"}))
+		})
+
+		It("waits before retrying after failed attempt", func() {
+			server.Stop()
+
+			_, err := provider.ProvideConnection()
+			Expect(err).To(HaveOccurred())
+			Expect(events).To(Equal([]string{"disconnected"}))
+
+			server.Restart()
+
+			connCh := make(chan *yagnats.Connection, 1)
+			go func() {
+				defer GinkgoRecover()
+
+				conn, err := provider.ProvideConnection()
+				Expect(err).ToNot(HaveOccurred())
+				connCh <- conn
+			}()
+
+			Eventually(timeService.WatcherCount).Should(Equal(1))
+			Consistently(connCh).ShouldNot(Receive())
+
+			timeService.Increment(time.Second)
+
+			var conn *yagnats.Connection
+			Eventually(connCh).Should(Receive(&conn))
+			defer conn.Disconnect()
+		})
+	})
+}
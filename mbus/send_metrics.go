@@ -0,0 +1,45 @@
+package mbus
+
+import (
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+)
+
+// sendMetrics are updated by transports themselves since only they know
+// whether message was published, queued until connection is back or dropped
+type sendMetrics struct {
+	sent     boshmetrics.Counter
+	queued   boshmetrics.Counter
+	dropped  boshmetrics.Counter
+	failures boshmetrics.Counter
+}
+
+func newSendMetrics(registry *boshmetrics.Registry) sendMetrics {
+	return sendMetrics{
+		sent: registry.Counter(
+			"bosh_agent_mbus_messages_sent_total",
+			"Number of messages published by agent by topic.",
+			"topic",
+		),
+		queued: registry.Counter(
+			"bosh_agent_mbus_messages_queued_total",
+			"Number of messages added to outbox since agent could not publish them by topic.",
+			"topic",
+		),
+		dropped: registry.Counter(
+			"bosh_agent_mbus_messages_dropped_total",
+			"Number of messages agent gave up sending by topic.",
+			"topic",
+		),
+		failures: registry.Counter(
+			"bosh_agent_mbus_send_failures_total",
+			"Number of times publishing a message failed by topic.",
+			"topic",
+		),
+	}
+}
+
+func (m sendMetrics) Sent(topic boshhandler.Topic)    { m.sent.Inc(string(topic)) }
+func (m sendMetrics) Queued(topic boshhandler.Topic)  { m.queued.Inc(string(topic)) }
+func (m sendMetrics) Dropped(topic boshhandler.Topic) { m.dropped.Inc(string(topic)) }
+func (m sendMetrics) Failed(topic boshhandler.Topic)  { m.failures.Inc(string(topic)) }
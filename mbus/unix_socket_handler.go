@@ -0,0 +1,223 @@
+package mbus
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	// Longest request line accepted over unix socket
+	unixSocketMaxRequestLength = 1024 * 1024
+)
+
+// DefaultUnixSocketActions only let local clients look at the agent's state
+var DefaultUnixSocketActions = []string{"get_state", "get_task", "list_disk", "ping"}
+
+type UnixSocketOptions struct {
+	// Path of the socket; defaults to run/agent_socket/agent.sock in bosh directory.
+	// Directory of the socket is restricted to root so it must not be shared with other files
+	Path string
+
+	// Actions that can be requested over the socket.
+	// Defaults to DefaultUnixSocketActions when not set
+	AllowedActions []string
+}
+
+type unixSocketHandler struct {
+	fs             boshsys.FileSystem
+	path           string
+	allowedActions map[string]bool
+	logger         boshlog.Logger
+	logTag         string
+
+	listener net.Listener
+	doneCh   chan struct{}
+	lock     sync.Mutex
+}
+
+// NewUnixSocketHandler accepts requests from local clients (e.g. operator scripts)
+// on a socket only root can connect to. Every request is a line of JSON
+// in the same format as NATS requests and is answered with a line of JSON.
+func NewUnixSocketHandler(
+	options UnixSocketOptions,
+	fs boshsys.FileSystem,
+	dirProvider boshdir.Provider,
+	logger boshlog.Logger,
+) boshhandler.Handler {
+	path := options.Path
+	if path == "" {
+		path = filepath.Join(dirProvider.BoshDir(), "run", "agent_socket", "agent.sock")
+	}
+
+	allowedActions := options.AllowedActions
+	if len(allowedActions) == 0 {
+		allowedActions = DefaultUnixSocketActions
+	}
+
+	allowed := map[string]bool{}
+	for _, action := range allowedActions {
+		allowed[action] = true
+	}
+
+	return &unixSocketHandler{
+		fs:             fs,
+		path:           path,
+		allowedActions: allowed,
+		logger:         logger,
+		logTag:         "Unix Socket Handler",
+	}
+}
+
+func (h *unixSocketHandler) Run(handlerFunc boshhandler.Func) error {
+	err := h.Start(handlerFunc)
+	if err != nil {
+		return bosherr.WrapError(err, "Starting unix socket handler")
+	}
+
+	h.lock.Lock()
+	doneCh := h.doneCh
+	h.lock.Unlock()
+
+	<-doneCh
+
+	return nil
+}
+
+func (h *unixSocketHandler) Start(handlerFunc boshhandler.Func) error {
+	// Socket is created in its own directory only root can enter
+	// so that nobody else can connect before permissions are set on the socket itself
+	// without restricting access to other files in shared run directory
+	socketDir := filepath.Dir(h.path)
+
+	err := h.fs.MkdirAll(filepath.Dir(socketDir), os.FileMode(0755))
+	if err != nil {
+		return bosherr.WrapError(err, "Creating socket parent directory")
+	}
+
+	err = h.fs.MkdirAll(socketDir, os.FileMode(0700))
+	if err != nil {
+		return bosherr.WrapError(err, "Creating socket directory")
+	}
+
+	err = h.fs.Chmod(socketDir, os.FileMode(0700))
+	if err != nil {
+		return bosherr.WrapError(err, "Restricting socket directory permissions")
+	}
+
+	// Socket might be left behind by previous agent run
+	err = h.fs.RemoveAll(h.path)
+	if err != nil {
+		return bosherr.WrapError(err, "Removing stale socket")
+	}
+
+	listener, err := net.Listen("unix", h.path)
+	if err != nil {
+		return bosherr.WrapError(err, "Listening on socket")
+	}
+
+	err = h.fs.Chmod(h.path, os.FileMode(0600))
+	if err != nil {
+		listener.Close()
+		return bosherr.WrapError(err, "Restricting socket permissions")
+	}
+
+	h.lock.Lock()
+	h.listener = listener
+	h.doneCh = make(chan struct{})
+	h.lock.Unlock()
+
+	h.logger.Info(h.logTag, "Listening on %s", h.path)
+
+	go h.acceptConns(listener, h.restrictedFunc(handlerFunc))
+
+	return nil
+}
+
+func (h *unixSocketHandler) RegisterAdditionalFunc(handlerFunc boshhandler.Func) {
+	panic("UnixSocketHandler does not support registering additional handler funcs")
+}
+
+// Send does nothing since local clients only ask for information
+func (h *unixSocketHandler) Send(target boshhandler.Target, topic boshhandler.Topic, message interface{}) error {
+	return nil
+}
+
+func (h *unixSocketHandler) Stop() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.listener != nil {
+		h.listener.Close()
+		h.listener = nil
+		close(h.doneCh)
+	}
+}
+
+func (h *unixSocketHandler) restrictedFunc(handlerFunc boshhandler.Func) boshhandler.Func {
+	return func(req boshhandler.Request) boshhandler.Response {
+		if !h.allowedActions[req.Method] {
+			return boshhandler.NewExceptionResponse(
+				bosherr.Errorf("Action %s is not allowed over unix socket", req.Method))
+		}
+		return handlerFunc(req)
+	}
+}
+
+func (h *unixSocketHandler) acceptConns(listener net.Listener, handlerFunc boshhandler.Func) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			h.logger.Debug(h.logTag, "Stopped accepting connections: %s", err.Error())
+			return
+		}
+
+		go h.serveConn(conn, handlerFunc)
+	}
+}
+
+func (h *unixSocketHandler) serveConn(conn net.Conn, handlerFunc boshhandler.Func) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), unixSocketMaxRequestLength)
+
+	for scanner.Scan() {
+		// Scanner reuses its buffer while request payload might be kept by handler
+		rawJSON := append([]byte{}, scanner.Bytes()...)
+
+		respBytes, _, err := boshhandler.PerformHandlerWithJSON(
+			rawJSON,
+			handlerFunc,
+			boshhandler.UnlimitedResponseLength,
+			h.logger,
+		)
+		if err != nil {
+			h.logger.Error(h.logTag, "Running handler: %s", err.Error())
+
+			respBytes, err = boshhandler.BuildErrorWithJSON(err.Error(), h.logger)
+			if err != nil {
+				return
+			}
+		}
+
+		// Every request gets a line back even if handler had nothing to say
+		if len(respBytes) == 0 {
+			respBytes = []byte("{}")
+		}
+
+		_, err = conn.Write(append(respBytes, '\n'))
+		if err != nil {
+			h.logger.Error(h.logTag, "Writing response: %s", err.Error())
+			return
+		}
+	}
+}
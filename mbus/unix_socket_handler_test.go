@@ -0,0 +1,256 @@
+package mbus_test
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("unixSocketHandler", func() {
+	var (
+		fs         boshsys.FileSystem
+		dir        string
+		socketPath string
+		options    UnixSocketOptions
+		handler    boshhandler.Handler
+
+		receivedRequests []boshhandler.Request
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "unix-socket-handler")
+		Expect(err).ToNot(HaveOccurred())
+
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		socketPath = filepath.Join(dir, "run", "agent_socket", "agent.sock")
+		options = UnixSocketOptions{Path: socketPath}
+		receivedRequests = []boshhandler.Request{}
+	})
+
+	AfterEach(func() {
+		if handler != nil {
+			handler.Stop()
+		}
+		os.RemoveAll(dir)
+	})
+
+	start := func() {
+		handler = NewUnixSocketHandler(options, fs, boshdir.NewProvider(dir), boshlog.NewLogger(boshlog.LevelNone))
+
+		err := handler.Start(func(req boshhandler.Request) boshhandler.Response {
+			receivedRequests = append(receivedRequests, req)
+			return boshhandler.NewValueResponse("fake-" + req.Method + "-value")
+		})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	request := func(conn net.Conn, reader *bufio.Reader, line string) string {
+		_, err := conn.Write([]byte(line + "\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := reader.ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+
+		return resp
+	}
+
+	It("answers every request line with a line of JSON", func() {
+		start()
+
+		conn, err := net.Dial("unix", socketPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+
+		resp := request(conn, reader, `{"method":"ping","arguments":[]}`)
+		Expect(resp).To(Equal(`{"value":"fake-ping-value"}` + "\n"))
+
+		resp = request(conn, reader, `{"method":"get_state","arguments":["full"]}`)
+		Expect(resp).To(Equal(`{"value":"fake-get_state-value"}` + "\n"))
+
+		Expect(receivedRequests).To(Equal([]boshhandler.Request{
+			{Method: "ping", Payload: []byte(`{"method":"ping","arguments":[]}`)},
+			{Method: "get_state", Payload: []byte(`{"method":"get_state","arguments":["full"]}`)},
+		}))
+	})
+
+	It("only allows read-only actions by default", func() {
+		start()
+
+		conn, err := net.Dial("unix", socketPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		resp := request(conn, bufio.NewReader(conn), `{"method":"apply","arguments":[{}]}`)
+		Expect(resp).To(MatchJSON(`{"exception":{"message":"Action apply is not allowed over unix socket"}}`))
+
+		Expect(receivedRequests).To(BeEmpty())
+	})
+
+	It("allows configured actions", func() {
+		options.AllowedActions = []string{"apply"}
+		start()
+
+		conn, err := net.Dial("unix", socketPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+
+		resp := request(conn, reader, `{"method":"apply","arguments":[{}]}`)
+		Expect(resp).To(MatchJSON(`{"value":"fake-apply-value"}`))
+
+		resp = request(conn, reader, `{"method":"ping","arguments":[]}`)
+		Expect(resp).To(MatchJSON(`{"exception":{"message":"Action ping is not allowed over unix socket"}}`))
+	})
+
+	It("responds with error when request is not valid JSON", func() {
+		start()
+
+		conn, err := net.Dial("unix", socketPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		resp := request(conn, bufio.NewReader(conn), `fake-invalid-json`)
+		Expect(resp).To(ContainSubstring(`"exception"`))
+		Expect(resp).To(ContainSubstring("Unmarshalling JSON payload"))
+	})
+
+	It("only lets owner (root) connect", func() {
+		start()
+
+		socketInfo, err := os.Stat(socketPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(socketInfo.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		dirInfo, err := os.Stat(filepath.Dir(socketPath))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dirInfo.Mode().Perm()).To(Equal(os.FileMode(0700)))
+	})
+
+	It("replaces socket left behind by previous run", func() {
+		Expect(os.MkdirAll(filepath.Dir(socketPath), 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(socketPath, []byte{}, 0600)).To(Succeed())
+
+		start()
+
+		conn, err := net.Dial("unix", socketPath)
+		Expect(err).ToNot(HaveOccurred())
+		conn.Close()
+	})
+
+	It("listens in bosh directory by default", func() {
+		options.Path = ""
+		start()
+
+		conn, err := net.Dial("unix", filepath.Join(dir, "bosh", "run", "agent_socket", "agent.sock"))
+		Expect(err).ToNot(HaveOccurred())
+		conn.Close()
+	})
+
+	It("does not restrict permissions of shared run directory", func() {
+		runDir := filepath.Join(dir, "bosh", "run")
+		Expect(os.MkdirAll(runDir, 0755)).To(Succeed())
+		Expect(os.Chmod(runDir, 0755)).To(Succeed())
+
+		options.Path = ""
+		start()
+
+		runDirInfo, err := os.Stat(runDir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runDirInfo.Mode().Perm()).To(Equal(os.FileMode(0755)))
+
+		socketDirInfo, err := os.Stat(filepath.Join(runDir, "agent_socket"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(socketDirInfo.Mode().Perm()).To(Equal(os.FileMode(0700)))
+	})
+
+	It("stops accepting connections once stopped", func() {
+		start()
+
+		handler.Stop()
+
+		_, err := net.Dial("unix", socketPath)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("when socket directory cannot be prepared", func() {
+		var (
+			fakeFs *fakesys.FakeFileSystem
+		)
+
+		BeforeEach(func() {
+			fakeFs = fakesys.NewFakeFileSystem()
+			fs = fakeFs
+		})
+
+		startErr := func() error {
+			handler = NewUnixSocketHandler(options, fs, boshdir.NewProvider(dir), boshlog.NewLogger(boshlog.LevelNone))
+			return handler.Start(func(req boshhandler.Request) boshhandler.Response { return nil })
+		}
+
+		It("returns error when socket directory cannot be created", func() {
+			fakeFs.MkdirAllError = errors.New("fake-mkdir-err")
+
+			err := startErr()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-mkdir-err"))
+		})
+
+		It("returns error when socket directory permissions cannot be restricted", func() {
+			fakeFs.ChmodErr = errors.New("fake-chmod-err")
+
+			err := startErr()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-chmod-err"))
+		})
+
+		It("returns error when stale socket cannot be removed", func() {
+			fakeFs.RemoveAllError = errors.New("fake-remove-err")
+
+			err := startErr()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-remove-err"))
+		})
+	})
+
+	Describe("Run", func() {
+		It("returns once stopped", func() {
+			handler = NewUnixSocketHandler(options, fs, boshdir.NewProvider(dir), boshlog.NewLogger(boshlog.LevelNone))
+
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- handler.Run(func(req boshhandler.Request) boshhandler.Response { return nil })
+			}()
+
+			Eventually(func() error {
+				conn, err := net.Dial("unix", socketPath)
+				if err == nil {
+					conn.Close()
+				}
+				return err
+			}).ShouldNot(HaveOccurred())
+
+			Consistently(errCh).ShouldNot(Receive())
+
+			handler.Stop()
+
+			Eventually(errCh).Should(Receive(BeNil()))
+		})
+	})
+})
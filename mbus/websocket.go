@@ -0,0 +1,346 @@
+package mbus
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	webSocketDialTimeout = 10 * time.Second
+
+	// Longest message controller is allowed to send
+	webSocketMaxMessageLength = 16 * 1024 * 1024
+
+	// Defined by RFC 6455 to compute Sec-WebSocket-Accept from Sec-WebSocket-Key
+	webSocketAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	webSocketOpContinuation = 0x0
+	webSocketOpText         = 0x1
+	webSocketOpBinary       = 0x2
+	webSocketOpClose        = 0x8
+	webSocketOpPing         = 0x9
+	webSocketOpPong         = 0xA
+)
+
+// errWebSocketClosed is returned once controller closed connection
+var errWebSocketClosed = errors.New("WebSocket connection was closed")
+
+// webSocketConn is the client side of RFC 6455 connection.
+// Only whole messages are exchanged; pings are answered while reading.
+type webSocketConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	logger boshlog.Logger
+	logTag string
+
+	// Frames of one message must not interleave with frames of another
+	writeLock sync.Mutex
+}
+
+// dialWebSocket connects to ws or wss URL and performs opening handshake.
+// User info of the URL is sent as basic auth credentials.
+func dialWebSocket(wsURL *url.URL, tlsConfig *tls.Config, logger boshlog.Logger) (*webSocketConn, error) {
+	host := wsURL.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		if wsURL.Scheme == "wss" {
+			host = net.JoinHostPort(host, "443")
+		} else {
+			host = net.JoinHostPort(host, "80")
+		}
+	}
+
+	var conn net.Conn
+	var err error
+
+	dialer := &net.Dialer{Timeout: webSocketDialTimeout}
+
+	if wsURL.Scheme == "wss" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Dialing %s", host)
+	}
+
+	err = conn.SetDeadline(time.Now().Add(webSocketDialTimeout))
+	if err != nil {
+		conn.Close()
+		return nil, bosherr.WrapError(err, "Setting handshake deadline")
+	}
+
+	reader := bufio.NewReader(conn)
+
+	err = webSocketHandshake(conn, reader, wsURL)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, bosherr.WrapError(err, "Clearing handshake deadline")
+	}
+
+	return &webSocketConn{conn: conn, reader: reader, logger: logger, logTag: "WebSocket Connection"}, nil
+}
+
+func webSocketHandshake(conn net.Conn, reader *bufio.Reader, wsURL *url.URL) error {
+	keyBytes := make([]byte, 16)
+
+	_, err := rand.Read(keyBytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Generating handshake key")
+	}
+
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	req := &http.Request{
+		Method:     "GET",
+		URL:        &url.URL{Path: wsURL.Path, RawQuery: wsURL.RawQuery},
+		Host:       wsURL.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-Websocket-Key":     {key},
+			"Sec-Websocket-Version": {"13"},
+		},
+	}
+
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+
+	if wsURL.User != nil {
+		password, _ := wsURL.User.Password()
+		req.SetBasicAuth(wsURL.User.Username(), password)
+	}
+
+	err = req.Write(conn)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing handshake request")
+	}
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading handshake response")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return bosherr.Errorf("Expected handshake response status 101 but got %d", resp.StatusCode)
+	}
+
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		return bosherr.Errorf("Expected connection to be upgraded to websocket but got '%s'", resp.Header.Get("Upgrade"))
+	}
+
+	if resp.Header.Get("Sec-Websocket-Accept") != webSocketAccept(key) {
+		return bosherr.Error("Handshake response has unexpected Sec-WebSocket-Accept")
+	}
+
+	return nil
+}
+
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketAcceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ReadMessage returns payload of next text or binary message
+func (c *webSocketConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case webSocketOpPing:
+			err = c.writeFrame(webSocketOpPong, payload)
+			if err != nil {
+				return nil, bosherr.WrapError(err, "Answering ping")
+			}
+			continue
+
+		case webSocketOpPong:
+			continue
+
+		case webSocketOpClose:
+			// Echo status code of controller to finish closing handshake
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			err = c.writeFrame(webSocketOpClose, payload)
+			if err != nil {
+				// Connection is going away anyway
+				c.logger.Warn(c.logTag, "Answering close frame: %s", err.Error())
+			}
+			return nil, errWebSocketClosed
+
+		case webSocketOpText, webSocketOpBinary:
+			if started {
+				return nil, bosherr.Error("Expected continuation frame")
+			}
+			started = true
+
+		case webSocketOpContinuation:
+			if !started {
+				return nil, bosherr.Error("Unexpected continuation frame")
+			}
+
+		default:
+			return nil, bosherr.Errorf("Unknown frame opcode %d", opcode)
+		}
+
+		if len(message)+len(payload) > webSocketMaxMessageLength {
+			return nil, bosherr.Errorf("Message is longer than %d bytes", webSocketMaxMessageLength)
+		}
+
+		message = append(message, payload...)
+
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// WriteMessage sends payload as one text message
+func (c *webSocketConn) WriteMessage(payload []byte) error {
+	return c.writeFrame(webSocketOpText, payload)
+}
+
+// Close sends close frame and closes connection without waiting for the reply
+func (c *webSocketConn) Close() error {
+	err := c.writeFrame(webSocketOpClose, []byte{0x03, 0xE8}) // 1000: normal closure
+	if err != nil {
+		c.logger.Warn(c.logTag, "Sending close frame: %s", err.Error())
+	}
+
+	return c.conn.Close()
+}
+
+func (c *webSocketConn) readFrame() (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+
+	_, err := io.ReadFull(c.reader, header)
+	if err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		_, err = io.ReadFull(c.reader, extended)
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		_, err = io.ReadFull(c.reader, extended)
+		length = binary.BigEndian.Uint64(extended)
+	}
+
+	if err != nil {
+		return false, 0, nil, err
+	}
+
+	if length > webSocketMaxMessageLength {
+		return false, 0, nil, bosherr.Errorf("Frame is longer than %d bytes", webSocketMaxMessageLength)
+	}
+
+	// Servers must not mask frames but masked ones are still understood
+	mask := make([]byte, 4)
+	if masked {
+		_, err = io.ReadFull(c.reader, mask)
+		if err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+
+	_, err = io.ReadFull(c.reader, payload)
+	if err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		maskWebSocketPayload(payload, mask)
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame sends single masked frame as required from clients
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+
+	length := len(payload)
+
+	switch {
+	case length < 126:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+
+	mask := make([]byte, 4)
+
+	_, err := rand.Read(mask)
+	if err != nil {
+		return bosherr.WrapError(err, "Generating frame mask")
+	}
+
+	frame = append(frame, mask...)
+
+	maskedPayload := make([]byte, length)
+	copy(maskedPayload, payload)
+	maskWebSocketPayload(maskedPayload, mask)
+
+	frame = append(frame, maskedPayload...)
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	_, err = c.conn.Write(frame)
+
+	return err
+}
+
+func maskWebSocketPayload(payload, mask []byte) {
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+}
@@ -0,0 +1,376 @@
+package mbus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pivotal-golang/clock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// WebSocketMessage is sent by agent for every response and message.
+// Subject is named the same way as on NATS so that controller can route
+// responses by reply_to of requests and messages by target and topic
+// (e.g. hm.agent.heartbeat.<agent-id>).
+type WebSocketMessage struct {
+	Subject string          `json:"subject"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// webSocketHandler dials out to controller at ws or wss mbus URL
+// so that agents behind NAT or firewalls can be managed without
+// opening inbound ports. Controller sends requests as text messages
+// with the same JSON as on NATS and agent answers with WebSocketMessage.
+type webSocketHandler struct {
+	settingsService boshsettings.Service
+	backoff         Backoff
+	timeService     clock.Clock
+	logger          boshlog.Logger
+	handlerFuncs    []boshhandler.Func
+	logTag          string
+
+	agentID string
+
+	conn        *webSocketConn
+	connections int
+	stopped     bool
+	connLock    sync.Mutex
+
+	// Closed once handler is stopped to interrupt waiting for reconnect
+	stopCh chan struct{}
+
+	connectedGauge   boshmetrics.Gauge
+	reconnectCounter boshmetrics.Counter
+	sendMetrics      sendMetrics
+}
+
+func init() {
+	for _, scheme := range []string{"ws", "wss"} {
+		RegisterHandlerFactory(scheme, newWebSocketHandlerFromURL)
+	}
+}
+
+func newWebSocketHandlerFromURL(mbusURL *url.URL, deps HandlerDeps) (boshhandler.Handler, error) {
+	return NewWebSocketHandler(
+		deps.SettingsService,
+		newReconnectBackoff(deps.Options),
+		deps.TimeService,
+		deps.MetricsRegistry,
+		deps.Logger,
+	), nil
+}
+
+func NewWebSocketHandler(
+	settingsService boshsettings.Service,
+	backoff Backoff,
+	timeService clock.Clock,
+	registry *boshmetrics.Registry,
+	logger boshlog.Logger,
+) Handler {
+	return &webSocketHandler{
+		settingsService: settingsService,
+		backoff:         backoff,
+		timeService:     timeService,
+		logger:          logger,
+		logTag:          "WebSocket Handler",
+
+		stopCh: make(chan struct{}),
+
+		connectedGauge: registry.Gauge(
+			"bosh_agent_mbus_connected",
+			"Whether agent is connected to message bus (1) or not (0).",
+		),
+		reconnectCounter: registry.Counter(
+			"bosh_agent_mbus_reconnects_total",
+			"Number of times agent connected to message bus again after losing connection.",
+		),
+		sendMetrics: newSendMetrics(registry),
+	}
+}
+
+func (h *webSocketHandler) Run(handlerFunc boshhandler.Func) error {
+	err := h.Start(handlerFunc)
+	if err != nil {
+		return bosherr.WrapError(err, "Starting websocket handler")
+	}
+
+	defer h.Stop()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	<-c
+
+	return nil
+}
+
+// Start returns once controller URL is checked;
+// connecting and reconnecting happens in the background
+func (h *webSocketHandler) Start(handlerFunc boshhandler.Func) error {
+	h.RegisterAdditionalFunc(handlerFunc)
+
+	settings := h.settingsService.GetSettings()
+
+	wsURL, err := url.Parse(settings.Mbus)
+	if err != nil {
+		return bosherr.WrapError(err, "Parsing WebSocket URL")
+	}
+
+	var tlsConfig *tls.Config
+
+	if wsURL.Scheme == "wss" {
+		host, _, err := net.SplitHostPort(wsURL.Host)
+		if err != nil {
+			host = wsURL.Host
+		}
+
+		tlsConfig, err = webSocketTLSConfig(settings.Env.Bosh.Mbus.Cert, host)
+		if err != nil {
+			return bosherr.WrapError(err, "Building TLS config")
+		}
+	}
+
+	h.agentID = settings.AgentID
+
+	go h.serve(wsURL, tlsConfig)
+
+	return nil
+}
+
+func (h *webSocketHandler) RegisterAdditionalFunc(handlerFunc boshhandler.Func) {
+	// Currently not locking since RegisterAdditionalFunc
+	// is not a primary way of adding handlerFunc.
+	h.handlerFuncs = append(h.handlerFuncs, handlerFunc)
+}
+
+// Send fails when agent is not connected to controller;
+// heartbeats are sent again soon and alerts are only best effort
+func (h *webSocketHandler) Send(target boshhandler.Target, topic boshhandler.Topic, message interface{}) error {
+	bytes, err := json.Marshal(message)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling message (target=%s, topic=%s): %#v", target, topic, message)
+	}
+
+	h.logger.Info(h.logTag, "Sending %s message '%s'", target, topic)
+	h.logger.DebugWithDetails(h.logTag, "Message Payload", string(boshhandler.RedactJSON(bytes)))
+
+	conn := h.currentConn()
+	if conn == nil {
+		h.sendMetrics.Dropped(topic)
+		return bosherr.Errorf("Not connected, cannot send %s message '%s'", target, topic)
+	}
+
+	subject := fmt.Sprintf("%s.agent.%s.%s", target, topic, h.agentID)
+
+	err = h.write(conn, subject, bytes)
+	if err != nil {
+		h.sendMetrics.Failed(topic)
+		h.sendMetrics.Dropped(topic)
+		return err
+	}
+
+	h.sendMetrics.Sent(topic)
+
+	return nil
+}
+
+func (h *webSocketHandler) Stop() {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	if h.stopped {
+		return
+	}
+
+	h.stopped = true
+	close(h.stopCh)
+
+	if h.conn != nil {
+		h.conn.Close()
+		h.conn = nil
+	}
+
+	h.connectedGauge.Set(0)
+}
+
+func (h *webSocketHandler) serve(wsURL *url.URL, tlsConfig *tls.Config) {
+	for {
+		conn, err := dialWebSocket(wsURL, tlsConfig, h.logger)
+		if err != nil {
+			// URL is not logged since it might have credentials
+			h.logger.Error(h.logTag, "Connecting to %s: %s", wsURL.Host, err.Error())
+
+			timer := h.timeService.NewTimer(h.backoff.Next())
+
+			select {
+			case <-timer.C():
+				continue
+			case <-h.stopCh:
+				timer.Stop()
+				return
+			}
+		}
+
+		h.backoff.Reset()
+
+		if !h.setConn(conn) {
+			conn.Close()
+			return
+		}
+
+		h.logger.Info(h.logTag, "Connected to %s", wsURL.Host)
+
+		h.readRequests(conn)
+
+		if !h.clearConn(conn) {
+			return
+		}
+	}
+}
+
+func (h *webSocketHandler) readRequests(conn *webSocketConn) {
+	for {
+		payload, err := conn.ReadMessage()
+		if err != nil {
+			h.logger.Error(h.logTag, "Reading request: %s", err.Error())
+			conn.Close()
+			return
+		}
+
+		// Long running requests must not hold up reading of following ones
+		go h.handleRequest(conn, payload)
+	}
+}
+
+func (h *webSocketHandler) handleRequest(conn *webSocketConn, payload []byte) {
+	for _, handlerFunc := range h.handlerFuncs {
+		identifyingHandlerFunc := func(req boshhandler.Request) boshhandler.Response {
+			if req.RequestID == "" {
+				req.RequestID = req.ReplyTo
+			}
+			return handlerFunc(req)
+		}
+
+		respBytes, req, err := boshhandler.PerformHandlerWithJSON(
+			payload,
+			identifyingHandlerFunc,
+			boshhandler.UnlimitedResponseLength,
+			h.logger,
+		)
+		if err != nil {
+			h.logger.Error(h.logTag, "Running handler: %s", err)
+			return
+		}
+
+		if len(respBytes) == 0 {
+			continue
+		}
+
+		err = h.write(conn, req.ReplyTo, respBytes)
+		if err != nil {
+			h.logger.Error(h.logTag, "Responding: %s", err)
+		}
+	}
+}
+
+func (h *webSocketHandler) write(conn *webSocketConn, subject string, payload []byte) error {
+	msgBytes, err := json.Marshal(WebSocketMessage{Subject: subject, Payload: payload})
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling websocket message")
+	}
+
+	err = conn.WriteMessage(msgBytes)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing websocket message (subject=%s)", subject)
+	}
+
+	return nil
+}
+
+func (h *webSocketHandler) currentConn() *webSocketConn {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	return h.conn
+}
+
+// setConn returns false when handler was stopped while connecting
+func (h *webSocketHandler) setConn(conn *webSocketConn) bool {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	if h.stopped {
+		return false
+	}
+
+	h.conn = conn
+	h.connections++
+
+	if h.connections > 1 {
+		h.reconnectCounter.Inc()
+	}
+
+	h.connectedGauge.Set(1)
+
+	return true
+}
+
+// clearConn returns false when connection was lost because handler was stopped
+func (h *webSocketHandler) clearConn(conn *webSocketConn) bool {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	if h.stopped {
+		return false
+	}
+
+	if h.conn == conn {
+		h.conn = nil
+	}
+
+	h.connectedGauge.Set(0)
+
+	return true
+}
+
+// webSocketTLSConfig trusts system CAs unless CA is given
+// and presents client certificate to controller when one is given
+func webSocketTLSConfig(cert boshsettings.CertKeyPair, serverName string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cert.CA != "" {
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM([]byte(cert.CA)) {
+			return nil, bosherr.Error("Parsing CA certificate")
+		}
+
+		tlsConfig.RootCAs = caPool
+	}
+
+	if cert.Certificate != "" || cert.PrivateKey != "" {
+		clientCert, err := tls.X509KeyPair([]byte(cert.Certificate), []byte(cert.PrivateKey))
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Parsing client certificate and private key")
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	return tlsConfig, nil
+}
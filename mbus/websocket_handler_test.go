@@ -0,0 +1,403 @@
+package mbus_test
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// webSocketServer plays controller agent connects to;
+// every upgraded connection is handed out over conns
+type webSocketServer struct {
+	server *httptest.Server
+	conns  chan *testWebSocketConn
+}
+
+type testWebSocketConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	request *http.Request
+}
+
+func newWebSocketServer(listener net.Listener, tlsConfig *tls.Config) *webSocketServer {
+	s := &webSocketServer{conns: make(chan *testWebSocketConn, 10)}
+
+	s.server = httptest.NewUnstartedServer(http.HandlerFunc(s.upgrade))
+
+	if listener != nil {
+		s.server.Listener.Close()
+		s.server.Listener = listener
+	}
+
+	if tlsConfig != nil {
+		s.server.TLS = tlsConfig
+		s.server.StartTLS()
+	} else {
+		s.server.Start()
+	}
+
+	return s
+}
+
+func (s *webSocketServer) Addr() string {
+	return s.server.Listener.Addr().String()
+}
+
+func (s *webSocketServer) Close() {
+	s.server.CloseClientConnections()
+	s.server.Close()
+}
+
+func (s *webSocketServer) upgrade(w http.ResponseWriter, r *http.Request) {
+	Expect(r.Header.Get("Upgrade")).To(Equal("websocket"))
+	Expect(r.Header.Get("Sec-Websocket-Version")).To(Equal("13"))
+
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-Websocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	Expect(err).ToNot(HaveOccurred())
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	Expect(rw.Flush()).To(Succeed())
+
+	s.conns <- &testWebSocketConn{conn: conn, reader: rw.Reader, request: r}
+}
+
+// writeFrame sends unmasked frame as servers do
+func (c *testWebSocketConn) writeFrame(fin bool, opcode byte, payload []byte) {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+
+	frame := []byte{first}
+
+	if len(payload) < 126 {
+		frame = append(frame, byte(len(payload)))
+	} else {
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	}
+
+	_, err := c.conn.Write(append(frame, payload...))
+	Expect(err).ToNot(HaveOccurred())
+}
+
+func (c *testWebSocketConn) writeText(payload string) {
+	c.writeFrame(true, 0x1, []byte(payload))
+}
+
+// readFrame expects frame to be masked as clients must do
+func (c *testWebSocketConn) readFrame() (byte, []byte) {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	header := make([]byte, 2)
+	_, err := io.ReadFull(c.reader, header)
+	Expect(err).ToNot(HaveOccurred())
+
+	Expect(header[0] & 0x80).To(Equal(byte(0x80)))
+	Expect(header[1] & 0x80).To(Equal(byte(0x80)))
+
+	length := int(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		_, err = io.ReadFull(c.reader, extended)
+		Expect(err).ToNot(HaveOccurred())
+		length = int(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		_, err = io.ReadFull(c.reader, extended)
+		Expect(err).ToNot(HaveOccurred())
+		length = int(binary.BigEndian.Uint64(extended))
+	}
+
+	mask := make([]byte, 4)
+	_, err = io.ReadFull(c.reader, mask)
+	Expect(err).ToNot(HaveOccurred())
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.reader, payload)
+	Expect(err).ToNot(HaveOccurred())
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return header[0] & 0x0F, payload
+}
+
+func (c *testWebSocketConn) readMessage() WebSocketMessage {
+	opcode, payload := c.readFrame()
+	Expect(opcode).To(Equal(byte(0x1)))
+
+	var message WebSocketMessage
+	Expect(json.Unmarshal(payload, &message)).To(Succeed())
+
+	return message
+}
+
+var _ = Describe("webSocketHandler", func() {
+	var (
+		settingsService *fakesettings.FakeSettingsService
+		timeService     *fakeclock.FakeClock
+		registry        *boshmetrics.Registry
+		server          *webSocketServer
+		handler         boshhandler.Handler
+	)
+
+	BeforeEach(func() {
+		settingsService = &fakesettings.FakeSettingsService{
+			Settings: boshsettings.Settings{AgentID: "my-agent-id"},
+		}
+		timeService = fakeclock.NewFakeClock(time.Now())
+		registry = boshmetrics.NewRegistry()
+	})
+
+	AfterEach(func() {
+		if handler != nil {
+			handler.Stop()
+		}
+		if server != nil {
+			server.Close()
+		}
+	})
+
+	newHandler := func() boshhandler.Handler {
+		backoff := NewExponentialBackoff(time.Second, time.Minute, rand.New(rand.NewSource(1)))
+		return NewWebSocketHandler(settingsService, backoff, timeService, registry, boshlog.NewLogger(boshlog.LevelNone))
+	}
+
+	metricValue := func(name string) float64 {
+		for _, family := range registry.Families() {
+			if family.Name == name && len(family.Samples) == 1 {
+				return family.Samples[0].Value
+			}
+		}
+		return 0
+	}
+
+	start := func(handlerFunc boshhandler.Func) *testWebSocketConn {
+		handler = newHandler()
+		Expect(handler.Start(handlerFunc)).To(Succeed())
+
+		var conn *testWebSocketConn
+		Eventually(server.conns, 5*time.Second).Should(Receive(&conn))
+
+		return conn
+	}
+
+	Context("when controller is reachable", func() {
+		BeforeEach(func() {
+			server = newWebSocketServer(nil, nil)
+			settingsService.Settings.Mbus = "ws://fake-user:fake-password@" + server.Addr() + "/agents?fake=param"
+		})
+
+		It("dials out to controller with credentials from URL", func() {
+			conn := start(func(req boshhandler.Request) boshhandler.Response { return nil })
+
+			Expect(conn.request.URL.Path).To(Equal("/agents"))
+			Expect(conn.request.URL.RawQuery).To(Equal("fake=param"))
+
+			username, password, ok := conn.request.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(username).To(Equal("fake-user"))
+			Expect(password).To(Equal("fake-password"))
+
+			Eventually(func() float64 { return metricValue("bosh_agent_mbus_connected") }).Should(Equal(float64(1)))
+		})
+
+		It("answers requests with responses addressed to reply_to", func() {
+			receivedRequests := make(chan boshhandler.Request, 1)
+
+			conn := start(func(req boshhandler.Request) boshhandler.Response {
+				receivedRequests <- req
+				return boshhandler.NewValueResponse("fake-" + req.Method + "-value")
+			})
+
+			conn.writeText(`{"method":"ping","arguments":[],"reply_to":"director.fake-reply-to"}`)
+
+			Expect(conn.readMessage()).To(Equal(WebSocketMessage{
+				Subject: "director.fake-reply-to",
+				Payload: json.RawMessage(`{"value":"fake-ping-value"}`),
+			}))
+
+			var req boshhandler.Request
+			Eventually(receivedRequests).Should(Receive(&req))
+			Expect(req.Method).To(Equal("ping"))
+			Expect(req.RequestID).To(Equal("director.fake-reply-to"))
+		})
+
+		It("understands requests split into several frames", func() {
+			conn := start(func(req boshhandler.Request) boshhandler.Response {
+				return boshhandler.NewValueResponse("fake-value")
+			})
+
+			conn.writeFrame(false, 0x1, []byte(`{"method":"ping",`))
+			conn.writeFrame(true, 0x0, []byte(`"arguments":[],"reply_to":"fake-reply-to"}`))
+
+			Expect(conn.readMessage().Subject).To(Equal("fake-reply-to"))
+		})
+
+		It("answers pings with pongs", func() {
+			conn := start(func(req boshhandler.Request) boshhandler.Response { return nil })
+
+			conn.writeFrame(true, 0x9, []byte("fake-ping"))
+
+			opcode, payload := conn.readFrame()
+			Expect(opcode).To(Equal(byte(0xA)))
+			Expect(string(payload)).To(Equal("fake-ping"))
+		})
+
+		It("sends messages addressed by target and topic", func() {
+			conn := start(func(req boshhandler.Request) boshhandler.Response { return nil })
+
+			Eventually(func() error {
+				return handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{"id": "heartbeat-1"})
+			}).Should(Succeed())
+
+			Expect(conn.readMessage()).To(Equal(WebSocketMessage{
+				Subject: "hm.agent.heartbeat.my-agent-id",
+				Payload: json.RawMessage(`{"id":"heartbeat-1"}`),
+			}))
+		})
+
+		It("connects again once connection is lost", func() {
+			conn := start(func(req boshhandler.Request) boshhandler.Response { return nil })
+
+			conn.conn.Close()
+
+			Eventually(server.conns, 5*time.Second).Should(Receive(&conn))
+			Eventually(func() float64 { return metricValue("bosh_agent_mbus_reconnects_total") }).Should(Equal(float64(1)))
+		})
+
+		It("closes connection once stopped", func() {
+			conn := start(func(req boshhandler.Request) boshhandler.Response { return nil })
+
+			handler.Stop()
+
+			opcode, _ := conn.readFrame()
+			Expect(opcode).To(Equal(byte(0x8)))
+
+			Expect(metricValue("bosh_agent_mbus_connected")).To(Equal(float64(0)))
+			Consistently(server.conns).ShouldNot(Receive())
+		})
+	})
+
+	It("fails to send messages when not connected", func() {
+		handler = newHandler()
+
+		err := handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, "fake-message")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Not connected"))
+	})
+
+	It("keeps trying to connect with backoff until controller is reachable", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		addr := listener.Addr().String()
+		Expect(listener.Close()).To(Succeed())
+
+		settingsService.Settings.Mbus = "ws://" + addr
+
+		handler = newHandler()
+		Expect(handler.Start(func(req boshhandler.Request) boshhandler.Response { return nil })).To(Succeed())
+
+		// Connection attempt failed and next one is waiting for backoff delay
+		Eventually(timeService.WatcherCount, 5*time.Second).Should(Equal(1))
+
+		listener, err = net.Listen("tcp", addr)
+		Expect(err).ToNot(HaveOccurred())
+
+		server = newWebSocketServer(listener, nil)
+
+		timeService.Increment(time.Minute)
+
+		Eventually(server.conns, 5*time.Second).Should(Receive())
+	})
+
+	Context("when controller URL has wss scheme", func() {
+		var (
+			ca         testCert
+			serverCert testCert
+			clientCert testCert
+		)
+
+		BeforeEach(func() {
+			ca = newTestCert("fake-ca", nil, true)
+			serverCert = newTestCert("127.0.0.1", &ca, false)
+			clientCert = newTestCert("fake-agent", &ca, false)
+
+			caPool := x509.NewCertPool()
+			caPool.AddCert(ca.cert)
+
+			tlsCert, err := tls.X509KeyPair([]byte(serverCert.certPEM), []byte(serverCert.keyPEM))
+			Expect(err).ToNot(HaveOccurred())
+
+			server = newWebSocketServer(nil, &tls.Config{
+				Certificates: []tls.Certificate{tlsCert},
+				ClientCAs:    caPool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+			})
+
+			settingsService.Settings.Mbus = "wss://" + server.Addr() + "/agents"
+		})
+
+		It("trusts CA and presents client certificate from settings", func() {
+			settingsService.Settings.Env.Bosh.Mbus.Cert = boshsettings.CertKeyPair{
+				CA:          ca.certPEM,
+				Certificate: clientCert.certPEM,
+				PrivateKey:  clientCert.keyPEM,
+			}
+
+			conn := start(func(req boshhandler.Request) boshhandler.Response {
+				return boshhandler.NewValueResponse("fake-value")
+			})
+
+			Expect(conn.request.TLS.PeerCertificates[0].Subject.CommonName).To(Equal("fake-agent"))
+
+			conn.writeText(`{"method":"ping","arguments":[],"reply_to":"fake-reply-to"}`)
+			Expect(conn.readMessage().Subject).To(Equal("fake-reply-to"))
+		})
+
+		It("returns error if client certificate cannot be parsed", func() {
+			settingsService.Settings.Env.Bosh.Mbus.Cert = boshsettings.CertKeyPair{
+				Certificate: "fake-invalid-cert",
+				PrivateKey:  "fake-invalid-key",
+			}
+
+			handler = newHandler()
+
+			err := handler.Start(func(req boshhandler.Request) boshhandler.Response { return nil })
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing client certificate and private key"))
+		})
+	})
+})
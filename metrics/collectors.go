@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const collectorsLogTag = "Metrics Collectors"
+
+// NewTasksCollector reports number of tasks that are queued or running
+func NewTasksCollector(taskService boshtask.Service) CollectFunc {
+	return func() []Family {
+		counts := map[boshtask.State]int{}
+
+		for _, summary := range taskService.ListTasks() {
+			counts[summary.State]++
+		}
+
+		family := Family{
+			Name: "bosh_agent_tasks",
+			Help: "Number of tasks in flight by state.",
+			Type: TypeGauge,
+		}
+
+		for _, state := range []boshtask.State{boshtask.StateQueued, boshtask.StateRunning} {
+			family.Samples = append(family.Samples, Sample{
+				Labels: []Label{{Name: "state", Value: string(state)}},
+				Value:  float64(counts[state]),
+			})
+		}
+
+		return []Family{family}
+	}
+}
+
+// NewVitalsCollector reports vitals agent already collects for heartbeats
+// so that node exporters do not have to collect them again
+func NewVitalsCollector(vitalsService boshvitals.Service, logger boshlog.Logger) CollectFunc {
+	return func() []Family {
+		vitals, err := vitalsService.Get()
+		if err != nil {
+			logger.Error(collectorsLogTag, "Getting vitals: %s", err.Error())
+			return nil
+		}
+
+		load := newVitalsFamily("bosh_agent_vitals_load", "System load average.")
+		for i, period := range []string{"1m", "5m", "15m"} {
+			if i < len(vitals.Load) {
+				load.add(vitals.Load[i], Label{Name: "period", Value: period})
+			}
+		}
+
+		cpu := newVitalsFamily("bosh_agent_vitals_cpu_percent", "CPU usage in percent by mode.")
+		cpu.add(vitals.CPU.Sys, Label{Name: "mode", Value: "sys"})
+		cpu.add(vitals.CPU.User, Label{Name: "mode", Value: "user"})
+		cpu.add(vitals.CPU.Wait, Label{Name: "mode", Value: "wait"})
+
+		memPercent := newVitalsFamily("bosh_agent_vitals_mem_percent", "Memory usage in percent.")
+		memPercent.add(vitals.Mem.Percent)
+
+		memKb := newVitalsFamily("bosh_agent_vitals_mem_kb", "Memory usage in kilobytes.")
+		memKb.add(vitals.Mem.Kb)
+
+		swapPercent := newVitalsFamily("bosh_agent_vitals_swap_percent", "Swap usage in percent.")
+		swapPercent.add(vitals.Swap.Percent)
+
+		swapKb := newVitalsFamily("bosh_agent_vitals_swap_kb", "Swap usage in kilobytes.")
+		swapKb.add(vitals.Swap.Kb)
+
+		diskPercent := newVitalsFamily("bosh_agent_vitals_disk_percent", "Disk usage in percent by disk.")
+		diskInodePercent := newVitalsFamily("bosh_agent_vitals_disk_inode_percent", "Disk inode usage in percent by disk.")
+
+		diskNames := []string{}
+		for diskName := range vitals.Disk {
+			diskNames = append(diskNames, diskName)
+		}
+
+		sort.Strings(diskNames)
+
+		for _, diskName := range diskNames {
+			diskLabel := Label{Name: "disk", Value: diskName}
+			diskPercent.add(vitals.Disk[diskName].Percent, diskLabel)
+			diskInodePercent.add(vitals.Disk[diskName].InodePercent, diskLabel)
+		}
+
+		families := []Family{}
+
+		for _, f := range []*vitalsFamily{load, cpu, memPercent, memKb, swapPercent, swapKb, diskPercent, diskInodePercent} {
+			if len(f.Samples) > 0 {
+				families = append(families, f.Family)
+			}
+		}
+
+		return families
+	}
+}
+
+// vitalsFamily collects gauges from vitals which are kept as strings
+type vitalsFamily struct {
+	Family
+}
+
+func newVitalsFamily(name, help string) *vitalsFamily {
+	return &vitalsFamily{Family{Name: name, Help: help, Type: TypeGauge}}
+}
+
+// add skips values that were not collected (e.g. disk that is not mounted)
+func (f *vitalsFamily) add(value string, labels ...Label) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+
+	f.Samples = append(f.Samples, Sample{Labels: labels, Value: v})
+}
@@ -0,0 +1,128 @@
+package metrics_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
+	. "github.com/cloudfoundry/bosh-agent/metrics"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	fakevitals "github.com/cloudfoundry/bosh-agent/platform/vitals/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("NewTasksCollector", func() {
+	It("reports number of queued and running tasks", func() {
+		taskService := faketask.NewFakeService()
+		taskService.ListedTasks = []boshtask.Summary{
+			{State: boshtask.StateRunning},
+			{State: boshtask.StateRunning},
+			{State: boshtask.StateDone},
+		}
+
+		Expect(NewTasksCollector(taskService)()).To(Equal([]Family{
+			{
+				Name: "bosh_agent_tasks",
+				Help: "Number of tasks in flight by state.",
+				Type: TypeGauge,
+				Samples: []Sample{
+					{Labels: []Label{{Name: "state", Value: "queued"}}, Value: 0},
+					{Labels: []Label{{Name: "state", Value: "running"}}, Value: 2},
+				},
+			},
+		}))
+	})
+})
+
+var _ = Describe("NewVitalsCollector", func() {
+	var (
+		vitalsService *fakevitals.FakeService
+		collect       CollectFunc
+	)
+
+	BeforeEach(func() {
+		vitalsService = fakevitals.NewFakeService()
+		collect = NewVitalsCollector(vitalsService, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	samplesOf := func(families []Family, name string) []Sample {
+		for _, family := range families {
+			if family.Name == name {
+				Expect(family.Type).To(Equal(TypeGauge))
+				return family.Samples
+			}
+		}
+		return nil
+	}
+
+	It("reports collected vitals as gauges", func() {
+		vitalsService.GetVitals = boshvitals.Vitals{
+			Load: []string{"0.2", "4.55", "1.123"},
+			CPU: boshvitals.CPUVitals{
+				User: "15.6",
+				Sys:  "1.3",
+				Wait: "0.3",
+			},
+			Mem: boshvitals.MemoryVitals{
+				Kb:      "2048",
+				Percent: "50",
+			},
+			Swap: boshvitals.MemoryVitals{
+				Kb:      "1024",
+				Percent: "25",
+			},
+			Disk: boshvitals.DiskVitals{
+				"system":     boshvitals.SpecificDiskVitals{Percent: "10", InodePercent: "2"},
+				"persistent": boshvitals.SpecificDiskVitals{Percent: "80", InodePercent: "8"},
+			},
+		}
+
+		families := collect()
+
+		Expect(samplesOf(families, "bosh_agent_vitals_load")).To(Equal([]Sample{
+			{Labels: []Label{{Name: "period", Value: "1m"}}, Value: 0.2},
+			{Labels: []Label{{Name: "period", Value: "5m"}}, Value: 4.55},
+			{Labels: []Label{{Name: "period", Value: "15m"}}, Value: 1.123},
+		}))
+
+		Expect(samplesOf(families, "bosh_agent_vitals_cpu_percent")).To(Equal([]Sample{
+			{Labels: []Label{{Name: "mode", Value: "sys"}}, Value: 1.3},
+			{Labels: []Label{{Name: "mode", Value: "user"}}, Value: 15.6},
+			{Labels: []Label{{Name: "mode", Value: "wait"}}, Value: 0.3},
+		}))
+
+		Expect(samplesOf(families, "bosh_agent_vitals_mem_kb")).To(Equal([]Sample{{Value: 2048}}))
+		Expect(samplesOf(families, "bosh_agent_vitals_mem_percent")).To(Equal([]Sample{{Value: 50}}))
+		Expect(samplesOf(families, "bosh_agent_vitals_swap_kb")).To(Equal([]Sample{{Value: 1024}}))
+		Expect(samplesOf(families, "bosh_agent_vitals_swap_percent")).To(Equal([]Sample{{Value: 25}}))
+
+		Expect(samplesOf(families, "bosh_agent_vitals_disk_percent")).To(Equal([]Sample{
+			{Labels: []Label{{Name: "disk", Value: "persistent"}}, Value: 80},
+			{Labels: []Label{{Name: "disk", Value: "system"}}, Value: 10},
+		}))
+
+		Expect(samplesOf(families, "bosh_agent_vitals_disk_inode_percent")).To(Equal([]Sample{
+			{Labels: []Label{{Name: "disk", Value: "persistent"}}, Value: 8},
+			{Labels: []Label{{Name: "disk", Value: "system"}}, Value: 2},
+		}))
+	})
+
+	It("skips vitals that were not collected", func() {
+		vitalsService.GetVitals = boshvitals.Vitals{
+			Mem: boshvitals.MemoryVitals{Percent: "50"},
+		}
+
+		families := collect()
+		Expect(families).To(HaveLen(1))
+		Expect(families[0].Name).To(Equal("bosh_agent_vitals_mem_percent"))
+	})
+
+	It("reports nothing when vitals cannot be collected", func() {
+		vitalsService.GetErr = errors.New("fake-get-err")
+
+		Expect(collect()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type instrumentedBlobstore struct {
+	boshblob.Blobstore
+
+	fs boshsys.FileSystem
+
+	downloadedBytes Counter
+	uploadedBytes   Counter
+	failures        Counter
+}
+
+// NewInstrumentedBlobstore counts bytes of blobs that were downloaded and uploaded
+func NewInstrumentedBlobstore(blobstore boshblob.Blobstore, fs boshsys.FileSystem, registry *Registry) boshblob.Blobstore {
+	return instrumentedBlobstore{
+		Blobstore: blobstore,
+		fs:        fs,
+
+		downloadedBytes: registry.Counter(
+			"bosh_agent_blobstore_downloaded_bytes_total",
+			"Number of bytes downloaded from blobstore.",
+		),
+		uploadedBytes: registry.Counter(
+			"bosh_agent_blobstore_uploaded_bytes_total",
+			"Number of bytes uploaded to blobstore.",
+		),
+		failures: registry.Counter(
+			"bosh_agent_blobstore_failures_total",
+			"Number of failed blobstore operations by operation.",
+			"operation",
+		),
+	}
+}
+
+func (b instrumentedBlobstore) Get(blobID, fingerprint string) (string, error) {
+	fileName, err := b.Blobstore.Get(blobID, fingerprint)
+	if err != nil {
+		b.failures.Inc("get")
+		return fileName, err
+	}
+
+	b.downloadedBytes.Add(b.fileSize(fileName))
+
+	return fileName, nil
+}
+
+func (b instrumentedBlobstore) Create(fileName string) (string, string, error) {
+	blobID, fingerprint, err := b.Blobstore.Create(fileName)
+	if err != nil {
+		b.failures.Inc("create")
+		return blobID, fingerprint, err
+	}
+
+	b.uploadedBytes.Add(b.fileSize(fileName))
+
+	return blobID, fingerprint, nil
+}
+
+func (b instrumentedBlobstore) fileSize(fileName string) float64 {
+	// Metrics are best effort; blob that cannot be inspected is not counted
+	file, err := b.fs.OpenFile(fileName, 0, 0)
+	if err != nil {
+		return 0
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0
+	}
+
+	return float64(info.Size())
+}
@@ -0,0 +1,106 @@
+package metrics_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/metrics"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("instrumentedBlobstore", func() {
+	var (
+		blobstore *fakeblob.FakeBlobstore
+		fs        *fakesys.FakeFileSystem
+		registry  *Registry
+		subject   boshblob.Blobstore
+	)
+
+	BeforeEach(func() {
+		blobstore = fakeblob.NewFakeBlobstore()
+		fs = fakesys.NewFakeFileSystem()
+		registry = NewRegistry()
+		subject = NewInstrumentedBlobstore(blobstore, fs, registry)
+	})
+
+	registerFile := func(path, contents string) {
+		file := fakesys.NewFakeFile(path, fs)
+		file.Contents = []byte(contents)
+		fs.RegisterOpenFile(path, file)
+	}
+
+	counterFamily := func(name, help string, samples ...Sample) Family {
+		return Family{Name: name, Help: help, Type: TypeCounter, Samples: samples}
+	}
+
+	Describe("Get", func() {
+		It("counts bytes of downloaded blobs", func() {
+			registerFile("/fake-blob", "fake-contents")
+			blobstore.GetFileName = "/fake-blob"
+
+			fileName, err := subject.Get("fake-blob-id", "fake-fingerprint")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileName).To(Equal("/fake-blob"))
+
+			Expect(blobstore.GetBlobIDs).To(Equal([]string{"fake-blob-id"}))
+			Expect(blobstore.GetFingerprints).To(Equal([]string{"fake-fingerprint"}))
+
+			Expect(registry.Families()).To(ContainElement(counterFamily(
+				"bosh_agent_blobstore_downloaded_bytes_total",
+				"Number of bytes downloaded from blobstore.",
+				Sample{Labels: []Label{}, Value: 13},
+			)))
+		})
+
+		It("counts failed downloads", func() {
+			blobstore.GetError = errors.New("fake-get-err")
+
+			_, err := subject.Get("fake-blob-id", "fake-fingerprint")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("fake-get-err"))
+
+			Expect(registry.Families()).To(ContainElement(counterFamily(
+				"bosh_agent_blobstore_failures_total",
+				"Number of failed blobstore operations by operation.",
+				Sample{Labels: []Label{{Name: "operation", Value: "get"}}, Value: 1},
+			)))
+		})
+	})
+
+	Describe("Create", func() {
+		It("counts bytes of uploaded blobs", func() {
+			registerFile("/fake-file", "fake")
+			blobstore.CreateBlobID = "fake-blob-id"
+			blobstore.CreateFingerprint = "fake-fingerprint"
+
+			blobID, fingerprint, err := subject.Create("/fake-file")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(fingerprint).To(Equal("fake-fingerprint"))
+
+			Expect(registry.Families()).To(ContainElement(counterFamily(
+				"bosh_agent_blobstore_uploaded_bytes_total",
+				"Number of bytes uploaded to blobstore.",
+				Sample{Labels: []Label{}, Value: 4},
+			)))
+		})
+
+		It("counts failed uploads", func() {
+			blobstore.CreateErr = errors.New("fake-create-err")
+
+			_, _, err := subject.Create("/fake-file")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("fake-create-err"))
+
+			Expect(registry.Families()).To(ContainElement(counterFamily(
+				"bosh_agent_blobstore_failures_total",
+				"Number of failed blobstore operations by operation.",
+				Sample{Labels: []Label{{Name: "operation", Value: "create"}}, Value: 1},
+			)))
+		})
+	})
+})
@@ -0,0 +1,295 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+	TypeSummary = "summary"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a metric
+type Sample struct {
+	// Defaults to name of the family; summaries use it for _sum and _count samples
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Family groups samples of one metric under its help text and type
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// CollectFunc computes families when metrics are scraped,
+// e.g. from state other components already keep
+type CollectFunc func() []Family
+
+// Registry keeps metrics agent components update as they run
+// and writes them in Prometheus text exposition format
+type Registry struct {
+	families   map[string]*family
+	collectors []CollectFunc
+	lock       sync.Mutex
+}
+
+type family struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	values     map[string]*value
+}
+
+type value struct {
+	labelValues []string
+	value       float64
+	count       float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// Counter returns counter with given name; counter registered
+// under the same name before is returned again
+func (r *Registry) Counter(name, help string, labelNames ...string) Counter {
+	return Counter{registry: r, family: r.family(name, help, TypeCounter, labelNames)}
+}
+
+func (r *Registry) Gauge(name, help string, labelNames ...string) Gauge {
+	return Gauge{registry: r, family: r.family(name, help, TypeGauge, labelNames)}
+}
+
+// Summary keeps sum and count of observed values (e.g. durations in seconds)
+func (r *Registry) Summary(name, help string, labelNames ...string) Summary {
+	return Summary{registry: r, family: r.family(name, help, TypeSummary, labelNames)}
+}
+
+func (r *Registry) RegisterCollector(collector CollectFunc) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.collectors = append(r.collectors, collector)
+}
+
+// Families returns registered metrics and metrics computed by collectors, sorted by name
+func (r *Registry) Families() []Family {
+	r.lock.Lock()
+
+	families := []Family{}
+	for _, f := range r.families {
+		families = append(families, f.snapshot())
+	}
+
+	collectors := r.collectors
+
+	r.lock.Unlock()
+
+	// Collectors might take a while (e.g. reading vitals) so they are run without holding the lock
+	for _, collector := range collectors {
+		families = append(families, collector()...)
+	}
+
+	sort.Sort(familiesByName(families))
+
+	return families
+}
+
+func (r *Registry) WriteText(w io.Writer) error {
+	bufW := bufio.NewWriter(w)
+
+	for _, f := range r.Families() {
+		fmt.Fprintf(bufW, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+		fmt.Fprintf(bufW, "# TYPE %s %s\n", f.Name, f.Type)
+
+		for _, sample := range f.Samples {
+			name := sample.Name
+			if name == "" {
+				name = f.Name
+			}
+
+			fmt.Fprintf(bufW, "%s%s %s\n", name, formatLabels(sample.Labels), formatValue(sample.Value))
+		}
+	}
+
+	return bufW.Flush()
+}
+
+func (r *Registry) family(name, help, kind string, labelNames []string) *family {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if f, found := r.families[name]; found {
+		return f
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		values:     map[string]*value{},
+	}
+
+	r.families[name] = f
+
+	return f
+}
+
+// value must be called with registry lock held
+func (f *family) value(labelValues []string) *value {
+	key := strings.Join(labelValues, "\xff")
+
+	v, found := f.values[key]
+	if !found {
+		v = &value{labelValues: labelValues}
+		f.values[key] = v
+	}
+
+	return v
+}
+
+func (f *family) snapshot() Family {
+	keys := []string{}
+	for key := range f.values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	snapshot := Family{Name: f.name, Help: f.help, Type: f.kind}
+
+	for _, key := range keys {
+		v := f.values[key]
+
+		labels := []Label{}
+		for i, labelName := range f.labelNames {
+			if i < len(v.labelValues) {
+				labels = append(labels, Label{Name: labelName, Value: v.labelValues[i]})
+			}
+		}
+
+		if f.kind == TypeSummary {
+			snapshot.Samples = append(snapshot.Samples,
+				Sample{Name: f.name + "_sum", Labels: labels, Value: v.value},
+				Sample{Name: f.name + "_count", Labels: labels, Value: v.count},
+			)
+		} else {
+			snapshot.Samples = append(snapshot.Samples, Sample{Labels: labels, Value: v.value})
+		}
+	}
+
+	return snapshot
+}
+
+type Counter struct {
+	registry *Registry
+	family   *family
+}
+
+// Inc adds one to counter with given label values
+func (c Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c Counter) Add(delta float64, labelValues ...string) {
+	c.registry.lock.Lock()
+	defer c.registry.lock.Unlock()
+
+	c.family.value(labelValues).value += delta
+}
+
+type Gauge struct {
+	registry *Registry
+	family   *family
+}
+
+func (g Gauge) Set(v float64, labelValues ...string) {
+	g.registry.lock.Lock()
+	defer g.registry.lock.Unlock()
+
+	g.family.value(labelValues).value = v
+}
+
+func (g Gauge) Add(delta float64, labelValues ...string) {
+	g.registry.lock.Lock()
+	defer g.registry.lock.Unlock()
+
+	g.family.value(labelValues).value += delta
+}
+
+type Summary struct {
+	registry *Registry
+	family   *family
+}
+
+func (s Summary) Observe(v float64, labelValues ...string) {
+	s.registry.lock.Lock()
+	defer s.registry.lock.Unlock()
+
+	value := s.family.value(labelValues)
+	value.value += v
+	value.count++
+}
+
+type familiesByName []Family
+
+func (s familiesByName) Len() int           { return len(s) }
+func (s familiesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s familiesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := []string{}
+	for _, label := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", label.Name, escapeLabelValue(label.Value)))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
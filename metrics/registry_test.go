@@ -0,0 +1,130 @@
+package metrics_test
+
+import (
+	"bytes"
+	"math"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/metrics"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		registry *Registry
+	)
+
+	BeforeEach(func() {
+		registry = NewRegistry()
+	})
+
+	writeText := func() string {
+		buf := &bytes.Buffer{}
+		err := registry.WriteText(buf)
+		Expect(err).ToNot(HaveOccurred())
+		return buf.String()
+	}
+
+	Describe("Counter", func() {
+		It("keeps separate values for each set of label values", func() {
+			counter := registry.Counter("fake_total", "fake-help", "topic")
+			counter.Inc("heartbeat")
+			counter.Inc("heartbeat")
+			counter.Add(3, "alert")
+
+			Expect(registry.Families()).To(Equal([]Family{
+				{
+					Name: "fake_total",
+					Help: "fake-help",
+					Type: TypeCounter,
+					Samples: []Sample{
+						{Labels: []Label{{Name: "topic", Value: "alert"}}, Value: 3},
+						{Labels: []Label{{Name: "topic", Value: "heartbeat"}}, Value: 2},
+					},
+				},
+			}))
+		})
+
+		It("returns counter registered before under the same name", func() {
+			registry.Counter("fake_total", "fake-help").Inc()
+			registry.Counter("fake_total", "other-help").Inc()
+
+			Expect(writeText()).To(Equal("# HELP fake_total fake-help\n# TYPE fake_total counter\nfake_total 2\n"))
+		})
+	})
+
+	Describe("Gauge", func() {
+		It("can be set and changed", func() {
+			gauge := registry.Gauge("fake_gauge", "fake-help")
+			gauge.Set(5)
+			gauge.Add(-2)
+
+			Expect(writeText()).To(Equal("# HELP fake_gauge fake-help\n# TYPE fake_gauge gauge\nfake_gauge 3\n"))
+		})
+	})
+
+	Describe("Summary", func() {
+		It("writes sum and count of observed values", func() {
+			summary := registry.Summary("fake_seconds", "fake-help", "method")
+			summary.Observe(1.5, "apply")
+			summary.Observe(0.5, "apply")
+
+			Expect(writeText()).To(Equal(
+				"# HELP fake_seconds fake-help\n" +
+					"# TYPE fake_seconds summary\n" +
+					"fake_seconds_sum{method=\"apply\"} 2\n" +
+					"fake_seconds_count{method=\"apply\"} 2\n",
+			))
+		})
+	})
+
+	Describe("RegisterCollector", func() {
+		It("includes families computed by collectors sorted by name", func() {
+			registry.Counter("fake_b_total", "fake-b-help").Inc()
+
+			registry.RegisterCollector(func() []Family {
+				return []Family{
+					{
+						Name:    "fake_a",
+						Help:    "fake-a-help",
+						Type:    TypeGauge,
+						Samples: []Sample{{Value: 1}},
+					},
+				}
+			})
+
+			families := registry.Families()
+			Expect(families).To(HaveLen(2))
+			Expect(families[0].Name).To(Equal("fake_a"))
+			Expect(families[1].Name).To(Equal("fake_b_total"))
+		})
+	})
+
+	Describe("WriteText", func() {
+		It("escapes help and label values", func() {
+			registry.Counter("fake_total", "fake\\help\nline", "name").Inc("fake\"value\"\n")
+
+			Expect(writeText()).To(Equal(
+				"# HELP fake_total fake\\\\help\\nline\n" +
+					"# TYPE fake_total counter\n" +
+					"fake_total{name=\"fake\\\"value\\\"\\n\"} 1\n",
+			))
+		})
+
+		It("writes special float values", func() {
+			gauge := registry.Gauge("fake_gauge", "fake-help", "kind")
+			gauge.Set(math.Inf(1), "pos")
+			gauge.Set(math.Inf(-1), "neg")
+			gauge.Set(0.25, "small")
+
+			Expect(writeText()).To(Equal(
+				"# HELP fake_gauge fake-help\n" +
+					"# TYPE fake_gauge gauge\n" +
+					"fake_gauge{kind=\"neg\"} -Inf\n" +
+					"fake_gauge{kind=\"pos\"} +Inf\n" +
+					"fake_gauge{kind=\"small\"} 0.25\n",
+			))
+		})
+	})
+})
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const serverLogTag = "Metrics Server"
+
+type Options struct {
+	// Port metrics are served on at http://127.0.0.1:<port>/metrics;
+	// metrics are not served when not set
+	Port int
+}
+
+func (o Options) Enabled() bool {
+	return o.Port > 0
+}
+
+// Server only listens on localhost so that metrics are scraped
+// by exporters running on the same VM
+type Server struct {
+	port     int
+	registry *Registry
+	logger   boshlog.Logger
+
+	listener net.Listener
+	lock     sync.Mutex
+}
+
+func NewServer(options Options, registry *Registry, logger boshlog.Logger) *Server {
+	return &Server{
+		port:     options.Port,
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+// Start listens and serves requests in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(s.port))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Listening on port %d", s.port)
+	}
+
+	s.lock.Lock()
+	s.listener = listener
+	s.lock.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.serveMetrics)
+
+	s.logger.Info(serverLogTag, "Serving metrics on %s", listener.Addr())
+
+	go func() {
+		err := http.Serve(listener, mux)
+		s.logger.Debug(serverLogTag, "Stopped serving metrics: %s", err)
+	}()
+
+	return nil
+}
+
+// Addr is useful when server was started on a random port
+func (s *Server) Addr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Addr()
+}
+
+func (s *Server) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+}
+
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	err := s.registry.WriteText(w)
+	if err != nil {
+		s.logger.Error(serverLogTag, "Writing metrics: %s", err.Error())
+	}
+}
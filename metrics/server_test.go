@@ -0,0 +1,79 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/metrics"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		registry *Registry
+		server   *Server
+	)
+
+	BeforeEach(func() {
+		registry = NewRegistry()
+		registry.Counter("fake_total", "fake-help").Inc()
+
+		// Port 0 makes server listen on a random port
+		server = NewServer(Options{}, registry, boshlog.NewLogger(boshlog.LevelNone))
+		Expect(server.Start()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		server.Stop()
+	})
+
+	metricsURL := func() string {
+		return "http://" + server.Addr().String() + "/metrics"
+	}
+
+	It("listens on localhost only", func() {
+		Expect(strings.HasPrefix(server.Addr().String(), "127.0.0.1:")).To(BeTrue())
+	})
+
+	It("serves metrics in text format", func() {
+		resp, err := http.Get(metricsURL())
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/plain; version=0.0.4; charset=utf-8"))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal("# HELP fake_total fake-help\n# TYPE fake_total counter\nfake_total 1\n"))
+	})
+
+	It("only allows GET requests", func() {
+		resp, err := http.Post(metricsURL(), "text/plain", strings.NewReader(""))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("stops listening once stopped", func() {
+		url := metricsURL()
+
+		server.Stop()
+		Expect(server.Addr()).To(BeNil())
+
+		_, err := http.Get(url)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Options", func() {
+	It("is enabled when port is set", func() {
+		Expect(Options{}.Enabled()).To(BeFalse())
+		Expect(Options{Port: 9190}.Enabled()).To(BeTrue())
+	})
+})
@@ -0,0 +1,104 @@
+package micro
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+)
+
+const (
+	maxBufferedEvents = 1000
+)
+
+// Event wraps message the same way NATS handler addresses it:
+// by target (e.g. hm) and topic (e.g. heartbeat)
+type Event struct {
+	ID      uint64             `json:"id"`
+	Target  boshhandler.Target `json:"target"`
+	Topic   boshhandler.Topic  `json:"topic"`
+	Message json.RawMessage    `json:"message"`
+}
+
+// eventBuffer keeps latest events so that clients polling /events
+// can pick up events sent since their previous poll
+type eventBuffer struct {
+	events    []Event
+	lastID    uint64
+	maxEvents int
+
+	// Closed and replaced every time event is added
+	addedCh chan struct{}
+
+	lock sync.Mutex
+}
+
+func newEventBuffer(maxEvents int) *eventBuffer {
+	return &eventBuffer{maxEvents: maxEvents}
+}
+
+func (b *eventBuffer) Add(target boshhandler.Target, topic boshhandler.Topic, message json.RawMessage) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.lastID++
+
+	b.events = append(b.events, Event{
+		ID:      b.lastID,
+		Target:  target,
+		Topic:   topic,
+		Message: message,
+	})
+
+	if overflow := len(b.events) - b.maxEvents; overflow > 0 {
+		b.events = append([]Event{}, b.events[overflow:]...)
+	}
+
+	if b.addedCh != nil {
+		close(b.addedCh)
+		b.addedCh = nil
+	}
+}
+
+// EventsAfter returns events with ids greater than afterID;
+// if there are none it waits up to timeout for new events to be added
+func (b *eventBuffer) EventsAfter(afterID uint64, timeout time.Duration) []Event {
+	events, addedCh := b.eventsAfter(afterID)
+	if len(events) > 0 || timeout <= 0 {
+		return events
+	}
+
+	select {
+	case <-addedCh:
+	case <-time.After(timeout):
+	}
+
+	events, _ = b.eventsAfter(afterID)
+
+	return events
+}
+
+func (b *eventBuffer) eventsAfter(afterID uint64) ([]Event, <-chan struct{}) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// Ids start over when agent restarts
+	if afterID > b.lastID {
+		afterID = 0
+	}
+
+	events := []Event{}
+
+	for _, event := range b.events {
+		if event.ID > afterID {
+			events = append(events, event)
+		}
+	}
+
+	if b.addedCh == nil {
+		b.addedCh = make(chan struct{})
+	}
+
+	return events, b.addedCh
+}
@@ -1,11 +1,14 @@
 package micro
 
 import (
-	"encoding/base64"
+	"crypto/subtle"
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
+	"time"
 
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
@@ -16,25 +19,35 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
+const (
+	defaultEventsPollTimeout = 30 * time.Second
+	maxEventsPollTimeout     = 60 * time.Second
+)
+
 type HTTPSHandler struct {
 	parsedURL   *url.URL
+	options     boshdispatcher.Options
 	logger      boshlog.Logger
 	dispatcher  *boshdispatcher.HTTPSDispatcher
 	fs          boshsys.FileSystem
 	dirProvider boshdir.Provider
+	events      *eventBuffer
 }
 
 func NewHTTPSHandler(
 	parsedURL *url.URL,
+	options boshdispatcher.Options,
 	logger boshlog.Logger,
 	fs boshsys.FileSystem,
 	dirProvider boshdir.Provider,
 ) (handler HTTPSHandler) {
 	handler.parsedURL = parsedURL
+	handler.options = options
 	handler.logger = logger
 	handler.fs = fs
 	handler.dirProvider = dirProvider
-	handler.dispatcher = boshdispatcher.NewHTTPSDispatcher(parsedURL, logger)
+	handler.dispatcher = boshdispatcher.NewHTTPSDispatcher(parsedURL, options, logger)
+	handler.events = newEventBuffer(maxBufferedEvents)
 	return
 }
 
@@ -49,6 +62,7 @@ func (h HTTPSHandler) Run(handlerFunc boshhandler.Func) error {
 func (h HTTPSHandler) Start(handlerFunc boshhandler.Func) error {
 	h.dispatcher.AddRoute("/agent", h.agentHandler(handlerFunc))
 	h.dispatcher.AddRoute("/blobs/", h.blobsHandler())
+	h.dispatcher.AddRoute("/events", h.eventsHandler())
 	h.dispatcher.Start()
 	return nil
 }
@@ -61,17 +75,41 @@ func (h HTTPSHandler) RegisterAdditionalFunc(handlerFunc boshhandler.Func) {
 	panic("HTTPSHandler does not support registering additional handler funcs")
 }
 
+// Send makes message available to clients polling /events
 func (h HTTPSHandler) Send(target boshhandler.Target, topic boshhandler.Topic, message interface{}) error {
+	bytes, err := json.Marshal(message)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling message (target=%s, topic=%s): %#v", target, topic, message)
+	}
+
+	h.logger.Info("https-handler", "Queueing %s message '%s'", target, topic)
+	h.logger.DebugWithDetails("https-handler", "Message Payload", string(boshhandler.RedactJSON(bytes)))
+
+	h.events.Add(target, topic, bytes)
+
 	return nil
 }
 
+// requestNotAuthorized checks basic auth credentials given in mbus URL.
+// Client certificates are already verified during TLS handshake
+// so credentials may be omitted from mbus URL when they are required.
 func (h HTTPSHandler) requestNotAuthorized(request *http.Request) bool {
+	if h.parsedURL.User == nil && h.options.ClientCertificateRequired() {
+		return false
+	}
+
 	username := h.parsedURL.User.Username()
 	password, _ := h.parsedURL.User.Password()
-	auth := username + ":" + password
-	expectedAuthorizationHeader := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
 
-	return expectedAuthorizationHeader != request.Header.Get("Authorization")
+	requestUsername, requestPassword, ok := request.BasicAuth()
+	if !ok {
+		return true
+	}
+
+	usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(requestUsername)) == 1
+	passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(requestPassword)) == 1
+
+	return !usernameMatches || !passwordMatches
 }
 
 func (h HTTPSHandler) agentHandler(handlerFunc boshhandler.Func) (agentHandler func(http.ResponseWriter, *http.Request)) {
@@ -109,6 +147,65 @@ func (h HTTPSHandler) agentHandler(handlerFunc boshhandler.Func) (agentHandler f
 	return
 }
 
+// eventsHandler long-polls for messages given to Send.
+// Clients pass id of the last event they have seen as 'after'
+// and how many seconds to wait for new events as 'timeout'.
+func (h HTTPSHandler) eventsHandler() (eventsHandler func(http.ResponseWriter, *http.Request)) {
+	eventsHandler = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(404)
+			return
+		}
+
+		if h.requestNotAuthorized(r) {
+			w.Header().Add("WWW-Authenticate", `Basic realm=""`)
+			w.WriteHeader(401)
+			return
+		}
+
+		var afterID uint64
+		var err error
+
+		if after := r.URL.Query().Get("after"); after != "" {
+			afterID, err = strconv.ParseUint(after, 10, 64)
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte("Invalid 'after' parameter"))
+				return
+			}
+		}
+
+		timeout := defaultEventsPollTimeout
+
+		if timeoutSeconds := r.URL.Query().Get("timeout"); timeoutSeconds != "" {
+			seconds, err := strconv.Atoi(timeoutSeconds)
+			if err != nil || seconds < 0 {
+				w.WriteHeader(400)
+				w.Write([]byte("Invalid 'timeout' parameter"))
+				return
+			}
+
+			timeout = time.Duration(seconds) * time.Second
+			if timeout > maxEventsPollTimeout {
+				timeout = maxEventsPollTimeout
+			}
+		}
+
+		respBytes, err := json.Marshal(map[string][]Event{
+			"events": h.events.EventsAfter(afterID, timeout),
+		})
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(respBytes)
+	}
+	return
+}
+
 func (h HTTPSHandler) blobsHandler() (blobsHandler func(http.ResponseWriter, *http.Request)) {
 	blobsHandler = func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -14,6 +14,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -34,7 +35,7 @@ var _ = Describe("HTTPSHandler", func() {
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 		fs = fakesys.NewFakeFileSystem()
 		dirProvider := boshdir.NewProvider("/var/vcap")
-		handler = NewHTTPSHandler(mbusURL, logger, fs, dirProvider)
+		handler = NewHTTPSHandler(mbusURL, boshdispatcher.Options{}, logger, fs, dirProvider)
 
 		go handler.Start(func(req boshhandler.Request) (resp boshhandler.Response) {
 			receivedRequest = req
@@ -168,6 +169,111 @@ var _ = Describe("HTTPSHandler", func() {
 		})
 	})
 
+	Describe("GET /events", func() {
+		getEvents := func(query string) (int, string) {
+			httpResponse, err := httpClient.Get(serverURL + "/events?" + query)
+			Expect(err).ToNot(HaveOccurred())
+
+			defer httpResponse.Body.Close()
+
+			httpBody, err := ioutil.ReadAll(httpResponse.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			return httpResponse.StatusCode, string(httpBody)
+		}
+
+		It("returns messages that were sent", func() {
+			err := handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{"job": "fake-job"})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, map[string]string{"id": "fake-alert-id"})
+			Expect(err).ToNot(HaveOccurred())
+
+			status, body := getEvents("timeout=0")
+			Expect(status).To(Equal(200))
+			Expect(body).To(MatchJSON(`{"events":[
+				{"id":1,"target":"hm","topic":"heartbeat","message":{"job":"fake-job"}},
+				{"id":2,"target":"hm","topic":"alert","message":{"id":"fake-alert-id"}}
+			]}`))
+		})
+
+		It("returns only messages sent after given event id", func() {
+			handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{"job": "fake-job"})
+			handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, map[string]string{"id": "fake-alert-id"})
+
+			status, body := getEvents("after=1&timeout=0")
+			Expect(status).To(Equal(200))
+			Expect(body).To(MatchJSON(`{"events":[
+				{"id":2,"target":"hm","topic":"alert","message":{"id":"fake-alert-id"}}
+			]}`))
+		})
+
+		It("waits for new messages when there are none", func() {
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, map[string]string{"id": "fake-alert-id"})
+			}()
+
+			status, body := getEvents("timeout=5")
+			Expect(status).To(Equal(200))
+			Expect(body).To(MatchJSON(`{"events":[
+				{"id":1,"target":"hm","topic":"alert","message":{"id":"fake-alert-id"}}
+			]}`))
+		})
+
+		It("returns no messages when none were sent before timeout", func() {
+			status, body := getEvents("timeout=1")
+			Expect(status).To(Equal(200))
+			Expect(body).To(MatchJSON(`{"events":[]}`))
+		})
+
+		It("returns all messages when given event id is from previous agent run", func() {
+			handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, map[string]string{"id": "fake-alert-id"})
+
+			status, body := getEvents("after=100&timeout=0")
+			Expect(status).To(Equal(200))
+			Expect(body).To(MatchJSON(`{"events":[
+				{"id":1,"target":"hm","topic":"alert","message":{"id":"fake-alert-id"}}
+			]}`))
+		})
+
+		It("returns a 400 when parameters are invalid", func() {
+			status, _ := getEvents("after=fake-id")
+			Expect(status).To(Equal(400))
+
+			status, _ = getEvents("timeout=-1")
+			Expect(status).To(Equal(400))
+		})
+
+		It("returns a 401 when incorrect username/password was provided", func() {
+			httpResponse, err := httpClient.Get(strings.Replace(serverURL, "pass", "wrong", -1) + "/events")
+			Expect(err).ToNot(HaveOccurred())
+
+			defer httpResponse.Body.Close()
+
+			Expect(httpResponse.StatusCode).To(Equal(401))
+		})
+
+		Context("when incorrect http method is used", func() {
+			It("returns a 404", func() {
+				httpResponse, err := httpClient.Post(serverURL+"/events", "application/json", strings.NewReader("{}"))
+				Expect(err).ToNot(HaveOccurred())
+
+				defer httpResponse.Body.Close()
+
+				Expect(httpResponse.StatusCode).To(Equal(404))
+			})
+		})
+	})
+
+	Describe("Send", func() {
+		It("returns error when message cannot be marshalled", func() {
+			err := handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, make(chan int))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Marshalling message"))
+		})
+	})
+
 	Describe("routing and auth", func() {
 		Context("when an incorrect uri is specificed", func() {
 			It("returns a 404", func() {
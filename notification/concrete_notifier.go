@@ -1,6 +1,7 @@
 package notification
 
 import (
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 )
 
@@ -8,6 +9,16 @@ type concreteNotifier struct {
 	handler boshhandler.Handler
 }
 
+type taskProgressMessage struct {
+	AgentTaskID string `json:"agent_task_id"`
+	boshtask.Event
+}
+
+type taskOutputMessage struct {
+	AgentTaskID string                `json:"agent_task_id"`
+	Lines       []boshtask.OutputLine `json:"lines"`
+}
+
 func NewNotifier(handler boshhandler.Handler) Notifier {
 	return concreteNotifier{handler: handler}
 }
@@ -15,3 +26,13 @@ func NewNotifier(handler boshhandler.Handler) Notifier {
 func (n concreteNotifier) NotifyShutdown() error {
 	return n.handler.Send(boshhandler.HealthMonitor, boshhandler.Shutdown, nil)
 }
+
+func (n concreteNotifier) NotifyTaskProgress(taskID string, event boshtask.Event) error {
+	msg := taskProgressMessage{AgentTaskID: taskID, Event: event}
+	return n.handler.Send(boshhandler.Director, boshhandler.TaskProgress, msg)
+}
+
+func (n concreteNotifier) NotifyTaskOutput(taskID string, lines []boshtask.OutputLine) error {
+	msg := taskOutputMessage{AgentTaskID: taskID, Lines: lines}
+	return n.handler.Send(boshhandler.Director, boshhandler.TaskOutput, msg)
+}
@@ -1,11 +1,13 @@
 package notification_test
 
 import (
+	"encoding/json"
 	"errors"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	fakembus "github.com/cloudfoundry/bosh-agent/mbus/fakes"
 	. "github.com/cloudfoundry/bosh-agent/notification"
@@ -44,4 +46,92 @@ var _ = Describe("concreteNotifier", func() {
 			Expect(err.Error()).To(ContainSubstring("fake-send-error"))
 		})
 	})
+
+	Describe("NotifyTaskProgress", func() {
+		var (
+			handler  *fakembus.FakeHandler
+			notifier Notifier
+		)
+
+		BeforeEach(func() {
+			handler = fakembus.NewFakeHandler()
+			notifier = NewNotifier(handler)
+		})
+
+		It("sends task progress message to director", func() {
+			event := boshtask.Event{Time: 1234, Stage: "fake-stage", Percent: 42}
+
+			err := notifier.NotifyTaskProgress("fake-task-id", event)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(handler.SendInputs()).To(HaveLen(1))
+
+			sendInput := handler.SendInputs()[0]
+			Expect(sendInput.Target).To(Equal(boshhandler.Director))
+			Expect(sendInput.Topic).To(Equal(boshhandler.TaskProgress))
+
+			msgJSON, err := json.Marshal(sendInput.Message)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msgJSON).To(MatchJSON(`{
+				"agent_task_id": "fake-task-id",
+				"time": 1234,
+				"stage": "fake-stage",
+				"percent": 42
+			}`))
+		})
+
+		It("returns error if sending task progress message fails", func() {
+			handler.SendErr = errors.New("fake-send-error")
+
+			err := notifier.NotifyTaskProgress("fake-task-id", boshtask.Event{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-send-error"))
+		})
+	})
+
+	Describe("NotifyTaskOutput", func() {
+		var (
+			handler  *fakembus.FakeHandler
+			notifier Notifier
+		)
+
+		BeforeEach(func() {
+			handler = fakembus.NewFakeHandler()
+			notifier = NewNotifier(handler)
+		})
+
+		It("sends task output message with all lines to director", func() {
+			lines := []boshtask.OutputLine{
+				{Sequence: 3, Time: 1234, Stream: boshtask.OutputStdout, Line: "fake-line-1"},
+				{Sequence: 4, Time: 1235, Stream: boshtask.OutputStderr, Line: "fake-line-2"},
+			}
+
+			err := notifier.NotifyTaskOutput("fake-task-id", lines)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(handler.SendInputs()).To(HaveLen(1))
+
+			sendInput := handler.SendInputs()[0]
+			Expect(sendInput.Target).To(Equal(boshhandler.Director))
+			Expect(sendInput.Topic).To(Equal(boshhandler.TaskOutput))
+
+			msgJSON, err := json.Marshal(sendInput.Message)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msgJSON).To(MatchJSON(`{
+				"agent_task_id": "fake-task-id",
+				"lines": [
+					{"sequence": 3, "time": 1234, "stream": "stdout", "line": "fake-line-1"},
+					{"sequence": 4, "time": 1235, "stream": "stderr", "line": "fake-line-2"}
+				]
+			}`))
+		})
+
+		It("returns error if sending task output message fails", func() {
+			handler.SendErr = errors.New("fake-send-error")
+
+			err := notifier.NotifyTaskOutput("fake-task-id", []boshtask.OutputLine{{}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-send-error"))
+		})
+	})
 })
@@ -1,8 +1,24 @@
 package fakes
 
+import (
+	"sync"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+)
+
 type FakeNotifier struct {
 	NotifiedShutdown  bool
 	NotifyShutdownErr error
+
+	NotifiedTaskProgressTaskIDs []string
+	NotifiedTaskProgressEvents  []boshtask.Event
+	NotifyTaskProgressErr       error
+
+	// Output is published from background goroutine
+	notifiedTaskOutputTaskIDs []string
+	notifiedTaskOutputLines   []boshtask.OutputLine
+	NotifyTaskOutputErr       error
+	outputLock                sync.Mutex
 }
 
 func NewFakeNotifier() *FakeNotifier {
@@ -13,3 +29,33 @@ func (n *FakeNotifier) NotifyShutdown() error {
 	n.NotifiedShutdown = true
 	return n.NotifyShutdownErr
 }
+
+func (n *FakeNotifier) NotifyTaskProgress(taskID string, event boshtask.Event) error {
+	n.NotifiedTaskProgressTaskIDs = append(n.NotifiedTaskProgressTaskIDs, taskID)
+	n.NotifiedTaskProgressEvents = append(n.NotifiedTaskProgressEvents, event)
+	return n.NotifyTaskProgressErr
+}
+
+func (n *FakeNotifier) NotifyTaskOutput(taskID string, lines []boshtask.OutputLine) error {
+	n.outputLock.Lock()
+	defer n.outputLock.Unlock()
+
+	n.notifiedTaskOutputTaskIDs = append(n.notifiedTaskOutputTaskIDs, taskID)
+	n.notifiedTaskOutputLines = append(n.notifiedTaskOutputLines, lines...)
+	return n.NotifyTaskOutputErr
+}
+
+// NotifiedTaskOutputTaskIDs has task id for every batch of lines
+func (n *FakeNotifier) NotifiedTaskOutputTaskIDs() []string {
+	n.outputLock.Lock()
+	defer n.outputLock.Unlock()
+
+	return append([]string{}, n.notifiedTaskOutputTaskIDs...)
+}
+
+func (n *FakeNotifier) NotifiedTaskOutputLines() []boshtask.OutputLine {
+	n.outputLock.Lock()
+	defer n.outputLock.Unlock()
+
+	return append([]boshtask.OutputLine{}, n.notifiedTaskOutputLines...)
+}
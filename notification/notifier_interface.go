@@ -1,5 +1,11 @@
 package notification
 
+import (
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+)
+
 type Notifier interface {
 	NotifyShutdown() (err error)
+	NotifyTaskProgress(taskID string, event boshtask.Event) (err error)
+	NotifyTaskOutput(taskID string, lines []boshtask.OutputLine) (err error)
 }
@@ -163,6 +163,14 @@ func (p dummyPlatform) MigratePersistentDisk(fromMountPoint, toMountPoint string
 	return
 }
 
+func (p dummyPlatform) CopyPersistentDisk(fromMountPoint, toMountPoint string) (err error) {
+	return
+}
+
+func (p dummyPlatform) RemountPersistentDisk(fromMountPoint, toMountPoint string) (err error) {
+	return
+}
+
 func (p dummyPlatform) IsMountPoint(path string) (result bool, err error) {
 	return
 }
@@ -89,6 +89,14 @@ type FakePlatform struct {
 	MigratePersistentDiskFromMountPoint string
 	MigratePersistentDiskToMountPoint   string
 
+	CopyPersistentDiskFromMountPoint string
+	CopyPersistentDiskToMountPoint   string
+	CopyPersistentDiskErr            error
+
+	RemountPersistentDiskFromMountPoint string
+	RemountPersistentDiskToMountPoint   string
+	RemountPersistentDiskErr            error
+
 	IsMountPointPath   string
 	IsMountPointResult bool
 	IsMountPointErr    error
@@ -294,6 +302,18 @@ func (p *FakePlatform) MigratePersistentDisk(fromMountPoint, toMountPoint string
 	return
 }
 
+func (p *FakePlatform) CopyPersistentDisk(fromMountPoint, toMountPoint string) error {
+	p.CopyPersistentDiskFromMountPoint = fromMountPoint
+	p.CopyPersistentDiskToMountPoint = toMountPoint
+	return p.CopyPersistentDiskErr
+}
+
+func (p *FakePlatform) RemountPersistentDisk(fromMountPoint, toMountPoint string) error {
+	p.RemountPersistentDiskFromMountPoint = fromMountPoint
+	p.RemountPersistentDiskToMountPoint = toMountPoint
+	return p.RemountPersistentDiskErr
+}
+
 func (p *FakePlatform) IsMountPoint(path string) (bool, error) {
 	p.IsMountPointPath = path
 	return p.IsMountPointResult, p.IsMountPointErr
@@ -685,6 +685,19 @@ func (p linux) IsMountPoint(path string) (bool, error) {
 func (p linux) MigratePersistentDisk(fromMountPoint, toMountPoint string) (err error) {
 	p.logger.Debug(logTag, "Migrating persistent disk %v to %v", fromMountPoint, toMountPoint)
 
+	err = p.CopyPersistentDisk(fromMountPoint, toMountPoint)
+	if err != nil {
+		return
+	}
+
+	err = p.RemountPersistentDisk(fromMountPoint, toMountPoint)
+	return
+}
+
+// CopyPersistentDisk copies files of old disk mounted read-only to new disk
+func (p linux) CopyPersistentDisk(fromMountPoint, toMountPoint string) (err error) {
+	p.logger.Debug(logTag, "Copying persistent disk %v to %v", fromMountPoint, toMountPoint)
+
 	err = p.diskManager.GetMounter().RemountAsReadonly(fromMountPoint)
 	if err != nil {
 		err = bosherr.WrapError(err, "Remounting persistent disk as readonly")
@@ -697,8 +710,13 @@ func (p linux) MigratePersistentDisk(fromMountPoint, toMountPoint string) (err e
 	_, _, _, err = p.cmdRunner.RunCommand("sh", "-c", tarCopy)
 	if err != nil {
 		err = bosherr.WrapError(err, "Copying files from old disk to new disk")
-		return
 	}
+	return
+}
+
+// RemountPersistentDisk unmounts old disk and mounts new disk in its place
+func (p linux) RemountPersistentDisk(fromMountPoint, toMountPoint string) (err error) {
+	p.logger.Debug(logTag, "Remounting persistent disk %v on %v", toMountPoint, fromMountPoint)
 
 	_, err = p.diskManager.GetMounter().Unmount(fromMountPoint)
 	if err != nil {
@@ -1263,6 +1263,35 @@ fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/*/*.log fake-base-
 		})
 	})
 
+	Describe("CopyPersistentDisk", func() {
+		It("copies files of old disk remounted as readonly without unmounting it", func() {
+			mounter := diskManager.FakeMounter
+
+			err := platform.CopyPersistentDisk("/from/path", "/to/path")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(mounter.RemountAsReadonlyPath).To(Equal("/from/path"))
+			Expect(cmdRunner.RunCommands).To(Equal([][]string{
+				{"sh", "-c", "(tar -C /from/path -cf - .) | (tar -C /to/path -xpf -)"},
+			}))
+			Expect(mounter.UnmountPartitionPathOrMountPoint).To(BeEmpty())
+		})
+	})
+
+	Describe("RemountPersistentDisk", func() {
+		It("mounts new disk on original mount point without copying files", func() {
+			mounter := diskManager.FakeMounter
+
+			err := platform.RemountPersistentDisk("/from/path", "/to/path")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cmdRunner.RunCommands).To(BeEmpty())
+			Expect(mounter.UnmountPartitionPathOrMountPoint).To(Equal("/from/path"))
+			Expect(mounter.RemountFromMountPoint).To(Equal("/to/path"))
+			Expect(mounter.RemountToMountPoint).To(Equal("/from/path"))
+		})
+	})
+
 	Describe("IsPersistentDiskMounted", func() {
 		act := func() (bool, error) {
 			return platform.IsPersistentDiskMounted(boshsettings.DiskSettings{Path: "fake-device-path"})
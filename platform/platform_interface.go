@@ -43,6 +43,11 @@ type Platform interface {
 	MountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) error
 	UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (didUnmount bool, err error)
 	MigratePersistentDisk(fromMountPoint, toMountPoint string) (err error)
+
+	// Steps of MigratePersistentDisk so that interrupted migration can be resumed
+	CopyPersistentDisk(fromMountPoint, toMountPoint string) (err error)
+	RemountPersistentDisk(fromMountPoint, toMountPoint string) (err error)
+
 	GetEphemeralDiskPath(diskSettings boshsettings.DiskSettings) string
 	IsMountPoint(path string) (result bool, err error)
 	IsPersistentDiskMounted(diskSettings boshsettings.DiskSettings) (result bool, err error)
@@ -37,3 +37,13 @@ func (p dummyStatsCollector) GetDiskStats(devicePath string) (stats DiskStats, e
 	stats.InodeUsage.Total = 1
 	return
 }
+
+func (p dummyStatsCollector) GetNetStats() (stats map[string]NetStats, err error) {
+	stats = map[string]NetStats{}
+	return
+}
+
+func (p dummyStatsCollector) GetDiskIOStats() (stats map[string]DiskIOStats, err error) {
+	stats = map[string]DiskIOStats{}
+	return
+}
@@ -18,6 +18,12 @@ type FakeCollector struct {
 
 	SwapStats boshstats.Usage
 	DiskStats map[string]boshstats.DiskStats
+
+	NetStats    map[string]boshstats.NetStats
+	NetStatsErr error
+
+	DiskIOStats    map[string]boshstats.DiskIOStats
+	DiskIOStatsErr error
 }
 
 func (c *FakeCollector) StartCollecting(collectionInterval time.Duration, latestGotUpdated chan struct{}) {
@@ -50,3 +56,11 @@ func (c *FakeCollector) GetDiskStats(devicePath string) (stats boshstats.DiskSta
 	}
 	return
 }
+
+func (c *FakeCollector) GetNetStats() (map[string]boshstats.NetStats, error) {
+	return c.NetStats, c.NetStatsErr
+}
+
+func (c *FakeCollector) GetDiskIOStats() (map[string]boshstats.DiskIOStats, error) {
+	return c.DiskIOStats, c.DiskIOStatsErr
+}
@@ -0,0 +1,268 @@
+package stats
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// Sectors in /proc/diskstats are always 512 bytes regardless of device
+const diskstatsSectorSize = 512
+
+type netCounters struct {
+	RxBytes   uint64
+	RxPackets uint64
+	RxErrors  uint64
+	TxBytes   uint64
+	TxPackets uint64
+	TxErrors  uint64
+}
+
+type diskIOCounters struct {
+	Reads        uint64
+	ReadSectors  uint64
+	ReadMillis   uint64
+	Writes       uint64
+	WriteSectors uint64
+	WriteMillis  uint64
+}
+
+// IOStatsSampler computes network and disk I/O rates
+// from counters in /proc/net/dev and /proc/diskstats
+// between two consecutive samples
+type IOStatsSampler struct {
+	fs          boshsys.FileSystem
+	procDir     string
+	timeService clock.Clock
+
+	lastSampledAt time.Time
+	lastNet       map[string]netCounters
+	lastDiskIO    map[string]diskIOCounters
+
+	netStats    map[string]NetStats
+	netErr      error
+	diskIOStats map[string]DiskIOStats
+	diskIOErr   error
+
+	lock sync.RWMutex
+}
+
+func NewIOStatsSampler(fs boshsys.FileSystem, procDir string, timeService clock.Clock) *IOStatsSampler {
+	return &IOStatsSampler{
+		fs:          fs,
+		procDir:     procDir,
+		timeService: timeService,
+		netStats:    map[string]NetStats{},
+		diskIOStats: map[string]DiskIOStats{},
+	}
+}
+
+// Sample reads counters and updates rates; rates are empty until second sample
+func (s *IOStatsSampler) Sample() {
+	net, netErr := s.readNetCounters()
+	diskIO, diskIOErr := s.readDiskIOCounters()
+
+	now := s.timeService.Now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	elapsed := now.Sub(s.lastSampledAt).Seconds()
+
+	s.netErr = netErr
+	if netErr == nil {
+		if s.lastNet != nil && elapsed > 0 {
+			s.netStats = netRates(s.lastNet, net, elapsed)
+		}
+		s.lastNet = net
+	}
+
+	s.diskIOErr = diskIOErr
+	if diskIOErr == nil {
+		if s.lastDiskIO != nil && elapsed > 0 {
+			s.diskIOStats = diskIORates(s.lastDiskIO, diskIO, elapsed)
+		}
+		s.lastDiskIO = diskIO
+	}
+
+	s.lastSampledAt = now
+}
+
+func (s *IOStatsSampler) GetNetStats() (map[string]NetStats, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.netStats, s.netErr
+}
+
+func (s *IOStatsSampler) GetDiskIOStats() (map[string]DiskIOStats, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.diskIOStats, s.diskIOErr
+}
+
+func (s *IOStatsSampler) readNetCounters() (map[string]netCounters, error) {
+	path := filepath.Join(s.procDir, "net", "dev")
+
+	contents, err := s.fs.ReadFileString(path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading %s", path)
+	}
+
+	counters := map[string]netCounters{}
+
+	// First two lines are headers which do not have colon
+	for _, line := range strings.Split(contents, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		fields := strings.Fields(parts[1])
+		if len(fields) < 16 {
+			return nil, bosherr.Errorf("Parsing %s: unexpected line '%s'", path, line)
+		}
+
+		values, err := parseCounters(fields, 0, 1, 2, 8, 9, 10)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing %s", path)
+		}
+
+		counters[strings.TrimSpace(parts[0])] = netCounters{
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			TxBytes:   values[3],
+			TxPackets: values[4],
+			TxErrors:  values[5],
+		}
+	}
+
+	return counters, nil
+}
+
+func (s *IOStatsSampler) readDiskIOCounters() (map[string]diskIOCounters, error) {
+	path := filepath.Join(s.procDir, "diskstats")
+
+	contents, err := s.fs.ReadFileString(path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading %s", path)
+	}
+
+	counters := map[string]diskIOCounters{}
+
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// major minor name followed by at least 11 counters
+		if len(fields) < 14 {
+			return nil, bosherr.Errorf("Parsing %s: unexpected line '%s'", path, line)
+		}
+
+		values, err := parseCounters(fields, 3, 5, 6, 7, 9, 10)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing %s", path)
+		}
+
+		// Skip devices that were never used (e.g. most of loop and ram devices)
+		if values[0] == 0 && values[3] == 0 {
+			continue
+		}
+
+		counters[fields[2]] = diskIOCounters{
+			Reads:        values[0],
+			ReadSectors:  values[1],
+			ReadMillis:   values[2],
+			Writes:       values[3],
+			WriteSectors: values[4],
+			WriteMillis:  values[5],
+		}
+	}
+
+	return counters, nil
+}
+
+func parseCounters(fields []string, indices ...int) ([]uint64, error) {
+	values := make([]uint64, len(indices))
+
+	for i, index := range indices {
+		value, err := strconv.ParseUint(fields[index], 10, 64)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing counter '%s'", fields[index])
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+func netRates(prev, cur map[string]netCounters, elapsed float64) map[string]NetStats {
+	rates := map[string]NetStats{}
+
+	for name, c := range cur {
+		p, found := prev[name]
+		if !found || c.RxBytes < p.RxBytes || c.TxBytes < p.TxBytes {
+			// Interface just appeared or its counters were reset
+			continue
+		}
+
+		rates[name] = NetStats{
+			RxBytes:   rate(p.RxBytes, c.RxBytes, elapsed),
+			RxPackets: rate(p.RxPackets, c.RxPackets, elapsed),
+			RxErrors:  rate(p.RxErrors, c.RxErrors, elapsed),
+			TxBytes:   rate(p.TxBytes, c.TxBytes, elapsed),
+			TxPackets: rate(p.TxPackets, c.TxPackets, elapsed),
+			TxErrors:  rate(p.TxErrors, c.TxErrors, elapsed),
+		}
+	}
+
+	return rates
+}
+
+func diskIORates(prev, cur map[string]diskIOCounters, elapsed float64) map[string]DiskIOStats {
+	rates := map[string]DiskIOStats{}
+
+	for name, c := range cur {
+		p, found := prev[name]
+		if !found || c.Reads < p.Reads || c.Writes < p.Writes {
+			continue
+		}
+
+		rates[name] = DiskIOStats{
+			ReadIOPS:   rate(p.Reads, c.Reads, elapsed),
+			WriteIOPS:  rate(p.Writes, c.Writes, elapsed),
+			ReadBytes:  rate(p.ReadSectors*diskstatsSectorSize, c.ReadSectors*diskstatsSectorSize, elapsed),
+			WriteBytes: rate(p.WriteSectors*diskstatsSectorSize, c.WriteSectors*diskstatsSectorSize, elapsed),
+			ReadAwait:  await(p.Reads, c.Reads, p.ReadMillis, c.ReadMillis),
+			WriteAwait: await(p.Writes, c.Writes, p.WriteMillis, c.WriteMillis),
+		}
+	}
+
+	return rates
+}
+
+func rate(prev, cur uint64, elapsed float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / elapsed
+}
+
+// await is average time in milliseconds an operation took including queueing
+func await(prevOps, curOps, prevMillis, curMillis uint64) float64 {
+	if curOps <= prevOps || curMillis < prevMillis {
+		return 0
+	}
+	return float64(curMillis-prevMillis) / float64(curOps-prevOps)
+}
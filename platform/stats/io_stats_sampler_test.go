@@ -0,0 +1,171 @@
+package stats_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/platform/stats"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+const netDevHeader = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+`
+
+var _ = Describe("IOStatsSampler", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		timeService *fakeclock.FakeClock
+		sampler     *IOStatsSampler
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		timeService = fakeclock.NewFakeClock(time.Now())
+		sampler = NewIOStatsSampler(fs, "/fake-proc", timeService)
+	})
+
+	writeSample := func(netDev, diskstats string) {
+		fs.WriteFileString("/fake-proc/net/dev", netDevHeader+netDev)
+		fs.WriteFileString("/fake-proc/diskstats", diskstats)
+	}
+
+	Describe("GetNetStats", func() {
+		It("returns no rates until second sample", func() {
+			writeSample("  eth0: 1000 10 0 0 0 0 0 0 2000 20 0 0 0 0 0 0\n", "")
+			sampler.Sample()
+
+			stats, err := sampler.GetNetStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(BeEmpty())
+		})
+
+		It("returns per second rates of every interface between last two samples", func() {
+			writeSample(
+				"    lo:  500 5 0 0 0 0 0 0 500 5 0 0 0 0 0 0\n"+
+					"  eth0:1000 10 1 0 0 0 0 0 2000 20 0 0 0 0 0 0\n",
+				"",
+			)
+			sampler.Sample()
+
+			timeService.Increment(2 * time.Second)
+
+			writeSample(
+				"    lo:  500 5 0 0 0 0 0 0 500 5 0 0 0 0 0 0\n"+
+					"  eth0:5000 30 3 0 0 0 0 0 3000 40 4 0 0 0 0 0\n",
+				"",
+			)
+			sampler.Sample()
+
+			stats, err := sampler.GetNetStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(map[string]NetStats{
+				"lo": NetStats{},
+				"eth0": NetStats{
+					RxBytes:   2000,
+					RxPackets: 10,
+					RxErrors:  1,
+					TxBytes:   500,
+					TxPackets: 10,
+					TxErrors:  2,
+				},
+			}))
+		})
+
+		It("leaves out interfaces whose counters were reset", func() {
+			writeSample("  eth0: 1000 10 0 0 0 0 0 0 2000 20 0 0 0 0 0 0\n", "")
+			sampler.Sample()
+
+			timeService.Increment(1 * time.Second)
+
+			writeSample("  eth0: 10 1 0 0 0 0 0 0 20 2 0 0 0 0 0 0\n", "")
+			sampler.Sample()
+
+			stats, err := sampler.GetNetStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(BeEmpty())
+		})
+
+		It("returns error when /proc/net/dev cannot be read", func() {
+			fs.WriteFileString("/fake-proc/diskstats", "")
+			sampler.Sample()
+
+			_, err := sampler.GetNetStats()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading /fake-proc/net/dev"))
+
+			_, err = sampler.GetDiskIOStats()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error when /proc/net/dev cannot be parsed", func() {
+			writeSample("  eth0: 1000 10\n", "")
+			sampler.Sample()
+
+			_, err := sampler.GetNetStats()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing /fake-proc/net/dev"))
+		})
+	})
+
+	Describe("GetDiskIOStats", func() {
+		It("returns per second rates and awaits of used devices between last two samples", func() {
+			writeSample("", ""+
+				"   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0\n"+
+				"   8       0 sda 100 0 1000 200 50 0 400 100 0 300 300\n"+
+				"   8      16 sdb 10 0 80 10 10 0 80 10 0 20 20 0 0 0 0\n",
+			)
+			sampler.Sample()
+
+			timeService.Increment(4 * time.Second)
+
+			writeSample("", ""+
+				"   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0\n"+
+				"   8       0 sda 140 0 2000 400 90 0 800 500 0 300 300\n"+
+				"   8      16 sdb 10 0 80 10 10 0 80 10 0 20 20 0 0 0 0\n",
+			)
+			sampler.Sample()
+
+			stats, err := sampler.GetDiskIOStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(map[string]DiskIOStats{
+				"sda": DiskIOStats{
+					ReadIOPS:   10,
+					WriteIOPS:  10,
+					ReadBytes:  1000 * 512 / 4,
+					WriteBytes: 400 * 512 / 4,
+					ReadAwait:  5,
+					WriteAwait: 10,
+				},
+				"sdb": DiskIOStats{},
+			}))
+		})
+
+		It("returns error when /proc/diskstats cannot be read", func() {
+			writeSample("", "")
+			fs.RegisterReadFileError("/fake-proc/diskstats", errors.New("fake-read-err"))
+			sampler.Sample()
+
+			_, err := sampler.GetDiskIOStats()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-read-err"))
+
+			_, err = sampler.GetNetStats()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error when /proc/diskstats cannot be parsed", func() {
+			writeSample("", "   8       0 sda 100 0 1000\n")
+			sampler.Sample()
+
+			_, err := sampler.GetDiskIOStats()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing /fake-proc/diskstats"))
+		})
+	})
+})
@@ -0,0 +1,21 @@
+package stats
+
+const (
+	CollectorSigar = "sigar"
+	CollectorProc  = "proc"
+)
+
+type Options struct {
+	// Either CollectorSigar or CollectorProc; proc collector reads /proc
+	// directly and does not need agent to be built with cgo
+	// (agent built with nosigar tag only supports proc collector).
+	// Defaults to CollectorSigar when not set
+	Collector string
+}
+
+func (o Options) CollectorType() string {
+	if o.Collector == "" {
+		return CollectorSigar
+	}
+	return o.Collector
+}
@@ -0,0 +1,321 @@
+package stats
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type procCPUCounters struct {
+	User  uint64
+	Nice  uint64
+	Sys   uint64
+	Wait  uint64
+	Total uint64
+}
+
+// procStatsCollector reads /proc directly so that agent
+// does not have to be built with cgo to report vitals
+type procStatsCollector struct {
+	fs             boshsys.FileSystem
+	procDir        string
+	timeService    clock.Clock
+	ioStatsSampler *IOStatsSampler
+
+	latestCPUStats     CPUStats
+	latestCPUStatsErr  error
+	latestCPUStatsLock sync.RWMutex
+}
+
+func NewProcStatsCollector(fs boshsys.FileSystem, procDir string, timeService clock.Clock) Collector {
+	return &procStatsCollector{
+		fs:             fs,
+		procDir:        procDir,
+		timeService:    timeService,
+		ioStatsSampler: NewIOStatsSampler(fs, procDir, timeService),
+	}
+}
+
+// StartCollecting reports CPU time since boot right away
+// and CPU time spent during every interval afterwards
+func (s *procStatsCollector) StartCollecting(collectionInterval time.Duration, latestGotUpdated chan struct{}) {
+	previous, err := s.readCPUCounters()
+	s.updateCPUStats(previous, procCPUCounters{}, err)
+	s.ioStatsSampler.Sample()
+
+	if latestGotUpdated != nil {
+		latestGotUpdated <- struct{}{}
+	}
+
+	ticker := s.timeService.NewTicker(collectionInterval)
+	defer ticker.Stop()
+
+	for range ticker.C() {
+		current, err := s.readCPUCounters()
+		s.updateCPUStats(current, previous, err)
+
+		if err == nil {
+			previous = current
+		}
+
+		s.ioStatsSampler.Sample()
+
+		if latestGotUpdated != nil {
+			latestGotUpdated <- struct{}{}
+		}
+	}
+}
+
+func (s *procStatsCollector) GetCPULoad() (load CPULoad, err error) {
+	path := filepath.Join(s.procDir, "loadavg")
+
+	contents, err := s.fs.ReadFileString(path)
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Reading %s", path)
+		return
+	}
+
+	fields := strings.Fields(contents)
+	if len(fields) < 3 {
+		err = bosherr.Errorf("Parsing %s: unexpected contents '%s'", path, contents)
+		return
+	}
+
+	averages := make([]float64, 3)
+
+	for i := range averages {
+		averages[i], err = strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			err = bosherr.WrapErrorf(err, "Parsing %s", path)
+			return
+		}
+	}
+
+	load.One = averages[0]
+	load.Five = averages[1]
+	load.Fifteen = averages[2]
+
+	return
+}
+
+func (s *procStatsCollector) GetCPUStats() (CPUStats, error) {
+	s.latestCPUStatsLock.RLock()
+	defer s.latestCPUStatsLock.RUnlock()
+
+	if s.latestCPUStatsErr != nil {
+		return CPUStats{}, bosherr.WrapError(s.latestCPUStatsErr, "Getting CPU Stats")
+	}
+
+	return s.latestCPUStats, nil
+}
+
+func (s *procStatsCollector) GetMemStats() (usage Usage, err error) {
+	meminfo, err := s.readMeminfo()
+	if err != nil {
+		return
+	}
+
+	usage.Total = meminfo["MemTotal"]
+
+	// Same as sigar: memory used by kernel buffers and page cache
+	// can be reclaimed so it is not counted as used
+	free := meminfo["MemFree"] + meminfo["Buffers"] + meminfo["Cached"]
+	if free < usage.Total {
+		usage.Used = usage.Total - free
+	}
+
+	return
+}
+
+func (s *procStatsCollector) GetSwapStats() (usage Usage, err error) {
+	path := filepath.Join(s.procDir, "swaps")
+
+	contents, err := s.fs.ReadFileString(path)
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Reading %s", path)
+		return
+	}
+
+	// First line is a header: Filename Type Size Used Priority
+	for _, line := range strings.Split(contents, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) < 4 {
+			err = bosherr.Errorf("Parsing %s: unexpected line '%s'", path, line)
+			return
+		}
+
+		values, parseErr := parseCounters(fields, 2, 3)
+		if parseErr != nil {
+			err = bosherr.WrapErrorf(parseErr, "Parsing %s", path)
+			return
+		}
+
+		usage.Total += values[0] * 1024
+		usage.Used += values[1] * 1024
+	}
+
+	return
+}
+
+// GetDiskStats reports disk usage in kilobytes like sigar does
+func (s *procStatsCollector) GetDiskStats(mountedPath string) (stats DiskStats, err error) {
+	var statfs syscall.Statfs_t
+
+	err = syscall.Statfs(mountedPath, &statfs)
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Getting file system stats of %s", mountedPath)
+		return
+	}
+
+	blockSize := uint64(statfs.Bsize)
+
+	stats.DiskUsage.Total = uint64(statfs.Blocks) * blockSize / 1024
+	stats.DiskUsage.Used = (uint64(statfs.Blocks) - uint64(statfs.Bfree)) * blockSize / 1024
+	stats.InodeUsage.Total = uint64(statfs.Files)
+	stats.InodeUsage.Used = uint64(statfs.Files) - uint64(statfs.Ffree)
+
+	return
+}
+
+func (s *procStatsCollector) GetNetStats() (map[string]NetStats, error) {
+	stats, err := s.ioStatsSampler.GetNetStats()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting Network Stats")
+	}
+
+	return stats, nil
+}
+
+func (s *procStatsCollector) GetDiskIOStats() (map[string]DiskIOStats, error) {
+	stats, err := s.ioStatsSampler.GetDiskIOStats()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting Disk I/O Stats")
+	}
+
+	return stats, nil
+}
+
+// updateCPUStats keeps last successfully collected stats around
+// so that a single failed read does not fail vitals
+func (s *procStatsCollector) updateCPUStats(current, previous procCPUCounters, err error) {
+	s.latestCPUStatsLock.Lock()
+	defer s.latestCPUStatsLock.Unlock()
+
+	if err != nil {
+		if s.latestCPUStats.Total == 0 {
+			s.latestCPUStatsErr = err
+		}
+		return
+	}
+
+	s.latestCPUStatsErr = nil
+	s.latestCPUStats = CPUStats{
+		User:  delta(previous.User, current.User),
+		Nice:  delta(previous.Nice, current.Nice),
+		Sys:   delta(previous.Sys, current.Sys),
+		Wait:  delta(previous.Wait, current.Wait),
+		Total: delta(previous.Total, current.Total),
+	}
+}
+
+func (s *procStatsCollector) readCPUCounters() (procCPUCounters, error) {
+	path := filepath.Join(s.procDir, "stat")
+
+	contents, err := s.fs.ReadFileString(path)
+	if err != nil {
+		return procCPUCounters{}, bosherr.WrapErrorf(err, "Reading %s", path)
+	}
+
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "cpu" {
+			continue
+		}
+
+		// user nice system idle iowait irq softirq steal
+		if len(fields) < 9 {
+			return procCPUCounters{}, bosherr.Errorf("Parsing %s: unexpected line '%s'", path, line)
+		}
+
+		values, err := parseCounters(fields, 1, 2, 3, 4, 5, 6, 7, 8)
+		if err != nil {
+			return procCPUCounters{}, bosherr.WrapErrorf(err, "Parsing %s", path)
+		}
+
+		counters := procCPUCounters{
+			User: values[0],
+			Nice: values[1],
+			Sys:  values[2],
+			Wait: values[4],
+		}
+
+		for _, value := range values {
+			counters.Total += value
+		}
+
+		return counters, nil
+	}
+
+	return procCPUCounters{}, bosherr.Errorf("Parsing %s: missing cpu line", path)
+}
+
+// readMeminfo returns values in bytes keyed by field name
+func (s *procStatsCollector) readMeminfo() (map[string]uint64, error) {
+	path := filepath.Join(s.procDir, "meminfo")
+
+	contents, err := s.fs.ReadFileString(path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading %s", path)
+	}
+
+	meminfo := map[string]uint64{}
+
+	for _, line := range strings.Split(contents, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		fields := strings.Fields(parts[1])
+		if len(fields) == 0 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing %s", path)
+		}
+
+		// Most values are in kB; a few (e.g. HugePages_Total) are counts
+		if len(fields) > 1 && fields[1] == "kB" {
+			value *= 1024
+		}
+
+		meminfo[parts[0]] = value
+	}
+
+	if _, found := meminfo["MemTotal"]; !found {
+		return nil, bosherr.Errorf("Parsing %s: missing MemTotal", path)
+	}
+
+	return meminfo, nil
+}
+
+func delta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
@@ -0,0 +1,222 @@
+package stats_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/platform/stats"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const procFixturePath = "test_assets/proc"
+
+var _ = Describe("procStatsCollector", func() {
+	var (
+		fs          boshsys.FileSystem
+		timeService *fakeclock.FakeClock
+		collector   Collector
+	)
+
+	BeforeEach(func() {
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		timeService = fakeclock.NewFakeClock(time.Now())
+		collector = NewProcStatsCollector(fs, procFixturePath, timeService)
+	})
+
+	Describe("GetCPULoad", func() {
+		It("returns load averages from loadavg", func() {
+			load, err := collector.GetCPULoad()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(load).To(Equal(CPULoad{One: 0.52, Five: 1.04, Fifteen: 2.25}))
+		})
+
+		It("returns error when loadavg cannot be read", func() {
+			collector = NewProcStatsCollector(fs, "/fake-missing-proc", timeService)
+
+			_, err := collector.GetCPULoad()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading /fake-missing-proc/loadavg"))
+		})
+	})
+
+	Describe("GetMemStats", func() {
+		It("returns memory usage without buffers and page cache", func() {
+			usage, err := collector.GetMemStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(usage).To(Equal(Usage{
+				Used:  (4046240 - 512000 - 128000 - 1024000) * 1024,
+				Total: 4046240 * 1024,
+			}))
+		})
+
+		It("returns error when meminfo cannot be read", func() {
+			collector = NewProcStatsCollector(fs, "/fake-missing-proc", timeService)
+
+			_, err := collector.GetMemStats()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading /fake-missing-proc/meminfo"))
+		})
+	})
+
+	Describe("GetSwapStats", func() {
+		It("returns usage summed across all swap devices and files", func() {
+			usage, err := collector.GetSwapStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(usage).To(Equal(Usage{
+				Used:  (262144 + 786432) * 1024,
+				Total: (1048572 + 1048576) * 1024,
+			}))
+		})
+
+		It("returns error when swaps cannot be read", func() {
+			collector = NewProcStatsCollector(fs, "/fake-missing-proc", timeService)
+
+			_, err := collector.GetSwapStats()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading /fake-missing-proc/swaps"))
+		})
+	})
+
+	Describe("GetDiskStats", func() {
+		It("returns usage of file system path is mounted on", func() {
+			stats, err := collector.GetDiskStats(procFixturePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats.DiskUsage.Total).To(BeNumerically(">", 0))
+			Expect(stats.DiskUsage.Used).To(BeNumerically("<=", stats.DiskUsage.Total))
+			Expect(stats.InodeUsage.Used).To(BeNumerically("<=", stats.InodeUsage.Total))
+		})
+
+		It("returns error when path does not exist", func() {
+			_, err := collector.GetDiskStats("/fake-missing-path")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Getting file system stats of /fake-missing-path"))
+		})
+	})
+
+	Describe("StartCollecting", func() {
+		var (
+			procDir          string
+			latestGotUpdated chan struct{}
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			procDir, err = ioutil.TempDir("", "proc-stats-collector")
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, name := range []string{"stat", "net/dev", "diskstats"} {
+				contents, err := fs.ReadFileString(filepath.Join(procFixturePath, name))
+				Expect(err).ToNot(HaveOccurred())
+
+				err = fs.WriteFileString(filepath.Join(procDir, name), contents)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			collector = NewProcStatsCollector(fs, procDir, timeService)
+			latestGotUpdated = make(chan struct{})
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(procDir)
+		})
+
+		It("reports cpu time since boot right away and cpu time spent during every interval afterwards", func() {
+			go collector.StartCollecting(10*time.Second, latestGotUpdated)
+			<-latestGotUpdated
+
+			stats, err := collector.GetCPUStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(CPUStats{
+				User:  1000,
+				Nice:  100,
+				Sys:   500,
+				Wait:  200,
+				Total: 1000 + 100 + 500 + 8000 + 200 + 10 + 20 + 30,
+			}))
+
+			err = fs.WriteFileString(filepath.Join(procDir, "stat"), "cpu  1060 100 520 8100 220 10 20 30 0 0\n")
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(timeService.WatcherCount).Should(Equal(1))
+			timeService.Increment(10 * time.Second)
+			<-latestGotUpdated
+
+			stats, err = collector.GetCPUStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(CPUStats{
+				User:  60,
+				Nice:  0,
+				Sys:   20,
+				Wait:  20,
+				Total: 60 + 20 + 100 + 20,
+			}))
+		})
+
+		It("samples network and disk I/O on every interval", func() {
+			go collector.StartCollecting(10*time.Second, latestGotUpdated)
+			<-latestGotUpdated
+
+			err := fs.WriteFileString(filepath.Join(procDir, "net/dev"), ""+
+				"Inter-|   Receive                                                |  Transmit\n"+
+				" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n"+
+				"  eth0: 5010000    4100    2    0    0     0          0         0  1005000    3050    0    0    0     0       0          0\n",
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(timeService.WatcherCount).Should(Equal(1))
+			timeService.Increment(10 * time.Second)
+			<-latestGotUpdated
+
+			netStats, err := collector.GetNetStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(netStats).To(Equal(map[string]NetStats{
+				"eth0": NetStats{RxBytes: 1000, RxPackets: 10, TxBytes: 500, TxPackets: 5},
+			}))
+
+			diskIOStats, err := collector.GetDiskIOStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diskIOStats).To(HaveKey("sda"))
+			Expect(diskIOStats).ToNot(HaveKey("loop0"))
+		})
+
+		It("keeps last cpu stats when stat cannot be read", func() {
+			go collector.StartCollecting(10*time.Second, latestGotUpdated)
+			<-latestGotUpdated
+
+			expectedStats, err := collector.GetCPUStats()
+			Expect(err).ToNot(HaveOccurred())
+
+			err = fs.RemoveAll(filepath.Join(procDir, "stat"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(timeService.WatcherCount).Should(Equal(1))
+			timeService.Increment(10 * time.Second)
+			<-latestGotUpdated
+
+			stats, err := collector.GetCPUStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(expectedStats))
+		})
+
+		It("returns error when stat could never be read", func() {
+			err := fs.RemoveAll(filepath.Join(procDir, "stat"))
+			Expect(err).ToNot(HaveOccurred())
+
+			go collector.StartCollecting(10*time.Second, latestGotUpdated)
+			<-latestGotUpdated
+
+			_, err = collector.GetCPUStats()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading " + filepath.Join(procDir, "stat")))
+		})
+	})
+})
@@ -26,6 +26,27 @@ type DiskStats struct {
 	InodeUsage Usage
 }
 
+// NetStats are per second rates of a network interface
+type NetStats struct {
+	RxBytes   float64
+	RxPackets float64
+	RxErrors  float64
+	TxBytes   float64
+	TxPackets float64
+	TxErrors  float64
+}
+
+// DiskIOStats are per second rates of a block device;
+// awaits are average milliseconds an operation took
+type DiskIOStats struct {
+	ReadIOPS   float64
+	WriteIOPS  float64
+	ReadBytes  float64
+	WriteBytes float64
+	ReadAwait  float64
+	WriteAwait float64
+}
+
 type Collector interface {
 	StartCollecting(time.Duration, chan struct{})
 
@@ -35,6 +56,10 @@ type Collector interface {
 	GetMemStats() (usage Usage, err error)
 	GetSwapStats() (usage Usage, err error)
 	GetDiskStats(mountedPath string) (stats DiskStats, err error)
+
+	// Rates are computed between samples taken by StartCollecting
+	GetNetStats() (stats map[string]NetStats, err error)
+	GetDiskIOStats() (stats map[string]DiskIOStats, err error)
 }
 
 func (cpuStats CPUStats) UserPercent() Percentage {
@@ -0,0 +1,43 @@
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
+)
+
+type FakeHistory struct {
+	addVitals []boshvitals.Vitals
+	AddErr    error
+
+	GetFrom       time.Time
+	GetTo         time.Time
+	GetResolution time.Duration
+	GetPoints     []boshvitals.HistoryPoint
+
+	// Vitals are added from agent's own goroutine
+	lock sync.Mutex
+}
+
+func (h *FakeHistory) Add(vitals boshvitals.Vitals) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.addVitals = append(h.addVitals, vitals)
+	return h.AddErr
+}
+
+func (h *FakeHistory) AddedVitals() []boshvitals.Vitals {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return append([]boshvitals.Vitals{}, h.addVitals...)
+}
+
+func (h *FakeHistory) Get(from, to time.Time, resolution time.Duration) []boshvitals.HistoryPoint {
+	h.GetFrom = from
+	h.GetTo = to
+	h.GetResolution = resolution
+	return h.GetPoints
+}
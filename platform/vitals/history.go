@@ -0,0 +1,358 @@
+package vitals
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	DefaultHistoryIntervalSeconds = 10
+
+	// One hour of samples at default interval
+	DefaultHistoryMaxSamples = 360
+
+	DefaultHistoryPersistIntervalSeconds = 60
+
+	historyLogTag = "Vitals History"
+)
+
+type HistoryOptions struct {
+	// Number of seconds between samples kept in history;
+	// vitals alert thresholds are checked against the same samples.
+	// Defaults to DefaultHistoryIntervalSeconds when not set
+	IntervalSeconds int
+
+	// Number of most recent samples kept; oldest samples are dropped first.
+	// Defaults to DefaultHistoryMaxSamples when not set
+	MaxSamples int
+
+	// Samples are also written to a file so that history survives agent restarts
+	Persist bool
+
+	// Number of seconds between writes of persisted samples; samples added
+	// since last write are lost when agent stops before next write.
+	// Defaults to DefaultHistoryPersistIntervalSeconds when not set
+	PersistIntervalSeconds int
+}
+
+func (o HistoryOptions) Interval() time.Duration {
+	if o.IntervalSeconds <= 0 {
+		return DefaultHistoryIntervalSeconds * time.Second
+	}
+	return time.Duration(o.IntervalSeconds) * time.Second
+}
+
+func (o HistoryOptions) persistInterval() time.Duration {
+	if o.PersistIntervalSeconds <= 0 {
+		return DefaultHistoryPersistIntervalSeconds * time.Second
+	}
+	return time.Duration(o.PersistIntervalSeconds) * time.Second
+}
+
+func (o HistoryOptions) maxSamples() int {
+	if o.MaxSamples <= 0 {
+		return DefaultHistoryMaxSamples
+	}
+	return o.MaxSamples
+}
+
+// HistorySample is kept for every time vitals are added to history
+type HistorySample struct {
+	Time   int64              `json:"time"`
+	Values map[string]float64 `json:"values"`
+}
+
+// HistoryPoint summarizes samples taken during one resolution period
+// starting at Time; vitals are keyed the same way as Vitals.Values
+type HistoryPoint struct {
+	Time    int64                   `json:"time"`
+	Samples int                     `json:"samples"`
+	Vitals  map[string]HistoryValue `json:"vitals"`
+}
+
+type HistoryValue struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
+type History interface {
+	Add(vitals Vitals) error
+
+	// Get returns points for samples taken between from and to (inclusive);
+	// zero from or to means oldest or newest sample and
+	// zero resolution means interval between samples.
+	// Periods without samples are left out.
+	Get(from, to time.Time, resolution time.Duration) []HistoryPoint
+}
+
+// ringHistory keeps samples in fixed size ring buffer
+type ringHistory struct {
+	interval        time.Duration
+	fs              boshsys.FileSystem
+	path            string
+	persistInterval time.Duration
+	timeService     clock.Clock
+	logger          boshlog.Logger
+
+	samples []HistorySample
+	next    int
+	full    bool
+	savedAt time.Time
+	lock    sync.RWMutex
+}
+
+// NewHistory loads previously persisted samples from path when options ask to persist them
+func NewHistory(
+	options HistoryOptions,
+	fs boshsys.FileSystem,
+	path string,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) History {
+	h := &ringHistory{
+		interval:    options.Interval(),
+		timeService: timeService,
+		logger:      logger,
+		samples:     make([]HistorySample, options.maxSamples()),
+	}
+
+	if options.Persist {
+		h.fs = fs
+		h.path = path
+		h.persistInterval = options.persistInterval()
+		h.load()
+	}
+
+	return h
+}
+
+func (h *ringHistory) Add(vitals Vitals) error {
+	now := h.timeService.Now()
+
+	sample := HistorySample{
+		Time:   now.Unix(),
+		Values: vitals.Values(),
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.add(sample)
+
+	if h.fs == nil {
+		return nil
+	}
+
+	if !h.savedAt.IsZero() && now.Sub(h.savedAt) < h.persistInterval {
+		return nil
+	}
+
+	err := h.save()
+	if err != nil {
+		return err
+	}
+
+	h.savedAt = now
+
+	return nil
+}
+
+func (h *ringHistory) Get(from, to time.Time, resolution time.Duration) []HistoryPoint {
+	h.lock.RLock()
+	samples := h.ordered()
+	h.lock.RUnlock()
+
+	points := []HistoryPoint{}
+
+	if len(samples) == 0 {
+		return points
+	}
+
+	fromUnix := samples[0].Time
+	if !from.IsZero() {
+		fromUnix = from.Unix()
+	}
+
+	toUnix := samples[len(samples)-1].Time
+	if !to.IsZero() {
+		toUnix = to.Unix()
+	}
+
+	if resolution <= 0 {
+		resolution = h.interval
+	}
+
+	period := int64(resolution / time.Second)
+	if period < 1 {
+		period = 1
+	}
+
+	var point *historyPointBuilder
+
+	for _, sample := range samples {
+		if sample.Time < fromUnix || sample.Time > toUnix {
+			continue
+		}
+
+		// Periods are aligned to from so that repeated requests return stable points
+		periodStart := fromUnix + (sample.Time-fromUnix)/period*period
+
+		if point == nil || point.time != periodStart {
+			if point != nil {
+				points = append(points, point.build())
+			}
+			point = newHistoryPointBuilder(periodStart)
+		}
+
+		point.add(sample)
+	}
+
+	if point != nil {
+		points = append(points, point.build())
+	}
+
+	return points
+}
+
+// add must be called with lock held
+func (h *ringHistory) add(sample HistorySample) {
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// ordered returns copy of samples from oldest to newest; must be called with lock held
+func (h *ringHistory) ordered() []HistorySample {
+	if !h.full {
+		return append([]HistorySample{}, h.samples[:h.next]...)
+	}
+
+	return append(append([]HistorySample{}, h.samples[h.next:]...), h.samples[:h.next]...)
+}
+
+// save replaces history file at once so that it is not left half written;
+// must be called with lock held
+func (h *ringHistory) save() error {
+	bytes, err := json.Marshal(h.ordered())
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling vitals history")
+	}
+
+	tmpPath := h.path + ".tmp"
+
+	err = h.fs.WriteFile(tmpPath, bytes)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing vitals history to %s", tmpPath)
+	}
+
+	err = h.fs.Rename(tmpPath, h.path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Replacing vitals history %s", h.path)
+	}
+
+	return nil
+}
+
+// load keeps history empty when it cannot be read since it is only best effort
+func (h *ringHistory) load() {
+	if !h.fs.FileExists(h.path) {
+		return
+	}
+
+	bytes, err := h.fs.ReadFile(h.path)
+	if err != nil {
+		h.logger.Error(historyLogTag, "Reading vitals history from %s: %s", h.path, err.Error())
+		return
+	}
+
+	var samples []HistorySample
+
+	err = json.Unmarshal(bytes, &samples)
+	if err != nil {
+		h.logger.Error(historyLogTag, "Unmarshalling vitals history from %s: %s", h.path, err.Error())
+		return
+	}
+
+	sort.Sort(historySamplesByTime(samples))
+
+	// Only most recent samples fit when max samples was lowered
+	if len(samples) > len(h.samples) {
+		samples = samples[len(samples)-len(h.samples):]
+	}
+
+	for _, sample := range samples {
+		h.add(sample)
+	}
+}
+
+type historyPointBuilder struct {
+	time    int64
+	samples int
+	values  map[string]*historyValueBuilder
+}
+
+type historyValueBuilder struct {
+	min   float64
+	max   float64
+	sum   float64
+	count int
+}
+
+func newHistoryPointBuilder(time int64) *historyPointBuilder {
+	return &historyPointBuilder{time: time, values: map[string]*historyValueBuilder{}}
+}
+
+func (b *historyPointBuilder) add(sample HistorySample) {
+	b.samples++
+
+	for name, v := range sample.Values {
+		value, found := b.values[name]
+		if !found {
+			value = &historyValueBuilder{min: math.Inf(1), max: math.Inf(-1)}
+			b.values[name] = value
+		}
+
+		value.min = math.Min(value.min, v)
+		value.max = math.Max(value.max, v)
+		value.sum += v
+		value.count++
+	}
+}
+
+func (b *historyPointBuilder) build() HistoryPoint {
+	point := HistoryPoint{
+		Time:    b.time,
+		Samples: b.samples,
+		Vitals:  map[string]HistoryValue{},
+	}
+
+	// Vitals missing from some samples (e.g. disk mounted midway) are averaged over samples that have them
+	for name, value := range b.values {
+		point.Vitals[name] = HistoryValue{
+			Min: value.min,
+			Max: value.max,
+			Avg: value.sum / float64(value.count),
+		}
+	}
+
+	return point
+}
+
+type historySamplesByTime []HistorySample
+
+func (s historySamplesByTime) Len() int           { return len(s) }
+func (s historySamplesByTime) Less(i, j int) bool { return s[i].Time < s[j].Time }
+func (s historySamplesByTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
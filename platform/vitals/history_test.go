@@ -0,0 +1,262 @@
+package vitals_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("HistoryOptions", func() {
+	It("defaults interval to DefaultHistoryIntervalSeconds", func() {
+		Expect(HistoryOptions{}.Interval()).To(Equal(DefaultHistoryIntervalSeconds * time.Second))
+		Expect(HistoryOptions{IntervalSeconds: 5}.Interval()).To(Equal(5 * time.Second))
+	})
+})
+
+var _ = Describe("History", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		timeService *fakeclock.FakeClock
+		logger      boshlog.Logger
+		options     HistoryOptions
+		history     History
+		startedAt   time.Time
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		startedAt = time.Unix(1000, 0)
+		timeService = fakeclock.NewFakeClock(startedAt)
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		options = HistoryOptions{IntervalSeconds: 10, MaxSamples: 4}
+	})
+
+	JustBeforeEach(func() {
+		history = NewHistory(options, fs, "/fake-history.json", timeService, logger)
+	})
+
+	memPercent := func(percent string) Vitals {
+		return Vitals{Mem: MemoryVitals{Percent: percent}}
+	}
+
+	addEvery10Seconds := func(percents ...string) {
+		for _, percent := range percents {
+			err := history.Add(memPercent(percent))
+			Expect(err).ToNot(HaveOccurred())
+			timeService.Increment(10 * time.Second)
+		}
+	}
+
+	point := func(time int64, samples int, min, max, avg float64) HistoryPoint {
+		return HistoryPoint{
+			Time:    time,
+			Samples: samples,
+			Vitals: map[string]HistoryValue{
+				"mem.percent": HistoryValue{Min: min, Max: max, Avg: avg},
+			},
+		}
+	}
+
+	It("returns empty list when nothing was added", func() {
+		Expect(history.Get(time.Time{}, time.Time{}, 0)).To(Equal([]HistoryPoint{}))
+	})
+
+	It("returns every sample at sample interval when resolution is not given", func() {
+		addEvery10Seconds("10", "20", "30")
+
+		Expect(history.Get(time.Time{}, time.Time{}, 0)).To(Equal([]HistoryPoint{
+			point(1000, 1, 10, 10, 10),
+			point(1010, 1, 20, 20, 20),
+			point(1020, 1, 30, 30, 30),
+		}))
+	})
+
+	It("keeps only most recent samples", func() {
+		addEvery10Seconds("10", "20", "30", "40", "50", "60")
+
+		Expect(history.Get(time.Time{}, time.Time{}, 0)).To(Equal([]HistoryPoint{
+			point(1020, 1, 30, 30, 30),
+			point(1030, 1, 40, 40, 40),
+			point(1040, 1, 50, 50, 50),
+			point(1050, 1, 60, 60, 60),
+		}))
+	})
+
+	It("returns only samples within requested window", func() {
+		addEvery10Seconds("10", "20", "30", "40")
+
+		Expect(history.Get(time.Unix(1010, 0), time.Unix(1020, 0), 0)).To(Equal([]HistoryPoint{
+			point(1010, 1, 20, 20, 20),
+			point(1020, 1, 30, 30, 30),
+		}))
+	})
+
+	It("downsamples into min, max and avg of each period aligned to from", func() {
+		addEvery10Seconds("10", "40", "20", "60")
+
+		Expect(history.Get(time.Unix(1000, 0), time.Time{}, 20*time.Second)).To(Equal([]HistoryPoint{
+			point(1000, 2, 10, 40, 25),
+			point(1020, 2, 20, 60, 40),
+		}))
+
+		Expect(history.Get(time.Unix(990, 0), time.Time{}, 20*time.Second)).To(Equal([]HistoryPoint{
+			point(990, 1, 10, 10, 10),
+			point(1010, 2, 20, 40, 30),
+			point(1030, 1, 60, 60, 60),
+		}))
+	})
+
+	It("averages vitals over samples that have them", func() {
+		err := history.Add(Vitals{
+			Mem:  MemoryVitals{Percent: "10"},
+			Disk: DiskVitals{"persistent": SpecificDiskVitals{Percent: "50"}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		timeService.Increment(10 * time.Second)
+		addEvery10Seconds("30")
+
+		points := history.Get(time.Time{}, time.Time{}, time.Minute)
+		Expect(points).To(HaveLen(1))
+		Expect(points[0].Samples).To(Equal(2))
+		Expect(points[0].Vitals["mem.percent"]).To(Equal(HistoryValue{Min: 10, Max: 30, Avg: 20}))
+		Expect(points[0].Vitals["disk.persistent.percent"]).To(Equal(HistoryValue{Min: 50, Max: 50, Avg: 50}))
+	})
+
+	It("does not write samples to file unless asked to", func() {
+		addEvery10Seconds("10")
+		Expect(fs.FileExists("/fake-history.json")).To(BeFalse())
+	})
+
+	Context("when history is persisted", func() {
+		BeforeEach(func() {
+			options.Persist = true
+			options.PersistIntervalSeconds = 20
+		})
+
+		persistedSamples := func() []HistorySample {
+			bytes, err := fs.ReadFile("/fake-history.json")
+			Expect(err).ToNot(HaveOccurred())
+
+			var samples []HistorySample
+			err = json.Unmarshal(bytes, &samples)
+			Expect(err).ToNot(HaveOccurred())
+
+			return samples
+		}
+
+		It("writes samples to file and loads them again", func() {
+			addEvery10Seconds("10", "20", "30")
+
+			Expect(fs.FileExists("/fake-history.json")).To(BeTrue())
+
+			history = NewHistory(options, fs, "/fake-history.json", timeService, logger)
+			addEvery10Seconds("40")
+
+			Expect(history.Get(time.Time{}, time.Time{}, 0)).To(Equal([]HistoryPoint{
+				point(1000, 1, 10, 10, 10),
+				point(1010, 1, 20, 20, 20),
+				point(1020, 1, 30, 30, 30),
+				point(1030, 1, 40, 40, 40),
+			}))
+		})
+
+		It("writes samples at most once per persist interval", func() {
+			addEvery10Seconds("10")
+			Expect(persistedSamples()).To(HaveLen(1))
+
+			addEvery10Seconds("20")
+			Expect(persistedSamples()).To(HaveLen(1))
+
+			addEvery10Seconds("30")
+			Expect(persistedSamples()).To(HaveLen(3))
+		})
+
+		It("defaults persist interval to DefaultHistoryPersistIntervalSeconds", func() {
+			options.PersistIntervalSeconds = 0
+			history = NewHistory(options, fs, "/fake-history.json", timeService, logger)
+
+			addEvery10Seconds("10")
+			timeService.Increment((DefaultHistoryPersistIntervalSeconds - 20) * time.Second)
+			addEvery10Seconds("20")
+			Expect(persistedSamples()).To(HaveLen(1))
+
+			addEvery10Seconds("30")
+			Expect(persistedSamples()).To(HaveLen(3))
+		})
+
+		It("replaces file at once through temporary file", func() {
+			addEvery10Seconds("10")
+
+			Expect(fs.RenameOldPaths).To(Equal([]string{"/fake-history.json.tmp"}))
+			Expect(fs.RenameNewPaths).To(Equal([]string{"/fake-history.json"}))
+			Expect(fs.FileExists("/fake-history.json.tmp")).To(BeFalse())
+		})
+
+		It("loads only most recent samples when file has more samples than are kept", func() {
+			samples := []HistorySample{}
+			for i := int64(0); i < 6; i++ {
+				samples = append(samples, HistorySample{Time: 1000 + i*10, Values: map[string]float64{"mem.percent": float64(i)}})
+			}
+
+			bytes, err := json.Marshal(samples)
+			Expect(err).ToNot(HaveOccurred())
+			fs.WriteFile("/fake-history.json", bytes)
+
+			history = NewHistory(options, fs, "/fake-history.json", timeService, logger)
+
+			points := history.Get(time.Time{}, time.Time{}, 0)
+			Expect(points).To(HaveLen(4))
+			Expect(points[0].Time).To(Equal(int64(1020)))
+			Expect(points[3].Time).To(Equal(int64(1050)))
+		})
+
+		It("starts with empty history when file cannot be parsed", func() {
+			fs.WriteFileString("/fake-history.json", "fake-invalid-json")
+
+			history = NewHistory(options, fs, "/fake-history.json", timeService, logger)
+			Expect(history.Get(time.Time{}, time.Time{}, 0)).To(BeEmpty())
+		})
+
+		It("returns error when samples cannot be written but keeps them in memory", func() {
+			fs.WriteFileError = errors.New("fake-write-err")
+
+			err := history.Add(memPercent("10"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-write-err"))
+
+			Expect(history.Get(time.Time{}, time.Time{}, 0)).To(HaveLen(1))
+		})
+
+		It("writes samples again with next sample when they could not be written", func() {
+			fs.WriteFileError = errors.New("fake-write-err")
+
+			err := history.Add(memPercent("10"))
+			Expect(err).To(HaveOccurred())
+
+			fs.WriteFileError = nil
+			timeService.Increment(10 * time.Second)
+
+			err = history.Add(memPercent("20"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(persistedSamples()).To(HaveLen(2))
+		})
+
+		It("returns error when file cannot be replaced", func() {
+			fs.RenameError = errors.New("fake-rename-err")
+
+			err := history.Add(memPercent("10"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-rename-err"))
+		})
+	})
+})
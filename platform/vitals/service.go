@@ -78,6 +78,19 @@ func (s concreteService) Get() (vitals Vitals, err error) {
 		Swap: createMemVitals(swapStats),
 		Disk: diskStats,
 	}
+
+	// Network and disk I/O are left out when they cannot be collected
+	// (e.g. on systems without /proc) instead of failing all vitals
+	netStats, netErr := s.statsCollector.GetNetStats()
+	if netErr == nil && len(netStats) > 0 {
+		vitals.Net = createNetVitals(netStats)
+	}
+
+	diskIOStats, diskIOErr := s.statsCollector.GetDiskIOStats()
+	if diskIOErr == nil && len(diskIOStats) > 0 {
+		vitals.DiskIO = createDiskIOVitals(diskIOStats)
+	}
+
 	return
 }
 
@@ -123,3 +136,37 @@ func createMemVitals(memUsage boshstats.Usage) MemoryVitals {
 		Kb:      fmt.Sprintf("%d", memUsage.Used/1024),
 	}
 }
+
+func createNetVitals(netStats map[string]boshstats.NetStats) NetVitals {
+	netVitals := make(NetVitals, len(netStats))
+
+	for name, stats := range netStats {
+		netVitals[name] = SpecificNetVitals{
+			RxBytes:   fmt.Sprintf("%.0f", stats.RxBytes),
+			RxPackets: fmt.Sprintf("%.1f", stats.RxPackets),
+			RxErrors:  fmt.Sprintf("%.1f", stats.RxErrors),
+			TxBytes:   fmt.Sprintf("%.0f", stats.TxBytes),
+			TxPackets: fmt.Sprintf("%.1f", stats.TxPackets),
+			TxErrors:  fmt.Sprintf("%.1f", stats.TxErrors),
+		}
+	}
+
+	return netVitals
+}
+
+func createDiskIOVitals(diskIOStats map[string]boshstats.DiskIOStats) DiskIOVitals {
+	diskIOVitals := make(DiskIOVitals, len(diskIOStats))
+
+	for name, stats := range diskIOStats {
+		diskIOVitals[name] = SpecificDiskIOVitals{
+			ReadIOPS:   fmt.Sprintf("%.1f", stats.ReadIOPS),
+			WriteIOPS:  fmt.Sprintf("%.1f", stats.WriteIOPS),
+			ReadBytes:  fmt.Sprintf("%.0f", stats.ReadBytes),
+			WriteBytes: fmt.Sprintf("%.0f", stats.WriteBytes),
+			ReadAwait:  fmt.Sprintf("%.2f", stats.ReadAwait),
+			WriteAwait: fmt.Sprintf("%.2f", stats.WriteAwait),
+		}
+	}
+
+	return diskIOVitals
+}
@@ -1,6 +1,7 @@
 package vitals_test
 
 import (
+	"errors"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -49,6 +50,26 @@ func buildVitalsService() (statsCollector *fakestats.FakeCollector, service Serv
 				InodeUsage: boshstats.Usage{Used: 3, Total: 4},
 			},
 		},
+		NetStats: map[string]boshstats.NetStats{
+			"eth0": boshstats.NetStats{
+				RxBytes:   1024.4,
+				RxPackets: 10.25,
+				RxErrors:  0,
+				TxBytes:   2048.6,
+				TxPackets: 20,
+				TxErrors:  0.5,
+			},
+		},
+		DiskIOStats: map[string]boshstats.DiskIOStats{
+			"sda": boshstats.DiskIOStats{
+				ReadIOPS:   12.5,
+				WriteIOPS:  3,
+				ReadBytes:  51200,
+				WriteBytes: 1536.2,
+				ReadAwait:  1.234,
+				WriteAwait: 10,
+			},
+		},
 	}
 
 	service = NewService(statsCollector, dirProvider)
@@ -81,6 +102,26 @@ func init() {
 						"inode_percent": "75",
 					},
 				},
+				"disk_io": map[string]interface{}{
+					"sda": map[string]string{
+						"read_iops":   "12.5",
+						"write_iops":  "3.0",
+						"read_bytes":  "51200",
+						"write_bytes": "1536",
+						"read_await":  "1.23",
+						"write_await": "10.00",
+					},
+				},
+				"net": map[string]interface{}{
+					"eth0": map[string]string{
+						"rx_bytes":   "1024",
+						"rx_packets": "10.2",
+						"rx_errors":  "0.0",
+						"tx_bytes":   "2049",
+						"tx_packets": "20.0",
+						"tx_errors":  "0.5",
+					},
+				},
 				"load": []string{"0.20", "4.55", "1.12"},
 				"mem": map[string]string{
 					"kb":      "700",
@@ -113,6 +154,18 @@ func init() {
 			boshassert.LacksJSONKey(GinkgoT(), vitals.Disk, "ephemeral")
 			boshassert.LacksJSONKey(GinkgoT(), vitals.Disk, "persistent")
 		})
+		It("getting vitals without network and disk I/O when they cannot be collected", func() {
+			statsCollector, service := buildVitalsService()
+			statsCollector.NetStatsErr = errors.New("fake-net-stats-err")
+			statsCollector.DiskIOStatsErr = errors.New("fake-disk-io-stats-err")
+
+			vitals, err := service.Get()
+			Expect(err).ToNot(HaveOccurred())
+
+			boshassert.LacksJSONKey(GinkgoT(), vitals, "net")
+			boshassert.LacksJSONKey(GinkgoT(), vitals, "disk_io")
+		})
+
 		It("get getting vitals on system disk error", func() {
 
 			statsCollector, service := buildVitalsService()
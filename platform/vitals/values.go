@@ -0,0 +1,60 @@
+package vitals
+
+import (
+	"strconv"
+)
+
+// Values flattens vitals into numbers keyed by dotted names
+// (e.g. cpu.user, load.1m, disk.persistent.percent, net.eth0.rx_bytes);
+// vitals that were not collected are left out
+func (v Vitals) Values() map[string]float64 {
+	values := map[string]float64{}
+
+	add := func(name, value string) {
+		number, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			values[name] = number
+		}
+	}
+
+	add("cpu.user", v.CPU.User)
+	add("cpu.sys", v.CPU.Sys)
+	add("cpu.wait", v.CPU.Wait)
+	add("mem.percent", v.Mem.Percent)
+	add("mem.kb", v.Mem.Kb)
+	add("swap.percent", v.Swap.Percent)
+	add("swap.kb", v.Swap.Kb)
+
+	for i, period := range []string{"1m", "5m", "15m"} {
+		if i < len(v.Load) {
+			add("load."+period, v.Load[i])
+		}
+	}
+
+	for name, disk := range v.Disk {
+		add("disk."+name+".percent", disk.Percent)
+		add("disk."+name+".inode_percent", disk.InodePercent)
+	}
+
+	for name, diskIO := range v.DiskIO {
+		prefix := "disk_io." + name + "."
+		add(prefix+"read_iops", diskIO.ReadIOPS)
+		add(prefix+"write_iops", diskIO.WriteIOPS)
+		add(prefix+"read_bytes", diskIO.ReadBytes)
+		add(prefix+"write_bytes", diskIO.WriteBytes)
+		add(prefix+"read_await", diskIO.ReadAwait)
+		add(prefix+"write_await", diskIO.WriteAwait)
+	}
+
+	for name, net := range v.Net {
+		prefix := "net." + name + "."
+		add(prefix+"rx_bytes", net.RxBytes)
+		add(prefix+"rx_packets", net.RxPackets)
+		add(prefix+"rx_errors", net.RxErrors)
+		add(prefix+"tx_bytes", net.TxBytes)
+		add(prefix+"tx_packets", net.TxPackets)
+		add(prefix+"tx_errors", net.TxErrors)
+	}
+
+	return values
+}
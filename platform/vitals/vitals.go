@@ -1,11 +1,13 @@
 package vitals
 
 type Vitals struct {
-	CPU  CPUVitals    `json:"cpu"`
-	Disk DiskVitals   `json:"disk,omitempty"`
-	Load []string     `json:"load,omitempty"`
-	Mem  MemoryVitals `json:"mem"`
-	Swap MemoryVitals `json:"swap"`
+	CPU    CPUVitals    `json:"cpu"`
+	Disk   DiskVitals   `json:"disk,omitempty"`
+	DiskIO DiskIOVitals `json:"disk_io,omitempty"`
+	Load   []string     `json:"load,omitempty"`
+	Mem    MemoryVitals `json:"mem"`
+	Net    NetVitals    `json:"net,omitempty"`
+	Swap   MemoryVitals `json:"swap"`
 }
 
 type CPUVitals struct {
@@ -21,6 +23,32 @@ type SpecificDiskVitals struct {
 	Percent      string `json:"percent,omitempty"`
 }
 
+// DiskIOVitals are keyed by block device name (e.g. sda)
+type DiskIOVitals map[string]SpecificDiskIOVitals
+
+// SpecificDiskIOVitals are per second rates; awaits are in milliseconds
+type SpecificDiskIOVitals struct {
+	ReadAwait  string `json:"read_await"`
+	ReadBytes  string `json:"read_bytes"`
+	ReadIOPS   string `json:"read_iops"`
+	WriteAwait string `json:"write_await"`
+	WriteBytes string `json:"write_bytes"`
+	WriteIOPS  string `json:"write_iops"`
+}
+
+// NetVitals are keyed by network interface name (e.g. eth0)
+type NetVitals map[string]SpecificNetVitals
+
+// SpecificNetVitals are per second rates
+type SpecificNetVitals struct {
+	RxBytes   string `json:"rx_bytes"`
+	RxErrors  string `json:"rx_errors"`
+	RxPackets string `json:"rx_packets"`
+	TxBytes   string `json:"tx_bytes"`
+	TxErrors  string `json:"tx_errors"`
+	TxPackets string `json:"tx_packets"`
+}
+
 type MemoryVitals struct {
 	Kb      string `json:"kb,omitempty"`
 	Percent string `json:"percent,omitempty"`
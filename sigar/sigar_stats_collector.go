@@ -14,11 +14,15 @@ type sigarStatsCollector struct {
 	statsSigar         sigar.Sigar
 	latestCPUStats     boshstats.CPUStats
 	latestCPUStatsLock sync.RWMutex
+
+	// Sigar does not collect network and disk I/O counters
+	ioStatsSampler *boshstats.IOStatsSampler
 }
 
-func NewSigarStatsCollector(sigar sigar.Sigar) boshstats.Collector {
+func NewSigarStatsCollector(sigar sigar.Sigar, ioStatsSampler *boshstats.IOStatsSampler) boshstats.Collector {
 	return &sigarStatsCollector{
-		statsSigar: sigar,
+		statsSigar:     sigar,
+		ioStatsSampler: ioStatsSampler,
 	}
 }
 
@@ -34,6 +38,8 @@ func (s *sigarStatsCollector) StartCollecting(collectionInterval time.Duration,
 		s.latestCPUStats.Total = cpuSample.Total()
 		s.latestCPUStatsLock.Unlock()
 
+		s.ioStatsSampler.Sample()
+
 		if latestGotUpdated != nil {
 			latestGotUpdated <- struct{}{}
 		}
@@ -104,3 +110,21 @@ func (s *sigarStatsCollector) GetDiskStats(mountedPath string) (stats boshstats.
 
 	return
 }
+
+func (s *sigarStatsCollector) GetNetStats() (map[string]boshstats.NetStats, error) {
+	stats, err := s.ioStatsSampler.GetNetStats()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting Network Stats")
+	}
+
+	return stats, nil
+}
+
+func (s *sigarStatsCollector) GetDiskIOStats() (map[string]boshstats.DiskIOStats, error) {
+	stats, err := s.ioStatsSampler.GetDiskIOStats()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting Disk I/O Stats")
+	}
+
+	return stats, nil
+}
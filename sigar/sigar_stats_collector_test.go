@@ -6,21 +6,29 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/pivotal-golang/clock/fakeclock"
+
 	. "github.com/cloudfoundry/bosh-agent/platform/stats"
 	boshsigar "github.com/cloudfoundry/bosh-agent/sigar"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	sigar "github.com/cloudfoundry/gosigar"
 	fakesigar "github.com/cloudfoundry/gosigar/fakes"
 )
 
 var _ = Describe("sigarStatsCollector", func() {
 	var (
-		collector Collector
-		fakeSigar *fakesigar.FakeSigar
+		collector   Collector
+		fakeSigar   *fakesigar.FakeSigar
+		fs          *fakesys.FakeFileSystem
+		timeService *fakeclock.FakeClock
 	)
 
 	BeforeEach(func() {
 		fakeSigar = fakesigar.NewFakeSigar()
-		collector = boshsigar.NewSigarStatsCollector(fakeSigar)
+		fs = fakesys.NewFakeFileSystem()
+		timeService = fakeclock.NewFakeClock(time.Now())
+		ioStatsSampler := NewIOStatsSampler(fs, "/fake-proc", timeService)
+		collector = boshsigar.NewSigarStatsCollector(fakeSigar, ioStatsSampler)
 	})
 
 	Describe("GetCPULoad", func() {
@@ -83,6 +91,61 @@ var _ = Describe("sigarStatsCollector", func() {
 
 			fakeSigar.CollectCpuStatsStopCh <- struct{}{}
 		})
+
+		It("samples network and disk I/O with every cpu sample", func() {
+			fs.WriteFileString("/fake-proc/net/dev", "  eth0: 1000 10 0 0 0 0 0 0 2000 20 0 0 0 0 0 0\n")
+			fs.WriteFileString("/fake-proc/diskstats", "   8       0 sda 100 0 1000 200 50 0 400 100 0 300 300\n")
+
+			fakeSigar.CollectCpuStatsCpuCh <- sigar.Cpu{}
+
+			latestGotUpdated := make(chan struct{})
+
+			go collector.StartCollecting(1*time.Millisecond, latestGotUpdated)
+			<-latestGotUpdated
+
+			timeService.Increment(1 * time.Second)
+
+			fs.WriteFileString("/fake-proc/net/dev", "  eth0: 3000 20 0 0 0 0 0 0 2500 25 0 0 0 0 0 0\n")
+			fs.WriteFileString("/fake-proc/diskstats", "   8       0 sda 110 0 1100 220 50 0 400 100 0 300 300\n")
+
+			fakeSigar.CollectCpuStatsCpuCh <- sigar.Cpu{}
+			<-latestGotUpdated
+
+			netStats, err := collector.GetNetStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(netStats).To(Equal(map[string]NetStats{
+				"eth0": NetStats{RxBytes: 2000, RxPackets: 10, TxBytes: 500, TxPackets: 5},
+			}))
+
+			diskIOStats, err := collector.GetDiskIOStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diskIOStats).To(Equal(map[string]DiskIOStats{
+				"sda": DiskIOStats{ReadIOPS: 10, ReadBytes: 100 * 512, ReadAwait: 2},
+			}))
+
+			fakeSigar.CollectCpuStatsStopCh <- struct{}{}
+		})
+	})
+
+	Describe("GetNetStats", func() {
+		It("returns error when network stats could not be sampled", func() {
+			fakeSigar.CollectCpuStatsCpuCh <- sigar.Cpu{}
+
+			latestGotUpdated := make(chan struct{})
+
+			go collector.StartCollecting(1*time.Millisecond, latestGotUpdated)
+			<-latestGotUpdated
+
+			_, err := collector.GetNetStats()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Getting Network Stats"))
+
+			_, err = collector.GetDiskIOStats()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Getting Disk I/O Stats"))
+
+			fakeSigar.CollectCpuStatsStopCh <- struct{}{}
+		})
 	})
 
 	Describe("GetMemStats", func() {